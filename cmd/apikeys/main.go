@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"ws-dummy-go/internal/app"
+	"ws-dummy-go/internal/auth"
+	"ws-dummy-go/internal/dummy"
+)
+
+const usage = `usage: apikeys [-config file] [-set KEY=VALUE] <command> [flags]
+
+commands:
+  create -owner NAME -scopes a,b [-ttl 720h]   create a key, the plaintext is printed once
+  list                                         list keys
+  revoke -prefix PREFIX                        revoke a key
+  rotate -prefix PREFIX [-ttl 720h] [-grace 24h]
+                                               create a replacement key, the old one expires after
+                                               the grace period, 0 revokes it at once
+`
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	src := app.RegisterConfigFlags(flag.CommandLine)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 2
+	}
+
+	cfg, report, err := app.LoadConfig(*src)
+	app.LogConfigReport(logger, report)
+	if err != nil {
+		level.Error(logger).Log("msg", "loading config", "err", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	pgPool, err := app.NewPostgresPool(ctx, cfg.Postgres, "apikeys-dummy-go", nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "connecting to postgres", "err", err)
+		return 1
+	}
+	defer pgPool.Close()
+
+	redisClient, err := app.NewRedisClient(ctx, cfg.Redis, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "connecting to redis", "err", err)
+		return 1
+	}
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			level.Error(logger).Log("msg", "closing redis client", "err", err)
+		}
+	}()
+
+	mgr := auth.NewAPIKeyManager(
+		auth.NewAPIKeysSQLRepo(pgPool),
+		auth.NewAPIKeysRedisCache(dummy.StaticRedisClient(redisClient), dummy.NewKeys(cfg.Redis.KeyApp, cfg.Redis.KeyEnv).Prefix(), cfg.Auth.APIKeysCacheTTL),
+	)
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+
+	switch cmd {
+	case "create":
+		owner := fs.String("owner", "", "key owner, e.g. a service name")
+		scopes := fs.String("scopes", "", "comma-separated scopes")
+		ttl := fs.Duration("ttl", 0, "key lifetime, 0 = never expires")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if *owner == "" {
+			fmt.Fprintln(os.Stderr, "-owner is required")
+			return 2
+		}
+		k, plain, err := mgr.Create(ctx, *owner, splitScopes(*scopes), *ttl)
+		if err != nil {
+			level.Error(logger).Log("msg", "creating api key", "err", err)
+			return 1
+		}
+		printCreated(k, plain)
+
+	case "list":
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		keys, err := mgr.List(ctx)
+		if err != nil {
+			level.Error(logger).Log("msg", "listing api keys", "err", err)
+			return 1
+		}
+		printKeys(keys)
+
+	case "revoke":
+		prefix := fs.String("prefix", "", "key prefix")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if err := mgr.Revoke(ctx, *prefix); err != nil {
+			level.Error(logger).Log("msg", "revoking api key", "prefix", *prefix, "err", err)
+			return 1
+		}
+		fmt.Printf("revoked %s\n", *prefix)
+
+	case "rotate":
+		prefix := fs.String("prefix", "", "key prefix")
+		ttl := fs.Duration("ttl", 0, "new key lifetime, 0 = never expires")
+		grace := fs.Duration("grace", 24*time.Hour, "old key lifetime, 0 = revoked at once")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		k, plain, err := mgr.Rotate(ctx, *prefix, *ttl, *grace)
+		if err != nil {
+			level.Error(logger).Log("msg", "rotating api key", "prefix", *prefix, "err", err)
+			return 1
+		}
+		if *grace > 0 {
+			fmt.Printf("%s expires in %s\n", *prefix, *grace)
+		} else {
+			fmt.Printf("revoked %s\n", *prefix)
+		}
+		printCreated(k, plain)
+
+	default:
+		flag.Usage()
+		return 2
+	}
+	return 0
+}
+
+func splitScopes(s string) []string {
+	scopes := []string{}
+	for _, sc := range strings.Split(s, ",") {
+		if sc = strings.TrimSpace(sc); sc != "" {
+			scopes = append(scopes, sc)
+		}
+	}
+	return scopes
+}
+
+func printCreated(k auth.APIKey, plain string) {
+	fmt.Printf("prefix:  %s\nowner:   %s\nscopes:  %s\nexpires: %s\n\n%s\n\n",
+		k.Prefix, k.Owner, strings.Join(k.Scopes, ","), formatTime(k.ExpiresAt), plain)
+	fmt.Println("Store the key now, it cannot be shown again.")
+}
+
+func printKeys(keys []auth.APIKey) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tOWNER\tSCOPES\tCREATED\tEXPIRES\tLAST USED\tREVOKED")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.Prefix, k.Owner, strings.Join(k.Scopes, ","), k.CreatedAt.Format(time.RFC3339),
+			formatTime(k.ExpiresAt), formatTime(k.LastUsedAt), formatTime(k.RevokedAt))
+	}
+	w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"ws-dummy-go/internal/app"
+	"ws-dummy-go/internal/dummy"
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/dummy/middleware"
+)
+
+const usage = `usage: gdpr [-config file] [-set KEY=VALUE] <command> [flags]
+
+commands:
+  export -user-id ID [-out FILE]   write the user's data in every store as a JSON archive, to stdout by default
+  erase -user-id ID                erase the user from every store, keeping a tombstone
+  resume [-batch N]                complete the erasures left pending by a failure
+
+erase can be run again on a user whose erasure failed, resume does it for all of them.
+`
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	src := app.RegisterConfigFlags(flag.CommandLine)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 2
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	userID := fs.String("user-id", "", "user ID")
+	out := fs.String("out", "", "archive file, - or empty for stdout")
+	batch := fs.Int("batch", 100, "pending erasures per query")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	switch cmd {
+	case "export", "erase":
+		if *userID == "" {
+			fmt.Fprintln(os.Stderr, "-user-id is required")
+			return 2
+		}
+	case "resume":
+	default:
+		flag.Usage()
+		return 2
+	}
+
+	cfg, report, err := app.LoadConfig(*src)
+	app.LogConfigReport(logger, report)
+	if err != nil {
+		level.Error(logger).Log("msg", "loading config", "err", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	pgPool, err := app.NewPostgresPool(ctx, cfg.Postgres, "gdpr-dummy-go", nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "connecting to postgres", "err", err)
+		return 1
+	}
+	defer pgPool.Close()
+
+	redisClient, err := app.NewRedisClient(ctx, cfg.Redis, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "connecting to redis", "err", err)
+		return 1
+	}
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			level.Error(logger).Log("msg", "closing redis client", "err", err)
+		}
+	}()
+
+	mongoClient, err := app.NewMongoClient(ctx, cfg.Mongo)
+	if err != nil {
+		level.Error(logger).Log("msg", "connecting to mongodb", "err", err)
+		return 1
+	}
+	defer func() {
+		if err := mongoClient.Disconnect(context.Background()); err != nil {
+			level.Error(logger).Log("msg", "disconnecting from mongodb", "err", err)
+		}
+	}()
+
+	cipher, err := app.NewCipher(ctx, cfg.PII, pgPool)
+	if err != nil {
+		level.Error(logger).Log("msg", "opening pii keys", "err", err)
+		return 1
+	}
+
+	mongoDB := mongoClient.Database(cfg.Mongo.Database)
+	redisKeys := dummy.NewKeys(cfg.Redis.KeyApp, cfg.Redis.KeyEnv)
+	sqlRepo := dummy.NewUsersSQLRepo(pgPool, cipher)
+	kvRepo := dummy.NewUsersKVRepo(dummy.StaticRedisClient(redisClient), redisKeys, cfg.Redis.UserTTL, cipher)
+	docsRepo := dummy.NewUsersDocsRepo(dummy.StaticCollection(mongoDB.Collection("users")), dummy.NewRandIDGenerator(), cipher)
+	profilesRepo := dummy.NewUsersProfilesRepo(dummy.StaticCollection(mongoDB.Collection("user_profiles")), dummy.ProfilesConfig{
+		MaxBytes: cfg.Profile.MaxBytes,
+		MaxDepth: cfg.Profile.MaxDepth,
+	})
+	usersCache := dummy.NewUsersRedisCache(dummy.StaticRedisClient(redisClient), redisKeys, dummy.UsersCacheConfig{
+		TTL:         cfg.Cache.UserTTL,
+		Jitter:      cfg.Cache.UserTTLJitter,
+		NegativeTTL: cfg.Cache.UserNegativeTTL,
+	}, discard.NewCounter(), cipher)
+	svc := dummy.NewUserService(kvRepo, sqlRepo, docsRepo, usersCache, profilesRepo, cfg.Purge.Retention)
+
+	switch cmd {
+	case "export":
+		e, err := svc.ExportUser(ctx, domain.UserID(*userID))
+		if err != nil {
+			level.Error(logger).Log("msg", "exporting user", "userId", *userID, "err", err)
+			return 1
+		}
+		if err := writeArchive(*out, middleware.NewUserArchive(e, time.Now())); err != nil {
+			level.Error(logger).Log("msg", "writing archive", "err", err)
+			return 1
+		}
+
+	case "erase":
+		e, err := svc.EraseUser(ctx, domain.UserID(*userID))
+		if err != nil {
+			level.Error(logger).Log("msg", "erasing user", "userId", *userID, "err", err)
+			return 1
+		}
+		fmt.Printf("erased %s: requested %s, completed %s, %d docs and %d keys deleted\n",
+			e.UserID, e.RequestedAt.Format(time.RFC3339), e.CompletedAt.Format(time.RFC3339), e.DocsDeleted, e.KeysDeleted)
+
+	case "resume":
+		erased, err := dummy.NewUsersEraser(sqlRepo, kvRepo, docsRepo, profilesRepo, usersCache).Resume(ctx, *batch)
+		fmt.Printf("completed %d pending erasures\n", erased)
+		if err != nil {
+			level.Error(logger).Log("msg", "resuming erasures", "err", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func writeArchive(path string, a middleware.UserArchive) (err error) {
+	var w io.Writer = os.Stdout
+	if path != "" && path != "-" {
+		// Personal data, readable by the owner only.
+		f, openErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if openErr != nil {
+			return openErr
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
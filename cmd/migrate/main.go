@@ -1,63 +1,462 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/go-kit/log"
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/go-kit/log/level"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"ws-dummy-go/internal/app"
+	"ws-dummy-go/internal/migrator"
+	"ws-dummy-go/migrations"
 )
 
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitUsage   = 2
+	exitAborted = 3 // not confirmed
+)
+
+const appName = "migrate-dummy-go"
+
+const (
+	targetPostgres = "postgres"
+	targetMongo    = "mongo"
+)
+
+const usage = `usage: migrate [-config file] [-set KEY=VALUE] [-target postgres|mongo] [-path dir] [-dry-run] [-yes] <command> [args]
+
+commands:
+  up [N]      apply all or the next N pending migrations
+  down [N]    revert all or the last N applied migrations
+  goto V      migrate up or down to version V
+  force V     set the version without running migrations, to fix a dirty database
+  version     print the current version
+  status      list applied and pending migrations
+  create NAME create empty up and down files for the next version
+  drift       diff the database schema against a fresh database migrated to the same version, exits 1 on differences
+  lint        check the migrations for destructive or locking SQL, exits 1 on findings
+
+down, goto to a lower version and force ask for confirmation unless -yes is given.
+-dry-run prints the migrations that would run and exits.
+Silence a lint finding with a comment before the statement: -- lint:allow <rule>[,<rule>] <reason>
+-target mongo runs the Mongo migrations, they are built in and -path doesn't apply.
+`
+
 func main() {
-	file := flag.String("config", "dev.env", "config file")
+	os.Exit(run())
+}
+
+func run() int {
+	src := app.RegisterConfigFlags(flag.CommandLine)
+	path := flag.String("path", "", "migrations directory, the embedded migrations if empty")
+	dryRun := flag.Bool("dry-run", false, "print the migrations that would run and exit")
+	yes := flag.Bool("yes", false, "don't ask for confirmation")
+	target := flag.String("target", targetPostgres, "database to migrate: postgres or mongo")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
 	flag.Parse()
 
 	logger := log.NewLogfmtLogger(os.Stderr)
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
 
-	logger.Log("msg", "migrate starting...")
-	defer logger.Log("msg", "migrate shut down")
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return exitUsage
+	}
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+
+	if *target != targetPostgres && *target != targetMongo {
+		fmt.Fprintln(os.Stderr, "target must be postgres or mongo")
+		return exitUsage
+	}
 
-	cfg, err := app.LoadConfig(*file)
+	// Don't need a database.
+	if cmd == "lint" {
+		if len(args) != 0 {
+			flag.Usage()
+			return exitUsage
+		}
+		return lint(*path, logger)
+	}
+	if cmd == "create" {
+		if *target == targetMongo {
+			fmt.Fprintln(os.Stderr, "mongo migrations are written in code, see migrations/mongo.go")
+			return exitUsage
+		}
+		if len(args) != 1 {
+			flag.Usage()
+			return exitUsage
+		}
+		dir := *path
+		if dir == "" {
+			dir = "migrations"
+		}
+		up, down, err := migrator.Create(dir, args[0])
+		if err != nil {
+			level.Error(logger).Log("msg", "creating migration", "err", err)
+			return exitError
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return exitOK
+	}
+
+	var n int
+	switch cmd {
+	case "up", "down":
+		if len(args) > 1 {
+			flag.Usage()
+			return exitUsage
+		}
+		if len(args) == 1 {
+			v, err := strconv.Atoi(args[0])
+			if err != nil || v < 1 {
+				fmt.Fprintln(os.Stderr, "N must be a positive number")
+				return exitUsage
+			}
+			n = v
+		}
+	case "goto", "force":
+		if len(args) != 1 {
+			flag.Usage()
+			return exitUsage
+		}
+		v, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "V must be a version number")
+			return exitUsage
+		}
+		n = int(v)
+	case "version", "status", "drift":
+		if len(args) != 0 {
+			flag.Usage()
+			return exitUsage
+		}
+	default:
+		flag.Usage()
+		return exitUsage
+	}
+
+	level.Info(logger).Log("msg", "migrate starting...")
+	defer level.Info(logger).Log("msg", "migrate shut down")
+
+	cfg, report, err := app.LoadConfig(*src)
+	app.LogConfigReport(logger, report)
 	if err != nil {
-		logger.Log("msg", "loading config", "err", err)
-		return
+		level.Error(logger).Log("msg", "loading config", "err", err)
+		return exitError
+	}
+	level.Info(logger).Log("msg", "config loaded")
+
+	ctx := context.Background()
+	if cmd == "drift" {
+		if *target != targetPostgres {
+			fmt.Fprintln(os.Stderr, "drift supports the postgres target only")
+			return exitUsage
+		}
+		return drift(ctx, cfg, *path, logger)
 	}
-	logger.Log("msg", "config loaded")
 
-	pgURL := composePostgresURL(cfg.Postgres)
-	mig, err := migrate.New("file://migrations", pgURL)
+	tgt, err := openTarget(ctx, *target, *path, cfg, logger)
 	if err != nil {
-		logger.Log("msg", "initializing migrations", "err", err)
-		return
+		level.Error(logger).Log("msg", "initializing migrations", "target", *target, "err", err)
+		return exitError
 	}
 	defer func() {
-		if err1, err2 := mig.Close(); err != nil {
-			logger.Log("msg", "closing migrations", "err1", err1, "err2", err2)
+		if err := tgt.Close(ctx); err != nil {
+			level.Error(logger).Log("msg", "closing migrations", "err", err)
 		}
 	}()
-	version, dirty, err := mig.Version()
+
+	current, dirty, err := tgt.Version(ctx)
+	if err != nil {
+		level.Error(logger).Log("msg", "getting migrations version", "err", err)
+		return exitError
+	}
+
+	if cmd == "version" {
+		if current == migrator.NoVersion {
+			fmt.Println("no migrations applied")
+			return exitOK
+		}
+		fmt.Println(formatVersion(current, dirty))
+		return exitOK
+	}
+
+	migs, err := tgt.Migrations(current, dirty)
+	if err != nil {
+		level.Error(logger).Log("msg", "listing migrations", "err", err)
+		return exitError
+	}
+
+	if cmd == "status" {
+		printStatus(migs)
+		return exitOK
+	}
+
+	if dirty && cmd != "force" {
+		level.Error(logger).Log("msg", "database is dirty, fix it and run force V", "version", current)
+		return exitError
+	}
+
+	var steps []migrator.Step
+	destructive := false
+	switch cmd {
+	case "up":
+		steps, err = migrator.PlanUp(migs, n)
+	case "down":
+		steps, err = migrator.PlanDown(migs, n)
+		destructive = true
+	case "goto":
+		steps, err = migrator.PlanGoto(migs, current, uint(n))
+		destructive = n < current
+	case "force":
+		destructive = true
+	}
 	if err != nil {
-		logger.Log("msg", "getting migrations version", "err", err)
-		return
+		level.Error(logger).Log("msg", "planning migrations", "err", err)
+		return exitError
 	}
-	logger.Log("msg", "migrations version", "version", version, "dirty", dirty)
 
-	if err := mig.Up(); err != nil {
-		logger.Log("msg", "running migrations up", "err", err)
-		return
+	if cmd == "force" {
+		fmt.Printf("force version %d, currently %s\n", n, formatVersion(current, dirty))
+	} else {
+		printPlan(steps)
+		if len(steps) == 0 {
+			level.Info(logger).Log("msg", "no change")
+			return exitOK
+		}
+	}
+	if *dryRun {
+		return exitOK
+	}
+	if destructive && !*yes && !confirm() {
+		fmt.Fprintln(os.Stderr, "aborted")
+		return exitAborted
+	}
+
+	if cmd == "force" {
+		err = tgt.Force(ctx, n)
+	} else {
+		err = tgt.Run(ctx, steps)
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "running migrations", "command", cmd, "err", err)
+		return exitError
+	}
+
+	current, dirty, err = tgt.Version(ctx)
+	if err != nil {
+		level.Error(logger).Log("msg", "getting migrations version", "err", err)
+		return exitError
+	}
+	level.Info(logger).Log("msg", "migrate done ok", "version", formatVersion(current, dirty))
+	return exitOK
+}
+
+func openTarget(ctx context.Context, target, path string, cfg *app.Config, logger log.Logger) (migrator.Target, error) {
+	switch target {
+	case targetMongo:
+		client, err := app.NewMongoClient(ctx, cfg.Mongo)
+		if err != nil {
+			return nil, err
+		}
+		tgt, err := migrator.NewMongo(client.Database(cfg.Mongo.Database), migrations.Mongo)
+		if err != nil {
+			_ = client.Disconnect(ctx)
+			return nil, err
+		}
+		return tgt, nil
+	default:
+		src, err := openSource(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening migrations: %w", err)
+		}
+		return migrator.NewPostgres(src, app.PostgresURL(cfg.Postgres, appName), logger)
+	}
+}
+
+// drift migrates a scratch database on the same server to the version of
+// the database and compares their schemas.
+func drift(ctx context.Context, cfg *app.Config, path string, logger log.Logger) int {
+	pool, err := app.NewPostgresPool(ctx, cfg.Postgres, appName, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "connecting to postgres", "err", err)
+		return exitError
+	}
+	defer pool.Close()
+
+	src, err := openSource(path)
+	if err != nil {
+		level.Error(logger).Log("msg", "opening migrations", "err", err)
+		return exitError
+	}
+	tgt, err := migrator.NewPostgres(src, app.PostgresURL(cfg.Postgres, appName), logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "initializing migrations", "err", err)
+		return exitError
+	}
+	current, dirty, err := tgt.Version(ctx)
+	_ = tgt.Close(ctx)
+	if err != nil {
+		level.Error(logger).Log("msg", "getting migrations version", "err", err)
+		return exitError
+	}
+	if dirty {
+		level.Error(logger).Log("msg", "database is dirty, fix it and run force V", "version", current)
+		return exitError
+	}
+
+	got, err := migrator.Snapshot(ctx, pool, "public")
+	if err != nil {
+		level.Error(logger).Log("msg", "reading schema", "err", err)
+		return exitError
+	}
+	want, err := scratchSnapshot(ctx, pool, cfg.Postgres, path, current, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "reading migrated schema", "err", err)
+		return exitError
+	}
+
+	diff := migrator.Diff(want, got)
+	for _, d := range diff {
+		fmt.Println(d)
+	}
+	if len(diff) > 0 {
+		level.Warn(logger).Log("msg", "schema drift", "version", formatVersion(current, false), "differences", len(diff))
+		return exitError
+	}
+	level.Info(logger).Log("msg", "no schema drift", "version", formatVersion(current, false))
+	return exitOK
+}
+
+// scratchSnapshot creates a database, migrates it to the version, reads
+// its schema and drops it. It needs the CREATEDB privilege.
+func scratchSnapshot(ctx context.Context, pool *pgxpool.Pool, cfg app.PostgresConfig, path string, version int, logger log.Logger) (migrator.Schema, error) {
+	cfg.Database = fmt.Sprintf("%s_drift_%d", cfg.Database, time.Now().Unix())
+	name := pgx.Identifier{cfg.Database}.Sanitize()
+	if _, err := pool.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+		return nil, fmt.Errorf("creating scratch database: %w", err)
+	}
+	defer func() {
+		if _, err := pool.Exec(context.Background(), "DROP DATABASE IF EXISTS "+name); err != nil {
+			level.Error(logger).Log("msg", "dropping scratch database", "db", cfg.Database, "err", err)
+		}
+	}()
+
+	src, err := openSource(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening migrations: %w", err)
+	}
+	tgt, err := migrator.NewPostgres(src, app.PostgresURL(cfg, appName), log.NewNopLogger())
+	if err != nil {
+		return nil, err
+	}
+	defer tgt.Close(ctx)
+
+	if version != migrator.NoVersion {
+		migs, err := tgt.Migrations(migrator.NoVersion, false)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := migrator.PlanGoto(migs, migrator.NoVersion, uint(version))
+		if err != nil {
+			return nil, err
+		}
+		if err := tgt.Run(ctx, steps); err != nil {
+			return nil, fmt.Errorf("migrating scratch database: %w", err)
+		}
+	}
+
+	scratch, err := app.NewPostgresPool(ctx, cfg, appName, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+	return migrator.Snapshot(ctx, scratch, "public")
+}
+
+func lint(path string, logger log.Logger) int {
+	fsys := fs.FS(migrations.FS)
+	if path != "" {
+		fsys = os.DirFS(path)
+	}
+	findings, err := migrator.Lint(fsys)
+	if err != nil {
+		level.Error(logger).Log("msg", "linting migrations", "err", err)
+		return exitError
+	}
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if len(findings) > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// openSource opens the migrations directory, the embedded migrations if empty.
+func openSource(path string) (source.Driver, error) {
+	if path == "" {
+		return migrations.Source()
+	}
+	return source.Open("file://" + path)
+}
+
+func formatVersion(v int, dirty bool) string {
+	switch {
+	case v == migrator.NoVersion:
+		return "none"
+	case dirty:
+		return fmt.Sprintf("%d (dirty)", v)
+	default:
+		return strconv.Itoa(v)
+	}
+}
+
+func printStatus(migs []migrator.Migration) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tDOWN")
+	for _, m := range migs {
+		state := "pending"
+		switch {
+		case m.Dirty:
+			state = "dirty"
+		case m.Applied:
+			state = "applied"
+		}
+		down := "yes"
+		if !m.HasDown {
+			down = "missing"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.Version, m.Name, state, down)
+	}
+	w.Flush()
+}
+
+func printPlan(steps []migrator.Step) {
+	for _, s := range steps {
+		fmt.Printf("%-4s %d %s\n", s.Direction, s.Version, s.Name)
 	}
-	logger.Log("msg", "migrate done ok")
 }
 
-func composePostgresURL(cfg app.PostgresConfig) string {
-	return fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?connect_timeout=%d&application_name=%s&sslmode=disable",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database, cfg.Timeout, "migrate-dummy-go",
-	)
+func confirm() bool {
+	fmt.Fprint(os.Stderr, "Proceed? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"ws-dummy-go/internal/app"
+	"ws-dummy-go/internal/dummy"
+	"ws-dummy-go/internal/pii"
+)
+
+const usage = `usage: pii [-config file] [-set KEY=VALUE] <command> [flags]
+
+commands:
+  genkey -file FILE [-id ID]   add a master key to the key file, created if missing, and make it the active one
+  init                         create the blind index key and the first data key, the server does it too
+  rotate                       add a data key, the one encrypting from now on
+  rewrap                       wrap the data keys with the active master key of PII_KEY_FILE
+  reencrypt [-batch N]         encrypt the names of every store with the active data key
+
+A data key rotation is rotate, a restart of the servers, then reencrypt. A master key
+rotation is genkey, rewrap, then the old master key can be removed from the file.
+reencrypt also encrypts the names stored as plaintext, run it after setting PII_KEY_FILE.
+`
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	src := app.RegisterConfigFlags(flag.CommandLine)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 2
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	fset := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	file := fset.String("file", "", "master key file")
+	id := fset.String("id", time.Now().UTC().Format("m20060102150405"), "master key ID")
+	batch := fset.Int("batch", 100, "users per query")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	switch cmd {
+	case "genkey":
+		if *file == "" {
+			fmt.Fprintln(os.Stderr, "-file is required")
+			return 2
+		}
+		if err := genKey(*file, *id); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("master key %s added to %s\n", *id, *file)
+		return 0
+	case "init", "rotate", "rewrap", "reencrypt":
+	default:
+		flag.Usage()
+		return 2
+	}
+
+	cfg, report, err := app.LoadConfig(*src)
+	app.LogConfigReport(logger, report)
+	if err != nil {
+		level.Error(logger).Log("msg", "loading config", "err", err)
+		return 1
+	}
+	if cfg.PII.KeyFile == "" {
+		level.Error(logger).Log("msg", "PII_KEY_FILE is not set")
+		return 1
+	}
+	master, err := pii.LoadKeyFile(cfg.PII.KeyFile)
+	if err != nil {
+		level.Error(logger).Log("msg", "loading master keys", "err", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	pgPool, err := app.NewPostgresPool(ctx, cfg.Postgres, "pii-dummy-go", nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "connecting to postgres", "err", err)
+		return 1
+	}
+	defer pgPool.Close()
+
+	keyring := pii.NewKeyring(master, pii.NewKeysSQLRepo(pgPool))
+
+	switch cmd {
+	case "init":
+		if err := keyring.Init(ctx); err != nil {
+			level.Error(logger).Log("msg", "creating keys", "err", err)
+			return 1
+		}
+		if err := keyring.Load(ctx); err != nil {
+			level.Error(logger).Log("msg", "loading keys", "err", err)
+			return 1
+		}
+		fmt.Printf("keys ready, data key %s active\n", keyring.ActiveID())
+
+	case "rotate":
+		keyID, err := keyring.Rotate(ctx)
+		if err != nil {
+			level.Error(logger).Log("msg", "rotating data key", "err", err)
+			return 1
+		}
+		fmt.Printf("data key %s active, restart the servers then run reencrypt\n", keyID)
+
+	case "rewrap":
+		n, err := keyring.Rewrap(ctx)
+		fmt.Printf("rewrapped %d keys with master key %s\n", n, master.ActiveID())
+		if err != nil {
+			level.Error(logger).Log("msg", "rewrapping keys", "err", err)
+			return 1
+		}
+
+	case "reencrypt":
+		if err := keyring.Load(ctx); err != nil {
+			level.Error(logger).Log("msg", "loading keys", "err", err)
+			return 1
+		}
+
+		redisClient, err := app.NewRedisClient(ctx, cfg.Redis, nil)
+		if err != nil {
+			level.Error(logger).Log("msg", "connecting to redis", "err", err)
+			return 1
+		}
+		defer func() {
+			if err := redisClient.Close(); err != nil {
+				level.Error(logger).Log("msg", "closing redis client", "err", err)
+			}
+		}()
+
+		mongoClient, err := app.NewMongoClient(ctx, cfg.Mongo)
+		if err != nil {
+			level.Error(logger).Log("msg", "connecting to mongodb", "err", err)
+			return 1
+		}
+		defer func() {
+			if err := mongoClient.Disconnect(context.Background()); err != nil {
+				level.Error(logger).Log("msg", "disconnecting from mongodb", "err", err)
+			}
+		}()
+
+		res, err := dummy.ReencryptUsers(ctx, pgPool, mongoClient.Database(cfg.Mongo.Database).Collection("users"),
+			redisClient, dummy.NewKeys(cfg.Redis.KeyApp, cfg.Redis.KeyEnv), keyring, *batch)
+		fmt.Printf("reencrypted with data key %s: %d postgres rows, %d mongo docs, %d redis hashes, %d cached users deleted\n",
+			keyring.ActiveID(), res.Rows, res.Docs, res.Hashes, res.CacheDeleted)
+		if err != nil {
+			level.Error(logger).Log("msg", "reencrypting users", "err", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// genKey adds the master key to the file, creating it if missing.
+func genKey(path, id string) error {
+	f, err := pii.LoadKeyFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = &pii.KeyFile{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.AddKey(id); err != nil {
+		return err
+	}
+	return f.Save(path)
+}
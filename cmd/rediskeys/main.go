@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"ws-dummy-go/internal/app"
+	"ws-dummy-go/internal/dummy"
+	"ws-dummy-go/internal/pii"
+)
+
+const usage = `usage: rediskeys [-config file] [-set KEY=VALUE] <command> [flags]
+
+commands:
+  migrate -match PATTERN [-dry-run=false]   move the keys stored before the key schema into the key space
+
+migrate moves the raw name -> ID keys of the users into REDIS_KEY_APP:REDIS_KEY_ENV:vN:
+and deletes the unprefixed cache entries, only the keys matching Postgres: the name of
+one live user, moved under its ID, and the cache entries of a user or API key. Other keys
+are left alone and counted as skipped. Each key is logged before it is changed. It is a
+dry run unless -dry-run=false. It can be run again, keys already in a key space are
+ignored. With PII_KEY_FILE set the names are encrypted.
+`
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	src := app.RegisterConfigFlags(flag.CommandLine)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 2
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+
+	switch cmd {
+	case "migrate":
+		match := fs.String("match", "", "SCAN pattern of the keys to look at, required")
+		dryRun := fs.Bool("dry-run", true, "count and log the keys without changing them")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if *match == "" {
+			fmt.Fprintln(os.Stderr, "migrate: -match is required")
+			return 2
+		}
+
+		cfg, report, err := app.LoadConfig(*src)
+		app.LogConfigReport(logger, report)
+		if err != nil {
+			level.Error(logger).Log("msg", "loading config", "err", err)
+			return 1
+		}
+
+		ctx := context.Background()
+		redisClient, err := app.NewRedisClient(ctx, cfg.Redis, nil)
+		if err != nil {
+			level.Error(logger).Log("msg", "connecting to redis", "err", err)
+			return 1
+		}
+		defer func() {
+			if err := redisClient.Close(); err != nil {
+				level.Error(logger).Log("msg", "closing redis client", "err", err)
+			}
+		}()
+
+		pgPool, err := app.NewPostgresPool(ctx, cfg.Postgres, "rediskeys-dummy-go", nil)
+		if err != nil {
+			level.Error(logger).Log("msg", "connecting to postgres", "err", err)
+			return 1
+		}
+		defer pgPool.Close()
+
+		cipher := pii.NewPlaintext()
+		if cfg.PII.KeyFile != "" {
+			if cipher, err = app.NewCipher(ctx, cfg.PII, pgPool); err != nil {
+				level.Error(logger).Log("msg", "opening pii keys", "err", err)
+				return 1
+			}
+		}
+
+		keys := dummy.NewKeys(cfg.Redis.KeyApp, cfg.Redis.KeyEnv)
+		res, err := dummy.MigrateRawKeys(ctx, redisClient, pgPool, keys, cipher, dummy.RawKeysOptions{
+			Match:  *match,
+			DryRun: *dryRun,
+			TTL:    cfg.Redis.UserTTL,
+			Log: func(action, key string) {
+				level.Info(logger).Log("msg", "changing key", "action", action, "key", key, "dry_run", *dryRun)
+			},
+		})
+		fmt.Printf("scanned %d, migrated %d, deleted %d, skipped %d", res.Scanned, res.Migrated, res.Deleted, res.Skipped)
+		if *dryRun {
+			fmt.Print(" (dry run)")
+		}
+		fmt.Println()
+		if err != nil {
+			level.Error(logger).Log("msg", "migrating keys", "prefix", keys.Prefix(), "err", err)
+			return 1
+		}
+
+	default:
+		flag.Usage()
+		return 2
+	}
+	return 0
+}
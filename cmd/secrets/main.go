@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"ws-dummy-go/internal/secrets"
+)
+
+const usage = `usage: secrets <command> [flags]
+
+commands:
+  keygen                                      print a new base64 key
+  encrypt -key-file KEY -in FILE -out FILE    encrypt a JSON object {"NAME": "value"}
+  list -key-file KEY -in FILE                 list the names in an encrypted file
+`
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 2
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+
+	switch cmd {
+	case "keygen":
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(key)
+
+	case "encrypt":
+		keyFile := fs.String("key-file", "", "base64 key file")
+		in := fs.String("in", "", "plaintext JSON file")
+		out := fs.String("out", "", "encrypted file")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if err := encrypt(*keyFile, *in, *out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("encrypted %s to %s\n", *in, *out)
+
+	case "list":
+		keyFile := fs.String("key-file", "", "base64 key file")
+		in := fs.String("in", "", "encrypted file")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		key, err := secrets.ReadKeyFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		names, err := secrets.NewEncryptedFileProvider(*in, key).Names()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Println(n)
+		}
+
+	default:
+		flag.Usage()
+		return 2
+	}
+	return 0
+}
+
+func encrypt(keyFile, in, out string) error {
+	key, err := secrets.ReadKeyFile(keyFile)
+	if err != nil {
+		return err
+	}
+	plain, err := os.ReadFile(in)
+	if err != nil {
+		return fmt.Errorf("reading plaintext: %w", err)
+	}
+	var values map[string]string
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return fmt.Errorf("expected a JSON object of strings: %w", err)
+	}
+	sealed, err := secrets.Seal(key, plain)
+	if err != nil {
+		return fmt.Errorf("encrypting: %w", err)
+	}
+	// Written next to the target and renamed, so a watching server never reads half a file.
+	tmp := out + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return fmt.Errorf("writing encrypted file: %w", err)
+	}
+	return os.Rename(tmp, out)
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"os"
+
 	"ws-dummy-go/internal/app"
 )
 
 func main() {
-	app.Run()
+	os.Exit(app.Run())
 }
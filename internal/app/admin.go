@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"ws-dummy-go/internal/logging"
+)
+
+const (
+	readinessTimeout = 2 * time.Second
+
+	adminReadHeaderTimeout = 5 * time.Second
+	adminReadTimeout       = 10 * time.Second
+	// Longer than the default 30s CPU profile of /debug/pprof/profile.
+	adminWriteTimeout = 60 * time.Second
+	adminIdleTimeout  = 60 * time.Second
+	// The admin server is stopped after the public one.
+	adminShutdownTimeout = 5 * time.Second
+)
+
+// buildTime is set at build time:
+// go build -ldflags "-X ws-dummy-go/internal/app.buildTime=$(date -u +%FT%TZ)"
+var buildTime string
+
+type (
+	// healthCheck tells whether a dependency is usable.
+	healthCheck func(ctx context.Context) error
+
+	versionInfo struct {
+		Module       string `json:"module"`
+		Version      string `json:"version"`
+		GoVersion    string `json:"goVersion"`
+		Revision     string `json:"revision,omitempty"`
+		RevisionTime string `json:"revisionTime,omitempty"`
+		Modified     bool   `json:"modified"`
+		BuildTime    string `json:"buildTime,omitempty"`
+	}
+
+	readiness struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+
+	// admin holds what the admin endpoints report on.
+	admin struct {
+		logLevel *logging.Level
+		config   func() Config
+		checks   map[string]healthCheck
+		draining atomic.Bool // fails the readiness check during shutdown
+	}
+)
+
+func readVersion() versionInfo {
+	v := versionInfo{BuildTime: buildTime}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	v.Module, v.Version, v.GoVersion = bi.Main.Path, bi.Main.Version, bi.GoVersion
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.time":
+			v.RevisionTime = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	return v
+}
+
+// Handler serves the operational endpoints. They are not meant to be
+// exposed publicly, so the admin listener has its own address.
+func (a *admin) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/metrics", promhttp.Handler())
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	mux.Handle("/debug/log-level", logging.LevelHandler(a.logLevel))
+	mux.HandleFunc("/debug/config", func(w http.ResponseWriter, _ *http.Request) {
+		cfg := a.config()
+		w.Header().Set("Content-Type", "application/json")
+		_ = PrintConfig(w, &cfg, "json") // secrets are masked
+	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, readVersion())
+	})
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, readiness{Status: "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status, res := a.ready(r.Context())
+		writeJSON(w, status, res)
+	})
+	return mux
+}
+
+func (a *admin) ready(ctx context.Context) (int, readiness) {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	res := readiness{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	if a.draining.Load() {
+		res.Status, status = "draining", http.StatusServiceUnavailable
+	}
+	for name, check := range a.checks {
+		if err := check(ctx); err != nil {
+			res.Checks[name] = err.Error()
+			res.Status, status = "unavailable", http.StatusServiceUnavailable
+			continue
+		}
+		res.Checks[name] = "ok"
+	}
+	return status, res
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
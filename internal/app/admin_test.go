@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/logging"
+)
+
+func Test_admin_Handler(t *testing.T) {
+	cfg, _, err := LoadConfig(ConfigSources{EnvFile: writeTestEnv(t)})
+	require.NoError(t, err)
+	lvl, err := logging.NewLevel("info")
+	require.NoError(t, err)
+
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		path       string
+		checks     map[string]healthCheck
+		draining   bool
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Positive: Liveness",
+			path:       "/healthz",
+			checks:     map[string]healthCheck{"postgres": down},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Positive: Ready",
+			path:       "/readyz",
+			checks:     map[string]healthCheck{"postgres": ok, "redis": ok},
+			wantStatus: http.StatusOK,
+			wantBody:   `"redis":"ok"`,
+		},
+		{
+			name:       "Positive: Version",
+			path:       "/version",
+			wantStatus: http.StatusOK,
+			wantBody:   `"goVersion":"go`,
+		},
+		{
+			name:       "Positive: Config with secrets masked",
+			path:       "/debug/config",
+			wantStatus: http.StatusOK,
+			wantBody:   `"POSTGRES_PASSWORD": "******"`,
+		},
+		{
+			name:       "Positive: Log level",
+			path:       "/debug/log-level",
+			wantStatus: http.StatusOK,
+			wantBody:   `"level":"info"`,
+		},
+		{
+			name:       "Positive: pprof",
+			path:       "/debug/pprof/",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Positive: expvar",
+			path:       "/debug/vars",
+			wantStatus: http.StatusOK,
+			wantBody:   `"memstats"`,
+		},
+		{
+			name:       "Positive: Metrics",
+			path:       "/metrics",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Negative: Dependency down",
+			path:       "/readyz",
+			checks:     map[string]healthCheck{"postgres": down, "redis": ok},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"postgres":"connection refused"`,
+		},
+		{
+			name:       "Negative: Draining",
+			path:       "/readyz",
+			draining:   true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"status":"draining"`,
+		},
+		{
+			name:       "Negative: Unknown path",
+			path:       "/createUser",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			adm := &admin{logLevel: lvl, config: func() Config { return *cfg }, checks: tt.checks}
+			adm.draining.Store(tt.draining)
+
+			rec := httptest.NewRecorder()
+			adm.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(tt.wantStatus, rec.Code)
+			assert.Contains(rec.Body.String(), tt.wantBody)
+			assert.NotContains(rec.Body.String(), `"pass"`)
+		})
+	}
+}
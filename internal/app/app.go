@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,43 +10,92 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/go-kit/kit/endpoint"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/go-kit/log/level"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/redis/go-redis/v9"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"ws-dummy-go/internal/auth"
 	"ws-dummy-go/internal/dummy"
 	"ws-dummy-go/internal/dummy/middleware"
+	"ws-dummy-go/internal/logging"
+	"ws-dummy-go/internal/secrets"
+	"ws-dummy-go/internal/settings"
 )
 
 const (
-	appName      = "ws-dummy-go"
-	poolMaxConns = 10
+	appName = "ws-dummy-go"
+
+	scopeUsersWrite = "users:write"
+	scopeUsersRead  = "users:read"
 )
 
-func Run() {
-	file := flag.String("config", "dev.env", "config file")
+// Run runs the server and returns the process exit code.
+func Run() int {
+	src := RegisterConfigFlags(flag.CommandLine)
+	checkConfig := flag.Bool("check-config", false, "validate the config, resolve the hosts and exit")
+	printConfig := flag.String("print-config", "", "print the effective config with secrets masked and exit: yaml or json")
 	flag.Parse()
 
-	logger := log.NewLogfmtLogger(os.Stderr)
+	cfg, report, cfgErr := LoadConfig(*src)
+
+	// The level and the format are known once the config is loaded.
+	logLevel := &logging.Level{}
+	logFormat := logging.FormatLogfmt
+	if cfgErr == nil {
+		logFormat = cfg.LogFormat
+		if err := logLevel.Set(cfg.LogLevel); err != nil { // validated, can't happen
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	logger, err := logging.New(os.Stderr, logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	logger = logging.NewFilter(logger, logLevel)
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
 
-	logger.Log("msg", "server starting...")
-	defer logger.Log("msg", "server shut down")
+	level.Info(logger).Log("msg", "server starting...")
+	defer level.Info(logger).Log("msg", "server shut down")
 
-	cfg, err := LoadConfig(*file)
-	if err != nil {
-		logger.Log("msg", "loading config", "err", err)
-		return
+	LogConfigReport(logger, report)
+	if cfgErr != nil {
+		level.Error(logger).Log("msg", "loading config", "err", cfgErr)
+		return 1
+	}
+	if *checkConfig || cfg.CheckHosts {
+		if err := CheckHosts(context.Background(), cfg); err != nil {
+			level.Error(logger).Log("msg", "checking config", "err", err)
+			return 1
+		}
 	}
-	logger.Log("msg", "config loaded")
+	level.Info(logger).Log("msg", "config loaded", "logLevel", cfg.LogLevel, "logFormat", cfg.LogFormat)
+
+	if *printConfig != "" {
+		if err := PrintConfig(os.Stdout, cfg, *printConfig); err != nil {
+			level.Error(logger).Log("msg", "printing config", "err", err)
+			return 1
+		}
+	}
+	if *checkConfig || *printConfig != "" {
+		level.Info(logger).Log("msg", "config ok")
+		return 0
+	}
+
+	// Hot-reloadable settings
+	settingsStore := settings.NewStore(settingsFrom(cfg))
+
+	// Secrets
+	secretsWatcher := secrets.NewWatcher(cfg.Secrets.Refresh, logger, report.Secrets)
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
 
 	fieldKeys := []string{"method", "error"}
 
@@ -63,140 +113,511 @@ func Run() {
 		Help:      "Total duration of requests in microseconds.",
 	}, fieldKeys)
 
+	userCacheLookups := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "dummy_group",
+		Subsystem: "ws_dummy_go",
+		Name:      "user_cache_lookup_count",
+		Help:      "Number of user cache lookups by result: hit, negative_hit, miss or error.",
+	}, []string{"result"})
+
+	verifiers := map[string]auth.Verifier{}
+
+	// Readiness checks
+	checks := map[string]healthCheck{}
+
 	var svc dummy.UserService
 	{
 		// Postgres
-		pgPool, err := pgxpool.New(context.Background(), composePostgresURL(cfg.Postgres))
+		pgPool, err := NewPostgresPool(context.Background(), cfg.Postgres, appName, secretsWatcher.Get("POSTGRES_PASSWORD"))
 		if err != nil {
-			logger.Log("msg", "connecting to postgres", "err", err)
-			return
-		}
-		if err := pgPool.Ping(context.Background()); err != nil {
-			logger.Log("msg", "pinging postgres", "err", err)
-			return
+			level.Error(logger).Log("msg", "connecting to postgres", "err", err)
+			return 1
 		}
 		s := pgPool.Stat()
-		logger.Log("msg", "postgres pool connected", "total", s.TotalConns(), "max", s.MaxConns())
+		level.Info(logger).Log("msg", "postgres pool connected", "total", s.TotalConns(), "max", s.MaxConns())
+		level.Debug(logger).Log(
+			"msg", "postgres pool config",
+			"minConns", cfg.Postgres.MinConns, "maxConns", cfg.Postgres.MaxConns,
+			"maxConnLifetime", cfg.Postgres.MaxConnLifetime, "maxConnIdleTime", cfg.Postgres.MaxConnIdleTime,
+			"healthCheckPeriod", cfg.Postgres.HealthCheckPeriod,
+		)
+
+		if cfg.AutoMigrate {
+			if err := AutoMigrate(context.Background(), pgPool, cfg.Postgres, appName, cfg.AutoMigrateLockTimeout, logger); err != nil {
+				level.Error(logger).Log("msg", "migrating postgres", "err", err)
+				pgPool.Close()
+				return 1
+			}
+		}
+
+		checks["postgres"] = pgPool.Ping
+
+		// Rotated password: drop the connections, new ones use it.
+		secretsWatcher.OnChange("POSTGRES_PASSWORD", pgPool.Reset)
 
 		defer func() {
 			pgPool.Close()
-			logger.Log("msg", "postgres pool closed")
+			level.Info(logger).Log("msg", "postgres pool closed")
 		}()
 
 		// Redis
-		redisAddr := fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port)
-		redisClient := redis.NewClient(&redis.Options{
-			Addr:         redisAddr,
-			Password:     cfg.Redis.Password,
-			DB:           0,
-			DialTimeout:  cfg.Redis.Timeout,
-			ReadTimeout:  0, // 0 = 3s
-			WriteTimeout: 0, // 0 = 3s
-		})
-		if err := redisClient.Ping(context.Background()).Err(); err != nil {
-			logger.Log("msg", "pinging redis", "err", err)
-			return
+		redisConn, err := NewRedisConn(context.Background(), cfg.Redis, secretsWatcher.Get("REDIS_PASSWORD"))
+		if err != nil {
+			level.Error(logger).Log("msg", "connecting to redis", "err", err)
+			return 1
+		}
+		level.Info(logger).Log("msg", "redis connected")
+		level.Debug(logger).Log(
+			"msg", "redis client config",
+			"db", cfg.Redis.DB, "poolSize", cfg.Redis.PoolSize, "minIdleConns", cfg.Redis.MinIdleConns,
+			"readTimeout", cfg.Redis.ReadTimeout, "writeTimeout", cfg.Redis.WriteTimeout,
+		)
+
+		checks["redis"] = func(ctx context.Context) error {
+			return redisConn.Client().Ping(ctx).Err()
 		}
-		logger.Log("msg", "redis connected")
+
+		// Rotated password: a new client replaces the one with the
+		// connections using the old password.
+		secretsWatcher.OnChange("REDIS_PASSWORD", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Redis.Timeout)
+			defer cancel()
+			if err := redisConn.Reconnect(ctx); err != nil {
+				level.Error(logger).Log("msg", "reconnecting to redis", "err", err)
+				return
+			}
+			level.Info(logger).Log("msg", "redis reconnected")
+		})
 
 		defer func() {
-			if err := redisClient.Close(); err != nil {
-				logger.Log("msg", "closing redis client", "err", err)
+			if err := redisConn.Close(); err != nil {
+				level.Error(logger).Log("msg", "closing redis client", "err", err)
 				return
 			}
-			logger.Log("msg", "redis client closed")
+			level.Info(logger).Log("msg", "redis client closed")
 		}()
 
 		// Mongo
-		mongoURI := fmt.Sprintf("mongodb://%s:%s@%s:%d/%s",
-			cfg.Mongo.Username, cfg.Mongo.Password, cfg.Mongo.Host, cfg.Mongo.Port, cfg.Mongo.Database,
-		)
-		mongoClient, err := mongo.Connect(context.Background(), options.Client().
-			ApplyURI(mongoURI).
-			SetConnectTimeout(cfg.Mongo.Timeout).
-			SetServerSelectionTimeout(cfg.Mongo.Timeout).
-			SetTimeout(cfg.Mongo.Timeout))
+		mongoConn, err := NewMongoConn(context.Background(), cfg.Mongo, secretsWatcher.Get("MONGO_PASSWORD"))
 		if err != nil {
-			logger.Log("msg", "connecting to mongodb", "err", err)
-			return
+			level.Error(logger).Log("msg", "connecting to mongodb", "err", err)
+			return 1
 		}
-		if err := mongoClient.Ping(context.Background(), nil); err != nil {
-			logger.Log("msg", "pinging mongodb", "err", err)
-			return
+		level.Info(logger).Log("msg", "mongodb connected")
+		level.Debug(logger).Log(
+			"msg", "mongodb client config",
+			"minPoolSize", cfg.Mongo.MinPoolSize, "maxPoolSize", cfg.Mongo.MaxPoolSize, "appName", cfg.Mongo.AppName,
+		)
+
+		checks["mongodb"] = func(ctx context.Context) error {
+			return mongoConn.Client().Ping(ctx, nil)
 		}
-		logger.Log("msg", "mongodb connected")
+
+		// Rotated password: the driver can't change the credentials of a
+		// client, a new one replaces it.
+		secretsWatcher.OnChange("MONGO_PASSWORD", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Mongo.Timeout)
+			defer cancel()
+			if err := mongoConn.Reconnect(ctx); err != nil {
+				level.Error(logger).Log("msg", "reconnecting to mongodb", "err", err)
+				return
+			}
+			level.Info(logger).Log("msg", "mongodb reconnected")
+		})
 
 		defer func() {
-			if err := mongoClient.Disconnect(context.Background()); err != nil {
-				logger.Log("msg", "disconnecting from mongodb", "err", err)
+			if err := mongoConn.Disconnect(context.Background()); err != nil {
+				level.Error(logger).Log("msg", "disconnecting from mongodb", "err", err)
 				return
 			}
-			logger.Log("msg", "mongodb client disconnected")
+			level.Info(logger).Log("msg", "mongodb client disconnected")
 		}()
 
-		dummyCollection := mongoClient.Database(cfg.Mongo.Database).Collection("users")
+		cipher, err := NewCipher(context.Background(), cfg.PII, pgPool)
+		if err != nil {
+			level.Error(logger).Log("msg", "opening pii keys", "err", err)
+			return 1
+		}
+		level.Info(logger).Log("msg", "pii cipher ready", "encrypted", cipher.Enabled())
 
 		// Repos
-		docsRepo := dummy.NewUsersDocsRepo(dummyCollection, dummy.NewRandIDGenerator())
-		kvRepo := dummy.NewUsersKVRepo(redisClient, dummy.NewRandIDGenerator())
-		sqlRepo := dummy.NewUsersSQLRepo(pgPool)
+		docsRepo := dummy.NewUsersDocsRepo(mongoConn.Collection("users"), dummy.NewRandIDGenerator(), cipher)
+		redisKeys := dummy.NewKeys(cfg.Redis.KeyApp, cfg.Redis.KeyEnv)
+		kvRepo := dummy.NewUsersKVRepo(redisConn.Client, redisKeys, cfg.Redis.UserTTL, cipher)
+		sqlRepo := dummy.NewUsersSQLRepo(pgPool, cipher)
+		profilesRepo := dummy.NewUsersProfilesRepo(mongoConn.Collection("user_profiles"), dummy.ProfilesConfig{
+			MaxBytes: cfg.Profile.MaxBytes,
+			MaxDepth: cfg.Profile.MaxDepth,
+		})
 
-		svc = dummy.NewUserService(kvRepo, sqlRepo, docsRepo)
+		usersCache := dummy.NewUsersRedisCache(redisConn.Client, redisKeys, dummy.UsersCacheConfig{
+			TTL:         cfg.Cache.UserTTL,
+			Jitter:      cfg.Cache.UserTTLJitter,
+			NegativeTTL: cfg.Cache.UserNegativeTTL,
+		}, userCacheLookups, cipher)
+
+		svc = dummy.NewUserService(kvRepo, sqlRepo, docsRepo, usersCache, profilesRepo, cfg.Purge.Retention)
+
+		if cfg.Purge.Interval > 0 {
+			purger := dummy.NewUsersPurger(sqlRepo, kvRepo, docsRepo, profilesRepo, usersCache, cfg.Purge.Retention, cfg.Purge.Batch)
+			eraser := dummy.NewUsersEraser(sqlRepo, kvRepo, docsRepo, profilesRepo, usersCache)
+			go RunPurges(watchCtx, pgPool, purger, eraser, cfg.Purge.Batch, cfg.Purge.Interval, logger)
+			level.Info(logger).Log("msg", "purging deleted users", "retention", cfg.Purge.Retention, "interval", cfg.Purge.Interval)
+		}
+
+		if cfg.Auth.Enabled && cfg.Auth.APIKeys {
+			verifiers[auth.SchemeAPIKey] = auth.NewAPIKeyVerifier(
+				auth.NewAPIKeysSQLRepo(pgPool),
+				auth.NewAPIKeysRedisCache(redisConn.Client, redisKeys.Prefix(), cfg.Auth.APIKeysCacheTTL),
+			)
+		}
 	}
 
+	go secretsWatcher.Run(watchCtx)
+	level.Info(logger).Log("msg", "watching secrets", "count", len(report.Secrets), "refresh", cfg.Secrets.Refresh)
+
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
+	cfgReloader := newReloader(*src, cfg, settingsStore, logLevel, logger)
+	go cfgReloader.Run(watchCtx, hups, cfg.ConfigWatchInterval)
+	level.Info(logger).Log("msg", "config reloads on SIGHUP", "watchInterval", cfg.ConfigWatchInterval)
+
 	svc = middleware.NewLoggingMiddleware(logger)(svc)
 	svc = middleware.NewInstrumentingMiddleware(requestCount, requestLatency)(svc)
 
+	// Auth
+	secured := func(_ ...string) endpoint.Middleware {
+		return func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	}
+	if cfg.Auth.Enabled {
+		if cfg.Auth.JWKSSource != "" {
+			keySet := auth.NewKeySet(cfg.Auth.JWKSSource, cfg.Auth.JWKSRefresh, cfg.Auth.Timeout)
+			if err := keySet.Load(context.Background()); err != nil {
+				level.Error(logger).Log("msg", "loading jwks", "err", err)
+				return 1
+			}
+			level.Info(logger).Log("msg", "jwks loaded", "source", cfg.Auth.JWKSSource)
+
+			verifiers[auth.SchemeBearer] = auth.NewJWTVerifier(
+				keySet, cfg.Auth.Issuer, cfg.Auth.Audience, cfg.Auth.Leeway,
+			)
+		}
+		if cfg.TLS.ClientCAFile != "" {
+			verifiers[auth.SchemeClientCert] = auth.NewClientCertVerifier(cfg.Auth.ClientCertScopes)
+		}
+		secured = func(scopes ...string) endpoint.Middleware {
+			return endpoint.Chain(
+				middleware.Authentication(verifiers),
+				middleware.Authorization(scopes...),
+			)
+		}
+	}
+
+	// One limiter for the server: RATE_LIMIT is the total of all the routes.
+	limits := endpoint.Chain(
+		middleware.RateLimit(middleware.NewRateLimiter(settingsStore)),
+		middleware.Timeout(settingsStore),
+	)
+
+	serverOptions := []httptransport.ServerOption{
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerBefore(middleware.RequestID),
+		httptransport.ServerBefore(middleware.ClientCert),
+		httptransport.ServerBefore(middleware.Credentials),
+		httptransport.ServerBefore(middleware.DebugOverride(cfg.LogDebugToken)),
+		httptransport.ServerBefore(middleware.RequestLogging(logger, settingsStore)),
+		httptransport.ServerAfter(middleware.SetRequestID),
+		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(level.Error(logger))),
+		httptransport.ServerErrorEncoder(middleware.ErrorEncoder()),
+	}
+
 	createUserHandler := httptransport.NewServer(
 		middleware.Recovery(logger)(
-			middleware.MakeCreateUserEndpoint(svc),
+			limits(
+				secured(scopeUsersWrite)(
+					middleware.MakeCreateUserEndpoint(svc),
+				),
+			),
 		),
 		middleware.DecodingRecovery(logger)(
 			middleware.DecodeCreateUserRequest,
 		),
 		httptransport.EncodeJSONResponse,
-		httptransport.ServerBefore(middleware.RequestID),
-		httptransport.ServerBefore(middleware.RequestLogging(logger, cfg.Mode)),
-		httptransport.ServerAfter(middleware.SetRequestID),
-		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
-		httptransport.ServerErrorEncoder(middleware.ErrorEncoder()),
+		serverOptions...,
+	)
+
+	preconditions := middleware.Preconditions{RequireIfMatch: cfg.RequireIfMatch}
+
+	getUserHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersRead)(
+					middleware.MakeGetUserEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			middleware.DecodeGetUserRequest,
+		),
+		middleware.EncodeVersionedResponse,
+		serverOptions...,
+	)
+
+	updateUserHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersWrite)(
+					middleware.MakeUpdateUserEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			preconditions.DecodeUpdateUserRequest,
+		),
+		middleware.EncodeVersionedResponse,
+		serverOptions...,
+	)
+
+	deleteUserHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersWrite)(
+					middleware.MakeDeleteUserEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			preconditions.DecodeDeleteUserRequest,
+		),
+		httptransport.EncodeJSONResponse,
+		serverOptions...,
+	)
+
+	restoreUserHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersWrite)(
+					middleware.MakeRestoreUserEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			middleware.DecodeRestoreUserRequest,
+		),
+		middleware.EncodeVersionedResponse,
+		serverOptions...,
+	)
+
+	eraseUserHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersWrite)(
+					middleware.MakeEraseUserEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			middleware.DecodeEraseUserRequest,
+		),
+		httptransport.EncodeJSONResponse,
+		serverOptions...,
+	)
+
+	exportUserHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersRead)(
+					middleware.MakeExportUserEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			middleware.DecodeExportUserRequest,
+		),
+		middleware.EncodeUserArchive,
+		serverOptions...,
+	)
+
+	// The config requires CURSOR_SECRET in release mode.
+	cursorKey := []byte(cfg.CursorSecret)
+	if len(cursorKey) == 0 {
+		cursorKey = make([]byte, middleware.MinCursorKeyLen)
+		if _, err := rand.Read(cursorKey); err != nil {
+			level.Error(logger).Log("msg", "generating cursor key", "err", err)
+			return 1
+		}
+		level.Warn(logger).Log("msg", "CURSOR_SECRET not set, page cursors only work on this instance until it restarts")
+	}
+	cursorCodec, err := middleware.NewCursorCodec(cursorKey)
+	if err != nil {
+		level.Error(logger).Log("msg", "creating cursor codec", "err", err)
+		return 1
+	}
+
+	listUsersHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersRead)(
+					middleware.MakeListUsersEndpoint(svc, cursorCodec),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			middleware.DecodeListUsersRequest,
+		),
+		middleware.EncodeListUsersResponse,
+		serverOptions...,
+	)
+
+	searchUsersHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersRead)(
+					middleware.MakeSearchUsersEndpoint(svc, settingsStore),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			middleware.DecodeSearchUsersRequest,
+		),
+		httptransport.EncodeJSONResponse,
+		serverOptions...,
+	)
+
+	getProfileHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersRead)(
+					middleware.MakeGetProfileEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			middleware.DecodeGetProfileRequest,
+		),
+		middleware.EncodeVersionedResponse,
+		serverOptions...,
+	)
+
+	putProfileHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersWrite)(
+					middleware.MakePutProfileEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			preconditions.DecodePutProfileRequest,
+		),
+		middleware.EncodeVersionedResponse,
+		serverOptions...,
+	)
+
+	patchProfileHandler := httptransport.NewServer(
+		middleware.Recovery(logger)(
+			limits(
+				secured(scopeUsersWrite)(
+					middleware.MakePatchProfileEndpoint(svc),
+				),
+			),
+		),
+		middleware.DecodingRecovery(logger)(
+			preconditions.DecodePatchProfileRequest,
+		),
+		middleware.EncodeVersionedResponse,
+		serverOptions...,
 	)
 
 	server := &http.Server{
 		Addr: cfg.Port,
 	}
-	http.Handle("/createUser", createUserHandler)
-	http.Handle("/metrics", promhttp.Handler())
+	if cfg.TLS.Enabled() {
+		tlsCfg, err := newServerTLSConfig(cfg.TLS)
+		if err != nil {
+			level.Error(logger).Log("msg", "configuring tls", "err", err)
+			return 1
+		}
+		server.TLSConfig = tlsCfg
+		level.Info(logger).Log("msg", "tls enabled", "clientCA", cfg.TLS.ClientCAFile, "clientAuth", cfg.TLS.ClientAuth)
+	}
+	// Not the default mux, pprof and expvar register themselves there.
+	mux := http.NewServeMux()
+	mux.Handle("/createUser", createUserHandler)
+	mux.Handle("GET /getUser", getUserHandler)
+	mux.Handle("POST /updateUser", updateUserHandler)
+	mux.Handle("POST /deleteUser", deleteUserHandler)
+	mux.Handle("POST /restoreUser", restoreUserHandler)
+	mux.Handle("POST /eraseUser", eraseUserHandler)
+	mux.Handle("GET /exportUser", exportUserHandler)
+	mux.Handle("GET /listUsers", listUsersHandler)
+	mux.Handle("GET /searchUsers", searchUsersHandler)
+	mux.Handle("GET /users/{id}/profile", getProfileHandler)
+	mux.Handle("PUT /users/{id}/profile", putProfileHandler)
+	mux.Handle("PATCH /users/{id}/profile", patchProfileHandler)
+	server.Handler = mux
+
+	adm := &admin{logLevel: logLevel, config: cfgReloader.Config, checks: checks}
+	adminServer := &http.Server{
+		Addr:              cfg.AdminAddr,
+		Handler:           adm.Handler(),
+		ReadHeaderTimeout: adminReadHeaderTimeout,
+		ReadTimeout:       adminReadTimeout,
+		WriteTimeout:      adminWriteTimeout,
+		IdleTimeout:       adminIdleTimeout,
+	}
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	if cfg.AdminAddr != "" {
+		go func() {
+			level.Info(logger).Log("msg", "admin HTTP", "addr", cfg.AdminAddr)
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				level.Error(logger).Log("msg", "serving admin http", "err", err)
+			}
+		}()
+	}
+
 	go func() {
-		logger.Log("msg", "HTTP", "addr", cfg.Port)
+		level.Info(logger).Log("msg", "HTTP", "addr", cfg.Port, "tls", cfg.TLS.Enabled())
 
-		if err := server.ListenAndServe(); err != nil {
+		var err error
+		if cfg.TLS.Enabled() {
+			err = server.ListenAndServeTLS("", "") // Certificates come from TLSConfig
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
 			if errors.Is(err, http.ErrServerClosed) {
-				logger.Log("msg", "server closed")
+				level.Info(logger).Log("msg", "server closed")
 			} else {
-				logger.Log("err", err)
+				level.Error(logger).Log("msg", "serving http", "err", err)
 			}
 		}
 		sigs <- syscall.SIGUSR1 // Reusing the channel
 	}()
 
 	s := <-sigs
-	logger.Log("msg", "got signal", "signal", s)
+	level.Info(logger).Log("msg", "got signal", "signal", s)
+	// The load balancer sees /readyz failing and stops routing requests
+	// while the public server still serves, the admin server stops last.
+	adm.draining.Store(true)
+	if s != syscall.SIGUSR1 { // Not when the server already stopped
+		time.Sleep(cfg.DrainDelay)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
-	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Log("msg", "server shutting down", "err", err)
+	if err := shutdown(server, settingsStore.Load().ShutdownTimeout); err != nil {
+		level.Error(logger).Log("msg", "server shutting down", "err", err)
+	}
+	if err := shutdown(adminServer, adminShutdownTimeout); err != nil {
+		level.Error(logger).Log("msg", "admin server shutting down", "err", err)
 	}
+	return 0
 }
 
-func composePostgresURL(cfg PostgresConfig) string {
-	return fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?connect_timeout=%d&pool_max_conns=%d&application_name=%s&sslmode=disable",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database, cfg.Timeout, poolMaxConns, appName,
-	)
+func shutdown(server *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return server.Shutdown(ctx)
 }
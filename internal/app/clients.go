@@ -0,0 +1,319 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"ws-dummy-go/internal/dummy"
+	"ws-dummy-go/internal/pii"
+	"ws-dummy-go/internal/secrets"
+)
+
+// NewPostgresPool connects to Postgres and pings it. If the password is
+// a secret, new connections use its current value.
+func NewPostgresPool(ctx context.Context, cfg PostgresConfig, name string, password *secrets.Secret) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(PostgresURL(cfg, name))
+	if err != nil {
+		return nil, fmt.Errorf("parsing postgres config: %w", err)
+	}
+	poolCfg.MinConns = cfg.MinConns
+	poolCfg.MaxConns = cfg.MaxConns
+	poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	if password != nil {
+		poolCfg.BeforeConnect = func(_ context.Context, cc *pgx.ConnConfig) error {
+			cc.Password = password.Value()
+			return nil
+		}
+	}
+
+	if cfg.TLS.ServerName != "" {
+		// Not expressible in the URL, pgx defaults it to the host.
+		if poolCfg.ConnConfig.TLSConfig != nil {
+			poolCfg.ConnConfig.TLSConfig.ServerName = cfg.TLS.ServerName
+		}
+		for _, fb := range poolCfg.ConnConfig.Fallbacks {
+			if fb.TLSConfig != nil {
+				fb.TLSConfig.ServerName = cfg.TLS.ServerName
+			}
+		}
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("pinging postgres: %w", err)
+	}
+	return pool, nil
+}
+
+// PostgresURL composes a libpq-compatible connection URL with escaped credentials.
+// It is understood by both pgx and the migrate postgres driver.
+func PostgresURL(cfg PostgresConfig, name string) string {
+	q := url.Values{}
+	q.Set("connect_timeout", strconv.Itoa(int(cfg.Timeout.Seconds())))
+	q.Set("application_name", name)
+	q.Set("sslmode", cfg.SSLMode)
+	if cfg.TLS.CAFile != "" {
+		q.Set("sslrootcert", cfg.TLS.CAFile)
+	}
+	if cfg.TLS.CertFile != "" {
+		q.Set("sslcert", cfg.TLS.CertFile)
+	}
+	if cfg.TLS.KeyFile != "" {
+		q.Set("sslkey", cfg.TLS.KeyFile)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		Path:     "/" + cfg.Database,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// NewRedisClient connects to Redis and pings it. If the password is
+// a secret, new connections use its current value.
+func NewRedisClient(ctx context.Context, cfg RedisConfig, password *secrets.Secret) (*redis.Client, error) {
+	opts := &redis.Options{
+		Addr:         net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+	}
+	if password != nil {
+		opts.CredentialsProvider = func() (string, string) {
+			return "", password.Value()
+		}
+	}
+	if cfg.TLSEnabled {
+		tlsCfg, err := newClientTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("configuring redis tls: %w", err)
+		}
+		opts.TLSConfig = tlsCfg
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("pinging redis: %w", err)
+	}
+	return client, nil
+}
+
+// redisGrace is how long a replaced Redis client is kept open, for the
+// commands running on it.
+const redisGrace = 30 * time.Second
+
+// RedisConn is a Redis client replaced by a new one when the password
+// rotates: the connections are authenticated once, when dialed, and
+// go-redis can't reset its pool.
+type RedisConn struct {
+	cfg      RedisConfig
+	password *secrets.Secret
+	client   atomic.Pointer[redis.Client]
+	mu       sync.Mutex // one reconnection at a time
+}
+
+// NewRedisConn connects to Redis. If the password is a secret, the
+// clients use its current value.
+func NewRedisConn(ctx context.Context, cfg RedisConfig, password *secrets.Secret) (*RedisConn, error) {
+	c := &RedisConn{cfg: cfg, password: password}
+	client, err := NewRedisClient(ctx, cfg, password)
+	if err != nil {
+		return nil, err
+	}
+	c.client.Store(client)
+	return c, nil
+}
+
+// Client returns the current client.
+func (c *RedisConn) Client() *redis.Client {
+	return c.client.Load()
+}
+
+// Reconnect connects a new client and closes the replaced one after
+// redisGrace. On error the current client is kept.
+func (c *RedisConn) Reconnect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, err := NewRedisClient(ctx, c.cfg, c.password)
+	if err != nil {
+		return err
+	}
+	old := c.client.Swap(client)
+	time.AfterFunc(redisGrace, func() {
+		_ = old.Close() // fails only if closed already
+	})
+	return nil
+}
+
+// Close closes the current client.
+func (c *RedisConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Client().Close()
+}
+
+// NewMongoClient connects to MongoDB and pings it.
+func NewMongoClient(ctx context.Context, cfg MongoConfig) (*mongo.Client, error) {
+	u := url.URL{
+		Scheme: "mongodb",
+		User:   url.UserPassword(cfg.Username, cfg.Password),
+		Host:   net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		Path:   "/" + cfg.Database,
+	}
+	opts := options.Client().
+		ApplyURI(u.String()).
+		SetConnectTimeout(cfg.Timeout).
+		SetServerSelectionTimeout(cfg.Timeout).
+		SetTimeout(cfg.Timeout).
+		SetMinPoolSize(cfg.MinPoolSize).
+		SetMaxPoolSize(cfg.MaxPoolSize).
+		SetAppName(cfg.AppName)
+
+	if cfg.TLSEnabled {
+		tlsCfg, err := newClientTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("configuring mongodb tls: %w", err)
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to mongodb: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, fmt.Errorf("pinging mongodb: %w", err)
+	}
+	return client, nil
+}
+
+// MongoConn is a MongoDB client replaced by a new one when the password
+// rotates, the driver can't change the credentials of a connected client.
+type MongoConn struct {
+	cfg      MongoConfig
+	password *secrets.Secret
+	client   atomic.Pointer[mongo.Client]
+	mu       sync.Mutex // one reconnection at a time
+}
+
+// NewMongoConn connects to MongoDB. If the password is a secret,
+// Reconnect uses its current value.
+func NewMongoConn(ctx context.Context, cfg MongoConfig, password *secrets.Secret) (*MongoConn, error) {
+	c := &MongoConn{cfg: cfg, password: password}
+	client, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.client.Store(client)
+	return c, nil
+}
+
+func (c *MongoConn) connect(ctx context.Context) (*mongo.Client, error) {
+	cfg := c.cfg
+	if c.password != nil {
+		cfg.Password = c.password.Value()
+	}
+	return NewMongoClient(ctx, cfg)
+}
+
+// Client returns the current client.
+func (c *MongoConn) Client() *mongo.Client {
+	return c.client.Load()
+}
+
+// Collection returns the collection of the current client.
+func (c *MongoConn) Collection(name string) dummy.Collection {
+	return func() *mongo.Collection {
+		return c.Client().Database(c.cfg.Database).Collection(name)
+	}
+}
+
+// Reconnect connects a new client and disconnects the replaced one, after
+// the operations running on it are done or ctx is. On error the current
+// client is kept.
+func (c *MongoConn) Reconnect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	old := c.client.Swap(client)
+	if err := old.Disconnect(ctx); err != nil {
+		return fmt.Errorf("disconnecting the replaced client: %w", err)
+	}
+	return nil
+}
+
+// Disconnect disconnects the current client.
+func (c *MongoConn) Disconnect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Client().Disconnect(ctx)
+}
+
+func newClientTLSConfig(cfg ClientTLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client key pair: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// NewCipher opens the keyring of the key file, creating the keys on the
+// first run. Without a key file the names are stored as plaintext.
+func NewCipher(ctx context.Context, cfg PIIConfig, pool *pgxpool.Pool) (pii.Cipher, error) {
+	if cfg.KeyFile == "" {
+		return pii.NewPlaintext(), nil
+	}
+	master, err := pii.LoadKeyFile(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := pii.OpenKeyring(ctx, master, pii.NewKeysSQLRepo(pool))
+	if err != nil {
+		return nil, fmt.Errorf("opening keyring: %w", err)
+	}
+	return keyring, nil
+}
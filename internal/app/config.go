@@ -1,57 +1,193 @@
 package app
 
 import (
-	"fmt"
 	"time"
 
-	"github.com/caarlos0/env/v6"
-	"github.com/joho/godotenv"
+	"github.com/go-playground/validator/v10"
 )
 
+var (
+	validate = validator.New(validator.WithRequiredStructEnabled())
+)
+
+// Config fields tagged secret:"true" may also be given as <KEY>_FILE,
+// or be left out and resolved by the secrets provider. Fields tagged
+// reload:"true" are applied on reload without a restart.
 type Config struct {
-	Port    string        `env:"PORT" envDefault:":8080"`
-	Mode    string        `env:"MODE" envDefault:"debug"`
-	Timeout time.Duration `env:"TIMEOUT"`
+	Port    string        `env:"PORT" envDefault:":8080" validate:"hostname_port"`
+	Mode    string        `env:"MODE" envDefault:"debug" validate:"oneof=debug release" reload:"true"`
+	Timeout time.Duration `env:"TIMEOUT" validate:"gt=0s" reload:"true"` // graceful shutdown
+	// How long /readyz reports draining before the server stops taking requests.
+	DrainDelay time.Duration `env:"DRAIN_DELAY" envDefault:"0s" validate:"gte=0s"`
+
+	// Metrics, pprof, health and the other operational endpoints, empty = off.
+	AdminAddr string `env:"ADMIN_ADDR" envDefault:"127.0.0.1:8081" validate:"omitempty,hostname_port"`
+
+	LogLevel  string `env:"LOG_LEVEL" envDefault:"info" validate:"oneof=debug info warn error" reload:"true"`
+	LogFormat string `env:"LOG_FORMAT" envDefault:"logfmt" validate:"oneof=logfmt json"`
+	// Requests with the X-Debug-Log header set to the token are logged at debug level, empty = off.
+	LogDebugToken string `env:"LOG_DEBUG_TOKEN,unset" envDefault:"" secret:"true"`
+
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" envDefault:"30s" validate:"gt=0s" reload:"true"`
+	RateLimit      float64       `env:"RATE_LIMIT" envDefault:"0" validate:"gte=0" reload:"true"` // requests per second, 0 = unlimited
+	RateBurst      int           `env:"RATE_BURST" envDefault:"20" validate:"gte=1" reload:"true"`
+	Features       []string      `env:"FEATURES" envDefault:"" reload:"true"`
+
+	// Default for the searches that don't give one, trigram similarity from 0 to 1.
+	SearchMinSimilarity float64 `env:"SEARCH_MIN_SIMILARITY" envDefault:"0.3" validate:"gt=0,lte=1" reload:"true"`
+
+	// Signs the page cursors, shared by the replicas, at least 32 bytes.
+	// Required in release mode, random per process if empty in debug mode.
+	CursorSecret string `env:"CURSOR_SECRET,unset" envDefault:"" secret:"true" validate:"required_if=Mode release,omitempty,min=32"`
+
+	// Changes without an If-Match get 428 Precondition Required, else they
+	// apply to any version.
+	RequireIfMatch bool `env:"REQUIRE_IF_MATCH" envDefault:"true"`
+
+	// Besides SIGHUP, the config files are checked for changes every interval, 0 = never.
+	ConfigWatchInterval time.Duration `env:"CONFIG_WATCH_INTERVAL" envDefault:"0s" validate:"gte=0s"`
+
+	// Apply the embedded migrations on startup, one replica at a time.
+	AutoMigrate bool `env:"AUTO_MIGRATE" envDefault:"false"`
+	// How long a replica waits for another one to finish migrating.
+	AutoMigrateLockTimeout time.Duration `env:"AUTO_MIGRATE_LOCK_TIMEOUT" envDefault:"5m" validate:"gt=0s"`
+
+	// Resolve the database hosts on startup too, not only with -check-config.
+	CheckHosts bool `env:"CHECK_HOSTS" envDefault:"false"`
 
 	Postgres PostgresConfig
 	Redis    RedisConfig
 	Mongo    MongoConfig
+	Cache    CacheConfig
+	Profile  ProfileConfig
+	Purge    PurgeConfig
+	PII      PIIConfig
+	Auth     AuthConfig
+	TLS      TLSConfig
+	Secrets  SecretsConfig
 }
 
 type PostgresConfig struct {
-	Host     string        `env:"POSTGRES_HOST"`
-	Port     uint16        `env:"POSTGRES_PORT"`
+	Host     string        `env:"POSTGRES_HOST" validate:"required"`
+	Port     uint16        `env:"POSTGRES_PORT" validate:"gt=0"`
 	User     string        `env:"POSTGRES_USER"`
-	Password string        `env:"POSTGRES_PASSWORD,unset"`
-	Database string        `env:"POSTGRES_DATABASE"`
-	Timeout  time.Duration `env:"POSTGRES_TIMEOUT"`
+	Password string        `env:"POSTGRES_PASSWORD,unset" secret:"true"`
+	Database string        `env:"POSTGRES_DATABASE" validate:"required"`
+	Timeout  time.Duration `env:"POSTGRES_TIMEOUT" validate:"gt=0s"`
+
+	SSLMode string          `env:"POSTGRES_SSLMODE" envDefault:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	TLS     ClientTLSConfig `envPrefix:"POSTGRES_TLS_"`
+
+	MinConns          int32         `env:"POSTGRES_MIN_CONNS" envDefault:"0" validate:"gte=0,ltefield=MaxConns"`
+	MaxConns          int32         `env:"POSTGRES_MAX_CONNS" envDefault:"10" validate:"gte=1"`
+	MaxConnLifetime   time.Duration `env:"POSTGRES_MAX_CONN_LIFETIME" envDefault:"1h" validate:"gt=0s"`
+	MaxConnIdleTime   time.Duration `env:"POSTGRES_MAX_CONN_IDLE_TIME" envDefault:"30m" validate:"gt=0s"`
+	HealthCheckPeriod time.Duration `env:"POSTGRES_HEALTH_CHECK_PERIOD" envDefault:"1m" validate:"gt=0s"`
 }
 
 type RedisConfig struct {
-	Host     string        `env:"REDIS_HOST"`
-	Port     uint16        `env:"REDIS_PORT"`
-	Password string        `env:"REDIS_PASSWORD,unset"`
-	Timeout  time.Duration `env:"REDIS_TIMEOUT"`
+	Host     string        `env:"REDIS_HOST" validate:"required"`
+	Port     uint16        `env:"REDIS_PORT" validate:"gt=0"`
+	Password string        `env:"REDIS_PASSWORD,unset" secret:"true"`
+	Timeout  time.Duration `env:"REDIS_TIMEOUT" validate:"gt=0s"`
+
+	TLSEnabled bool            `env:"REDIS_TLS_ENABLED" envDefault:"false"`
+	TLS        ClientTLSConfig `envPrefix:"REDIS_TLS_"`
+
+	DB           int           `env:"REDIS_DB" envDefault:"0" validate:"gte=0,lte=15"`
+	PoolSize     int           `env:"REDIS_POOL_SIZE" envDefault:"20" validate:"gte=1"`
+	MinIdleConns int           `env:"REDIS_MIN_IDLE_CONNS" envDefault:"2" validate:"gte=0,ltefield=PoolSize"`
+	ReadTimeout  time.Duration `env:"REDIS_READ_TIMEOUT" envDefault:"3s" validate:"gt=0s"`
+	WriteTimeout time.Duration `env:"REDIS_WRITE_TIMEOUT" envDefault:"3s" validate:"gt=0s"`
+
+	// The keys are prefixed <app>:<env>:v<schema version>:.
+	KeyApp  string        `env:"REDIS_KEY_APP" envDefault:"ws-dummy-go" validate:"required,excludesall=:*?[]"`
+	KeyEnv  string        `env:"REDIS_KEY_ENV" envDefault:"dev" validate:"required,excludesall=:*?[]"`
+	UserTTL time.Duration `env:"REDIS_USER_TTL" envDefault:"720h" validate:"gte=0s"` // users and the name index, 0 = no expiry
 }
 
 type MongoConfig struct {
-	Host     string        `env:"MONGO_HOST"`
-	Port     uint16        `env:"MONGO_PORT"`
+	Host     string        `env:"MONGO_HOST" validate:"required"`
+	Port     uint16        `env:"MONGO_PORT" validate:"gt=0"`
 	Username string        `env:"MONGO_USERNAME"`
-	Password string        `env:"MONGO_PASSWORD,unset"`
-	Database string        `env:"MONGO_DATABASE"`
-	Timeout  time.Duration `env:"MONGO_TIMEOUT"`
-}
-
-func LoadConfig(filename string) (*Config, error) {
-	if err := godotenv.Load(filename); err != nil {
-		return nil, fmt.Errorf("loading file: %w", err)
-	}
-	var cfg Config
-	opts := env.Options{RequiredIfNoDef: true}
-
-	if err := env.Parse(&cfg, opts); err != nil {
-		return nil, fmt.Errorf("parsing config: %w", err)
-	}
-	return &cfg, nil
+	Password string        `env:"MONGO_PASSWORD,unset" secret:"true"`
+	Database string        `env:"MONGO_DATABASE" validate:"required"`
+	Timeout  time.Duration `env:"MONGO_TIMEOUT" validate:"gt=0s"`
+
+	TLSEnabled bool            `env:"MONGO_TLS_ENABLED" envDefault:"false"`
+	TLS        ClientTLSConfig `envPrefix:"MONGO_TLS_"`
+
+	MinPoolSize uint64 `env:"MONGO_MIN_POOL_SIZE" envDefault:"0" validate:"ltefield=MaxPoolSize"`
+	MaxPoolSize uint64 `env:"MONGO_MAX_POOL_SIZE" envDefault:"100" validate:"gte=1"`
+	AppName     string `env:"MONGO_APP_NAME" envDefault:"ws-dummy-go"`
+}
+
+// CacheConfig is for the users cached in Redis by GetUser.
+type CacheConfig struct {
+	UserTTL         time.Duration `env:"CACHE_USER_TTL" envDefault:"5m" validate:"gt=0s"`
+	UserTTLJitter   time.Duration `env:"CACHE_USER_TTL_JITTER" envDefault:"30s" validate:"gte=0s"`   // random extra TTL
+	UserNegativeTTL time.Duration `env:"CACHE_USER_NEGATIVE_TTL" envDefault:"30s" validate:"gte=0s"` // not found users, 0 = not cached
+}
+
+// ProfileConfig limits the user profiles stored in Mongo.
+type ProfileConfig struct {
+	MaxBytes int `env:"PROFILE_MAX_BYTES" envDefault:"16384" validate:"gte=1024,lte=1048576"` // BSON size of the data
+	MaxDepth int `env:"PROFILE_MAX_DEPTH" envDefault:"10" validate:"gte=1,lte=50"`            // nested objects and arrays
+}
+
+// PurgeConfig is for the soft deleted users, restorable until purged.
+type PurgeConfig struct {
+	Retention time.Duration `env:"PURGE_RETENTION" envDefault:"720h" validate:"gt=0s"`
+	Interval  time.Duration `env:"PURGE_INTERVAL" envDefault:"1h" validate:"gte=0s"` // 0 = never purged
+	Batch     int           `env:"PURGE_BATCH" envDefault:"100" validate:"gte=1,lte=10000"`
+}
+
+// PIIConfig enables the encryption of the user names, with the master
+// keys of the key file. See the pii command.
+type PIIConfig struct {
+	KeyFile string `env:"PII_KEY_FILE" envDefault:""` // empty = stored as plaintext
+}
+
+// ClientTLSConfig holds TLS options for connections to the databases.
+// Empty values fall back to the system roots and the host name.
+type ClientTLSConfig struct {
+	CAFile     string `env:"CA_FILE" envDefault:""`
+	CertFile   string `env:"CERT_FILE" envDefault:""`
+	KeyFile    string `env:"KEY_FILE" envDefault:""`
+	ServerName string `env:"SERVER_NAME" envDefault:""`
+}
+
+type AuthConfig struct {
+	Enabled     bool          `env:"AUTH_ENABLED" envDefault:"false"`
+	JWKSSource  string        `env:"AUTH_JWKS_SOURCE" envDefault:""` // file path or URL
+	JWKSRefresh time.Duration `env:"AUTH_JWKS_REFRESH" envDefault:"5m" validate:"gt=0s"`
+	Issuer      string        `env:"AUTH_ISSUER" envDefault:""`
+	Audience    string        `env:"AUTH_AUDIENCE" envDefault:""`
+	Leeway      time.Duration `env:"AUTH_LEEWAY" envDefault:"30s" validate:"gte=0s"`
+	Timeout     time.Duration `env:"AUTH_TIMEOUT" envDefault:"5s" validate:"gt=0s"`
+
+	APIKeys         bool          `env:"AUTH_API_KEYS" envDefault:"true"`
+	APIKeysCacheTTL time.Duration `env:"AUTH_API_KEYS_CACHE_TTL" envDefault:"30s" validate:"gte=0s"`
+
+	// Granted to callers authenticated by a TLS client certificate.
+	ClientCertScopes []string `env:"AUTH_CLIENT_CERT_SCOPES" envDefault:""`
+}
+
+type TLSConfig struct {
+	CertFile       string        `env:"TLS_CERT_FILE" envDefault:""`
+	KeyFile        string        `env:"TLS_KEY_FILE" envDefault:"" validate:"required_with=CertFile"`
+	ClientCAFile   string        `env:"TLS_CLIENT_CA_FILE" envDefault:""`
+	ClientAuth     string        `env:"TLS_CLIENT_AUTH" envDefault:"require" validate:"oneof=require verify-if-given"`
+	ReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" envDefault:"10s" validate:"gt=0s"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type SecretsConfig struct {
+	Provider string        `env:"SECRETS_PROVIDER" envDefault:"" validate:"omitempty,oneof=encrypted-file"`
+	File     string        `env:"SECRETS_FILE" envDefault:""`
+	KeyFile  string        `env:"SECRETS_KEY_FILE" envDefault:""`
+	Refresh  time.Duration `env:"SECRETS_REFRESH" envDefault:"1m" validate:"gt=0s"`
 }
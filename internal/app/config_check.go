@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const maskedSecret = "******"
+
+// checkHostsTimeout bounds the resolution of all the hosts, a slow DNS
+// must not stall the startup.
+const checkHostsTimeout = 3 * time.Second
+
+// CheckHosts resolves the database hosts, so typos are reported before
+// the first connection attempt.
+func CheckHosts(ctx context.Context, cfg *Config) error {
+	ctx, cancel := context.WithTimeout(ctx, checkHostsTimeout)
+	defer cancel()
+
+	hosts := []struct{ key, host string }{
+		{"POSTGRES_HOST", cfg.Postgres.Host},
+		{"REDIS_HOST", cfg.Redis.Host},
+		{"MONGO_HOST", cfg.Mongo.Host},
+	}
+	var problems []string
+	for _, h := range hosts {
+		if net.ParseIP(h.host) != nil {
+			continue
+		}
+		if _, err := net.DefaultResolver.LookupHost(ctx, h.host); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: can't resolve %q: %v", h.key, h.host, err))
+		}
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// PrintConfig writes the effective config as YAML or JSON with the secrets
+// masked. The keys are the env keys, so the output is a valid config file.
+func PrintConfig(w io.Writer, cfg *Config, format string) error {
+	values := map[string]any{}
+	root := reflect.ValueOf(*cfg)
+	for _, f := range configFields(root.Type(), "") {
+		values[f.Key] = printableValue(fieldValue(root, f.Path), f.Secret)
+	}
+
+	switch format {
+	case "yaml":
+		// Sorted by yaml.v3, like the JSON keys.
+		return yaml.NewEncoder(w).Encode(values)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+	default:
+		return fmt.Errorf("unsupported format %q, expected yaml or json", format)
+	}
+}
+
+// fieldValue walks the Go field path, e.g. Config.Postgres.Host.
+func fieldValue(v reflect.Value, path string) reflect.Value {
+	names := strings.Split(path, ".")[1:]
+	for _, n := range names {
+		v = v.FieldByName(n)
+	}
+	return v
+}
+
+func printableValue(v reflect.Value, secret bool) any {
+	if secret {
+		if v.String() == "" {
+			return ""
+		}
+		return maskedSecret
+	}
+	switch vv := v.Interface().(type) {
+	case time.Duration:
+		return vv.String()
+	case []string:
+		if vv == nil {
+			return []string{}
+		}
+		return vv
+	default:
+		return vv
+	}
+}
@@ -0,0 +1,382 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+
+	"ws-dummy-go/internal/secrets"
+)
+
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnvFile = "env-file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+	SourceSecret  = "secret"
+
+	secretFileSuffix = "_FILE"
+)
+
+// ConfigSources tells where to load the config from. Layers are applied in
+// order, later ones win: built-in defaults, the YAML/JSON file, the .env file,
+// the process environment and the -set flags. Both files are optional.
+type ConfigSources struct {
+	File      string
+	EnvFile   string
+	Overrides map[string]string
+}
+
+// ConfigReport tells which source each config value came from.
+type ConfigReport struct {
+	Origins map[string]string
+	Skipped []string // optional files that don't exist
+	// Secrets resolved from files or the secrets provider, to be watched for rotation.
+	Secrets []*secrets.Secret
+}
+
+// ByOrigin groups config keys by their source, for logging.
+func (r ConfigReport) ByOrigin() map[string][]string {
+	res := map[string][]string{}
+	for k, o := range r.Origins {
+		res[o] = append(res[o], k)
+	}
+	for _, keys := range res {
+		sort.Strings(keys)
+	}
+	return res
+}
+
+// ConfigError lists every missing or invalid config value at once.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// RegisterConfigFlags adds the config flags to the flag set.
+func RegisterConfigFlags(flags *flag.FlagSet) *ConfigSources {
+	src := &ConfigSources{Overrides: map[string]string{}}
+
+	flags.Func("config", "config file: YAML/JSON, or .env for backward compatibility", func(s string) error {
+		if strings.HasSuffix(s, ".env") {
+			src.EnvFile = s
+		} else {
+			src.File = s
+		}
+		return nil
+	})
+	flags.StringVar(&src.EnvFile, "env-file", ".env", "optional .env file")
+	flags.Func("set", "override a config value, KEY=VALUE or section.key=value, repeatable", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok {
+			return errors.New("expected KEY=VALUE")
+		}
+		src.Overrides[normalizeKey(k)] = v
+		return nil
+	})
+	return src
+}
+
+// LoadConfig loads and validates the layered config.
+func LoadConfig(src ConfigSources) (*Config, ConfigReport, error) {
+	report := ConfigReport{Origins: map[string]string{}}
+	fields := configFields(reflect.TypeOf(Config{}), "")
+	known := map[string]configField{}
+	for _, f := range fields {
+		known[f.Key] = f
+		if f.Secret {
+			known[f.Key+secretFileSuffix] = f
+		}
+	}
+
+	var problems []string
+	values := map[string]string{}
+
+	apply := func(source string, layer map[string]string, strict bool) {
+		for k, v := range layer {
+			if _, ok := known[k]; !ok {
+				if strict {
+					problems = append(problems, fmt.Sprintf("%s: unknown key %s", source, k))
+				}
+				continue
+			}
+			values[k] = v
+			report.Origins[k] = source
+		}
+	}
+
+	if src.File != "" {
+		layer, err := readConfigFile(src.File)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			report.Skipped = append(report.Skipped, src.File)
+		case err != nil:
+			return nil, report, fmt.Errorf("reading config file: %w", err)
+		default:
+			apply(SourceFile, layer, true)
+		}
+	}
+	if src.EnvFile != "" {
+		layer, err := godotenv.Read(src.EnvFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			report.Skipped = append(report.Skipped, src.EnvFile)
+		case err != nil:
+			return nil, report, fmt.Errorf("reading env file: %w", err)
+		default:
+			apply(SourceEnvFile, layer, true)
+		}
+	}
+	apply(SourceEnv, environ(), false)
+	apply(SourceFlag, src.Overrides, true)
+
+	problems = append(problems, resolveSecrets(fields, values, &report)...)
+
+	reported := map[string]bool{}
+	for _, f := range fields {
+		v, ok := values[f.Key]
+		if !ok && !f.HasDefault {
+			problems = append(problems, fmt.Sprintf("%s is required", f.Key))
+			reported[f.Key] = true
+		}
+		if ok && !f.parses(v) {
+			problems = append(problems, fmt.Sprintf("%s: invalid %s value %q", f.Key, f.Type, v))
+			reported[f.Key] = true
+			// Dropped, so the rest of the config can still be parsed and validated.
+			delete(values, f.Key)
+		}
+	}
+
+	var cfg Config
+	opts := env.Options{
+		Environment: values,
+		OnSet: func(key string, _ interface{}, isDefault bool) {
+			if isDefault {
+				report.Origins[key] = SourceDefault
+			}
+		},
+	}
+	if err := env.Parse(&cfg, opts); err != nil {
+		return nil, report, fmt.Errorf("parsing config: %w", err)
+	}
+	if err := validate.Struct(cfg); err != nil {
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) {
+			return nil, report, fmt.Errorf("validating config: %w", err)
+		}
+		for _, fe := range verrs {
+			key := fieldKey(fields, fe.StructNamespace())
+			if reported[key] {
+				continue
+			}
+			rule := fe.Tag()
+			if fe.Param() != "" {
+				rule += "=" + fe.Param()
+			}
+			problems = append(problems, fmt.Sprintf("%s must satisfy %s", key, rule))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, report, &ConfigError{Problems: problems}
+	}
+	return &cfg, report, nil
+}
+
+// LogConfigReport logs skipped files and the keys taken from each source.
+func LogConfigReport(logger log.Logger, report ConfigReport) {
+	for _, f := range report.Skipped {
+		level.Warn(logger).Log("msg", "config file not found, skipped", "file", f)
+	}
+	byOrigin := report.ByOrigin()
+	for _, origin := range []string{SourceDefault, SourceFile, SourceEnvFile, SourceEnv, SourceFlag, SourceSecret} {
+		if keys, ok := byOrigin[origin]; ok {
+			level.Debug(logger).Log("msg", "config source", "source", origin, "keys", strings.Join(keys, ","))
+		}
+	}
+}
+
+// resolveSecrets replaces <KEY>_FILE values with the file contents and fills
+// in the secrets missing from the other sources from the secrets provider.
+func resolveSecrets(fields []configField, values map[string]string, report *ConfigReport) []string {
+	var problems []string
+
+	var provider secrets.Provider
+	if values["SECRETS_PROVIDER"] == "encrypted-file" {
+		key, err := secrets.ReadKeyFile(values["SECRETS_KEY_FILE"])
+		if err != nil {
+			return []string{fmt.Sprintf("SECRETS_KEY_FILE: %v", err)}
+		}
+		provider = secrets.NewEncryptedFileProvider(values["SECRETS_FILE"], key)
+	}
+
+	for _, f := range fields {
+		if !f.Secret {
+			continue
+		}
+		fileKey := f.Key + secretFileSuffix
+		path, fromFile := values[fileKey]
+		_, plain := values[f.Key]
+
+		var s *secrets.Secret
+		switch {
+		case fromFile && plain:
+			problems = append(problems, fmt.Sprintf("%s and %s are mutually exclusive", f.Key, fileKey))
+			continue
+		case fromFile:
+			s = secrets.NewSecret(f.Key, secrets.NewFileProvider(), path)
+			delete(values, fileKey)
+			delete(report.Origins, fileKey)
+		case !plain && provider != nil:
+			s = secrets.NewSecret(f.Key, provider, f.Key)
+		default:
+			continue
+		}
+		if _, err := s.Refresh(context.Background()); err != nil {
+			// Optional secrets don't have to be kept by the provider.
+			if !fromFile && f.HasDefault && errors.Is(err, secrets.ErrNotFound) {
+				continue
+			}
+			problems = append(problems, err.Error())
+			continue
+		}
+		values[f.Key] = s.Value()
+		report.Origins[f.Key] = SourceSecret
+		report.Secrets = append(report.Secrets, s)
+	}
+	return problems
+}
+
+// configField is a leaf of the Config struct with its env key.
+type configField struct {
+	Key        string
+	Path       string // Go field path, e.g. Config.Postgres.Host
+	HasDefault bool
+	Secret     bool
+	Reload     bool
+	Type       reflect.Type
+	Tag        reflect.StructTag
+}
+
+// parses checks the value alone, so every bad value is reported by its key.
+func (f configField) parses(value string) bool {
+	tag := `env:"V"`
+	if sep, ok := f.Tag.Lookup("envSeparator"); ok {
+		tag += fmt.Sprintf(` envSeparator:%q`, sep)
+	}
+	single := reflect.New(reflect.StructOf([]reflect.StructField{
+		{Name: "V", Type: f.Type, Tag: reflect.StructTag(tag)},
+	}))
+	return env.Parse(single.Interface(), env.Options{Environment: map[string]string{"V": value}}) == nil
+}
+
+func configFields(t reflect.Type, prefix string) []configField {
+	return collectFields(t, prefix, t.Name())
+}
+
+func collectFields(t reflect.Type, prefix, path string) []configField {
+	var res []configField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if tag, ok := sf.Tag.Lookup("env"); ok {
+			key, _, _ := strings.Cut(tag, ",")
+			_, hasDefault := sf.Tag.Lookup("envDefault")
+			res = append(res, configField{
+				Key:        prefix + key,
+				Path:       path + "." + sf.Name,
+				HasDefault: hasDefault,
+				Secret:     sf.Tag.Get("secret") == "true",
+				Reload:     sf.Tag.Get("reload") == "true",
+				Type:       sf.Type,
+				Tag:        sf.Tag,
+			})
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct {
+			res = append(res, collectFields(sf.Type, prefix+sf.Tag.Get("envPrefix"), path+"."+sf.Name)...)
+		}
+	}
+	return res
+}
+
+func fieldKey(fields []configField, path string) string {
+	for _, f := range fields {
+		if f.Path == path {
+			return f.Key
+		}
+	}
+	return path
+}
+
+// readConfigFile reads a YAML or JSON file. Nested sections are flattened
+// into env keys: {postgres: {max_conns: 10}} becomes POSTGRES_MAX_CONNS=10.
+func readConfigFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json": // JSON is valid YAML
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q", ext)
+	}
+	var tree map[string]any
+	if err := yaml.Unmarshal(raw, &tree); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	res := map[string]string{}
+	flatten(tree, "", res)
+	return res, nil
+}
+
+func flatten(tree map[string]any, prefix string, res map[string]string) {
+	for k, v := range tree {
+		key := prefix + normalizeKey(k)
+		switch vv := v.(type) {
+		case map[string]any:
+			flatten(vv, key+"_", res)
+		case []any:
+			items := make([]string, 0, len(vv))
+			for _, item := range vv {
+				items = append(items, fmt.Sprint(item))
+			}
+			res[key] = strings.Join(items, ",")
+		case nil:
+			res[key] = ""
+		default:
+			res[key] = fmt.Sprint(vv)
+		}
+	}
+}
+
+func normalizeKey(k string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(k))
+}
+
+func environ() map[string]string {
+	res := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			res[k] = v
+		}
+	}
+	return res
+}
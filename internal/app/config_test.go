@@ -0,0 +1,331 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/secrets"
+)
+
+func writeTestEnv(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "test.env")
+	content := `TIMEOUT=5s
+POSTGRES_HOST=localhost
+POSTGRES_PORT=5432
+POSTGRES_USER=user
+POSTGRES_PASSWORD=pass
+POSTGRES_DATABASE=dummy
+POSTGRES_TIMEOUT=5s
+REDIS_HOST=localhost
+REDIS_PORT=6379
+REDIS_PASSWORD=pass
+REDIS_TIMEOUT=5s
+MONGO_HOST=localhost
+MONGO_PORT=27017
+MONGO_USERNAME=user
+MONGO_PASSWORD=pass
+MONGO_DATABASE=dummy
+MONGO_TIMEOUT=5s
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig_Pools(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(*assert.Assertions, *Config)
+		wantErr bool
+	}{
+		{
+			name: "Positive: Defaults",
+			check: func(assert *assert.Assertions, cfg *Config) {
+				assert.Equal(int32(10), cfg.Postgres.MaxConns)
+				assert.Equal(time.Hour, cfg.Postgres.MaxConnLifetime)
+				assert.Equal(0, cfg.Redis.DB)
+				assert.Equal(20, cfg.Redis.PoolSize)
+				assert.Equal(uint64(100), cfg.Mongo.MaxPoolSize)
+				assert.Equal("ws-dummy-go", cfg.Mongo.AppName)
+			},
+		},
+		{
+			name: "Positive: Overrides",
+			env:  map[string]string{"POSTGRES_MAX_CONNS": "50", "REDIS_DB": "3"},
+			check: func(assert *assert.Assertions, cfg *Config) {
+				assert.Equal(int32(50), cfg.Postgres.MaxConns)
+				assert.Equal(3, cfg.Redis.DB)
+			},
+		},
+		{
+			name:    "Negative: Postgres min conns above max",
+			env:     map[string]string{"POSTGRES_MIN_CONNS": "20", "POSTGRES_MAX_CONNS": "10"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Redis DB out of range",
+			env:     map[string]string{"REDIS_DB": "16"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Zero Redis read timeout",
+			env:     map[string]string{"REDIS_READ_TIMEOUT": "0s"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Empty Mongo pool",
+			env:     map[string]string{"MONGO_MAX_POOL_SIZE": "0"},
+			wantErr: true,
+		},
+		{
+			name: "Positive: Release mode with a cursor secret",
+			env:  map[string]string{"MODE": "release", "CURSOR_SECRET": "0123456789abcdef0123456789abcdef"},
+		},
+		{
+			name:    "Negative: Release mode without a cursor secret",
+			env:     map[string]string{"MODE": "release"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Short cursor secret",
+			env:     map[string]string{"CURSOR_SECRET": "secret"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Unknown mode",
+			env:     map[string]string{"MODE": "prod"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Port without colon",
+			env:     map[string]string{"PORT": "8080"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Zero timeout",
+			env:     map[string]string{"TIMEOUT": "0s"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Empty host",
+			env:     map[string]string{"REDIS_HOST": ""},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, _, err := LoadConfig(ConfigSources{EnvFile: writeTestEnv(t)})
+
+			assert.Equal(tt.wantErr, err != nil, err)
+			if tt.check != nil && got != nil {
+				tt.check(assert, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Layers(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	yamlFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte(`
+port: ":9090"
+mode: release
+postgres:
+  host: yaml-host
+  max_conns: 30
+redis:
+  tls:
+    server_name: redis.internal
+auth:
+  client_cert_scopes: [users:read, users:write]
+`), 0o600))
+
+	t.Setenv("POSTGRES_MAX_CONNS", "40")
+	t.Setenv("MODE", "debug")
+
+	cfg, report, err := LoadConfig(ConfigSources{
+		File:      yamlFile,
+		EnvFile:   writeTestEnv(t),
+		Overrides: map[string]string{"MODE": "release", "CURSOR_SECRET": "0123456789abcdef0123456789abcdef"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(":9090", cfg.Port)
+	assert.Equal("localhost", cfg.Postgres.Host) // .env beats the file
+	assert.Equal(int32(40), cfg.Postgres.MaxConns)
+	assert.Equal("release", cfg.Mode)
+	assert.Equal("redis.internal", cfg.Redis.TLS.ServerName)
+	assert.Equal([]string{"users:read", "users:write"}, cfg.Auth.ClientCertScopes)
+
+	assert.Equal(SourceFile, report.Origins["PORT"])
+	assert.Equal(SourceEnvFile, report.Origins["POSTGRES_HOST"])
+	assert.Equal(SourceEnv, report.Origins["POSTGRES_MAX_CONNS"])
+	assert.Equal(SourceFlag, report.Origins["MODE"])
+	assert.Equal(SourceDefault, report.Origins["REDIS_POOL_SIZE"])
+}
+
+func TestLoadConfig_Problems(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, report, err := LoadConfig(ConfigSources{
+		File:    filepath.Join(t.TempDir(), "missing.yaml"),
+		EnvFile: filepath.Join(t.TempDir(), "missing.env"),
+		Overrides: map[string]string{
+			"POSTGRES_PORT":  "not-a-port",
+			"REDIS_DB":       "99",
+			"NO_SUCH_OPTION": "1",
+		},
+	})
+
+	assert.Nil(cfg)
+	assert.Len(report.Skipped, 2)
+
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	msg := err.Error()
+	for _, want := range []string{
+		"TIMEOUT is required", "POSTGRES_HOST is required", "MONGO_PASSWORD is required",
+		`POSTGRES_PORT: invalid uint16 value "not-a-port"`, "REDIS_DB must satisfy lte=15", "unknown key NO_SUCH_OPTION",
+	} {
+		assert.Contains(msg, want)
+	}
+}
+
+func TestLoadConfig_Secrets(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	// The test env without passwords.
+	envFile := writeTestEnv(t)
+	raw, err := os.ReadFile(envFile)
+	require.NoError(t, err)
+	raw = regexp.MustCompile(`(?m)^\w+_PASSWORD=.*\n`).ReplaceAll(raw, nil)
+	require.NoError(t, os.WriteFile(envFile, raw, 0o600))
+
+	pgPasswordFile := filepath.Join(dir, "pg_password")
+	require.NoError(t, os.WriteFile(pgPasswordFile, []byte("from-file\n"), 0o600))
+
+	key, err := secrets.GenerateKey()
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "secrets.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(key), 0o600))
+	rawKey, err := secrets.ReadKeyFile(keyFile)
+	require.NoError(t, err)
+	sealed, err := secrets.Seal(rawKey, []byte(`{"REDIS_PASSWORD": "from-provider"}`))
+	require.NoError(t, err)
+	secretsFile := filepath.Join(dir, "secrets.enc")
+	require.NoError(t, os.WriteFile(secretsFile, sealed, 0o600))
+
+	cfg, report, err := LoadConfig(ConfigSources{
+		EnvFile: envFile,
+		Overrides: map[string]string{
+			"POSTGRES_PASSWORD_FILE": pgPasswordFile,
+			"MONGO_PASSWORD":         "plain",
+			"SECRETS_PROVIDER":       "encrypted-file",
+			"SECRETS_FILE":           secretsFile,
+			"SECRETS_KEY_FILE":       keyFile,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal("from-file", cfg.Postgres.Password)
+	assert.Equal("from-provider", cfg.Redis.Password)
+	assert.Equal("plain", cfg.Mongo.Password)
+	assert.Equal(SourceSecret, report.Origins["POSTGRES_PASSWORD"])
+	assert.Equal(SourceSecret, report.Origins["REDIS_PASSWORD"])
+	assert.Equal(SourceFlag, report.Origins["MONGO_PASSWORD"])
+	assert.NotContains(report.Origins, "POSTGRES_PASSWORD_FILE")
+	assert.Len(report.Secrets, 2)
+
+	// Both a value and a file.
+	_, _, err = LoadConfig(ConfigSources{
+		EnvFile:   writeTestEnv(t),
+		Overrides: map[string]string{"POSTGRES_PASSWORD_FILE": pgPasswordFile},
+	})
+	assert.ErrorContains(err, "POSTGRES_PASSWORD and POSTGRES_PASSWORD_FILE are mutually exclusive")
+}
+
+func TestPrintConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, _, err := LoadConfig(ConfigSources{EnvFile: writeTestEnv(t)})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, PrintConfig(&buf, cfg, "json"))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(maskedSecret, got["POSTGRES_PASSWORD"])
+	assert.Equal("", got["SECRETS_FILE"])
+	assert.Equal("localhost", got["POSTGRES_HOST"])
+	assert.Equal("5s", got["TIMEOUT"])
+	assert.NotContains(buf.String(), "pass\"")
+
+	// The output is a valid config file.
+	path := filepath.Join(t.TempDir(), "printed.yaml")
+	buf.Reset()
+	require.NoError(t, PrintConfig(&buf, cfg, "yaml"))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+	reloaded, _, err := LoadConfig(ConfigSources{File: path})
+	require.NoError(t, err)
+	assert.Equal(cfg.Postgres.MaxConnLifetime, reloaded.Postgres.MaxConnLifetime)
+	assert.Equal(maskedSecret, reloaded.Postgres.Password)
+
+	assert.Error(PrintConfig(&buf, cfg, "toml"))
+}
+
+func TestCheckHosts(t *testing.T) {
+	cfg, _, err := LoadConfig(ConfigSources{EnvFile: writeTestEnv(t)})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		host    string
+		wantErr bool
+	}{
+		{
+			name: "Positive: Resolvable name",
+			host: "localhost",
+		},
+		{
+			name: "Positive: IP address",
+			host: "127.0.0.1",
+		},
+		{
+			name:    "Negative: Unresolvable name",
+			host:    "no-such-host.invalid",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := *cfg
+			c.Redis.Host = tt.host
+
+			err := CheckHosts(context.Background(), &c)
+
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ws-dummy-go/internal/migrator"
+	"ws-dummy-go/migrations"
+)
+
+const (
+	// migrateLockKey is the advisory lock the replicas take to migrate one at a time.
+	migrateLockKey int64 = 0x77735f6d6967 // "ws_mig"
+
+	migrateLockRetry = time.Second
+)
+
+// AutoMigrate applies the pending embedded migrations. It holds a Postgres
+// advisory lock meanwhile, so when several replicas start together one of
+// them migrates and the others wait and find nothing to do. Waiting for
+// the lock gives up after lockTimeout or when ctx is done.
+func AutoMigrate(
+	ctx context.Context, pool *pgxpool.Pool, cfg PostgresConfig, name string, lockTimeout time.Duration, logger log.Logger,
+) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	level.Info(logger).Log("msg", "waiting for the migrations lock", "timeout", lockTimeout)
+	if err := tryLock(ctx, conn, migrateLockKey, lockTimeout); err != nil {
+		return fmt.Errorf("taking migrations lock: %w", err)
+	}
+	defer func() {
+		// A fresh context: unlock even if ctx is done.
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrateLockKey); err != nil {
+			level.Error(logger).Log("msg", "releasing migrations lock", "err", err)
+			// Closing the session releases the lock.
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	src, err := migrations.Source()
+	if err != nil {
+		return fmt.Errorf("opening migrations: %w", err)
+	}
+	tgt, err := migrator.NewPostgres(src, PostgresURL(cfg, name), logger)
+	if err != nil {
+		return err
+	}
+	defer tgt.Close(ctx)
+
+	current, dirty, err := tgt.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("getting migrations version: %w", err)
+	}
+	if dirty {
+		return errors.New("database is dirty, fix it with migrate force")
+	}
+	migs, err := tgt.Migrations(current, dirty)
+	if err != nil {
+		return fmt.Errorf("listing migrations: %w", err)
+	}
+	steps, err := migrator.PlanUp(migs, 0)
+	if err != nil {
+		return err
+	}
+	if err := tgt.Run(ctx, steps); err != nil {
+		return fmt.Errorf("running migrations: %w", err)
+	}
+	level.Info(logger).Log("msg", "migrations applied", "count", len(steps), "from", current)
+	return nil
+}
+
+// tryLock takes the session advisory lock, retrying until it's free. It
+// gives up after timeout or when ctx is done.
+func tryLock(ctx context.Context, conn *pgxpool.Conn, key int64, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t := time.NewTicker(migrateLockRetry)
+	defer t.Stop()
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("still locked: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
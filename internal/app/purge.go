@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ws-dummy-go/internal/dummy"
+)
+
+// purgeLockKey is the advisory lock of the replica purging the users.
+const purgeLockKey int64 = 0x77735f707267 // "ws_prg"
+
+// RunPurges purges the expired users and resumes the pending erasures,
+// batch at a time, every interval until ctx is done. A run is skipped
+// when another replica holds the purge lock.
+func RunPurges(
+	ctx context.Context, pool *pgxpool.Pool, purger dummy.UsersPurger, eraser dummy.UsersEraser, batch int,
+	interval time.Duration, logger log.Logger,
+) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			locked, err := purgeLocked(ctx, pool, func() error {
+				report, err := purger.Purge(ctx, now)
+				level.Info(logger).Log("msg", "users purged", "purged", report.Purged, "skipped", report.Skipped)
+				if err != nil {
+					return err
+				}
+				erased, err := eraser.Resume(ctx, batch)
+				if erased > 0 {
+					level.Info(logger).Log("msg", "pending erasures completed", "erased", erased)
+				}
+				return err
+			})
+			if err != nil {
+				level.Error(logger).Log("msg", "purging users", "err", err)
+			} else if !locked {
+				level.Debug(logger).Log("msg", "users purged by another replica")
+			}
+		}
+	}
+}
+
+// purgeLocked runs purge if it takes the purge lock.
+func purgeLocked(ctx context.Context, pool *pgxpool.Pool, purge func() error) (bool, error) {
+	// The lock belongs to the session, the connection is kept until unlocked.
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", purgeLockKey).Scan(&locked); err != nil {
+		return false, fmt.Errorf("taking purge lock: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		// A fresh context: unlock even if ctx is done.
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", purgeLockKey); err != nil {
+			// Closing the session releases the lock.
+			conn.Conn().Close(context.Background())
+		}
+	}()
+	return true, purge()
+}
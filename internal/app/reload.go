@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"ws-dummy-go/internal/logging"
+	"ws-dummy-go/internal/settings"
+)
+
+// reloader reloads the config on SIGHUP or when the config files change and
+// applies the fields tagged reload:"true". Changes to the other fields are
+// rejected, they need a restart. Secrets are left to the secrets watcher.
+type reloader struct {
+	src      ConfigSources
+	store    *settings.Store
+	logLevel *logging.Level
+	logger   log.Logger
+
+	mu       sync.Mutex
+	cfg      Config
+	modTimes map[string]time.Time
+}
+
+func newReloader(src ConfigSources, cfg *Config, store *settings.Store, logLevel *logging.Level, logger log.Logger) *reloader {
+	r := &reloader{
+		src:      src,
+		store:    store,
+		logLevel: logLevel,
+		logger:   logger,
+		cfg:      *cfg,
+	}
+	r.modTimes = r.statFiles()
+	return r
+}
+
+func settingsFrom(cfg *Config) settings.Settings {
+	features := map[string]bool{}
+	for _, f := range cfg.Features {
+		features[f] = true
+	}
+	return settings.Settings{
+		LogLevel:        cfg.LogLevel,
+		Mode:            cfg.Mode,
+		RequestTimeout:  cfg.RequestTimeout,
+		ShutdownTimeout: cfg.Timeout,
+		RateLimit:       cfg.RateLimit,
+		RateBurst:       cfg.RateBurst,
+		Features:        features,
+
+		SearchMinSimilarity: cfg.SearchMinSimilarity,
+	}
+}
+
+// Config returns the config with the reloaded values applied.
+func (r *reloader) Config() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// Reload loads the config again and applies what can be applied.
+// An invalid config is ignored as a whole.
+func (r *reloader) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, _, err := LoadConfig(r.src)
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "reloading config, keeping the current one", "err", err)
+		return
+	}
+
+	merged := r.cfg
+	cur, upd := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(next).Elem()
+	var applied []string
+	for _, f := range configFields(cur.Type(), "") {
+		if f.Secret {
+			continue
+		}
+		oldV, newV := fieldValue(cur, f.Path), fieldValue(upd, f.Path)
+		if reflect.DeepEqual(oldV.Interface(), newV.Interface()) {
+			continue
+		}
+		if !f.Reload {
+			level.Warn(r.logger).Log("msg", "config change rejected, restart required", "key", f.Key)
+			continue
+		}
+		oldV.Set(newV)
+		applied = append(applied, f.Key)
+	}
+	if len(applied) == 0 {
+		level.Info(r.logger).Log("msg", "config reloaded, nothing to apply")
+		return
+	}
+
+	// Only when changed, not to undo a level set at runtime.
+	if merged.LogLevel != r.cfg.LogLevel {
+		if err := r.logLevel.Set(merged.LogLevel); err != nil { // validated, can't happen
+			level.Error(r.logger).Log("msg", "setting log level", "err", err)
+		}
+	}
+	r.cfg = merged
+	r.store.Update(settingsFrom(&merged))
+	level.Info(r.logger).Log("msg", "config reloaded", "applied", strings.Join(applied, ","))
+}
+
+// Run reloads on the signals and, if the interval is set, when the
+// config files change, until the context is done.
+func (r *reloader) Run(ctx context.Context, signals <-chan os.Signal, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-signals:
+			level.Info(r.logger).Log("msg", "got signal, reloading config", "signal", s)
+			r.Reload()
+		case <-tick:
+			if r.filesChanged() {
+				level.Info(r.logger).Log("msg", "config files changed, reloading config")
+				r.Reload()
+			}
+		}
+	}
+}
+
+func (r *reloader) filesChanged() bool {
+	modTimes := r.statFiles()
+	changed := !reflect.DeepEqual(modTimes, r.modTimes)
+	r.modTimes = modTimes
+	return changed
+}
+
+func (r *reloader) statFiles() map[string]time.Time {
+	res := map[string]time.Time{}
+	for _, f := range []string{r.src.File, r.src.EnvFile} {
+		if f == "" {
+			continue
+		}
+		if fi, err := os.Stat(f); err == nil {
+			res[f] = fi.ModTime()
+		}
+	}
+	return res
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/logging"
+	"ws-dummy-go/internal/settings"
+)
+
+func Test_reloader_Reload(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	now := time.Now()
+	write("mode: debug\nport: \":8080\"\n", now)
+
+	src := ConfigSources{File: path, EnvFile: writeTestEnv(t)}
+	cfg, _, err := LoadConfig(src)
+	require.NoError(t, err)
+
+	store := settings.NewStore(settingsFrom(cfg))
+	level, err := logging.NewLevel(cfg.LogLevel)
+	require.NoError(t, err)
+	r := newReloader(src, cfg, store, level, log.NewNopLogger())
+	assert.False(r.filesChanged())
+
+	write(`
+mode: release
+cursor_secret: 0123456789abcdef0123456789abcdef
+port: ":9090"
+log_level: warn
+rate_limit: 5.5
+features: [fast-path]
+`, now.Add(time.Minute))
+	assert.True(r.filesChanged())
+	r.Reload()
+
+	got := store.Load()
+	assert.Equal("release", got.Mode)
+	assert.Equal(5.5, got.RateLimit)
+	assert.True(got.Feature("fast-path"))
+	assert.False(got.Feature("slow-path"))
+	assert.Equal("warn", level.String())
+	assert.Equal(":8080", r.cfg.Port) // not reloadable
+
+	// An invalid config changes nothing.
+	write("mode: verbose\n", now.Add(2*time.Minute))
+	r.Reload()
+	assert.Equal("release", store.Load().Mode)
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, serial int64) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCert{cert: cert, key: key, der: der}
+}
+
+func (c testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func (c testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func Test_newServerTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "test-ca", nil, 1)
+	server := newTestCert(t, "localhost", &ca, 2)
+	client := newTestCert(t, "svc-a", &ca, 3)
+	stranger := newTestCert(t, "stranger", nil, 4)
+
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := server.write(t, dir, "server")
+
+	tlsCfg, err := newServerTLSConfig(TLSConfig{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ClientCAFile:   caFile,
+		ClientAuth:     "require",
+		ReloadInterval: time.Second,
+	})
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.VerifiedChains[0][0].Subject.CommonName)
+	}))
+	srv.TLS = tlsCfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name    string
+		certs   []tls.Certificate
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Positive: Client cert signed by the CA",
+			certs: []tls.Certificate{client.tlsCertificate()},
+			want:  "svc-a",
+		},
+		{
+			name:    "Negative: No client cert",
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Client cert from an unknown CA",
+			certs:   []tls.Certificate{stranger.tlsCertificate()},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: tt.certs,
+			}}}
+			// httptest adds its own certificate, SNI makes the server use GetCertificate.
+			resp, err := c.Get(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+
+			assert.Equal(tt.wantErr, err != nil, err)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(err)
+			assert.Equal(tt.want, string(body))
+		})
+	}
+}
+
+func Test_certReloader_GetCertificate(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "test-ca", nil, 1)
+	certFile, keyFile := newTestCert(t, "localhost", &ca, 10).write(t, dir, "server")
+
+	r, err := newCertReloader(certFile, keyFile, 0)
+	require.NoError(t, err)
+
+	got, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(int64(10), serialOf(t, got))
+
+	// Renewal: new files with a later modification time.
+	newTestCert(t, "localhost", &ca, 11).write(t, dir, "server")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	got, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(int64(11), serialOf(t, got))
+
+	// A broken renewal keeps the current certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	got, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(int64(11), serialOf(t, got))
+}
+
+func serialOf(t *testing.T, c *tls.Certificate) int64 {
+	cert, err := x509.ParseCertificate(c.Certificate[0])
+	require.NoError(t, err)
+	return cert.SerialNumber.Int64()
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyPrefix    = "wsd"
+	apiKeyPrefixLen = 8
+	apiKeySecretLen = 32
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	keyEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+)
+
+// APIKey is the stored part of an API key. The secret itself is never stored,
+// only its SHA-256 hash. The prefix is public and used for lookups.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Hash       []byte     `json:"hash"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (k APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// GenerateAPIKey returns a new plaintext key in the "wsd_<prefix>_<secret>" form,
+// its prefix and hash.
+func GenerateAPIKey() (plain, prefix string, hash []byte, err error) {
+	buf := make([]byte, 25)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", nil, fmt.Errorf("reading random: %w", err)
+	}
+	s := keyEncoding.EncodeToString(buf) // 40 chars
+	prefix = s[:apiKeyPrefixLen]
+	plain = fmt.Sprintf("%s_%s_%s", apiKeyPrefix, prefix, s[apiKeyPrefixLen:apiKeyPrefixLen+apiKeySecretLen])
+	return plain, prefix, hashAPIKey(plain), nil
+}
+
+// ParseAPIKey extracts the lookup prefix from a plaintext key.
+func ParseAPIKey(plain string) (prefix string, err error) {
+	parts := strings.Split(plain, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix ||
+		len(parts[1]) != apiKeyPrefixLen || len(parts[2]) != apiKeySecretLen {
+		return "", fmt.Errorf("%w: malformed api key", ErrInvalidCredentials)
+	}
+	return parts[1], nil
+}
+
+func hashAPIKey(plain string) []byte {
+	// Keys have 200 bits of entropy, a fast hash is enough.
+	sum := sha256.Sum256([]byte(plain))
+	return sum[:]
+}
+
+type APIKeysRepo interface {
+	Insert(ctx context.Context, k APIKey) (APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (APIKey, error)
+	List(ctx context.Context) ([]APIKey, error)
+	Revoke(ctx context.Context, prefix string) error
+	// Expire brings the expiry of an active key forward to at, a key
+	// expiring sooner is left as is.
+	Expire(ctx context.Context, prefix string, at time.Time) error
+	TouchLastUsed(ctx context.Context, prefix string, at time.Time) error
+}
+
+type APIKeysCache interface {
+	Get(ctx context.Context, prefix string) (APIKey, bool, error)
+	Set(ctx context.Context, k APIKey) error
+	Delete(ctx context.Context, prefix string) error
+}
+
+// APIKeyVerifier validates plaintext API keys against the repo, caching lookups.
+type APIKeyVerifier struct {
+	repo  APIKeysRepo
+	cache APIKeysCache
+	now   func() time.Time
+}
+
+func NewAPIKeyVerifier(repo APIKeysRepo, cache APIKeysCache) *APIKeyVerifier {
+	return &APIKeyVerifier{
+		repo:  repo,
+		cache: cache,
+		now:   time.Now,
+	}
+}
+
+func (v *APIKeyVerifier) Verify(ctx context.Context, token string) (Claims, error) {
+	prefix, err := ParseAPIKey(token)
+	if err != nil {
+		return Claims{}, err
+	}
+	key, found, err := v.cache.Get(ctx, prefix)
+	if err != nil {
+		return Claims{}, fmt.Errorf("getting cached api key: %w", err)
+	}
+	if !found {
+		key, err = v.repo.GetByPrefix(ctx, prefix)
+		switch {
+		case errors.Is(err, ErrAPIKeyNotFound):
+			// Cached without a hash, so guessed prefixes don't reach the
+			// repo again. It matches no key, Create deletes it.
+			key = APIKey{Prefix: prefix}
+		case err != nil:
+			return Claims{}, fmt.Errorf("getting api key: %w", err)
+		default:
+			// Last use is tracked with the cache TTL precision.
+			if err := v.repo.TouchLastUsed(ctx, prefix, v.now()); err != nil {
+				return Claims{}, fmt.Errorf("touching api key: %w", err)
+			}
+		}
+		if err := v.cache.Set(ctx, key); err != nil {
+			return Claims{}, fmt.Errorf("caching api key: %w", err)
+		}
+	}
+	if len(key.Hash) == 0 || subtle.ConstantTimeCompare(key.Hash, hashAPIKey(token)) != 1 {
+		return Claims{}, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+	if !key.Active(v.now()) {
+		return Claims{}, fmt.Errorf("%w: api key revoked or expired", ErrInvalidCredentials)
+	}
+	return Claims{
+		Subject: key.Owner,
+		Scopes:  key.Scopes,
+	}, nil
+}
+
+// APIKeyManager creates and revokes keys, keeping the cache consistent.
+type APIKeyManager struct {
+	repo  APIKeysRepo
+	cache APIKeysCache
+}
+
+func NewAPIKeyManager(repo APIKeysRepo, cache APIKeysCache) *APIKeyManager {
+	return &APIKeyManager{
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+// Create returns the stored key and its plaintext, which is shown only once.
+func (m *APIKeyManager) Create(
+	ctx context.Context, owner string, scopes []string, ttl time.Duration,
+) (APIKey, string, error) {
+	plain, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("generating api key: %w", err)
+	}
+	k := APIKey{
+		Prefix: prefix,
+		Hash:   hash,
+		Owner:  owner,
+		Scopes: scopes,
+	}
+	if ttl > 0 {
+		exp := time.Now().Add(ttl)
+		k.ExpiresAt = &exp
+	}
+	k, err = m.repo.Insert(ctx, k)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("inserting api key: %w", err)
+	}
+	// The prefix may be cached as unknown.
+	if err := m.cache.Delete(ctx, prefix); err != nil {
+		return APIKey{}, "", fmt.Errorf("invalidating cached api key: %w", err)
+	}
+	return k, plain, nil
+}
+
+func (m *APIKeyManager) List(ctx context.Context) ([]APIKey, error) {
+	keys, err := m.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (m *APIKeyManager) Revoke(ctx context.Context, prefix string) error {
+	if err := m.repo.Revoke(ctx, prefix); err != nil {
+		return fmt.Errorf("revoking api key: %w", err)
+	}
+	if err := m.cache.Delete(ctx, prefix); err != nil {
+		return fmt.Errorf("invalidating cached api key: %w", err)
+	}
+	return nil
+}
+
+// Rotate issues a new key with the same owner and scopes. The old one
+// keeps working for the grace period, so its clients can switch to the
+// new one, a grace of 0 revokes it at once.
+func (m *APIKeyManager) Rotate(
+	ctx context.Context, prefix string, ttl, grace time.Duration,
+) (APIKey, string, error) {
+	old, err := m.repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("getting api key: %w", err)
+	}
+	if old.RevokedAt != nil {
+		return APIKey{}, "", fmt.Errorf("api key %s is already revoked", prefix)
+	}
+	k, plain, err := m.Create(ctx, old.Owner, old.Scopes, ttl)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if grace <= 0 {
+		if err := m.Revoke(ctx, prefix); err != nil {
+			return APIKey{}, "", err
+		}
+		return k, plain, nil
+	}
+	if err := m.repo.Expire(ctx, prefix, time.Now().Add(grace)); err != nil {
+		return APIKey{}, "", fmt.Errorf("expiring api key: %w", err)
+	}
+	if err := m.cache.Delete(ctx, prefix); err != nil {
+		return APIKey{}, "", fmt.Errorf("invalidating cached api key: %w", err)
+	}
+	return k, plain, nil
+}
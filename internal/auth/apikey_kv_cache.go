@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewAPIKeysRedisCache keeps the keys under the namespace, the prefix of
+// the app's Redis keys. The client is got for each call, it may be
+// replaced while the cache is in use.
+func NewAPIKeysRedisCache(c func() *redis.Client, namespace string, ttl time.Duration) APIKeysCache {
+	return apiKeysRedisCache{
+		client:    c,
+		namespace: namespace,
+		ttl:       ttl,
+	}
+}
+
+type apiKeysRedisCache struct {
+	client    func() *redis.Client
+	namespace string
+	ttl       time.Duration
+}
+
+func (c apiKeysRedisCache) Get(ctx context.Context, prefix string) (APIKey, bool, error) {
+	raw, err := c.client().Get(ctx, c.key(prefix)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return APIKey{}, false, nil
+		}
+		return APIKey{}, false, fmt.Errorf("getting key: %w", err)
+	}
+	var k APIKey
+	if err := json.Unmarshal(raw, &k); err != nil {
+		return APIKey{}, false, fmt.Errorf("decoding key: %w", err)
+	}
+	return k, true, nil
+}
+
+func (c apiKeysRedisCache) Set(ctx context.Context, k APIKey) error {
+	raw, err := json.Marshal(k)
+	if err != nil {
+		return fmt.Errorf("encoding key: %w", err)
+	}
+	if err := c.client().Set(ctx, c.key(k.Prefix), raw, c.ttl).Err(); err != nil {
+		return fmt.Errorf("setting key: %w", err)
+	}
+	return nil
+}
+
+func (c apiKeysRedisCache) Delete(ctx context.Context, prefix string) error {
+	if err := c.client().Del(ctx, c.key(prefix)).Err(); err != nil {
+		return fmt.Errorf("deleting key: %w", err)
+	}
+	return nil
+}
+
+func (c apiKeysRedisCache) key(prefix string) string {
+	return c.namespace + "cache:apikey:" + prefix
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	// Needed to choose dialect
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var db = goqu.Dialect("postgres")
+
+var apiKeyCols = []any{
+	"key_id", "prefix", "key_hash", "owner", "scopes",
+	"created_at", "expires_at", "last_used_at", "revoked_at",
+}
+
+func NewAPIKeysSQLRepo(p *pgxpool.Pool) APIKeysRepo {
+	return apiKeysSQLRepo{
+		pool: p,
+	}
+}
+
+type apiKeysSQLRepo struct {
+	pool *pgxpool.Pool
+}
+
+func (r apiKeysSQLRepo) Insert(ctx context.Context, k APIKey) (APIKey, error) {
+	if k.Scopes == nil { // the column is NOT NULL
+		k.Scopes = []string{}
+	}
+	q := db.
+		Insert("api_keys").
+		Rows(goqu.Record{
+			"prefix":     k.Prefix,
+			"key_hash":   k.Hash,
+			"owner":      k.Owner,
+			"scopes":     k.Scopes,
+			"expires_at": k.ExpiresAt,
+		}).
+		Returning("key_id", "created_at").
+		Prepared(true)
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return APIKey{}, fmt.Errorf("creating query: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, params...).Scan(&k.ID, &k.CreatedAt); err != nil {
+		return APIKey{}, fmt.Errorf("executing query: %w", err)
+	}
+	return k, nil
+}
+
+func (r apiKeysSQLRepo) GetByPrefix(ctx context.Context, prefix string) (APIKey, error) {
+	q := db.
+		Select(apiKeyCols...).
+		From("api_keys").
+		Where(goqu.C("prefix").Eq(prefix)).
+		Prepared(true)
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return APIKey{}, fmt.Errorf("creating query: %w", err)
+	}
+	k, err := scanAPIKey(r.pool.QueryRow(ctx, sql, params...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return APIKey{}, ErrAPIKeyNotFound
+		}
+		return APIKey{}, fmt.Errorf("executing query: %w", err)
+	}
+	return k, nil
+}
+
+func (r apiKeysSQLRepo) List(ctx context.Context) ([]APIKey, error) {
+	q := db.
+		Select(apiKeyCols...).
+		From("api_keys").
+		Order(goqu.I("key_id").Asc())
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("creating query: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %w", err)
+	}
+	return keys, nil
+}
+
+func (r apiKeysSQLRepo) Revoke(ctx context.Context, prefix string) error {
+	q := db.
+		Update("api_keys").
+		Set(goqu.Record{"revoked_at": goqu.L("NOW()")}).
+		Where(goqu.C("prefix").Eq(prefix), goqu.C("revoked_at").IsNull()).
+		Prepared(true)
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return fmt.Errorf("creating query: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, params...)
+	if err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r apiKeysSQLRepo) Expire(ctx context.Context, prefix string, at time.Time) error {
+	q := db.
+		Update("api_keys").
+		Set(goqu.Record{"expires_at": at}).
+		Where(
+			goqu.C("prefix").Eq(prefix),
+			goqu.C("revoked_at").IsNull(),
+			goqu.Or(goqu.C("expires_at").IsNull(), goqu.C("expires_at").Gt(at)),
+		).
+		Prepared(true)
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return fmt.Errorf("creating query: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, params...); err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+	return nil
+}
+
+func (r apiKeysSQLRepo) TouchLastUsed(ctx context.Context, prefix string, at time.Time) error {
+	q := db.
+		Update("api_keys").
+		Set(goqu.Record{"last_used_at": at}).
+		Where(goqu.C("prefix").Eq(prefix)).
+		Prepared(true)
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return fmt.Errorf("creating query: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, params...); err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(
+		&k.ID, &k.Prefix, &k.Hash, &k.Owner, &k.Scopes,
+		&k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt,
+	)
+	return k, err
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testPostgresPool *pgxpool.Pool
+)
+
+func Test_apiKeysSQLRepo(t *testing.T) {
+	if testPostgresPool == nil {
+		t.Skip("postgres not available")
+	}
+	ctx := context.Background()
+	r := NewAPIKeysSQLRepo(testPostgresPool)
+
+	_, prefix, hash, err := GenerateAPIKey()
+	require.NoError(t, err)
+
+	// Nil scopes are stored as an empty array.
+	k, err := r.Insert(ctx, APIKey{Prefix: prefix, Hash: hash, Owner: "svc-a"})
+	require.NoError(t, err)
+	assert.NotZero(t, k.ID)
+	assert.False(t, k.CreatedAt.IsZero())
+
+	got, err := r.GetByPrefix(ctx, prefix)
+	require.NoError(t, err)
+	assert.Equal(t, hash, got.Hash)
+	assert.Equal(t, "svc-a", got.Owner)
+	assert.Equal(t, []string{}, got.Scopes)
+	assert.Nil(t, got.ExpiresAt)
+
+	_, err = r.GetByPrefix(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	at := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	require.NoError(t, r.TouchLastUsed(ctx, prefix, at))
+	require.NoError(t, r.Expire(ctx, prefix, at))
+	require.NoError(t, r.Expire(ctx, prefix, at.Add(time.Hour)), "a later expiry is ignored")
+	got, err = r.GetByPrefix(ctx, prefix)
+	require.NoError(t, err)
+	if assert.NotNil(t, got.ExpiresAt) {
+		assert.True(t, at.Equal(*got.ExpiresAt), got.ExpiresAt)
+	}
+	if assert.NotNil(t, got.LastUsedAt) {
+		assert.True(t, at.Equal(*got.LastUsedAt), got.LastUsedAt)
+	}
+
+	keys, err := r.List(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, prefixes(keys), prefix)
+
+	require.NoError(t, r.Revoke(ctx, prefix))
+	assert.ErrorIs(t, r.Revoke(ctx, prefix), ErrAPIKeyNotFound, "already revoked")
+	got, err = r.GetByPrefix(ctx, prefix)
+	require.NoError(t, err)
+	assert.False(t, got.Active(time.Now()))
+}
+
+func prefixes(keys []APIKey) []string {
+	var p []string
+	for _, k := range keys {
+		p = append(p, k.Prefix)
+	}
+	return p
+}
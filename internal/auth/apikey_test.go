@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAPIKeysRepo struct {
+	keys    map[string]APIKey
+	gets    int
+	touched int
+}
+
+func (r *fakeAPIKeysRepo) Insert(_ context.Context, k APIKey) (APIKey, error) {
+	k.ID = int64(len(r.keys) + 1)
+	k.CreatedAt = time.Now()
+	r.keys[k.Prefix] = k
+	return k, nil
+}
+
+func (r *fakeAPIKeysRepo) GetByPrefix(_ context.Context, prefix string) (APIKey, error) {
+	r.gets++
+	k, ok := r.keys[prefix]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+func (r *fakeAPIKeysRepo) List(context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (r *fakeAPIKeysRepo) Revoke(_ context.Context, prefix string) error {
+	k, ok := r.keys[prefix]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+	r.keys[prefix] = k
+	return nil
+}
+
+func (r *fakeAPIKeysRepo) Expire(_ context.Context, prefix string, at time.Time) error {
+	k, ok := r.keys[prefix]
+	if !ok || k.RevokedAt != nil || (k.ExpiresAt != nil && k.ExpiresAt.Before(at)) {
+		return nil
+	}
+	k.ExpiresAt = &at
+	r.keys[prefix] = k
+	return nil
+}
+
+func (r *fakeAPIKeysRepo) TouchLastUsed(context.Context, string, time.Time) error {
+	r.touched++
+	return nil
+}
+
+type fakeAPIKeysCache map[string]APIKey
+
+func (c fakeAPIKeysCache) Get(_ context.Context, prefix string) (APIKey, bool, error) {
+	k, ok := c[prefix]
+	return k, ok, nil
+}
+
+func (c fakeAPIKeysCache) Set(_ context.Context, k APIKey) error {
+	c[k.Prefix] = k
+	return nil
+}
+
+func (c fakeAPIKeysCache) Delete(_ context.Context, prefix string) error {
+	delete(c, prefix)
+	return nil
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	assert := assert.New(t)
+
+	plain, prefix, hash, err := GenerateAPIKey()
+	require.NoError(t, err)
+
+	got, err := ParseAPIKey(plain)
+	assert.NoError(err)
+	assert.Equal(prefix, got)
+	assert.Equal(hashAPIKey(plain), hash)
+
+	_, err = ParseAPIKey("wsd_short_key")
+	assert.ErrorIs(err, ErrInvalidCredentials)
+}
+
+func TestAPIKeyVerifier_Verify(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &fakeAPIKeysRepo{keys: map[string]APIKey{}}
+	cache := fakeAPIKeysCache{}
+	mgr := NewAPIKeyManager(repo, cache)
+	v := NewAPIKeyVerifier(repo, cache)
+
+	_, active, err := mgr.Create(ctx, "svc-a", []string{"users:write"}, 0)
+	require.NoError(t, err)
+	_, expired, err := mgr.Create(ctx, "svc-b", nil, time.Nanosecond)
+	require.NoError(t, err)
+	revokedKey, revoked, err := mgr.Create(ctx, "svc-c", nil, 0)
+	require.NoError(t, err)
+
+	// Warm the cache before revoking, revoke must invalidate it.
+	_, err = v.Verify(ctx, revoked)
+	require.NoError(t, err)
+	require.NoError(t, mgr.Revoke(ctx, revokedKey.Prefix))
+
+	prefix, err := ParseAPIKey(active)
+	require.NoError(t, err)
+	forged := active[:len(active)-4] + "aaaa"
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Claims
+		wantErr bool
+	}{
+		{
+			name:  "Positive: Active key",
+			token: active,
+			want:  Claims{Subject: "svc-a", Scopes: []string{"users:write"}},
+		},
+		{
+			name:  "Positive: Active key from cache",
+			token: active,
+			want:  Claims{Subject: "svc-a", Scopes: []string{"users:write"}},
+		},
+		{
+			name:    "Negative: Wrong secret with a known prefix",
+			token:   forged,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Expired key",
+			token:   expired,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Revoked key",
+			token:   revoked,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Unknown key",
+			token:   "wsd_aaaaaaaa_" + prefix + prefix + prefix + prefix,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := v.Verify(ctx, tt.token)
+
+			assert.Equal(tt.want, got)
+			assert.Equal(tt.wantErr, err != nil, err)
+			if err != nil {
+				assert.ErrorIs(err, ErrInvalidCredentials)
+			}
+		})
+	}
+}
+
+func TestAPIKeyVerifier_Verify_unknownCached(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &fakeAPIKeysRepo{keys: map[string]APIKey{}}
+	cache := fakeAPIKeysCache{}
+	v := NewAPIKeyVerifier(repo, cache)
+
+	unknown := "wsd_aaaaaaaa_" + strings.Repeat("b", apiKeySecretLen)
+	for i := 0; i < 3; i++ {
+		_, err := v.Verify(ctx, unknown)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	assert.Equal(t, 1, repo.gets, "looked up once")
+	assert.Zero(t, repo.touched)
+
+	cached, found, err := cache.Get(ctx, "aaaaaaaa")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Empty(t, cached.Hash)
+}
+
+func TestAPIKeyManager_Rotate(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		grace      time.Duration
+		wantOldErr bool
+	}{
+		{
+			name:  "Positive: Old key works during the grace period",
+			grace: time.Hour,
+		},
+		{
+			name:       "Positive: No grace period",
+			grace:      0,
+			wantOldErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAPIKeysRepo{keys: map[string]APIKey{}}
+			cache := fakeAPIKeysCache{}
+			mgr := NewAPIKeyManager(repo, cache)
+			v := NewAPIKeyVerifier(repo, cache)
+
+			old, oldPlain, err := mgr.Create(ctx, "svc-a", []string{"users:read"}, 0)
+			require.NoError(t, err)
+			_, err = v.Verify(ctx, oldPlain) // cached
+			require.NoError(t, err)
+
+			k, plain, err := mgr.Rotate(ctx, old.Prefix, 0, tt.grace)
+			require.NoError(t, err)
+			assert.Equal(t, old.Scopes, k.Scopes)
+
+			got, err := v.Verify(ctx, plain)
+			require.NoError(t, err)
+			assert.Equal(t, "svc-a", got.Subject)
+
+			_, err = v.Verify(ctx, oldPlain)
+			assert.Equal(t, tt.wantOldErr, err != nil, err)
+			if tt.grace > 0 {
+				exp := repo.keys[old.Prefix].ExpiresAt
+				require.NotNil(t, exp)
+				assert.WithinDuration(t, time.Now().Add(tt.grace), *exp, time.Minute)
+
+				v.now = func() time.Time { return time.Now().Add(tt.grace + time.Second) }
+				_, err = v.Verify(ctx, oldPlain)
+				assert.ErrorIs(t, err, ErrInvalidCredentials, "expired after the grace period")
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+var (
+	// ErrInvalidCredentials is returned (wrapped) when the presented credentials
+	// are malformed, expired or don't match. Anything else is a server-side failure.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type (
+	contextKey string
+)
+
+const (
+	claimsContextKey contextKey = "auth-claims"
+
+	SchemeBearer = "Bearer"
+	SchemeAPIKey = "ApiKey"
+	// SchemeClientCert is used internally for verified TLS client certificates.
+	SchemeClientCert = "ClientCert"
+)
+
+// Verifier checks raw credentials and returns the claims they carry.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (Claims, error)
+}
+
+// Claims is the authenticated identity of a caller.
+type Claims struct {
+	Subject  string
+	Issuer   string
+	Audience []string
+	Scopes   []string
+}
+
+// HasScopes reports whether all the required scopes are granted.
+func (c Claims) HasScopes(required ...string) bool {
+	for _, s := range required {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func NewContext(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, c)
+}
+
+func FromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsContextKey).(Claims)
+	return c, ok
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"context"
+)
+
+// ClientCertVerifier authenticates callers by their TLS client certificate.
+// The certificate chain is already verified by the TLS handshake, so the
+// token is the certificate subject and every such caller gets the same scopes.
+type ClientCertVerifier struct {
+	scopes []string
+}
+
+func NewClientCertVerifier(scopes []string) *ClientCertVerifier {
+	return &ClientCertVerifier{
+		scopes: scopes,
+	}
+}
+
+func (v *ClientCertVerifier) Verify(_ context.Context, subject string) (Claims, error) {
+	return Claims{
+		Subject: subject,
+		Scopes:  v.scopes,
+	}, nil
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minRefreshInterval throttles the reloads, those triggered by unknown
+	// key IDs and those retrying a failed reload.
+	minRefreshInterval = 10 * time.Second
+	maxJWKSSize        = 1 << 20
+)
+
+type (
+	jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+		// RSA
+		N string `json:"n"`
+		E string `json:"e"`
+		// EC
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		// Symmetric
+		K string `json:"k"`
+	}
+
+	jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	publicKey struct {
+		alg string // optional, from the JWK
+		key any    // *rsa.PublicKey, *ecdsa.PublicKey or []byte
+	}
+)
+
+// KeySet is a cached JSON Web Key Set loaded from a local file or a URL.
+// Keys are reloaded when the cache gets older than the refresh interval
+// or when a token refers to an unknown key ID, so rotated keys are picked up.
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	mu       sync.RWMutex
+	keys     map[string]publicKey
+	loadedAt time.Time
+	// The last reload, failed or not, and its error.
+	triedAt time.Time
+	loadErr error
+}
+
+// NewKeySet creates a key set. The source is either a file path or an http(s) URL.
+func NewKeySet(source string, refresh time.Duration, timeout time.Duration) *KeySet {
+	return &KeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Load fetches the key set eagerly, so misconfiguration is reported at startup.
+func (ks *KeySet) Load(ctx context.Context) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	return ks.load(ctx)
+}
+
+func (ks *KeySet) key(ctx context.Context, kid string) (publicKey, error) {
+	ks.mu.RLock()
+	k, ok := ks.lookup(kid)
+	stale := time.Since(ks.loadedAt) > ks.refresh
+	ks.mu.RUnlock()
+
+	if ok && !stale {
+		return k, nil
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	// Re-check, another goroutine may have reloaded already.
+	k, ok = ks.lookup(kid)
+	if ok && time.Since(ks.loadedAt) <= ks.refresh {
+		return k, nil
+	}
+	if time.Since(ks.triedAt) < minRefreshInterval {
+		switch {
+		case ok:
+			// Stale, the last reload failed.
+			return k, nil
+		case ks.loadErr != nil:
+			return publicKey{}, fmt.Errorf("reloading key set: %w", ks.loadErr)
+		}
+		return publicKey{}, fmt.Errorf("%w: unknown key id %q", ErrInvalidCredentials, kid)
+	}
+	if err := ks.load(ctx); err != nil {
+		if ok {
+			// Serve the cached key while the source is unavailable.
+			return k, nil
+		}
+		return publicKey{}, fmt.Errorf("reloading key set: %w", err)
+	}
+	if k, ok := ks.lookup(kid); ok {
+		return k, nil
+	}
+	return publicKey{}, fmt.Errorf("%w: unknown key id %q", ErrInvalidCredentials, kid)
+}
+
+func (ks *KeySet) lookup(kid string) (publicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// load records the attempt, a failed one keeps the keys.
+func (ks *KeySet) load(ctx context.Context) error {
+	ks.triedAt = time.Now()
+	ks.loadErr = ks.reload(ctx)
+	return ks.loadErr
+}
+
+func (ks *KeySet) reload(ctx context.Context) error {
+	raw, err := ks.read(ctx)
+	if err != nil {
+		return err
+	}
+	var set jwks
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return fmt.Errorf("decoding key set: %w", err)
+	}
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pk, err := parseJWK(k)
+		if err != nil {
+			return fmt.Errorf("parsing key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = pk
+	}
+	if len(keys) == 0 {
+		return errors.New("key set has no signing keys")
+	}
+	ks.keys = keys
+	ks.loadedAt = time.Now()
+	return nil
+}
+
+func (ks *KeySet) read(ctx context.Context) ([]byte, error) {
+	if !isURL(ks.source) {
+		raw, err := os.ReadFile(ks.source)
+		if err != nil {
+			return nil, fmt.Errorf("reading key set file: %w", err)
+		}
+		return raw, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating key set request: %w", err)
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching key set: unexpected status %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading key set: %w", err)
+	}
+	return raw, nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func parseJWK(k jwk) (publicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("decoding modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("decoding exponent: %w", err)
+		}
+		if !e.IsInt64() {
+			return publicKey{}, errors.New("exponent is too large")
+		}
+		return publicKey{alg: k.Alg, key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return publicKey{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("decoding x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("decoding y: %w", err)
+		}
+		if x.BitLen() > 256 || y.BitLen() > 256 {
+			return publicKey{}, errors.New("coordinates are too large")
+		}
+		// Validates that the point is on the curve.
+		point := make([]byte, 65)
+		point[0] = 4 // uncompressed
+		x.FillBytes(point[1:33])
+		y.FillBytes(point[33:])
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return publicKey{}, fmt.Errorf("validating point: %w", err)
+		}
+		return publicKey{alg: k.Alg, key: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("decoding secret: %w", err)
+		}
+		return publicKey{alg: k.Alg, key: secret}, nil
+	default:
+		return publicKey{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgHS256 = "HS256"
+)
+
+type (
+	jwtHeader struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	jwtPayload struct {
+		Subject   string       `json:"sub"`
+		Issuer    string       `json:"iss"`
+		Audience  audience     `json:"aud"`
+		ExpiresAt *numericDate `json:"exp"`
+		NotBefore *numericDate `json:"nbf"`
+		Scope     string       `json:"scope"`
+		Scp       []string     `json:"scp"`
+	}
+
+	audience    []string
+	numericDate float64
+)
+
+// JWTVerifier verifies RS256, ES256 and HS256 signed JWTs against a KeySet.
+type JWTVerifier struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewJWTVerifier creates a verifier. Empty issuer or audience are not checked.
+func NewJWTVerifier(keys *KeySet, issuer, audience string, leeway time.Duration) *JWTVerifier {
+	return &JWTVerifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   leeway,
+		now:      time.Now,
+	}
+}
+
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("%w: decoding header", ErrInvalidCredentials)
+	}
+	if !slices.Contains([]string{AlgRS256, AlgES256, AlgHS256}, header.Alg) {
+		return Claims{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidCredentials, header.Alg)
+	}
+	key, err := v.keys.key(ctx, header.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return Claims{}, fmt.Errorf("%w: algorithm mismatch", ErrInvalidCredentials)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: decoding signature", ErrInvalidCredentials)
+	}
+	if err := verifySignature(header.Alg, key.key, parts[0]+"."+parts[1], sig); err != nil {
+		return Claims{}, err
+	}
+
+	var payload jwtPayload
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return Claims{}, fmt.Errorf("%w: decoding payload", ErrInvalidCredentials)
+	}
+	if err := v.validate(payload); err != nil {
+		return Claims{}, err
+	}
+
+	scopes := payload.Scp
+	if payload.Scope != "" {
+		scopes = strings.Fields(payload.Scope)
+	}
+	return Claims{
+		Subject:  payload.Subject,
+		Issuer:   payload.Issuer,
+		Audience: payload.Audience,
+		Scopes:   scopes,
+	}, nil
+}
+
+func (v *JWTVerifier) validate(p jwtPayload) error {
+	now := v.now()
+	if p.ExpiresAt == nil {
+		return fmt.Errorf("%w: missing exp", ErrInvalidCredentials)
+	}
+	if now.After(p.ExpiresAt.Time().Add(v.leeway)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	}
+	if p.NotBefore != nil && now.Add(v.leeway).Before(p.NotBefore.Time()) {
+		return fmt.Errorf("%w: token not valid yet", ErrInvalidCredentials)
+	}
+	if v.issuer != "" && p.Issuer != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	}
+	if v.audience != "" && !slices.Contains(p.Audience, v.audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+	}
+	return nil
+}
+
+func verifySignature(alg string, key any, signingInput string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key type mismatch", ErrInvalidCredentials)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key type mismatch", ErrInvalidCredentials)
+		}
+		if len(sig) != 64 {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+	case AlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%w: key type mismatch", ErrInvalidCredentials)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (d numericDate) Time() time.Time {
+	sec, frac := math.Modf(float64(d))
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
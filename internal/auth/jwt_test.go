@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var b64 = base64.RawURLEncoding
+
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return testKeys{rsa: rsaKey, ec: ecKey, secret: []byte("0123456789abcdef0123456789abcdef")}
+}
+
+func writeJWKS(t *testing.T, keys testKeys) string {
+	set := jwks{Keys: []jwk{
+		{
+			Kty: "RSA", Kid: "rsa1", Alg: AlgRS256, Use: "sig",
+			N: b64.EncodeToString(keys.rsa.N.Bytes()),
+			E: b64.EncodeToString(big.NewInt(int64(keys.rsa.E)).Bytes()),
+		},
+		{
+			Kty: "EC", Kid: "ec1", Alg: AlgES256, Crv: "P-256",
+			X: b64.EncodeToString(keys.ec.X.FillBytes(make([]byte, 32))),
+			Y: b64.EncodeToString(keys.ec.Y.FillBytes(make([]byte, 32))),
+		},
+		{
+			Kty: "oct", Kid: "hs1", Alg: AlgHS256,
+			K: b64.EncodeToString(keys.secret),
+		},
+	}}
+	raw, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+	return path
+}
+
+func sign(t *testing.T, keys testKeys, alg, kid string, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch alg {
+	case AlgRS256:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, keys.rsa, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case AlgES256:
+		r, s, err := ecdsa.Sign(rand.Reader, keys.ec, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case AlgHS256:
+		mac := hmac.New(sha256.New, keys.secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	}
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	keys := newTestKeys(t)
+	other := newTestKeys(t)
+
+	ks := NewKeySet(writeJWKS(t, keys), time.Minute, time.Second)
+	require.NoError(t, ks.Load(context.Background()))
+
+	v := NewJWTVerifier(ks, "test-issuer", "ws-dummy-go", 0)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := map[string]any{
+		"sub": "user1", "iss": "test-issuer", "aud": "ws-dummy-go", "exp": exp,
+		"scope": "users:read users:write",
+	}
+	with := func(k string, val any) map[string]any {
+		c := map[string]any{}
+		for kk, vv := range valid {
+			c[kk] = vv
+		}
+		c[k] = val
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Claims
+		wantErr bool
+	}{
+		{
+			name:  "Positive: RS256",
+			token: sign(t, keys, AlgRS256, "rsa1", valid),
+			want: Claims{
+				Subject: "user1", Issuer: "test-issuer", Audience: []string{"ws-dummy-go"},
+				Scopes: []string{"users:read", "users:write"},
+			},
+		},
+		{
+			name:  "Positive: ES256 without scopes",
+			token: sign(t, keys, AlgES256, "ec1", with("scope", "")),
+			want: Claims{
+				Subject: "user1", Issuer: "test-issuer", Audience: []string{"ws-dummy-go"},
+			},
+		},
+		{
+			name:  "Positive: HS256 with audience list",
+			token: sign(t, keys, AlgHS256, "hs1", with("aud", []string{"other", "ws-dummy-go"})),
+			want: Claims{
+				Subject: "user1", Issuer: "test-issuer", Audience: []string{"other", "ws-dummy-go"},
+				Scopes: []string{"users:read", "users:write"},
+			},
+		},
+		{
+			name:    "Negative: Signed by another key",
+			token:   sign(t, other, AlgRS256, "rsa1", valid),
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Algorithm doesn't match key",
+			token:   sign(t, keys, AlgHS256, "rsa1", valid),
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Unknown key id",
+			token:   sign(t, keys, AlgRS256, "nope", valid),
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Expired",
+			token:   sign(t, keys, AlgRS256, "rsa1", with("exp", time.Now().Add(-time.Minute).Unix())),
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Not valid yet",
+			token:   sign(t, keys, AlgRS256, "rsa1", with("nbf", time.Now().Add(time.Hour).Unix())),
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Wrong issuer",
+			token:   sign(t, keys, AlgRS256, "rsa1", with("iss", "evil")),
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Wrong audience",
+			token:   sign(t, keys, AlgRS256, "rsa1", with("aud", "other")),
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Alg none",
+			token:   b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + b64.EncodeToString([]byte(`{}`)) + ".",
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Garbage",
+			token:   "not-a-token",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := v.Verify(context.Background(), tt.token)
+
+			assert.Equal(tt.want, got)
+			assert.Equal(tt.wantErr, err != nil, err)
+			if err != nil {
+				assert.ErrorIs(err, ErrInvalidCredentials)
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	assert := assert.New(t)
+
+	oldKeys := newTestKeys(t)
+	newKeys := newTestKeys(t)
+	path := writeJWKS(t, oldKeys)
+
+	ks := NewKeySet(path, time.Minute, time.Second)
+	require.NoError(t, ks.Load(context.Background()))
+	v := NewJWTVerifier(ks, "", "", 0)
+
+	claims := map[string]any{"sub": "user1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	_, err := v.Verify(context.Background(), sign(t, oldKeys, AlgES256, "ec1", claims))
+	assert.NoError(err)
+
+	// Rotate: the file now holds the new keys, the cache is stale.
+	raw, err := os.ReadFile(writeJWKS(t, newKeys))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+	ks.loadedAt = time.Now().Add(-2 * time.Minute)
+	ks.triedAt = ks.loadedAt
+
+	_, err = v.Verify(context.Background(), sign(t, newKeys, AlgES256, "ec1", claims))
+	assert.NoError(err)
+
+	_, err = v.Verify(context.Background(), sign(t, oldKeys, AlgES256, "ec1", claims))
+	assert.ErrorIs(err, ErrInvalidCredentials)
+}
+
+func TestKeySet_failedReload(t *testing.T) {
+	assert := assert.New(t)
+
+	keys := newTestKeys(t)
+	var (
+		fetches atomic.Int32
+		down    atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		raw, err := os.ReadFile(writeJWKS(t, keys))
+		require.NoError(t, err)
+		w.Write(raw)
+	}))
+	defer srv.Close()
+
+	ks := NewKeySet(srv.URL, time.Minute, time.Second)
+	require.NoError(t, ks.Load(context.Background()))
+	v := NewJWTVerifier(ks, "", "", 0)
+	claims := map[string]any{"sub": "user1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	// Stale and the source down: one reload, then the cached keys.
+	down.Store(true)
+	ks.loadedAt = time.Now().Add(-2 * time.Minute)
+	ks.triedAt = ks.loadedAt
+	for i := 0; i < 3; i++ {
+		_, err := v.Verify(context.Background(), sign(t, keys, AlgES256, "ec1", claims))
+		assert.NoError(err)
+	}
+	assert.Equal(int32(2), fetches.Load())
+
+	// Unknown key IDs don't reload either until the interval passed.
+	_, err := v.Verify(context.Background(), sign(t, keys, AlgES256, "ec2", claims))
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrInvalidCredentials, "the source is down")
+	assert.Equal(int32(2), fetches.Load())
+
+	down.Store(false)
+	ks.triedAt = time.Now().Add(-minRefreshInterval)
+	_, err = v.Verify(context.Background(), sign(t, keys, AlgES256, "ec1", claims))
+	assert.NoError(err)
+	assert.Equal(int32(3), fetches.Load())
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+
+	"ws-dummy-go/migrations"
+)
+
+const (
+	username = "mytestuser"
+	password = "mytestpassword"
+	timeout  = 30 // seconds
+)
+
+// TestMain runs the tests without Postgres if Docker isn't available,
+// the tests needing it are skipped.
+func TestMain(m *testing.M) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
+
+	stop, err := startPostgres(logger)
+	if err != nil {
+		logger.Log("msg", "skipping the postgres tests", "err", err)
+	} else {
+		defer stop()
+	}
+
+	m.Run()
+}
+
+func startPostgres(logger log.Logger) (func(), error) {
+	logger.Log("msg", "connecting to docker")
+
+	// Dockertest
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, fmt.Errorf("connecting to docker: %w", err)
+	}
+	if err := pool.Client.Ping(); err != nil {
+		return nil, fmt.Errorf("pinging docker: %w", err)
+	}
+	pool.MaxWait = timeout * time.Second
+
+	// Run images
+	pgImage, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "bitnami/postgresql",
+		Tag:        "16",
+		Env: []string{
+			"POSTGRESQL_USERNAME=" + username,
+			"POSTGRESQL_PASSWORD=" + password,
+		},
+	}, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("starting postgres: %w", err)
+	}
+
+	pgImage.Expire(timeout)
+	stop := func() {
+		if testPostgresPool != nil {
+			testPostgresPool.Close()
+		}
+		if err := pool.Purge(pgImage); err != nil {
+			logger.Log("msg", "purging postgres", "err", err)
+		}
+		logger.Log("msg", "disconnected from docker")
+	}
+
+	// Connect to images
+	pgURL := fmt.Sprintf(
+		"postgres://%s:%s@localhost:%s/postgres?connect_timeout=%d&sslmode=disable",
+		username, password, pgImage.GetPort("5432/tcp"), 10,
+	)
+	var pgPool *pgxpool.Pool
+	if err := pool.Retry(func() error {
+		pgPool, err = pgxpool.New(context.Background(), pgURL)
+		if err != nil {
+			return err
+		}
+		return pgPool.Ping(context.Background())
+	}); err != nil {
+		stop()
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+	logger.Log("msg", "connected to postgres")
+
+	// Migrate
+	src, err := migrations.Source()
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("opening migrations: %w", err)
+	}
+	mg, err := migrate.NewWithSourceInstance("iofs", src, pgURL)
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("initializing migrations: %w", err)
+	}
+	if err := mg.Up(); err != nil {
+		stop()
+		return nil, fmt.Errorf("running migrations up: %w", err)
+	}
+
+	testPostgresPool = pgPool
+	return stop, nil
+}
@@ -0,0 +1,14 @@
+package dummy
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Collection returns the Mongo collection of a repo. The client behind it
+// may be replaced while the repo is in use, e.g. when the password rotates.
+type Collection func() *mongo.Collection
+
+// StaticCollection always returns c.
+func StaticCollection(c *mongo.Collection) Collection {
+	return func() *mongo.Collection { return c }
+}
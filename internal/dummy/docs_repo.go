@@ -6,37 +6,133 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
 )
 
+// UsersDocsRepo finds the users by their Postgres ID, stored as user_id.
+// The changes of a missing user do nothing. The names are encrypted.
 type UsersDocsRepo interface {
-	Insert(ctx context.Context, name string) (domain.UserID, error)
+	Insert(ctx context.Context, id domain.UserID, name string) error
+	// Update renames the user and sets its version, the one of the user
+	// in Postgres: the versions of the two stay the same.
+	Update(ctx context.Context, id domain.UserID, name string, version int64) error
+	// SoftDelete flags the user as deleted, Restore clears the flag.
+	SoftDelete(ctx context.Context, id domain.UserID, at time.Time) error
+	Restore(ctx context.Context, id domain.UserID) error
+	// Purge removes the user if flagged as deleted, it returns the number
+	// of documents removed.
+	Purge(ctx context.Context, id domain.UserID) (int64, error)
+	// Export returns the documents of the user, deleted or not.
+	Export(ctx context.Context, id domain.UserID) ([]map[string]any, error)
 }
 
-func NewUsersDocsRepo(c *mongo.Collection, g IDGenerator) UsersDocsRepo {
+func NewUsersDocsRepo(c Collection, g IDGenerator, ci pii.Cipher) UsersDocsRepo {
 	return usersDocRepo{
 		col:         c,
 		idGenerator: g,
+		cipher:      ci,
 	}
 }
 
 type usersDocRepo struct {
-	col         *mongo.Collection
+	col         Collection
 	idGenerator IDGenerator
+	cipher      pii.Cipher
 }
 
-func (r usersDocRepo) Insert(ctx context.Context, name string) (domain.UserID, error) {
-	newID := r.idGenerator.NewID()
+func (r usersDocRepo) Insert(ctx context.Context, id domain.UserID, name string) error {
+	encrypted, err := r.cipher.Encrypt(nameField, name)
+	if err != nil {
+		return fmt.Errorf("encrypting name: %w", err)
+	}
 
-	res, err := r.col.InsertOne(ctx, bson.D{
-		{Key: "_id", Value: newID},
-		{Key: "name", Value: name},
+	_, err = r.col().InsertOne(ctx, bson.D{
+		{Key: "_id", Value: r.idGenerator.NewID()},
+		{Key: "user_id", Value: string(id)},
+		{Key: "name", Value: encrypted},
+		{Key: "name_bidx", Value: r.cipher.BlindIndex(nameField, name)},
 		{Key: "created_at", Value: time.Now()},
+		{Key: "version", Value: int64(1)},
 	})
 	if err != nil {
-		return "", fmt.Errorf("inserting a doc: %w", err)
+		return fmt.Errorf("inserting a doc: %w", err)
+	}
+	return nil
+}
+
+func (r usersDocRepo) Update(ctx context.Context, id domain.UserID, name string, version int64) error {
+	encrypted, err := r.cipher.Encrypt(nameField, name)
+	if err != nil {
+		return fmt.Errorf("encrypting name: %w", err)
+	}
+
+	_, err = r.col().UpdateOne(ctx,
+		bson.M{"user_id": string(id), "deleted": bson.M{"$ne": true}},
+		bson.M{
+			"$set": bson.M{"name": encrypted, "name_bidx": r.cipher.BlindIndex(nameField, name), "version": version},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("updating a doc: %w", err)
+	}
+	return nil
+}
+
+func (r usersDocRepo) SoftDelete(ctx context.Context, id domain.UserID, at time.Time) error {
+	_, err := r.col().UpdateOne(ctx,
+		bson.M{"user_id": string(id), "deleted": bson.M{"$ne": true}},
+		bson.M{
+			"$set": bson.M{"deleted": true, "deleted_at": at},
+			"$inc": bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("updating a doc: %w", err)
+	}
+	return nil
+}
+
+func (r usersDocRepo) Restore(ctx context.Context, id domain.UserID) error {
+	_, err := r.col().UpdateOne(ctx,
+		bson.M{"user_id": string(id), "deleted": true},
+		bson.M{
+			"$unset": bson.M{"deleted": "", "deleted_at": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("updating a doc: %w", err)
+	}
+	return nil
+}
+
+func (r usersDocRepo) Purge(ctx context.Context, id domain.UserID) (int64, error) {
+	res, err := r.col().DeleteMany(ctx, bson.M{"user_id": string(id), "deleted": true})
+	if err != nil {
+		return 0, fmt.Errorf("deleting docs: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+func (r usersDocRepo) Export(ctx context.Context, id domain.UserID) ([]map[string]any, error) {
+	cur, err := r.col().Find(ctx, bson.M{"user_id": string(id)})
+	if err != nil {
+		return nil, fmt.Errorf("finding docs: %w", err)
+	}
+	var docs []bson.M
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("reading docs: %w", err)
+	}
+	res := make([]map[string]any, len(docs))
+	for i, doc := range docs {
+		res[i] = fromBSON(doc).(map[string]any)
+		if encrypted, ok := res[i]["name"].(string); ok {
+			if res[i]["name"], err = r.cipher.Decrypt(ctx, nameField, encrypted); err != nil {
+				return nil, fmt.Errorf("decrypting name: %w", err)
+			}
+		}
 	}
-	return domain.UserID(fmt.Sprintf("%v", res.InsertedID)), nil
+	return res, nil
 }
@@ -3,12 +3,15 @@ package dummy
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/mongo"
 
 	"ws-dummy-go/internal/dummy/domain"
 	"ws-dummy-go/internal/mocks"
+	"ws-dummy-go/internal/pii"
 )
 
 var (
@@ -17,30 +20,36 @@ var (
 
 func Test_usersDocRepo_Insert(t *testing.T) {
 	type args struct {
+		id   domain.UserID
 		name string
 	}
 	tests := []struct {
 		name    string
 		args    args
-		want    domain.UserID
 		wantErr bool
 	}{
 		{
 			name:    "Positive: Insert user",
-			args:    args{name: "testname567"},
-			want:    domain.UserID("345678987654"),
+			args:    args{id: "567", name: "testname567"},
 			wantErr: false,
 		},
+		{
+			name:    "Negative: User inserted already",
+			args:    args{id: "567", name: "testname567"},
+			wantErr: true,
+		},
 	}
 
 	idGeneratorMock := &mocks.IDGenerator{}
-	idGeneratorMock.EXPECT().NewID().Return("345678987654").Times(1)
+	idGeneratorMock.EXPECT().NewID().Return("345678987654").Once()
+	idGeneratorMock.EXPECT().NewID().Return("345678987655").Once()
 
 	col := testMongoClient.Database("test_dummy").Collection("users")
 
 	r := usersDocRepo{
-		col:         col,
+		col:         StaticCollection(col),
 		idGenerator: idGeneratorMock,
+		cipher:      pii.NewPlaintext(),
 	}
 
 	for _, tt := range tests {
@@ -48,12 +57,70 @@ func Test_usersDocRepo_Insert(t *testing.T) {
 			// TODO: t.Parallel()
 			assert := assert.New(t)
 
-			got, err := r.Insert(context.Background(), tt.args.name)
+			err := r.Insert(context.Background(), tt.args.id, tt.args.name)
 
-			assert.Equal(tt.want, got)
 			assert.Equal(tt.wantErr, err != nil, err)
-
-			idGeneratorMock.AssertExpectations(t)
 		})
 	}
+	idGeneratorMock.AssertExpectations(t)
+}
+
+func Test_usersDocRepo_Export(t *testing.T) {
+	ctx := context.Background()
+	idGeneratorMock := &mocks.IDGenerator{}
+	idGeneratorMock.EXPECT().NewID().Return("export_id").Once()
+	idGeneratorMock.EXPECT().NewID().Return("export_other").Once()
+	r := usersDocRepo{
+		col:         StaticCollection(testMongoClient.Database("test_dummy").Collection("users")),
+		idGenerator: idGeneratorMock,
+		cipher:      pii.NewPlaintext(),
+	}
+	require.NoError(t, r.Insert(ctx, "601", "export_1"))
+	// Another user with the same name is left alone.
+	require.NoError(t, r.Insert(ctx, "602", "export_1"))
+	require.NoError(t, r.SoftDelete(ctx, "601", time.Now()))
+
+	got, err := r.Export(ctx, "601")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "export_id", got[0]["_id"])
+	assert.Equal(t, "601", got[0]["user_id"])
+	assert.Equal(t, true, got[0]["deleted"])
+	assert.Equal(t, int64(3), got[0]["version"])
+
+	purged, err := r.Purge(ctx, "601")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	got, err = r.Export(ctx, "601")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	got, err = r.Export(ctx, "602")
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+}
+
+func Test_usersDocRepo_Update(t *testing.T) {
+	ctx := context.Background()
+	idGeneratorMock := &mocks.IDGenerator{}
+	idGeneratorMock.EXPECT().NewID().Return("update_id").Once()
+	r := usersDocRepo{
+		col:         StaticCollection(testMongoClient.Database("test_dummy").Collection("users")),
+		idGenerator: idGeneratorMock,
+		cipher:      pii.NewPlaintext(),
+	}
+	require.NoError(t, r.Insert(ctx, "611", "update_1"))
+	require.NoError(t, r.Update(ctx, "611", "update_2", 3))
+	// Not stored, nothing to update.
+	require.NoError(t, r.Update(ctx, "612", "update_3", 2))
+
+	got, err := r.Export(ctx, "611")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "update_2", got[0]["name"])
+	assert.Equal(t, "update_2", got[0]["name_bidx"])
+	assert.Equal(t, int64(3), got[0]["version"])
+	got, err = r.Export(ctx, "612")
+	require.NoError(t, err)
+	assert.Empty(t, got)
 }
@@ -1,5 +1,156 @@
 package domain
 
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"ws-dummy-go/internal/jsondoc"
+)
+
+// Highlight markers, control characters not expected in names.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
 type (
 	UserID string
+
+	// User.Version starts at 1 and is bumped by every change. DeletedAt
+	// is zero unless the user is soft deleted.
+	User struct {
+		ID        UserID
+		Name      string
+		CreatedAt time.Time
+		Version   int64
+		DeletedAt time.Time
+	}
+
+	// UserPurge is the audit record of a soft deleted user hard deleted
+	// from all the stores, with the counts of its documents and keys.
+	UserPurge struct {
+		UserID      UserID
+		DeletedAt   time.Time
+		DocsDeleted int64
+		KeysDeleted int64
+	}
+
+	// UserErasure is the tombstone of an erased user, without its data.
+	// CompletedAt is zero while the erasure is pending.
+	UserErasure struct {
+		UserID      UserID
+		RequestedAt time.Time
+		CompletedAt time.Time
+		DocsDeleted int64
+		KeysDeleted int64
+	}
+
+	// UserExport is the data kept on a user in every store. Redis has the
+	// values by key, Docs the user documents in Mongo.
+	UserExport struct {
+		User    User
+		Redis   map[string]any
+		Docs    []map[string]any
+		Profile Profile
+	}
+
+	// VersionMatch is the versions a conditional request applies to, from
+	// an If-Match or If-None-Match. The zero value matches none.
+	VersionMatch struct {
+		Any      bool // *, or no If-Match where it's optional
+		Versions []int64
+	}
+
+	// UserKey is the position of a user in the listing order,
+	// created_at then user_id.
+	UserKey struct {
+		CreatedAt time.Time
+		ID        UserID
+	}
+
+	// UsersFilter narrows the users listing, zero values don't filter.
+	UsersFilter struct {
+		NamePrefix    string
+		CreatedAfter  time.Time
+		CreatedBefore time.Time
+	}
+
+	// ListUsersQuery asks for the page of users after or before a key,
+	// the first page if both are nil.
+	ListUsersQuery struct {
+		Filter UsersFilter
+		After  *UserKey
+		Before *UserKey
+		Limit  int
+	}
+
+	// SearchUsersQuery matches the names by trigram similarity and full text.
+	SearchUsersQuery struct {
+		Text          string
+		MinSimilarity float64
+		Offset        int
+		Limit         int
+	}
+
+	// UserMatch is a search result. The highlight is the name with the
+	// matched words between HighlightStart and HighlightStop.
+	UserMatch struct {
+		User
+		Rank      float64
+		Highlight string
+	}
+
+	// UsersSearchPage has the offset of the next page, 0 when there is none.
+	UsersSearchPage struct {
+		Matches    []UserMatch
+		NextOffset int
+	}
+
+	// UsersPage has the keys of the next and previous pages, nil when there are none.
+	UsersPage struct {
+		Users []User
+		Next  *UserKey
+		Prev  *UserKey
+	}
+
+	// Profile is the free-form attributes of a user, a JSON object.
+	// Version counts the saves, 0 for a profile never saved.
+	Profile struct {
+		UserID    UserID
+		Data      map[string]any
+		Version   int64
+		UpdatedAt time.Time
+	}
+
+	// ProfilePatch is a JSON Merge Patch (RFC 7396) if Merge is set, else
+	// a JSON Patch (RFC 6902).
+	ProfilePatch struct {
+		Merge map[string]any
+		Ops   []jsondoc.Operation
+	}
 )
+
+func (u User) Key() UserKey {
+	return UserKey{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
+// AnyVersion matches every version, for the unconditional changes.
+var AnyVersion = VersionMatch{Any: true}
+
+func (m VersionMatch) Matches(version int64) bool {
+	return m.Any || slices.Contains(m.Versions, version)
+}
+
+// String is * for any version, else the versions.
+func (m VersionMatch) String() string {
+	if m.Any {
+		return "*"
+	}
+	vs := make([]string, len(m.Versions))
+	for i, v := range m.Versions {
+		vs[i] = strconv.FormatInt(v, 10)
+	}
+	return strings.Join(vs, ",")
+}
@@ -13,3 +13,64 @@ func NewNotFoundError(msg string) error {
 func (e *NotFoundError) Error() string {
 	return e.Message
 }
+
+// InvalidError is a request the service can't make sense of.
+type InvalidError struct {
+	Message string
+}
+
+func NewInvalidError(msg string) error {
+	return &InvalidError{
+		Message: msg,
+	}
+}
+
+func (e *InvalidError) Error() string {
+	return e.Message
+}
+
+// ConflictError is a change that doesn't apply to the current state.
+type ConflictError struct {
+	Message string
+}
+
+func NewConflictError(msg string) error {
+	return &ConflictError{
+		Message: msg,
+	}
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// TooLargeError is a change over a size limit.
+type TooLargeError struct {
+	Message string
+}
+
+func NewTooLargeError(msg string) error {
+	return &TooLargeError{
+		Message: msg,
+	}
+}
+
+func (e *TooLargeError) Error() string {
+	return e.Message
+}
+
+// PreconditionFailedError is a conditional change of a resource whose
+// version doesn't match.
+type PreconditionFailedError struct {
+	Message string
+}
+
+func NewPreconditionFailedError(msg string) error {
+	return &PreconditionFailedError{
+		Message: msg,
+	}
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return e.Message
+}
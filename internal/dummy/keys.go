@@ -0,0 +1,42 @@
+package dummy
+
+import (
+	"fmt"
+
+	"ws-dummy-go/internal/dummy/domain"
+)
+
+// KeysVersion is the version of the Redis key schema. Changing the layout
+// of the keys or values bumps it, so the new keys don't mix with the old
+// ones while both versions run. 2 keys the users by their Postgres ID.
+const KeysVersion = 2
+
+// Keys builds the Redis keys, all under the <app>:<env>:v<version>: prefix.
+type Keys struct {
+	prefix string
+}
+
+func NewKeys(app, env string) Keys {
+	return Keys{prefix: fmt.Sprintf("%s:%s:v%d:", app, env, KeysVersion)}
+}
+
+// Prefix is also the namespace of the API keys cache.
+func (k Keys) Prefix() string {
+	return k.prefix
+}
+
+// User is the hash of the user stored by the kv repo, by its Postgres ID:
+// name and created_at.
+func (k Keys) User(id domain.UserID) string {
+	return k.prefix + "user:" + string(id)
+}
+
+// UserName is the name index, the ID of the last user stored with the name.
+func (k Keys) UserName(name string) string {
+	return k.prefix + "user_name:" + name
+}
+
+// CachedUser is the Postgres user in the users cache.
+func (k Keys) CachedUser(id domain.UserID) string {
+	return k.prefix + "cache:user:" + string(id)
+}
@@ -0,0 +1,220 @@
+package dummy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
+)
+
+var (
+	// <app>:<env>:v<version>: of any app and version.
+	namespacedKey = regexp.MustCompile(`^[^:]+:[^:]+:v[0-9]+:`)
+	// Unprefixed cache entries, safe to drop.
+	rawCacheKey = regexp.MustCompile(`^(user:[0-9]+|apikey:.+)$`)
+)
+
+// RawKeysReport counts the keys outside the key spaces seen by
+// MigrateRawKeys.
+type RawKeysReport struct {
+	Scanned  int
+	Migrated int // name -> ID keys moved into the key space
+	Deleted  int // cache entries
+	Skipped  int // not recognized or not in Postgres, left alone
+}
+
+// RawKeysOptions are the keys MigrateRawKeys looks at and what it does.
+type RawKeysOptions struct {
+	// Match is the SCAN pattern of the keys, required.
+	Match  string
+	DryRun bool
+	// TTL of the migrated users, 0 = without expiry.
+	TTL time.Duration
+	// Log, optional, is called before each key is changed, in a dry run
+	// too, with "migrate" or "delete". The raw user keys are names, they
+	// are logged by the key they move to.
+	Log func(action, key string)
+}
+
+// MigrateRawKeys moves the users stored before the key schema, raw
+// name -> ID keys, into the key space and drops the unprefixed cache
+// entries. Only the keys matching Postgres are changed: the names of one
+// live user, moved under its ID, and the cache entries of a user or API
+// key. The keys of any key space are left alone, so it can be run again.
+// The migrated users have no created_at, the user hashes and name index
+// set since are kept. The names are encrypted as by the kv repo.
+func MigrateRawKeys(
+	ctx context.Context, c *redis.Client, pool *pgxpool.Pool, keys Keys, ci pii.Cipher, opts RawKeysOptions,
+) (RawKeysReport, error) {
+	var report RawKeysReport
+	if opts.Match == "" {
+		return report, errors.New("no key pattern to match")
+	}
+	if opts.Log == nil {
+		opts.Log = func(string, string) {}
+	}
+	iter := c.Scan(ctx, 0, opts.Match, 1000).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if namespacedKey.MatchString(key) {
+			continue
+		}
+		report.Scanned++
+
+		if rawCacheKey.MatchString(key) {
+			cached, err := inPostgres(ctx, pool, key)
+			if err != nil {
+				return report, fmt.Errorf("checking %q: %w", key, err)
+			}
+			if !cached {
+				report.Skipped++
+				continue
+			}
+			report.Deleted++
+			opts.Log("delete", key)
+			if opts.DryRun {
+				continue
+			}
+			if err := c.Del(ctx, key).Err(); err != nil {
+				return report, fmt.Errorf("deleting %q: %w", key, err)
+			}
+			continue
+		}
+
+		migrated, err := migrateRawKey(ctx, c, pool, keys, ci, opts, key)
+		if err != nil {
+			return report, fmt.Errorf("migrating %q: %w", key, err)
+		}
+		if migrated {
+			report.Migrated++
+		} else {
+			report.Skipped++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return report, fmt.Errorf("scanning keys: %w", err)
+	}
+	return report, nil
+}
+
+// inPostgres tells if the raw cache key is of a stored user or API key.
+func inPostgres(ctx context.Context, pool *pgxpool.Pool, key string) (bool, error) {
+	kind, id, _ := strings.Cut(key, ":")
+	from, where := "api_keys", goqu.C("prefix").Eq(id)
+	if kind == "user" {
+		userID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return false, nil
+		}
+		from, where = "users", goqu.C("user_id").Eq(userID)
+	}
+	sql, params, err := db.Select(goqu.L("1")).From(from).Where(where).ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("creating query: %w", err)
+	}
+	var one int
+	if err := pool.QueryRow(ctx, sql, params...).Scan(&one); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("executing query: %w", err)
+	}
+	return true, nil
+}
+
+// userByName returns the ID of the only live user with the name, false
+// if there is none or more. The plaintext names not reencrypted yet match
+// too.
+func userByName(ctx context.Context, pool *pgxpool.Pool, ci pii.Cipher, name string) (domain.UserID, bool, error) {
+	sql, params, err := db.
+		Select("user_id").
+		From("users").
+		Where(
+			goqu.Or(goqu.C("name_bidx").Eq(ci.BlindIndex(nameField, name)), goqu.C("name").Eq(name)),
+			goqu.C("deleted_at").IsNull(),
+		).
+		Limit(2).
+		ToSQL()
+	if err != nil {
+		return "", false, fmt.Errorf("creating query: %w", err)
+	}
+	rows, err := pool.Query(ctx, sql, params...)
+	if err != nil {
+		return "", false, fmt.Errorf("executing query: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return "", false, fmt.Errorf("reading users: %w", err)
+	}
+	if len(ids) != 1 {
+		return "", false, nil
+	}
+	return domain.UserID(strconv.FormatInt(ids[0], 10)), true, nil
+}
+
+// migrateRawKey moves a string key holding a numeric ID to the user of
+// the name, watching it so a concurrent change isn't lost.
+func migrateRawKey(
+	ctx context.Context, c *redis.Client, pool *pgxpool.Pool, keys Keys, ci pii.Cipher, opts RawKeysOptions, name string,
+) (bool, error) {
+	var migrated bool
+	err := c.Watch(ctx, func(tx *redis.Tx) error {
+		typ, err := tx.Type(ctx, name).Result()
+		if err != nil {
+			return fmt.Errorf("getting type: %w", err)
+		}
+		if typ != "string" {
+			return nil
+		}
+		value, err := tx.Get(ctx, name).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) { // gone meanwhile
+				return nil
+			}
+			return fmt.Errorf("getting key: %w", err)
+		}
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+			return nil
+		}
+		id, found, err := userByName(ctx, pool, ci, name)
+		if err != nil || !found {
+			return err
+		}
+		migrated = true
+		userKey := keys.User(id)
+		opts.Log("migrate", userKey)
+		if opts.DryRun {
+			return nil
+		}
+		encrypted, err := ci.Encrypt(nameField, name)
+		if err != nil {
+			return fmt.Errorf("encrypting name: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSetNX(ctx, userKey, "name", encrypted)
+			if opts.TTL > 0 {
+				p.Expire(ctx, userKey, opts.TTL)
+			}
+			p.SetNX(ctx, keys.UserName(ci.BlindIndex(nameField, name)), string(id), opts.TTL)
+			p.Del(ctx, name)
+			return nil
+		})
+		return err
+	}, name)
+	if err != nil {
+		return false, err
+	}
+	return migrated, nil
+}
@@ -0,0 +1,113 @@
+package dummy
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/pii"
+)
+
+func TestMigrateRawKeys(t *testing.T) {
+	ctx := context.Background()
+	keys := NewKeys("migr", "test")
+	sqlRepo := NewUsersSQLRepo(testPostgresPool, pii.NewPlaintext())
+
+	suffix := strconv.FormatInt(time.Now().UnixNano()%1e12, 10)
+	john, jane, twin, ghost := "migr_john_"+suffix, "migr_jane_"+suffix, "migr_twin_"+suffix, "migr_ghost_"+suffix
+	johnID, err := sqlRepo.Insert(ctx, john)
+	require.NoError(t, err)
+	janeID, err := sqlRepo.Insert(ctx, jane)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = sqlRepo.Insert(ctx, twin)
+		require.NoError(t, err)
+	}
+	prefix := "m" + suffix
+	_, err = testPostgresPool.Exec(ctx, "INSERT INTO api_keys (prefix, key_hash, owner) VALUES ($1, '\\x00', 'migr')", prefix)
+	require.NoError(t, err)
+
+	leftover := []string{
+		"migr_counter_name", "migr_list", "other:app:v1:x", twin, ghost, "user:999999999999", "apikey:migr_none",
+	}
+	for key, value := range map[string]string{
+		john:                     "111",        // raw user
+		jane:                     "222",        // raw user, indexed since
+		twin:                     "333",        // raw user, name of two users
+		ghost:                    "444",        // raw user, not in Postgres
+		"migr_counter_name":      "not an id",  // not ours
+		"user:" + string(johnID): `{"id":"1"}`, // old users cache
+		"user:999999999999":      `{"id":"9"}`, // old users cache, not in Postgres
+		"apikey:" + prefix:       "{}",         // old api keys cache
+		"apikey:migr_none":       "{}",         // old api keys cache, not in Postgres
+		"other:app:v1:x":         "555",        // another key space
+		keys.UserName(jane):      "999",
+	} {
+		require.NoError(t, testRedisClient.Set(ctx, key, value, 0).Err())
+	}
+	require.NoError(t, testRedisClient.RPush(ctx, "migr_list", "1").Err())
+	t.Cleanup(func() { testRedisClient.Del(ctx, leftover...) })
+
+	tests := []struct {
+		name    string
+		dryRun  bool
+		want    RawKeysReport
+		wantLog []string
+	}{
+		{
+			name:    "Positive: Dry run counts and logs",
+			dryRun:  true,
+			want:    RawKeysReport{Scanned: 10, Migrated: 2, Deleted: 2, Skipped: 6},
+			wantLog: []string{"migrate " + keys.User(johnID), "migrate " + keys.User(janeID), "delete user:" + string(johnID), "delete apikey:" + prefix},
+		},
+		{
+			name:    "Positive: Migrate",
+			want:    RawKeysReport{Scanned: 10, Migrated: 2, Deleted: 2, Skipped: 6},
+			wantLog: []string{"migrate " + keys.User(johnID), "migrate " + keys.User(janeID), "delete user:" + string(johnID), "delete apikey:" + prefix},
+		},
+		{
+			name: "Positive: Run again",
+			want: RawKeysReport{Scanned: 6, Skipped: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged []string
+			got, err := MigrateRawKeys(ctx, testRedisClient, testPostgresPool, keys, pii.NewPlaintext(), RawKeysOptions{
+				Match:  "*",
+				DryRun: tt.dryRun,
+				TTL:    time.Hour,
+				Log:    func(action, key string) { logged = append(logged, action+" "+key) },
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.ElementsMatch(t, tt.wantLog, logged)
+		})
+	}
+
+	_, err = MigrateRawKeys(ctx, testRedisClient, testPostgresPool, keys, pii.NewPlaintext(), RawKeysOptions{})
+	assert.Error(t, err, "no pattern")
+
+	assert := assert.New(t)
+	name, err := testRedisClient.HGet(ctx, keys.User(johnID), "name").Result()
+	assert.NoError(err)
+	assert.Equal(john, name)
+	id, err := testRedisClient.Get(ctx, keys.UserName(john)).Result()
+	assert.NoError(err)
+	assert.Equal(string(johnID), id)
+	id, err = testRedisClient.Get(ctx, keys.UserName(jane)).Result()
+	assert.NoError(err)
+	assert.Equal("999", id, "the newer index entry is kept")
+	n, err := testRedisClient.Exists(ctx, john, jane, "user:"+string(johnID), "apikey:"+prefix).Result()
+	assert.NoError(err)
+	assert.Zero(n)
+	n, err = testRedisClient.Exists(ctx, leftover...).Result()
+	assert.NoError(err)
+	assert.Equal(int64(len(leftover)), n, "the keys not in Postgres are kept")
+}
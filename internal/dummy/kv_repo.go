@@ -2,41 +2,213 @@ package dummy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
 )
 
+// UsersKVRepo keeps the users by their Postgres ID.
 type UsersKVRepo interface {
-	Set(ctx context.Context, name string) (domain.UserID, error)
+	Set(ctx context.Context, id domain.UserID, name string) error
+	// Update renames a stored user and points the name index to it under
+	// the new name. It does nothing if the user isn't stored.
+	Update(ctx context.Context, id domain.UserID, name string) error
+	// Delete removes the user and its name index, it returns the number
+	// of keys removed.
+	Delete(ctx context.Context, id domain.UserID) (int64, error)
+	// Export returns the values of the user's keys by key, the user hash
+	// and its name index. Empty if the user isn't stored.
+	Export(ctx context.Context, id domain.UserID) (map[string]any, error)
 }
 
-func NewUsersKVRepo(c *redis.Client, g IDGenerator) UsersKVRepo {
+// NewUsersKVRepo stores the users for the TTL, 0 = without expiry. The
+// names are encrypted, the name index is keyed by their blind index.
+func NewUsersKVRepo(c RedisClient, keys Keys, ttl time.Duration, ci pii.Cipher) UsersKVRepo {
 	return usersKVRepo{
-		client:      c,
-		idGenerator: g,
+		client: c,
+		keys:   keys,
+		ttl:    ttl,
+		cipher: ci,
 	}
 }
 
 type usersKVRepo struct {
-	client      *redis.Client
-	idGenerator IDGenerator
+	client RedisClient
+	keys   Keys
+	ttl    time.Duration
+	cipher pii.Cipher
 }
 
-func (r usersKVRepo) Set(ctx context.Context, name string) (domain.UserID, error) {
-	newID := r.idGenerator.NewID()
+// Set stores the user hash and points the name index to it, atomically.
+func (r usersKVRepo) Set(ctx context.Context, id domain.UserID, name string) error {
+	encrypted, err := r.cipher.Encrypt(nameField, name)
+	if err != nil {
+		return fmt.Errorf("encrypting name: %w", err)
+	}
 
-	if err := r.client.Set(ctx, name, newID, 0).Err(); err != nil {
-		return "", fmt.Errorf("setting key: %w", err)
+	_, err = r.client().TxPipelined(ctx, func(p redis.Pipeliner) error {
+		userKey := r.keys.User(id)
+		p.HSet(ctx, userKey, "name", encrypted, "created_at", time.Now().UTC().Format(time.RFC3339Nano))
+		if r.ttl > 0 {
+			p.Expire(ctx, userKey, r.ttl)
+		}
+		p.Set(ctx, r.keys.UserName(r.cipher.BlindIndex(nameField, name)), string(id), r.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("setting user: %w", err)
 	}
-	id, err := r.client.Get(ctx, name).Result()
+	return nil
+}
+
+// Update watches the user hash and its name index like Delete, the old
+// index is deleted only if it still points to the user.
+func (r usersKVRepo) Update(ctx context.Context, id domain.UserID, name string) error {
+	encrypted, err := r.cipher.Encrypt(nameField, name)
 	if err != nil {
-		if err == redis.Nil {
-			return "", domain.NewNotFoundError("user not found")
+		return fmt.Errorf("encrypting name: %w", err)
+	}
+
+	userKey := r.keys.User(id)
+	err = r.client().Watch(ctx, func(tx *redis.Tx) error {
+		candidates, err := r.nameKeys(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		if err := tx.Watch(ctx, candidates...).Err(); err != nil {
+			return fmt.Errorf("watching name index: %w", err)
+		}
+		oldKeys, err := r.indexing(ctx, tx, id, candidates)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			if len(oldKeys) > 0 {
+				p.Del(ctx, oldKeys...)
+			}
+			p.HSet(ctx, userKey, "name", encrypted)
+			if r.ttl > 0 {
+				p.Expire(ctx, userKey, r.ttl)
+			}
+			p.Set(ctx, r.keys.UserName(r.cipher.BlindIndex(nameField, name)), string(id), r.ttl)
+			return nil
+		})
+		return err
+	}, userKey)
+	if err != nil {
+		return fmt.Errorf("updating user: %w", err)
+	}
+	return nil
+}
+
+// Delete watches the user hash and its name index, the index is deleted
+// only if it still points to the user.
+func (r usersKVRepo) Delete(ctx context.Context, id domain.UserID) (int64, error) {
+	userKey := r.keys.User(id)
+	var deleted int64
+	err := r.client().Watch(ctx, func(tx *redis.Tx) error {
+		candidates, err := r.nameKeys(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if len(candidates) > 0 {
+			if err := tx.Watch(ctx, candidates...).Err(); err != nil {
+				return fmt.Errorf("watching name index: %w", err)
+			}
+		}
+		nameKeys, err := r.indexing(ctx, tx, id, candidates)
+		if err != nil {
+			return err
+		}
+		cmds, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Del(ctx, append([]string{userKey}, nameKeys...)...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		deleted = cmds[0].(*redis.IntCmd).Val()
+		return nil
+	}, userKey)
+	if err != nil {
+		return 0, fmt.Errorf("deleting keys: %w", err)
+	}
+	return deleted, nil
+}
+
+func (r usersKVRepo) Export(ctx context.Context, id domain.UserID) (map[string]any, error) {
+	res := map[string]any{}
+	c := r.client()
+	userKey := r.keys.User(id)
+	user, err := c.HGetAll(ctx, userKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("getting hash: %w", err)
+	}
+	if len(user) > 0 {
+		if encrypted, ok := user["name"]; ok {
+			if user["name"], err = r.cipher.Decrypt(ctx, nameField, encrypted); err != nil {
+				return nil, fmt.Errorf("decrypting name: %w", err)
+			}
+		}
+		res[userKey] = user
+	}
+
+	candidates, err := r.nameKeys(ctx, c, id)
+	if err != nil {
+		return nil, err
+	}
+	nameKeys, err := r.indexing(ctx, c, id, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range nameKeys {
+		res[k] = string(id)
+	}
+	return res, nil
+}
+
+// nameKeys returns the name index keys that may point to the user: of the
+// blind index of its stored name, and of the plaintext name if not
+// reencrypted yet.
+func (r usersKVRepo) nameKeys(ctx context.Context, c redis.Cmdable, id domain.UserID) ([]string, error) {
+	value, err := c.HGet(ctx, r.keys.User(id), "name").Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting name: %w", err)
+	}
+	name, err := r.cipher.Decrypt(ctx, nameField, value)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting name: %w", err)
+	}
+	keys := []string{r.keys.UserName(r.cipher.BlindIndex(nameField, name))}
+	if plain := r.keys.UserName(name); plain != keys[0] {
+		keys = append(keys, plain)
+	}
+	return keys, nil
+}
+
+// indexing returns the name index keys pointing to the user, another user
+// with the same name may have taken the index since.
+func (r usersKVRepo) indexing(ctx context.Context, c redis.Cmdable, id domain.UserID, candidates []string) ([]string, error) {
+	var keys []string
+	for _, k := range candidates {
+		indexed, err := c.Get(ctx, k).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("getting name index: %w", err)
+		}
+		if indexed == string(id) {
+			keys = append(keys, k)
 		}
-		return "", fmt.Errorf("getting key: %w", err)
 	}
-	return domain.UserID(id), nil
+	return keys, nil
 }
@@ -3,12 +3,14 @@ package dummy
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"ws-dummy-go/internal/dummy/domain"
-	"ws-dummy-go/internal/mocks"
+	"ws-dummy-go/internal/pii"
 )
 
 var (
@@ -17,41 +19,136 @@ var (
 
 func Test_usersKVRepo_Set(t *testing.T) {
 	type args struct {
+		id   domain.UserID
 		name string
 	}
 	tests := []struct {
 		name    string
 		args    args
-		want    domain.UserID
 		wantErr bool
 	}{
 		{
 			name:    "Positive: Set user",
-			args:    args{name: "testname123"},
-			want:    domain.UserID("0987654321"),
+			args:    args{id: "987654321", name: "testname123"},
 			wantErr: false,
 		},
 	}
 
-	idGeneratorMock := &mocks.IDGenerator{}
-	idGeneratorMock.EXPECT().NewID().Return("0987654321").Times(1)
-
 	r := usersKVRepo{
-		client:      testRedisClient,
-		idGenerator: idGeneratorMock,
+		client: StaticRedisClient(testRedisClient),
+		keys:   NewKeys("test", "test"),
+		ttl:    time.Hour,
+		cipher: pii.NewPlaintext(),
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// TODO: t.Parallel()
 			assert := assert.New(t)
+			ctx := context.Background()
 
-			got, err := r.Set(context.Background(), tt.args.name)
+			err := r.Set(ctx, tt.args.id, tt.args.name)
 
-			assert.Equal(tt.want, got)
 			assert.Equal(tt.wantErr, err != nil, err)
 
-			idGeneratorMock.AssertExpectations(t)
+			userKey := "test:test:v2:user:" + string(tt.args.id)
+			name, err := testRedisClient.HGet(ctx, userKey, "name").Result()
+			require.NoError(t, err)
+			assert.Equal(tt.args.name, name)
+			id, err := testRedisClient.Get(ctx, "test:test:v2:user_name:"+tt.args.name).Result()
+			require.NoError(t, err)
+			assert.Equal(string(tt.args.id), id)
+			for _, key := range []string{userKey, "test:test:v2:user_name:" + tt.args.name} {
+				ttl, err := testRedisClient.TTL(ctx, key).Result()
+				require.NoError(t, err)
+				assert.Greater(ttl, 59*time.Minute, key)
+			}
 		})
 	}
 }
+
+func Test_usersKVRepo_Update(t *testing.T) {
+	ctx := context.Background()
+	r := usersKVRepo{
+		client: StaticRedisClient(testRedisClient),
+		keys:   NewKeys("test", "test"),
+		cipher: pii.NewPlaintext(),
+	}
+	require.NoError(t, r.Set(ctx, "571", "rename_1"))
+	// Another user took the old name since, its index is left alone.
+	require.NoError(t, r.Set(ctx, "572", "rename_2"))
+	require.NoError(t, r.Update(ctx, "571", "rename_2"))
+	require.NoError(t, r.Update(ctx, "572", "rename_3"))
+
+	got, err := r.Export(ctx, "571")
+	require.NoError(t, err)
+	assert.Equal(t, "571", got["test:test:v2:user_name:rename_2"])
+	assert.Equal(t, "rename_2", got["test:test:v2:user:571"].(map[string]string)["name"])
+	assert.NotContains(t, got, "test:test:v2:user_name:rename_1")
+	n, err := testRedisClient.Exists(ctx, "test:test:v2:user_name:rename_1").Result()
+	require.NoError(t, err)
+	assert.Zero(t, n, "old name index deleted")
+
+	got, err = r.Export(ctx, "572")
+	require.NoError(t, err)
+	assert.Equal(t, "572", got["test:test:v2:user_name:rename_3"])
+	assert.Equal(t, "rename_3", got["test:test:v2:user:572"].(map[string]string)["name"])
+
+	// Not stored, nothing to rename.
+	require.NoError(t, r.Update(ctx, "573", "rename_4"))
+	got, err = r.Export(ctx, "573")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	n, err = testRedisClient.Exists(ctx, "test:test:v2:user_name:rename_4").Result()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func Test_usersKVRepo_ExportDelete(t *testing.T) {
+	ctx := context.Background()
+	r := usersKVRepo{
+		client: StaticRedisClient(testRedisClient),
+		keys:   NewKeys("test", "test"),
+		cipher: pii.NewPlaintext(),
+	}
+	require.NoError(t, r.Set(ctx, "555", "export_1"))
+
+	got, err := r.Export(ctx, "555")
+	require.NoError(t, err)
+	assert.Equal(t, "555", got["test:test:v2:user_name:export_1"])
+	assert.Equal(t, "export_1", got["test:test:v2:user:555"].(map[string]string)["name"])
+
+	deleted, err := r.Delete(ctx, "555")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	got, err = r.Export(ctx, "555")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func Test_usersKVRepo_sameName(t *testing.T) {
+	ctx := context.Background()
+	r := usersKVRepo{
+		client: StaticRedisClient(testRedisClient),
+		keys:   NewKeys("test", "test"),
+		cipher: pii.NewPlaintext(),
+	}
+	require.NoError(t, r.Set(ctx, "561", "same_1"))
+	require.NoError(t, r.Set(ctx, "562", "same_1"))
+
+	// The name index points to the last one, the first keeps its hash only.
+	got, err := r.Export(ctx, "561")
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Contains(t, got, "test:test:v2:user:561")
+
+	deleted, err := r.Delete(ctx, "561")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	got, err = r.Export(ctx, "562")
+	require.NoError(t, err)
+	assert.Equal(t, "562", got["test:test:v2:user_name:same_1"])
+	assert.Equal(t, "same_1", got["test:test:v2:user:562"].(map[string]string)["name"])
+}
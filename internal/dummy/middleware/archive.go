@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"ws-dummy-go/internal/dummy/domain"
+)
+
+// UserArchive is the export of a user's data, by store. The exportUser
+// endpoint and the gdpr command write it.
+type UserArchive struct {
+	ExportedAt time.Time    `json:"exportedAt"`
+	UserID     string       `json:"userId"`
+	Postgres   archivedUser `json:"postgres"`
+	// The values by key, the hashes as objects.
+	Redis map[string]any `json:"redis"`
+	Mongo archivedDocs   `json:"mongo"`
+}
+
+type archivedUser struct {
+	UserID    string     `json:"userId"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Version   int64      `json:"version"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type archivedDocs struct {
+	Users       []map[string]any `json:"users"`
+	UserProfile profileResponse  `json:"userProfile"`
+}
+
+func NewUserArchive(e domain.UserExport, exportedAt time.Time) UserArchive {
+	a := UserArchive{
+		ExportedAt: exportedAt.UTC(),
+		UserID:     string(e.User.ID),
+		Postgres: archivedUser{
+			UserID:    string(e.User.ID),
+			Name:      e.User.Name,
+			CreatedAt: e.User.CreatedAt,
+			Version:   e.User.Version,
+		},
+		Redis: e.Redis,
+		Mongo: archivedDocs{
+			Users:       e.Docs,
+			UserProfile: newProfileResponse(e.Profile).Body.(profileResponse),
+		},
+	}
+	if !e.User.DeletedAt.IsZero() {
+		a.Postgres.DeletedAt = &e.User.DeletedAt
+	}
+	if a.Redis == nil {
+		a.Redis = map[string]any{}
+	}
+	if a.Mongo.Users == nil {
+		a.Mongo.Users = []map[string]any{}
+	}
+	return a
+}
+
+// EncodeUserArchive sends the archive as a file to download.
+func EncodeUserArchive(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if a, ok := response.(UserArchive); ok {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%s.json"`, a.UserID))
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
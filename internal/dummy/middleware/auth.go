@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"ws-dummy-go/internal/auth"
+)
+
+type (
+	authKeyType string
+
+	credentials struct {
+		scheme string
+		token  string
+	}
+)
+
+const (
+	credentialsKey   authKeyType = "credentials"
+	clientSubjectKey authKeyType = "client-subject"
+
+	apiKeyHeader = "X-API-Key"
+)
+
+// Credentials moves the caller's credentials to the context. Both
+// "Authorization: Bearer <jwt>" and "Authorization: ApiKey <key>" are accepted,
+// API keys may also come in the X-API-Key header.
+func Credentials(ctx context.Context, req *http.Request) context.Context {
+	if key := req.Header.Get(apiKeyHeader); key != "" {
+		return context.WithValue(ctx, credentialsKey, credentials{auth.SchemeAPIKey, key})
+	}
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok {
+		return ctx
+	}
+	for _, s := range []string{auth.SchemeBearer, auth.SchemeAPIKey} {
+		if strings.EqualFold(scheme, s) {
+			return context.WithValue(ctx, credentialsKey, credentials{s, strings.TrimSpace(token)})
+		}
+	}
+	return ctx
+}
+
+// ClientCert puts the subject of a verified TLS client certificate in the context.
+func ClientCert(ctx context.Context, req *http.Request) context.Context {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, clientSubjectKey, req.TLS.VerifiedChains[0][0].Subject.String())
+}
+
+func clientSubject(ctx context.Context) string {
+	subject, _ := ctx.Value(clientSubjectKey).(string)
+	return subject
+}
+
+// Authentication verifies the credentials with the verifier registered for
+// their scheme and puts the resulting claims in the context. Callers without
+// credentials fall back to their verified client certificate, if any.
+func Authentication(verifiers map[string]auth.Verifier) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			creds, _ := ctx.Value(credentialsKey).(credentials)
+			if creds.token == "" {
+				creds = credentials{auth.SchemeClientCert, clientSubject(ctx)}
+			}
+			if creds.token == "" {
+				return nil, NewUnauthorizedError("missing credentials")
+			}
+			v, ok := verifiers[creds.scheme]
+			if !ok {
+				return nil, NewUnauthorizedError("unsupported authorization scheme")
+			}
+			claims, err := v.Verify(ctx, creds.token)
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidCredentials) {
+					return nil, NewUnauthorizedError(err.Error())
+				}
+				return nil, NewInternalServerError()
+			}
+			return next(auth.NewContext(ctx, claims), req)
+		}
+	}
+}
+
+// Authorization requires the authenticated caller to have all the scopes.
+func Authorization(scopes ...string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			claims, ok := auth.FromContext(ctx)
+			if !ok {
+				return nil, NewUnauthorizedError("not authenticated")
+			}
+			if !claims.HasScopes(scopes...) {
+				return nil, NewForbiddenError("insufficient scope")
+			}
+			return next(ctx, req)
+		}
+	}
+}
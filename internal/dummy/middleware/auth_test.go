@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/auth"
+	"ws-dummy-go/internal/settings"
+)
+
+// fakeVerifier maps the tokens to their claims, other tokens are invalid.
+type fakeVerifier map[string]auth.Claims
+
+func (v fakeVerifier) Verify(_ context.Context, token string) (auth.Claims, error) {
+	switch token {
+	case "expired":
+		return auth.Claims{}, fmt.Errorf("%w: token expired", auth.ErrInvalidCredentials)
+	case "down":
+		return auth.Claims{}, errors.New("jwks unreachable")
+	}
+	claims, ok := v[token]
+	if !ok {
+		return auth.Claims{}, fmt.Errorf("%w: bad signature", auth.ErrInvalidCredentials)
+	}
+	return claims, nil
+}
+
+func TestAuth(t *testing.T) {
+	verifiers := map[string]auth.Verifier{
+		auth.SchemeBearer:     fakeVerifier{"reader": {Subject: "r", Scopes: []string{"users:read"}}},
+		auth.SchemeAPIKey:     fakeVerifier{"wk_writer": {Subject: "w", Scopes: []string{"users:read", "users:write"}}},
+		auth.SchemeClientCert: fakeVerifier{"CN=svc": {Subject: "CN=svc", Scopes: []string{"users:read"}}},
+	}
+	next := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		claims, _ := auth.FromContext(ctx)
+		return claims.Subject, nil
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		cert    string
+		scopes  []string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "Positive: Bearer token",
+			headers: map[string]string{"Authorization": "Bearer reader"},
+			scopes:  []string{"users:read"},
+			want:    "r",
+		},
+		{
+			name:    "Positive: Scheme case insensitive",
+			headers: map[string]string{"Authorization": "bearer reader"},
+			scopes:  []string{"users:read"},
+			want:    "r",
+		},
+		{
+			name:    "Positive: API key in the Authorization header",
+			headers: map[string]string{"Authorization": "ApiKey wk_writer"},
+			scopes:  []string{"users:read", "users:write"},
+			want:    "w",
+		},
+		{
+			name:    "Positive: API key header first",
+			headers: map[string]string{apiKeyHeader: "wk_writer", "Authorization": "Bearer reader"},
+			scopes:  []string{"users:write"},
+			want:    "w",
+		},
+		{
+			name:   "Positive: Client certificate",
+			cert:   "svc",
+			scopes: []string{"users:read"},
+			want:   "CN=svc",
+		},
+		{
+			name:    "Negative: Missing credentials",
+			scopes:  []string{"users:read"},
+			wantErr: &UnauthorizedError{},
+		},
+		{
+			name:    "Negative: No scheme",
+			headers: map[string]string{"Authorization": "reader"},
+			scopes:  []string{"users:read"},
+			wantErr: &UnauthorizedError{},
+		},
+		{
+			name:    "Negative: Unsupported scheme",
+			headers: map[string]string{"Authorization": "Basic cmVhZGVy"},
+			scopes:  []string{"users:read"},
+			wantErr: &UnauthorizedError{},
+		},
+		{
+			name:    "Negative: Invalid token",
+			headers: map[string]string{"Authorization": "Bearer forged"},
+			scopes:  []string{"users:read"},
+			wantErr: &UnauthorizedError{},
+		},
+		{
+			name:    "Negative: Expired token",
+			headers: map[string]string{"Authorization": "Bearer expired"},
+			scopes:  []string{"users:read"},
+			wantErr: &UnauthorizedError{},
+		},
+		{
+			name:    "Negative: Verifier failure",
+			headers: map[string]string{"Authorization": "Bearer down"},
+			scopes:  []string{"users:read"},
+			wantErr: &InternalServerError{},
+		},
+		{
+			name:    "Negative: Insufficient scope",
+			headers: map[string]string{"Authorization": "Bearer reader"},
+			scopes:  []string{"users:write"},
+			wantErr: &ForbiddenError{},
+		},
+		{
+			name:    "Negative: Client certificate insufficient scope",
+			cert:    "svc",
+			scopes:  []string{"users:write"},
+			wantErr: &ForbiddenError{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users/1", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.cert != "" {
+				cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.cert}}
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			}
+			ctx := ClientCert(Credentials(context.Background(), req), req)
+			e := Authentication(verifiers)(Authorization(tt.scopes...)(next))
+
+			got, err := e(ctx, nil)
+
+			if tt.wantErr != nil {
+				assert.IsType(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthorization_unauthenticated(t *testing.T) {
+	e := Authorization()(func(context.Context, interface{}) (interface{}, error) { return nil, nil })
+
+	_, err := e(context.Background(), nil)
+
+	assert.IsType(t, &UnauthorizedError{}, err)
+}
+
+func TestRequestLogging_credentials(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogfmtLogger(&buf)
+	store := settings.NewStore(settings.Settings{Mode: "debug"})
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"juwis"}`))
+	req.Header.Set("Authorization", "Bearer secret-jwt")
+	req.Header.Set(apiKeyHeader, "wk_secret-key")
+	req.Header.Set(debugLogHeader, "secret-debug")
+	ctx := context.WithValue(context.Background(), requestIDHeader, "req1")
+
+	RequestLogging(logger, store)(ctx, req)
+
+	for _, secret := range []string{"secret-jwt", "wk_secret-key", "secret-debug"} {
+		assert.NotContains(t, buf.String(), secret)
+	}
+	assert.Equal(t, "Bearer secret-jwt", req.Header.Get("Authorization"), "request headers kept")
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"juwis"}`, string(body), "request body kept")
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ws-dummy-go/internal/dummy/domain"
+)
+
+const (
+	cursorNext = "n"
+	cursorPrev = "p"
+
+	// MinCursorKeyLen is the shortest key of the HMAC-SHA256 signatures.
+	MinCursorKeyLen = 32
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type (
+	// CursorCodec makes the opaque page cursors. They are signed, so clients
+	// can't forge positions, and bound to the filters they were made for.
+	CursorCodec struct {
+		key []byte
+	}
+
+	cursor struct {
+		Direction string `json:"d"`
+		CreatedAt int64  `json:"t"` // unix microseconds, the precision of Postgres
+		ID        string `json:"i"`
+		Filter    string `json:"f"`
+	}
+)
+
+func NewCursorCodec(key []byte) (*CursorCodec, error) {
+	if len(key) < MinCursorKeyLen {
+		return nil, fmt.Errorf("cursor key of %d bytes, at least %d needed", len(key), MinCursorKeyLen)
+	}
+	return &CursorCodec{key: key}, nil
+}
+
+// Encode returns the cursor of the page after (cursorNext) or before
+// (cursorPrev) the key.
+func (c *CursorCodec) Encode(direction string, key domain.UserKey, filter domain.UsersFilter) string {
+	payload, _ := json.Marshal(cursor{
+		Direction: direction,
+		CreatedAt: key.CreatedAt.UnixMicro(),
+		ID:        string(key.ID),
+		Filter:    filterHash(filter),
+	})
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// Decode sets the query's position from the cursor.
+func (c *CursorCodec) Decode(s string, q *domain.ListUsersQuery) error {
+	p, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return errInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return errInvalidCursor
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotSig, c.sign(payload)) {
+		return errInvalidCursor
+	}
+
+	var cur cursor
+	if err := json.Unmarshal(payload, &cur); err != nil {
+		return errInvalidCursor
+	}
+	if cur.Filter != filterHash(q.Filter) {
+		return errors.New("cursor was made for other filters")
+	}
+	key := &domain.UserKey{CreatedAt: time.UnixMicro(cur.CreatedAt).UTC(), ID: domain.UserID(cur.ID)}
+	switch cur.Direction {
+	case cursorNext:
+		q.After = key
+	case cursorPrev:
+		q.Before = key
+	default:
+		return errInvalidCursor
+	}
+	return nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func filterHash(f domain.UsersFilter) string {
+	h := sha256.New()
+	for _, v := range []string{
+		f.NamePrefix,
+		strconv.FormatInt(timeMicro(f.CreatedAfter), 10),
+		strconv.FormatInt(timeMicro(f.CreatedBefore), 10),
+	} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12])
+}
+
+func timeMicro(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMicro()
+}
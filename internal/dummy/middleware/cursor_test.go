@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/dummy/domain"
+)
+
+func TestCursorCodec(t *testing.T) {
+	codec, err := NewCursorCodec([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	other, err := NewCursorCodec([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	_, err = NewCursorCodec([]byte("secret"))
+	assert.Error(t, err, "short key")
+	filter := domain.UsersFilter{NamePrefix: "ju"}
+	key := domain.UserKey{CreatedAt: time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC), ID: "42"}
+	next := codec.Encode(cursorNext, key, filter)
+
+	tests := []struct {
+		name    string
+		cursor  string
+		filter  domain.UsersFilter
+		want    domain.ListUsersQuery
+		wantErr bool
+	}{
+		{
+			name:   "Positive: Next page",
+			cursor: next,
+			filter: filter,
+			want:   domain.ListUsersQuery{Filter: filter, After: &key},
+		},
+		{
+			name:   "Positive: Previous page",
+			cursor: codec.Encode(cursorPrev, key, filter),
+			filter: filter,
+			want:   domain.ListUsersQuery{Filter: filter, Before: &key},
+		},
+		{
+			name:    "Negative: Other filter",
+			cursor:  next,
+			filter:  domain.UsersFilter{NamePrefix: "x"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Other key",
+			cursor:  other.Encode(cursorNext, key, filter),
+			filter:  filter,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Tampered payload",
+			cursor:  "x" + next[1:],
+			filter:  filter,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Garbage",
+			cursor:  "garbage",
+			filter:  filter,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			q := domain.ListUsersQuery{Filter: tt.filter}
+			err := codec.Decode(tt.cursor, &q)
+
+			assert.Equal(tt.wantErr, err != nil, err)
+			if !tt.wantErr {
+				assert.Equal(tt.want, q)
+			}
+		})
+	}
+}
+
+func TestEncodeListUsersResponse(t *testing.T) {
+	req := httptest.NewRequest("GET", "/listUsers?limit=2&name_prefix=ju&cursor=old", nil)
+	ctx := httptransport.PopulateRequestContext(context.Background(), req)
+	w := httptest.NewRecorder()
+
+	err := EncodeListUsersResponse(ctx, w, listUsersResponse{Users: []userResponse{}, NextCursor: "n1", PrevCursor: "p1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`</listUsers?cursor=n1&limit=2&name_prefix=ju>; rel="next"`,
+		`</listUsers?cursor=p1&limit=2&name_prefix=ju>; rel="prev"`,
+	}, w.Header().Values("Link"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), `{"users":[],"nextCursor":"n1"`))
+}
@@ -3,17 +3,32 @@ package middleware
 import (
 	"context"
 	"errors"
+	"html"
+	"strconv"
+	"strings"
+	"time"
 	"ws-dummy-go/internal/dummy"
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/settings"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-playground/validator/v10"
 )
 
 var (
-	validate = validator.New(validator.WithRequiredStructEnabled())
+	validate = newValidator()
 )
 
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// userid is a Postgres user_id: a positive bigint.
+	_ = v.RegisterValidation("userid", func(fl validator.FieldLevel) bool {
+		id, err := strconv.ParseInt(fl.Field().String(), 10, 64)
+		return err == nil && id > 0
+	})
+	return v
+}
+
 func MakeCreateUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
 	return func(ctx context.Context, req interface{}) (interface{}, error) {
 		request, ok := req.(createUserRequest)
@@ -34,3 +49,289 @@ func MakeCreateUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
 		return createUserResponse{UserID: string(id)}, nil
 	}
 }
+
+func MakeGetUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(getUserRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		u, err := svc.GetUser(ctx, domain.UserID(request.UserID))
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		return versionedResponse{
+			Body:        userResponse{UserID: string(u.ID), Name: u.Name, CreatedAt: u.CreatedAt},
+			Version:     u.Version,
+			NotModified: request.IfNoneMatch.Matches(u.Version),
+		}, nil
+	}
+}
+
+func MakeUpdateUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(updateUserRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		u, err := svc.UpdateUser(ctx, domain.UserID(request.UserID), request.Name, request.Match)
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		return versionedResponse{
+			Body:    userResponse{UserID: string(u.ID), Name: u.Name, CreatedAt: u.CreatedAt},
+			Version: u.Version,
+		}, nil
+	}
+}
+
+func MakeDeleteUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(deleteUserRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		if err := svc.DeleteUser(ctx, domain.UserID(request.UserID), request.Match); err != nil {
+			return nil, serviceError(err)
+		}
+		return emptyResponse{}, nil
+	}
+}
+
+func MakeRestoreUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(restoreUserRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		u, err := svc.RestoreUser(ctx, domain.UserID(request.UserID))
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		return versionedResponse{
+			Body:    userResponse{UserID: string(u.ID), Name: u.Name, CreatedAt: u.CreatedAt},
+			Version: u.Version,
+		}, nil
+	}
+}
+
+func MakeEraseUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(eraseUserRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		e, err := svc.EraseUser(ctx, domain.UserID(request.UserID))
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		return erasureResponse{
+			UserID:      string(e.UserID),
+			RequestedAt: e.RequestedAt,
+			CompletedAt: e.CompletedAt,
+			DocsDeleted: e.DocsDeleted,
+			KeysDeleted: e.KeysDeleted,
+		}, nil
+	}
+}
+
+func MakeExportUserEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(exportUserRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		e, err := svc.ExportUser(ctx, domain.UserID(request.UserID))
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		return NewUserArchive(e, time.Now()), nil
+	}
+}
+
+func MakeGetProfileEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(getProfileRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		p, err := svc.GetProfile(ctx, domain.UserID(request.UserID))
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		res := newProfileResponse(p)
+		res.NotModified = request.IfNoneMatch.Matches(p.Version)
+		return res, nil
+	}
+}
+
+func MakePutProfileEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(putProfileRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		p, err := svc.PutProfile(ctx, domain.UserID(request.UserID), request.Data, request.Match)
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		return newProfileResponse(p), nil
+	}
+}
+
+func MakePatchProfileEndpoint(svc dummy.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(patchProfileRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		p, err := svc.PatchProfile(ctx, domain.UserID(request.UserID), request.Patch, request.Match)
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		return newProfileResponse(p), nil
+	}
+}
+
+func newProfileResponse(p domain.Profile) versionedResponse {
+	body := profileResponse{UserID: string(p.UserID), Data: p.Data, Version: p.Version}
+	if !p.UpdatedAt.IsZero() {
+		body.UpdatedAt = &p.UpdatedAt
+	}
+	return versionedResponse{Body: body, Version: p.Version}
+}
+
+// serviceError maps the domain errors and hides the others.
+func serviceError(err error) error {
+	var (
+		notFound *domain.NotFoundError
+		invalid  *domain.InvalidError
+		conflict *domain.ConflictError
+		tooLarge *domain.TooLargeError
+		mismatch *domain.PreconditionFailedError
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return NewNotFoundError(notFound.Error())
+	case errors.As(err, &invalid):
+		return NewValidationError(invalid.Error())
+	case errors.As(err, &conflict):
+		return NewConflictError(conflict.Error())
+	case errors.As(err, &tooLarge):
+		return NewPayloadTooLargeError(tooLarge.Error())
+	case errors.As(err, &mismatch):
+		return NewPreconditionFailedError(mismatch.Error())
+	}
+	return NewInternalServerError()
+}
+
+func MakeListUsersEndpoint(svc dummy.UserService, cursors *CursorCodec) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(listUsersRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		q := domain.ListUsersQuery{
+			Filter: domain.UsersFilter{
+				NamePrefix:    request.NamePrefix,
+				CreatedAfter:  request.CreatedAfter,
+				CreatedBefore: request.CreatedBefore,
+			},
+			Limit: request.Limit,
+		}
+		if request.Cursor != "" {
+			if err := cursors.Decode(request.Cursor, &q); err != nil {
+				return nil, NewValidationError(err.Error())
+			}
+		}
+
+		page, err := svc.ListUsers(ctx, q)
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		res := listUsersResponse{Users: make([]userResponse, 0, len(page.Users))}
+		for _, u := range page.Users {
+			res.Users = append(res.Users, userResponse{UserID: string(u.ID), Name: u.Name, CreatedAt: u.CreatedAt})
+		}
+		if page.Next != nil {
+			res.NextCursor = cursors.Encode(cursorNext, *page.Next, q.Filter)
+		}
+		if page.Prev != nil {
+			res.PrevCursor = cursors.Encode(cursorPrev, *page.Prev, q.Filter)
+		}
+		return res, nil
+	}
+}
+
+// MakeSearchUsersEndpoint uses the configured minimum similarity for the
+// requests that don't give one.
+func MakeSearchUsersEndpoint(svc dummy.UserService, store *settings.Store) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		request, ok := req.(searchUsersRequest)
+		if !ok {
+			return nil, NewNotImplementedError()
+		}
+		if err := validate.Struct(request); err != nil {
+			return nil, NewValidationError(err.Error())
+		}
+		q := domain.SearchUsersQuery{
+			Text:          request.Text,
+			MinSimilarity: request.MinSimilarity,
+			Offset:        request.Offset,
+			Limit:         request.Limit,
+		}
+		if q.MinSimilarity == 0 {
+			q.MinSimilarity = store.Load().SearchMinSimilarity
+		}
+
+		page, err := svc.SearchUsers(ctx, q)
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		res := searchUsersResponse{Users: make([]userMatchResponse, 0, len(page.Matches)), NextOffset: page.NextOffset}
+		for _, m := range page.Matches {
+			res.Users = append(res.Users, userMatchResponse{
+				userResponse: userResponse{UserID: string(m.ID), Name: m.Name, CreatedAt: m.CreatedAt},
+				Rank:         m.Rank,
+				Highlight:    highlightHTML(m.Highlight),
+			})
+		}
+		return res, nil
+	}
+}
+
+// highlightHTML escapes the name and replaces the highlight markers with <mark>.
+func highlightHTML(s string) string {
+	s = html.EscapeString(s)
+	return strings.NewReplacer(domain.HighlightStart, "<mark>", domain.HighlightStop, "</mark>").Replace(s)
+}
@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/mocks"
+	"ws-dummy-go/internal/pii"
+	"ws-dummy-go/internal/settings"
+)
+
+func TestMakeCreateUserEndpoint(t *testing.T) {
+	svcMock := &mocks.UserService{}
+	e := MakeCreateUserEndpoint(svcMock)
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		arrange func()
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "Positive: Create user",
+			req:  createUserRequest{Name: "john"},
+			arrange: func() {
+				svcMock.EXPECT().CreateUser(mock.Anything, "john").Return(domain.UserID("42"), nil).Once()
+			},
+			want: createUserResponse{UserID: "42"},
+		},
+		{
+			name:    "Negative: No name",
+			req:     createUserRequest{},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+		{
+			name:    "Negative: Name like an encrypted one",
+			req:     createUserRequest{Name: pii.Prefix + "d1:abc"},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			tt.arrange()
+			got, err := e(context.Background(), tt.req)
+
+			if tt.wantErr != nil {
+				assert.IsType(tt.wantErr, err)
+				assert.Nil(got)
+			} else {
+				assert.NoError(err)
+				assert.Equal(tt.want, got)
+			}
+			svcMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMakeSearchUsersEndpoint(t *testing.T) {
+	svcMock := &mocks.UserService{}
+	store := settings.NewStore(settings.Settings{SearchMinSimilarity: 0.3})
+	e := MakeSearchUsersEndpoint(svcMock, store)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	match := domain.UserMatch{
+		User:      domain.User{ID: "1", Name: "<b>john</b> smith", CreatedAt: created},
+		Rank:      1.2,
+		Highlight: "<b>" + domain.HighlightStart + "john" + domain.HighlightStop + "</b> smith",
+	}
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		arrange func()
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "Positive: Default min similarity, highlight escaped",
+			req:  searchUsersRequest{Text: "jon", Limit: 10},
+			arrange: func() {
+				svcMock.EXPECT().SearchUsers(mock.Anything, domain.SearchUsersQuery{Text: "jon", MinSimilarity: 0.3, Limit: 10}).
+					Return(domain.UsersSearchPage{Matches: []domain.UserMatch{match}, NextOffset: 10}, nil).Once()
+			},
+			want: searchUsersResponse{
+				Users: []userMatchResponse{{
+					userResponse: userResponse{UserID: "1", Name: "<b>john</b> smith", CreatedAt: created},
+					Rank:         1.2,
+					Highlight:    "&lt;b&gt;<mark>john</mark>&lt;/b&gt; smith",
+				}},
+				NextOffset: 10,
+			},
+		},
+		{
+			name: "Positive: Given min similarity",
+			req:  searchUsersRequest{Text: "jon", MinSimilarity: 0.8, Offset: 20, Limit: 10},
+			arrange: func() {
+				svcMock.EXPECT().SearchUsers(mock.Anything, domain.SearchUsersQuery{Text: "jon", MinSimilarity: 0.8, Offset: 20, Limit: 10}).
+					Return(domain.UsersSearchPage{}, nil).Once()
+			},
+			want: searchUsersResponse{Users: []userMatchResponse{}},
+		},
+		{
+			name:    "Negative: No text",
+			req:     searchUsersRequest{Limit: 10},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+		{
+			name:    "Negative: Min similarity over 1",
+			req:     searchUsersRequest{Text: "jon", MinSimilarity: 1.5, Limit: 10},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+		{
+			name: "Negative: Service fails",
+			req:  searchUsersRequest{Text: "jon", Limit: 10},
+			arrange: func() {
+				svcMock.EXPECT().SearchUsers(mock.Anything, mock.Anything).
+					Return(domain.UsersSearchPage{}, errors.New("mock error")).Once()
+			},
+			wantErr: &InternalServerError{},
+		},
+		{
+			name: "Negative: Invalid query",
+			req:  searchUsersRequest{Text: "jon", Limit: 10},
+			arrange: func() {
+				svcMock.EXPECT().SearchUsers(mock.Anything, mock.Anything).
+					Return(domain.UsersSearchPage{}, domain.NewInvalidError("bad query")).Once()
+			},
+			wantErr: &ValidationError{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			tt.arrange()
+			got, err := e(context.Background(), tt.req)
+
+			if tt.wantErr != nil {
+				assert.IsType(tt.wantErr, err)
+				assert.Nil(got)
+			} else {
+				assert.NoError(err)
+				assert.Equal(tt.want, got)
+			}
+			svcMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMakeGetUserEndpoint(t *testing.T) {
+	svcMock := &mocks.UserService{}
+	e := MakeGetUserEndpoint(svcMock)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		arrange func()
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "Positive: Get user",
+			req:  getUserRequest{UserID: "42"},
+			arrange: func() {
+				svcMock.EXPECT().GetUser(mock.Anything, domain.UserID("42")).
+					Return(domain.User{ID: "42", Name: "john", CreatedAt: created, Version: 2}, nil).Once()
+			},
+			want: versionedResponse{Body: userResponse{UserID: "42", Name: "john", CreatedAt: created}, Version: 2},
+		},
+		{
+			name: "Positive: Not modified",
+			req:  getUserRequest{UserID: "42", IfNoneMatch: domain.VersionMatch{Versions: []int64{1, 2}}},
+			arrange: func() {
+				svcMock.EXPECT().GetUser(mock.Anything, domain.UserID("42")).
+					Return(domain.User{ID: "42", Name: "john", CreatedAt: created, Version: 2}, nil).Once()
+			},
+			want: versionedResponse{Body: userResponse{UserID: "42", Name: "john", CreatedAt: created}, Version: 2, NotModified: true},
+		},
+		{
+			name:    "Negative: Not a number",
+			req:     getUserRequest{UserID: "42abc"},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+		{
+			name:    "Negative: Overflows bigint",
+			req:     getUserRequest{UserID: "9223372036854775808"},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+		{
+			name:    "Negative: Not positive",
+			req:     getUserRequest{UserID: "0"},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+		{
+			name:    "Negative: Composite ID",
+			req:     getUserRequest{UserID: "1-2-3"},
+			arrange: func() {},
+			wantErr: &ValidationError{},
+		},
+		{
+			name: "Negative: Not found",
+			req:  getUserRequest{UserID: "43"},
+			arrange: func() {
+				svcMock.EXPECT().GetUser(mock.Anything, domain.UserID("43")).
+					Return(domain.User{}, fmt.Errorf("getting: %w", domain.NewNotFoundError("user not found"))).Once()
+			},
+			wantErr: &NotFoundError{},
+		},
+		{
+			name: "Negative: Service fails",
+			req:  getUserRequest{UserID: "44"},
+			arrange: func() {
+				svcMock.EXPECT().GetUser(mock.Anything, domain.UserID("44")).
+					Return(domain.User{}, errors.New("mock error")).Once()
+			},
+			wantErr: &InternalServerError{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			tt.arrange()
+			got, err := e(context.Background(), tt.req)
+
+			if tt.wantErr != nil {
+				assert.IsType(tt.wantErr, err)
+				assert.Nil(got)
+			} else {
+				assert.NoError(err)
+				assert.Equal(tt.want, got)
+			}
+			svcMock.AssertExpectations(t)
+		})
+	}
+}
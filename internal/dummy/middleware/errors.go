@@ -3,6 +3,9 @@ package middleware
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+
+	"ws-dummy-go/internal/auth"
 )
 
 type (
@@ -119,3 +122,252 @@ func (e *NotImplementedError) MarshalJSON() ([]byte, error) {
 		},
 	})
 }
+
+// 401 Unauthorized
+
+type UnauthorizedError struct {
+	Message string
+}
+
+func NewUnauthorizedError(msg string) error {
+	return &UnauthorizedError{Message: msg}
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Message
+}
+
+func (UnauthorizedError) StatusCode() int {
+	return http.StatusUnauthorized
+}
+
+func (UnauthorizedError) Headers() http.Header {
+	return http.Header{"WWW-Authenticate": []string{auth.SchemeBearer, auth.SchemeAPIKey}}
+}
+
+func (e *UnauthorizedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60803,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 403 Forbidden
+
+type ForbiddenError struct {
+	Message string
+}
+
+func NewForbiddenError(msg string) error {
+	return &ForbiddenError{Message: msg}
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
+func (ForbiddenError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e *ForbiddenError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60804,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 429 Too Many Requests
+
+type TooManyRequestsError struct{}
+
+func NewTooManyRequestsError() error {
+	return &TooManyRequestsError{}
+}
+
+func (*TooManyRequestsError) Error() string {
+	return "too many requests"
+}
+
+func (TooManyRequestsError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+func (e *TooManyRequestsError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60805,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 409 Conflict
+
+type ConflictError struct {
+	Message string
+}
+
+func NewConflictError(msg string) error {
+	return &ConflictError{Message: msg}
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+func (ConflictError) StatusCode() int {
+	return http.StatusConflict
+}
+
+func (e *ConflictError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60806,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 412 Precondition Failed
+
+type PreconditionFailedError struct {
+	Message string
+}
+
+func NewPreconditionFailedError(msg string) error {
+	return &PreconditionFailedError{Message: msg}
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return e.Message
+}
+
+func (PreconditionFailedError) StatusCode() int {
+	return http.StatusPreconditionFailed
+}
+
+func (e *PreconditionFailedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60809,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 413 Payload Too Large
+
+type PayloadTooLargeError struct {
+	Message string
+}
+
+func NewPayloadTooLargeError(msg string) error {
+	return &PayloadTooLargeError{Message: msg}
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return e.Message
+}
+
+func (PayloadTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+func (e *PayloadTooLargeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60807,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 415 Unsupported Media Type
+
+type UnsupportedMediaTypeError struct {
+	Accept []string // the supported patch types, also sent as Accept-Patch
+}
+
+func NewUnsupportedMediaTypeError(accept ...string) error {
+	return &UnsupportedMediaTypeError{Accept: accept}
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	return "unsupported content type, use one of: " + strings.Join(e.Accept, ", ")
+}
+
+func (UnsupportedMediaTypeError) StatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
+func (e UnsupportedMediaTypeError) Headers() http.Header {
+	return http.Header{"Accept-Patch": []string{strings.Join(e.Accept, ", ")}}
+}
+
+func (e *UnsupportedMediaTypeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60808,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 428 Precondition Required
+
+type PreconditionRequiredError struct {
+	Message string
+}
+
+func NewPreconditionRequiredError(msg string) error {
+	return &PreconditionRequiredError{Message: msg}
+}
+
+func (e *PreconditionRequiredError) Error() string {
+	return e.Message
+}
+
+func (PreconditionRequiredError) StatusCode() int {
+	return http.StatusPreconditionRequired
+}
+
+func (e *PreconditionRequiredError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60810,
+			Message: e.Error(),
+		},
+	})
+}
+
+// 503 Service Unavailable
+
+type ServiceUnavailableError struct {
+	Message string
+}
+
+func NewServiceUnavailableError(msg string) error {
+	return &ServiceUnavailableError{Message: msg}
+}
+
+func (e *ServiceUnavailableError) Error() string {
+	return e.Message
+}
+
+func (ServiceUnavailableError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e *ServiceUnavailableError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ErrorResponse{
+		Error: APIError{
+			Code:    60903,
+			Message: e.Error(),
+		},
+	})
+}
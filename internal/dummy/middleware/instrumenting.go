@@ -34,3 +34,113 @@ func (mw instrmw) CreateUser(ctx context.Context, name string) (domain.UserID, e
 
 	return mw.UserService.CreateUser(ctx, name)
 }
+
+func (mw instrmw) GetUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "GetUser", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.GetUser(ctx, id)
+}
+
+func (mw instrmw) UpdateUser(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (domain.User, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "UpdateUser", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.UpdateUser(ctx, id, name, match)
+}
+
+func (mw instrmw) DeleteUser(ctx context.Context, id domain.UserID, match domain.VersionMatch) error {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "DeleteUser", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.DeleteUser(ctx, id, match)
+}
+
+func (mw instrmw) RestoreUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "RestoreUser", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.RestoreUser(ctx, id)
+}
+
+func (mw instrmw) EraseUser(ctx context.Context, id domain.UserID) (domain.UserErasure, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "EraseUser", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.EraseUser(ctx, id)
+}
+
+func (mw instrmw) ExportUser(ctx context.Context, id domain.UserID) (domain.UserExport, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "ExportUser", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.ExportUser(ctx, id)
+}
+
+func (mw instrmw) GetProfile(ctx context.Context, id domain.UserID) (domain.Profile, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "GetProfile", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.GetProfile(ctx, id)
+}
+
+func (mw instrmw) PutProfile(ctx context.Context, id domain.UserID, data map[string]any, match domain.VersionMatch) (domain.Profile, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "PutProfile", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.PutProfile(ctx, id, data, match)
+}
+
+func (mw instrmw) PatchProfile(ctx context.Context, id domain.UserID, patch domain.ProfilePatch, match domain.VersionMatch) (domain.Profile, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "PatchProfile", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.PatchProfile(ctx, id, patch, match)
+}
+
+func (mw instrmw) ListUsers(ctx context.Context, q domain.ListUsersQuery) (domain.UsersPage, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "ListUsers", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.ListUsers(ctx, q)
+}
+
+func (mw instrmw) SearchUsers(ctx context.Context, q domain.SearchUsersQuery) (domain.UsersSearchPage, error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "SearchUsers", "error", "false"}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.UserService.SearchUsers(ctx, q)
+}
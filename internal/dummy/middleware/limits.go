@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+	"golang.org/x/time/rate"
+
+	"ws-dummy-go/internal/settings"
+)
+
+// NewRateLimiter returns the limiter of the whole server, every route
+// draws from it. The limit follows the settings, so it can be changed
+// without a restart.
+func NewRateLimiter(store *settings.Store) *rate.Limiter {
+	// Created with the current settings, so it starts with a full burst.
+	limiter := rate.NewLimiter(rateLimit(store.Load()), store.Load().RateBurst)
+	store.Subscribe(func(s *settings.Settings) {
+		limiter.SetLimit(rateLimit(s))
+		limiter.SetBurst(s.RateBurst)
+	})
+	return limiter
+}
+
+func rateLimit(s *settings.Settings) rate.Limit {
+	if s.RateLimit <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(s.RateLimit)
+}
+
+// RateLimit rejects requests above the rate of the limiter, shared by the
+// endpoints it wraps.
+func RateLimit(limiter *rate.Limiter) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			if !limiter.Allow() {
+				return nil, NewTooManyRequestsError()
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// Timeout cancels requests running longer than the current request timeout.
+func Timeout(store *settings.Store) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, store.Load().RequestTimeout)
+			defer cancel()
+
+			resp, err := next(ctx, req)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, NewServiceUnavailableError("request timed out")
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ws-dummy-go/internal/settings"
+)
+
+func TestRateLimit(t *testing.T) {
+	store := settings.NewStore(settings.Settings{RateLimit: 0.001, RateBurst: 2})
+	limit := RateLimit(NewRateLimiter(store))
+	ok := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	getUser, createUser := limit(ok), limit(ok)
+
+	_, err := getUser(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = createUser(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = getUser(context.Background(), nil)
+	assert.IsType(t, &TooManyRequestsError{}, err, "the burst is shared by the endpoints")
+
+	store.Update(settings.Settings{RateLimit: 0})
+	_, err = createUser(context.Background(), nil)
+	assert.NoError(t, err, "unlimited")
+}
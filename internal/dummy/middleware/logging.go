@@ -2,23 +2,39 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"time"
 
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 
 	"ws-dummy-go/internal/dummy"
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/logging"
+	"ws-dummy-go/internal/settings"
 )
 
+const debugLogHeader = "X-Debug-Log"
+
+// credentialHeaders are masked in the dumped requests.
+var credentialHeaders = []string{"Authorization", apiKeyHeader, debugLogHeader}
+
+// nameParams are the query parameters with names, masked in the logged
+// URLs. The cursors have the filters.
+var nameParams = []string{"q", "name_prefix", "cursor"}
+
 func NewLoggingMiddleware(logger log.Logger) UserServiceMiddleware {
 	return func(next dummy.UserService) dummy.UserService {
 		return logmw{logger, next}
 	}
 }
 
+// logmw logs the IDs, lengths and counts, no personal data: the names
+// only by their length.
 type logmw struct {
 	logger log.Logger
 	dummy.UserService
@@ -26,9 +42,13 @@ type logmw struct {
 
 func (mw logmw) CreateUser(ctx context.Context, name string) (output domain.UserID, err error) {
 	defer func(begin time.Time) {
-		mw.logger.Log(
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
 			"method", "CreateUser",
-			"input", name,
+			"nameLen", len(name),
 			"output", output,
 			"err", err,
 			"took", time.Since(begin),
@@ -39,23 +59,290 @@ func (mw logmw) CreateUser(ctx context.Context, name string) (output domain.User
 	return
 }
 
-func RequestLogging(logger log.Logger, mode string) httptransport.RequestFunc {
+func (mw logmw) GetUser(ctx context.Context, id domain.UserID) (output domain.User, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "GetUser",
+			"input", id,
+			"version", output.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.GetUser(ctx, id)
+	return
+}
+
+func (mw logmw) UpdateUser(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (output domain.User, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "UpdateUser",
+			"input", id,
+			"nameLen", len(name),
+			"match", match,
+			"version", output.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.UpdateUser(ctx, id, name, match)
+	return
+}
+
+func (mw logmw) DeleteUser(ctx context.Context, id domain.UserID, match domain.VersionMatch) (err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "DeleteUser",
+			"input", id,
+			"match", match,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	err = mw.UserService.DeleteUser(ctx, id, match)
+	return
+}
+
+func (mw logmw) RestoreUser(ctx context.Context, id domain.UserID) (output domain.User, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "RestoreUser",
+			"input", id,
+			"version", output.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.RestoreUser(ctx, id)
+	return
+}
+
+func (mw logmw) EraseUser(ctx context.Context, id domain.UserID) (output domain.UserErasure, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "EraseUser",
+			"input", id,
+			"docsDeleted", output.DocsDeleted,
+			"keysDeleted", output.KeysDeleted,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.EraseUser(ctx, id)
+	return
+}
+
+func (mw logmw) ExportUser(ctx context.Context, id domain.UserID) (output domain.UserExport, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "ExportUser",
+			"input", id,
+			"docs", len(output.Docs),
+			"keys", len(output.Redis),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.ExportUser(ctx, id)
+	return
+}
+
+func (mw logmw) GetProfile(ctx context.Context, id domain.UserID) (output domain.Profile, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "GetProfile",
+			"input", id,
+			"version", output.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.GetProfile(ctx, id)
+	return
+}
+
+func (mw logmw) PutProfile(ctx context.Context, id domain.UserID, data map[string]any, match domain.VersionMatch) (output domain.Profile, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "PutProfile",
+			"input", id,
+			"fields", len(data),
+			"match", match,
+			"version", output.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.PutProfile(ctx, id, data, match)
+	return
+}
+
+func (mw logmw) PatchProfile(ctx context.Context, id domain.UserID, patch domain.ProfilePatch, match domain.VersionMatch) (output domain.Profile, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "PatchProfile",
+			"input", id,
+			"merge", patch.Merge != nil,
+			"ops", len(patch.Ops),
+			"match", match,
+			"version", output.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.PatchProfile(ctx, id, patch, match)
+	return
+}
+
+func (mw logmw) ListUsers(ctx context.Context, q domain.ListUsersQuery) (output domain.UsersPage, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "ListUsers",
+			"namePrefixLen", len(q.Filter.NamePrefix),
+			"limit", q.Limit,
+			"paged", q.After != nil || q.Before != nil,
+			"output", len(output.Users),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.ListUsers(ctx, q)
+	return
+}
+
+func (mw logmw) SearchUsers(ctx context.Context, q domain.SearchUsersQuery) (output domain.UsersSearchPage, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(logging.FromContext(ctx, mw.logger))
+		if err != nil {
+			logger = level.Error(logging.FromContext(ctx, mw.logger))
+		}
+		logger.Log(
+			"method", "SearchUsers",
+			"textLen", len(q.Text),
+			"minSimilarity", q.MinSimilarity,
+			"offset", q.Offset,
+			"limit", q.Limit,
+			"output", len(output.Matches),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.UserService.SearchUsers(ctx, q)
+	return
+}
+
+// DebugOverride marks the request for debug logging when the X-Debug-Log
+// header matches the token. An empty token disables the override.
+func DebugOverride(token string) httptransport.RequestFunc {
 	return func(ctx context.Context, req *http.Request) context.Context {
+		got := req.Header.Get(debugLogHeader)
+		if token == "" || got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return ctx
+		}
+		return logging.NewContext(ctx)
+	}
+}
+
+// RequestLogging logs the requests, in debug mode or with the debug override
+// with the dumped headers. The bodies have names, they aren't logged. The
+// mode is read per request, so it can be changed without a restart.
+func RequestLogging(logger log.Logger, store *settings.Store) httptransport.RequestFunc {
+	return func(ctx context.Context, req *http.Request) context.Context {
+		logger := logging.FromContext(ctx, logger)
 		rawRequest := []byte("hidden")
 
-		if mode == "debug" {
+		if store.Load().Mode == "debug" || logging.Forced(ctx) {
 			var err error
-			rawRequest, err = httputil.DumpRequest(req, true)
+			rawRequest, err = dumpRequest(req)
 			if err != nil {
-				logger.Log("msg", "dumping request", "err", err)
+				level.Error(logger).Log("msg", "dumping request", "err", err)
 				return ctx
 			}
 		}
 		reqID := ctx.Value(requestIDHeader).(string)
-		logger.Log(
-			"msg", "request", "method", req.Method, "url", req.URL, "len", req.ContentLength,
-			"reqID", reqID, "rawRequest", rawRequest,
+		level.Info(logger).Log(
+			"msg", "request", "method", req.Method, "url", maskedURL(req.URL), "len", req.ContentLength,
+			"reqID", reqID, "clientSubject", clientSubject(ctx), "rawRequest", rawRequest,
 		)
 		return ctx
 	}
 }
+
+// dumpRequest dumps the request without the body, with the credential
+// headers and the names in the URL masked.
+func dumpRequest(req *http.Request) ([]byte, error) {
+	masked := *req
+	masked.Header = req.Header.Clone()
+	for _, h := range credentialHeaders {
+		if masked.Header.Get(h) != "" {
+			masked.Header.Set(h, "[masked]")
+		}
+	}
+	masked.URL = maskedURL(req.URL)
+	masked.RequestURI = ""
+	return httputil.DumpRequest(&masked, false)
+}
+
+// maskedURL copies the URL with the values of the name parameters masked.
+func maskedURL(u *url.URL) *url.URL {
+	masked := *u
+	query := u.Query()
+	for _, p := range nameParams {
+		if query.Has(p) {
+			query.Set(p, "[masked]")
+		}
+	}
+	masked.RawQuery = query.Encode()
+	return &masked
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/mocks"
+	"ws-dummy-go/internal/settings"
+)
+
+func TestLoggingMiddleware_names(t *testing.T) {
+	var buf bytes.Buffer
+	svcMock := &mocks.UserService{}
+	svc := NewLoggingMiddleware(log.NewLogfmtLogger(&buf))(svcMock)
+	ctx := context.Background()
+	match := domain.VersionMatch{Any: true}
+
+	svcMock.EXPECT().CreateUser(mock.Anything, "katherine").Return("1", nil).Once()
+	svcMock.EXPECT().GetUser(mock.Anything, domain.UserID("1")).Return(domain.User{ID: "1", Name: "katherine"}, nil).Once()
+	svcMock.EXPECT().UpdateUser(mock.Anything, domain.UserID("1"), "katherine", match).Return(domain.User{ID: "1", Name: "katherine"}, nil).Once()
+	svcMock.EXPECT().ListUsers(mock.Anything, mock.Anything).Return(domain.UsersPage{}, nil).Once()
+	svcMock.EXPECT().SearchUsers(mock.Anything, mock.Anything).Return(domain.UsersSearchPage{}, nil).Once()
+
+	_, _ = svc.CreateUser(ctx, "katherine")
+	_, _ = svc.GetUser(ctx, "1")
+	_, _ = svc.UpdateUser(ctx, "1", "katherine", match)
+	_, _ = svc.ListUsers(ctx, domain.ListUsersQuery{Filter: domain.UsersFilter{NamePrefix: "kath"}, Limit: 10})
+	_, _ = svc.SearchUsers(ctx, domain.SearchUsersQuery{Text: "katherin", Limit: 10})
+
+	assert.NotContains(t, buf.String(), "kath")
+	assert.Contains(t, buf.String(), "nameLen=9")
+	svcMock.AssertExpectations(t)
+}
+
+func TestRequestLogging_names(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogfmtLogger(&buf)
+	store := settings.NewStore(settings.Settings{Mode: "debug"})
+	ctx := context.WithValue(context.Background(), requestIDHeader, "req1")
+
+	for _, req := range []string{"/createUser", "/searchUsers?q=katherin&limit=5", "/listUsers?name_prefix=kath&cursor=abc"} {
+		RequestLogging(logger, store)(ctx, httptest.NewRequest("POST", req, strings.NewReader(`{"name":"katherine"}`)))
+	}
+
+	assert.NotContains(t, buf.String(), "kath")
+	assert.NotContains(t, buf.String(), "cursor=abc")
+	assert.Contains(t, buf.String(), "limit=5")
+}
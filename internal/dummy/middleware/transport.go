@@ -3,32 +3,165 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/jsondoc"
 )
 
 type (
 	DecodingMiddleware func(httptransport.DecodeRequestFunc) httptransport.DecodeRequestFunc
 )
 
+// The names can't start like the encrypted ones, see pii.Prefix.
 type createUserRequest struct {
-	Name string `json:"name" validate:"required"`
+	Name string `json:"name" validate:"required,startsnotwith=enc:v1:"`
 }
 
 type createUserResponse struct {
 	UserID string `json:"userId"`
 }
 
+type getUserRequest struct {
+	UserID      string `validate:"required,userid"`
+	IfNoneMatch domain.VersionMatch
+}
+
+type updateUserRequest struct {
+	UserID string              `json:"userId" validate:"required,userid"`
+	Name   string              `json:"name" validate:"required,startsnotwith=enc:v1:"`
+	Match  domain.VersionMatch `json:"-"`
+}
+
+type deleteUserRequest struct {
+	UserID string              `json:"userId" validate:"required,userid"`
+	Match  domain.VersionMatch `json:"-"`
+}
+
+type restoreUserRequest struct {
+	UserID string `json:"userId" validate:"required,userid"`
+}
+
+type eraseUserRequest struct {
+	UserID string `json:"userId" validate:"required,userid"`
+}
+
+type exportUserRequest struct {
+	UserID string `validate:"required,userid"`
+}
+
+// erasureResponse is the tombstone of the erased user.
+type erasureResponse struct {
+	UserID      string    `json:"userId"`
+	RequestedAt time.Time `json:"requestedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+	DocsDeleted int64     `json:"docsDeleted"`
+	KeysDeleted int64     `json:"keysDeleted"`
+}
+
+type emptyResponse struct{}
+
+// versionedResponse is a resource with its version, sent as the ETag.
+// NotModified leaves out the body, the If-None-Match matched.
+type versionedResponse struct {
+	Body        interface{}
+	Version     int64
+	NotModified bool
+}
+
+// Content types of the profile patches.
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// maxProfileBody caps the profile request bodies, the stored profile has
+// its own, lower limit.
+const maxProfileBody = 1 << 20
+
+type getProfileRequest struct {
+	UserID      string `validate:"required,userid"`
+	IfNoneMatch domain.VersionMatch
+}
+
+type putProfileRequest struct {
+	UserID string `validate:"required,userid"`
+	Data   map[string]any
+	Match  domain.VersionMatch
+}
+
+type patchProfileRequest struct {
+	UserID string `validate:"required,userid"`
+	Patch  domain.ProfilePatch
+	Match  domain.VersionMatch
+}
+
+type profileResponse struct {
+	UserID    string         `json:"userId"`
+	Data      map[string]any `json:"data"`
+	Version   int64          `json:"version"`
+	UpdatedAt *time.Time     `json:"updatedAt,omitempty"` // nil if never saved
+}
+
+const defaultPageSize = 20
+
+type listUsersRequest struct {
+	Limit         int       `validate:"min=1,max=100"` // page size
+	NamePrefix    string    `validate:"max=100"`
+	CreatedAfter  time.Time `validate:"-"`
+	CreatedBefore time.Time `validate:"omitempty,gtfield=CreatedAfter"`
+	Cursor        string    `validate:"max=512"`
+}
+
+type searchUsersRequest struct {
+	Text          string  `validate:"required,max=200"`
+	MinSimilarity float64 `validate:"gte=0,lte=1"` // 0 = the configured default
+	Offset        int     `validate:"gte=0,lte=10000"`
+	Limit         int     `validate:"min=1,max=100"`
+}
+
+type userMatchResponse struct {
+	userResponse
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"` // HTML, the matches in <mark>
+}
+
+type searchUsersResponse struct {
+	Users      []userMatchResponse `json:"users"`
+	NextOffset int                 `json:"nextOffset,omitempty"`
+}
+
+type userResponse struct {
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type listUsersResponse struct {
+	Users      []userResponse `json:"users"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+	PrevCursor string         `json:"prevCursor,omitempty"`
+}
+
 func Recovery(logger log.Logger) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, req interface{}) (v interface{}, e error) {
 			defer func() {
 				if err := recover(); err != nil {
-					logger.Log("msg", "panic recovered", "err", err, "stack", string(debug.Stack()))
+					level.Error(logger).Log("msg", "panic recovered", "err", err, "stack", string(debug.Stack()))
 					v = nil
 					e = NewInternalServerError()
 				}
@@ -43,7 +176,7 @@ func DecodingRecovery(logger log.Logger) DecodingMiddleware {
 		return func(ctx context.Context, req *http.Request) (v interface{}, e error) {
 			defer func() {
 				if err := recover(); err != nil {
-					logger.Log("msg", "panic recovered", "err", err, "stack", string(debug.Stack()))
+					level.Error(logger).Log("msg", "panic recovered", "err", err, "stack", string(debug.Stack()))
 					v = nil
 					e = NewValidationError("request validation failed")
 				}
@@ -65,6 +198,291 @@ func DecodeCreateUserRequest(_ context.Context, req *http.Request) (interface{},
 	return request, nil
 }
 
+// DecodeGetUserRequest reads the user_id query parameter.
+func DecodeGetUserRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	return getUserRequest{
+		UserID:      req.URL.Query().Get("user_id"),
+		IfNoneMatch: parseETags(req.Header.Get("If-None-Match"), true),
+	}, nil
+}
+
+// Preconditions reads the If-Match of the changes, the ETags of the
+// versions they apply to. Without RequireIfMatch a change without one is
+// unconditional.
+type Preconditions struct {
+	RequireIfMatch bool
+}
+
+func (p Preconditions) DecodeUpdateUserRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	match, err := p.ifMatch(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ContentLength == 0 {
+		return nil, NewValidationError("empty request")
+	}
+	var request updateUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return nil, NewValidationError("cannot decode request")
+	}
+	request.Match = match
+	return request, nil
+}
+
+func (p Preconditions) DecodeDeleteUserRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	match, err := p.ifMatch(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ContentLength == 0 {
+		return nil, NewValidationError("empty request")
+	}
+	var request deleteUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return nil, NewValidationError("cannot decode request")
+	}
+	request.Match = match
+	return request, nil
+}
+
+func DecodeRestoreUserRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	if req.ContentLength == 0 {
+		return nil, NewValidationError("empty request")
+	}
+	var request restoreUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return nil, NewValidationError("cannot decode request")
+	}
+	return request, nil
+}
+
+func DecodeEraseUserRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	if req.ContentLength == 0 {
+		return nil, NewValidationError("empty request")
+	}
+	var request eraseUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return nil, NewValidationError("cannot decode request")
+	}
+	return request, nil
+}
+
+// DecodeExportUserRequest reads the user_id query parameter.
+func DecodeExportUserRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	return exportUserRequest{UserID: req.URL.Query().Get("user_id")}, nil
+}
+
+func (p Preconditions) ifMatch(req *http.Request) (domain.VersionMatch, error) {
+	h := req.Header.Get("If-Match")
+	if h == "" {
+		if p.RequireIfMatch {
+			return domain.VersionMatch{}, NewPreconditionRequiredError("If-Match required, the ETag of the version to change")
+		}
+		return domain.AnyVersion, nil
+	}
+	return parseETags(h, false), nil
+}
+
+// etag is the strong ETag of a version.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseETags reads the versions of an If-Match or If-None-Match list.
+// Weak ETags only match with weak, the If-None-Match comparison. Tags
+// that aren't versions are ignored, they match none.
+func parseETags(h string, weak bool) domain.VersionMatch {
+	var match domain.VersionMatch
+	for _, tag := range strings.Split(h, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return domain.AnyVersion
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[len("W/"):]
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		v, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil || v < 0 {
+			continue
+		}
+		match.Versions = append(match.Versions, v)
+	}
+	return match
+}
+
+// EncodeVersionedResponse sets the ETag of a versionedResponse and writes
+// its body as JSON, or 304 Not Modified. Other responses are only JSON.
+func EncodeVersionedResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp, ok := response.(versionedResponse)
+	if !ok {
+		return httptransport.EncodeJSONResponse(ctx, w, response)
+	}
+	w.Header().Set("ETag", etag(resp.Version))
+	if resp.NotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, resp.Body)
+}
+
+// DecodeGetProfileRequest reads the user ID from the {id} path wildcard.
+func DecodeGetProfileRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	return getProfileRequest{
+		UserID:      req.PathValue("id"),
+		IfNoneMatch: parseETags(req.Header.Get("If-None-Match"), true),
+	}, nil
+}
+
+// DecodePutProfileRequest reads the profile data, a JSON object.
+func (p Preconditions) DecodePutProfileRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	match, err := p.ifMatch(req)
+	if err != nil {
+		return nil, err
+	}
+	v, err := jsondoc.Decode(http.MaxBytesReader(nil, req.Body, maxProfileBody))
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	data, ok := v.(map[string]any)
+	if !ok {
+		return nil, NewValidationError("profile must be an object")
+	}
+	return putProfileRequest{UserID: req.PathValue("id"), Data: data, Match: match}, nil
+}
+
+// DecodePatchProfileRequest reads a JSON Merge Patch or a JSON Patch, by
+// the Content-Type.
+func (p Preconditions) DecodePatchProfileRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	match, err := p.ifMatch(req)
+	if err != nil {
+		return nil, err
+	}
+	request := patchProfileRequest{UserID: req.PathValue("id"), Match: match}
+	body := http.MaxBytesReader(nil, req.Body, maxProfileBody)
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchType:
+		v, err := jsondoc.Decode(body)
+		if err != nil {
+			return nil, bodyError(err)
+		}
+		merge, ok := v.(map[string]any)
+		if !ok {
+			return nil, NewValidationError("merge patch must be an object, the profile stays one")
+		}
+		request.Patch.Merge = merge
+	case jsonPatchType:
+		ops, err := jsondoc.DecodePatch(body)
+		if err != nil {
+			return nil, bodyError(err)
+		}
+		request.Patch.Ops = ops
+	default:
+		return nil, NewUnsupportedMediaTypeError(mergePatchType, jsonPatchType)
+	}
+	return request, nil
+}
+
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return NewPayloadTooLargeError(fmt.Sprintf("request body over %d bytes", tooLarge.Limit))
+	}
+	if errors.Is(err, jsondoc.ErrInvalid) {
+		return NewValidationError(err.Error())
+	}
+	return NewValidationError("cannot decode request")
+}
+
+// DecodeListUsersRequest reads the query string: limit, name_prefix,
+// created_after, created_before (RFC 3339) and cursor.
+func DecodeListUsersRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	q := req.URL.Query()
+	request := listUsersRequest{
+		Limit:      defaultPageSize,
+		NamePrefix: q.Get("name_prefix"),
+		Cursor:     q.Get("cursor"),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, NewValidationError("limit must be a number")
+		}
+		request.Limit = limit
+	}
+	for name, t := range map[string]*time.Time{
+		"created_after":  &request.CreatedAfter,
+		"created_before": &request.CreatedBefore,
+	} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, NewValidationError(name + " must be an RFC 3339 time")
+		}
+		*t = parsed
+	}
+	return request, nil
+}
+
+// EncodeListUsersResponse adds Link headers for the next and previous
+// pages, the request URL with the cursor replaced. It needs
+// httptransport.PopulateRequestContext.
+func EncodeListUsersResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if resp, ok := response.(listUsersResponse); ok {
+		path, _ := ctx.Value(httptransport.ContextKeyRequestPath).(string)
+		uri, _ := ctx.Value(httptransport.ContextKeyRequestURI).(string)
+		query := url.Values{}
+		if u, err := url.ParseRequestURI(uri); err == nil {
+			query = u.Query()
+		}
+		for _, link := range []struct{ rel, cursor string }{{"next", resp.NextCursor}, {"prev", resp.PrevCursor}} {
+			if link.cursor == "" {
+				continue
+			}
+			query.Set("cursor", link.cursor)
+			w.Header().Add("Link", fmt.Sprintf(`<%s?%s>; rel="%s"`, path, query.Encode(), link.rel))
+		}
+	}
+	return httptransport.EncodeJSONResponse(ctx, w, response)
+}
+
+// DecodeSearchUsersRequest reads the query string: q, min_similarity,
+// offset and limit.
+func DecodeSearchUsersRequest(_ context.Context, req *http.Request) (interface{}, error) {
+	q := req.URL.Query()
+	request := searchUsersRequest{
+		Text:  q.Get("q"),
+		Limit: defaultPageSize,
+	}
+	for name, v := range map[string]*int{"offset": &request.Offset, "limit": &request.Limit} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, NewValidationError(name + " must be a number")
+			}
+			*v = n
+		}
+	}
+	if s := q.Get("min_similarity"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, NewValidationError("min_similarity must be a number")
+		}
+		request.MinSimilarity = f
+	}
+	return request, nil
+}
+
 func ErrorEncoder() httptransport.ErrorEncoder {
 	return func(ctx context.Context, err error, w http.ResponseWriter) {
 		SetRequestID(ctx, w)
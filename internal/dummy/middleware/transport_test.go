@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/jsondoc"
+)
+
+func TestDecodePatchProfileRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        interface{}
+		wantErr     error
+	}{
+		{
+			name:        "Positive: Merge patch",
+			contentType: "application/merge-patch+json; charset=utf-8",
+			body:        `{"a":null,"b":{"c":1}}`,
+			want: patchProfileRequest{UserID: "42", Patch: domain.ProfilePatch{
+				Merge: map[string]any{"a": nil, "b": map[string]any{"c": int64(1)}},
+			}, Match: domain.AnyVersion},
+		},
+		{
+			name:        "Positive: JSON Patch",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"remove","path":"/a"}]`,
+			want: patchProfileRequest{UserID: "42", Patch: domain.ProfilePatch{
+				Ops: []jsondoc.Operation{{Op: "remove", Path: "/a"}},
+			}, Match: domain.AnyVersion},
+		},
+		{
+			name:        "Negative: Plain JSON",
+			contentType: "application/json",
+			body:        `{"a":1}`,
+			wantErr:     &UnsupportedMediaTypeError{},
+		},
+		{
+			name:        "Negative: Merge patch not an object",
+			contentType: "application/merge-patch+json",
+			body:        `["a"]`,
+			wantErr:     &ValidationError{},
+		},
+		{
+			name:        "Negative: Invalid JSON Patch",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"merge","path":"/a"}]`,
+			wantErr:     &ValidationError{},
+		},
+		{
+			name:        "Negative: Body too large",
+			contentType: "application/merge-patch+json",
+			body:        `{"a":"` + strings.Repeat("x", maxProfileBody) + `"}`,
+			wantErr:     &PayloadTooLargeError{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			req := httptest.NewRequest("PATCH", "/users/42/profile", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.SetPathValue("id", "42")
+
+			got, err := Preconditions{}.DecodePatchProfileRequest(context.Background(), req)
+
+			if tt.wantErr != nil {
+				assert.IsType(tt.wantErr, err)
+				assert.Nil(got)
+			} else {
+				assert.NoError(err)
+				assert.Equal(tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPreconditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Preconditions
+		ifMatch string
+		want    domain.VersionMatch
+		wantErr error
+	}{
+		{
+			name:    "Positive: Versions, weak and invalid tags match none",
+			p:       Preconditions{RequireIfMatch: true},
+			ifMatch: `"3", W/"4", "x", 5, "6"`,
+			want:    domain.VersionMatch{Versions: []int64{3, 6}},
+		},
+		{
+			name:    "Positive: Any",
+			p:       Preconditions{RequireIfMatch: true},
+			ifMatch: `*`,
+			want:    domain.AnyVersion,
+		},
+		{
+			name: "Positive: Optional",
+			p:    Preconditions{RequireIfMatch: false},
+			want: domain.AnyVersion,
+		},
+		{
+			name:    "Negative: Required",
+			p:       Preconditions{RequireIfMatch: true},
+			wantErr: &PreconditionRequiredError{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			req := httptest.NewRequest("POST", "/deleteUser", strings.NewReader(`{"userId":"42"}`))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			got, err := tt.p.DecodeDeleteUserRequest(context.Background(), req)
+
+			if tt.wantErr != nil {
+				assert.IsType(tt.wantErr, err)
+				assert.Nil(got)
+			} else {
+				assert.NoError(err)
+				assert.Equal(deleteUserRequest{UserID: "42", Match: tt.want}, got)
+			}
+		})
+	}
+}
+
+func TestDecodeGetUserRequest_ifNoneMatch(t *testing.T) {
+	req := httptest.NewRequest("GET", "/getUser?user_id=42", nil)
+	req.Header.Set("If-None-Match", `W/"1", "2"`)
+
+	got, err := DecodeGetUserRequest(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, getUserRequest{UserID: "42", IfNoneMatch: domain.VersionMatch{Versions: []int64{1, 2}}}, got)
+}
+
+func TestEncodeVersionedResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   interface{}
+		wantStatus int
+		wantETag   string
+		wantBody   string
+	}{
+		{
+			name:       "Positive: Body and ETag",
+			response:   versionedResponse{Body: emptyResponse{}, Version: 7},
+			wantStatus: http.StatusOK,
+			wantETag:   `"7"`,
+			wantBody:   "{}\n",
+		},
+		{
+			name:       "Positive: Not modified",
+			response:   versionedResponse{Body: emptyResponse{}, Version: 7, NotModified: true},
+			wantStatus: http.StatusNotModified,
+			wantETag:   `"7"`,
+		},
+		{
+			name:       "Positive: Not versioned",
+			response:   emptyResponse{},
+			wantStatus: http.StatusOK,
+			wantBody:   "{}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			w := httptest.NewRecorder()
+
+			err := EncodeVersionedResponse(context.Background(), w, tt.response)
+
+			assert.NoError(err)
+			assert.Equal(tt.wantStatus, w.Code)
+			assert.Equal(tt.wantETag, w.Header().Get("ETag"))
+			assert.Equal(tt.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestEncodeUserArchive(t *testing.T) {
+	at := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	e := domain.UserExport{
+		User:    domain.User{ID: "1", Name: "a", CreatedAt: at, Version: 2, DeletedAt: at},
+		Redis:   map[string]any{"k:name:a": "7", "k:user:7": map[string]string{"name": "a"}},
+		Profile: domain.Profile{UserID: "1", Data: map[string]any{}},
+	}
+	w := httptest.NewRecorder()
+
+	err := EncodeUserArchive(context.Background(), w, NewUserArchive(e, at))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `attachment; filename="user-1.json"`, w.Header().Get("Content-Disposition"))
+	assert.JSONEq(t, `{
+		"exportedAt": "2024-02-01T00:00:00Z",
+		"userId": "1",
+		"postgres": {"userId": "1", "name": "a", "createdAt": "2024-02-01T00:00:00Z", "version": 2, "deletedAt": "2024-02-01T00:00:00Z"},
+		"redis": {"k:name:a": "7", "k:user:7": {"name": "a"}},
+		"mongo": {"users": [], "userProfile": {"userId": "1", "data": {}, "version": 0}}
+	}`, w.Body.String())
+}
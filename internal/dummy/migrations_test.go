@@ -0,0 +1,44 @@
+package dummy
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/migrator"
+	"ws-dummy-go/migrations"
+)
+
+func Test_postgresMigrations_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	// A database of its own, the test one is migrated already.
+	_, err := testPostgresPool.Exec(ctx, "CREATE DATABASE roundtrip")
+	require.NoError(t, err)
+	defer testPostgresPool.Exec(ctx, "DROP DATABASE roundtrip")
+
+	u, err := url.Parse(testPostgresURL)
+	require.NoError(t, err)
+	u.Path = "/roundtrip"
+
+	pool, err := pgxpool.New(ctx, u.String())
+	require.NoError(t, err)
+	defer pool.Close()
+
+	src, err := migrations.Source()
+	require.NoError(t, err)
+	tgt, err := migrator.NewPostgres(src, u.String(), log.NewNopLogger())
+	require.NoError(t, err)
+	defer tgt.Close(ctx)
+
+	err = migrator.RoundTrip(ctx, tgt, func(ctx context.Context) (migrator.Schema, error) {
+		return migrator.Snapshot(ctx, pool, "public")
+	})
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,144 @@
+package dummy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"ws-dummy-go/internal/migrator"
+	"ws-dummy-go/migrations"
+)
+
+func Test_mongoMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := testMongoClient.Database("test_mongo_migrations")
+	defer db.Drop(ctx)
+	users := db.Collection("users")
+
+	mg, err := migrator.NewMongo(db, migrations.Mongo)
+	require.NoError(t, err)
+
+	run := func(t *testing.T, plan func([]migrator.Migration, int) ([]migrator.Step, error)) {
+		current, dirty, err := mg.Version(ctx)
+		require.NoError(t, err)
+		migs, err := mg.Migrations(current, dirty)
+		require.NoError(t, err)
+		steps, err := plan(migs, current)
+		require.NoError(t, err)
+		require.NoError(t, mg.Run(ctx, steps))
+	}
+	up := func(m []migrator.Migration, _ int) ([]migrator.Step, error) { return migrator.PlanUp(m, 0) }
+	down := func(m []migrator.Migration, _ int) ([]migrator.Step, error) { return migrator.PlanDown(m, 0) }
+
+	tests := []struct {
+		name    string
+		doc     bson.D
+		wantErr bool
+	}{
+		{
+			name:    "Positive: Valid user",
+			doc:     bson.D{{Key: "_id", Value: "1"}, {Key: "name", Value: "juwis"}, {Key: "name_bidx", Value: "juwis"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: false,
+		},
+		{
+			name:    "Positive: Duplicate name",
+			doc:     bson.D{{Key: "_id", Value: "2"}, {Key: "name", Value: "juwis"}, {Key: "name_bidx", Value: "juwis"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: false,
+		},
+		{
+			name:    "Negative: Empty name",
+			doc:     bson.D{{Key: "_id", Value: "3"}, {Key: "name", Value: ""}, {Key: "name_bidx", Value: ""}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: No created_at",
+			doc:     bson.D{{Key: "_id", Value: "4"}, {Key: "name", Value: "other"}, {Key: "name_bidx", Value: "other"}, {Key: "version", Value: int64(1)}},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: No version",
+			doc:     bson.D{{Key: "_id", Value: "6"}, {Key: "name", Value: "another"}, {Key: "name_bidx", Value: "another"}, {Key: "created_at", Value: time.Now()}},
+			wantErr: true,
+		},
+		{
+			name:    "Positive: Soft deleted",
+			doc:     bson.D{{Key: "_id", Value: "7"}, {Key: "name", Value: "deleted"}, {Key: "name_bidx", Value: "deleted"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(2)}, {Key: "deleted", Value: true}, {Key: "deleted_at", Value: time.Now()}},
+			wantErr: false,
+		},
+		{
+			name:    "Negative: Deleted not a bool",
+			doc:     bson.D{{Key: "_id", Value: "8"}, {Key: "name", Value: "deleted2"}, {Key: "name_bidx", Value: "deleted2"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(2)}, {Key: "deleted", Value: "yes"}},
+			wantErr: true,
+		},
+		{
+			name:    "Positive: User ID",
+			doc:     bson.D{{Key: "_id", Value: "10"}, {Key: "user_id", Value: "42"}, {Key: "name", Value: "juwis"}, {Key: "name_bidx", Value: "juwis"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: false,
+		},
+		{
+			name:    "Negative: Duplicate user ID",
+			doc:     bson.D{{Key: "_id", Value: "11"}, {Key: "user_id", Value: "42"}, {Key: "name", Value: "juwis"}, {Key: "name_bidx", Value: "juwis"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: true,
+		},
+		{
+			name:    "Negative: User ID not a string",
+			doc:     bson.D{{Key: "_id", Value: "12"}, {Key: "user_id", Value: int64(43)}, {Key: "name", Value: "juwis"}, {Key: "name_bidx", Value: "juwis"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: true,
+		},
+		{
+			name:    "Positive: No name blind index, stored before",
+			doc:     bson.D{{Key: "_id", Value: "9"}, {Key: "name", Value: "nobidx"}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: false,
+		},
+		{
+			name:    "Negative: Empty name blind index",
+			doc:     bson.D{{Key: "_id", Value: "13"}, {Key: "name", Value: "emptybidx"}, {Key: "name_bidx", Value: ""}, {Key: "created_at", Value: time.Now()}, {Key: "version", Value: int64(1)}},
+			wantErr: true,
+		},
+	}
+
+	run(t, up)
+	v, dirty, err := mg.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int(migrations.Mongo[len(migrations.Mongo)-1].Version), v)
+	assert.False(t, dirty)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := users.InsertOne(ctx, tt.doc)
+
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+
+	// Down drops the indexes and the validator.
+	run(t, down)
+	v, _, err = mg.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrator.NoVersion, v)
+
+	_, err = users.InsertOne(ctx, bson.D{{Key: "_id", Value: "5"}, {Key: "name", Value: "juwis"}})
+	assert.NoError(t, err)
+
+	var idx []bson.M
+	cur, err := users.Indexes().List(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cur.All(ctx, &idx))
+	assert.Len(t, idx, 1) // _id
+
+	// An up failing, two users with the same user_id for the unique index, leaves the version dirty.
+	_, err = users.InsertMany(ctx, []interface{}{
+		bson.D{{Key: "_id", Value: "6"}, {Key: "user_id", Value: "1"}},
+		bson.D{{Key: "_id", Value: "7"}, {Key: "user_id", Value: "1"}},
+	})
+	require.NoError(t, err)
+	err = mg.Run(ctx, []migrator.Step{{Version: 7, Name: "add_users_user_id", Direction: migrator.Up}})
+	assert.Error(t, err)
+	_, dirty, err = mg.Version(ctx)
+	require.NoError(t, err)
+	assert.True(t, dirty)
+}
@@ -0,0 +1,227 @@
+package dummy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/jsondoc"
+)
+
+// ErrVersionConflict is a save of a profile changed since it was read.
+var ErrVersionConflict = errors.New("profile changed since read")
+
+type UsersProfilesRepo interface {
+	// Get returns an empty profile of version 0 for a user without one.
+	Get(ctx context.Context, id domain.UserID) (domain.Profile, error)
+	// Save replaces the data of the profile, read at its version, with
+	// one update of the changed fields. ErrVersionConflict if the stored
+	// version isn't the read one anymore.
+	Save(ctx context.Context, read domain.Profile, data map[string]any) (domain.Profile, error)
+	// Delete removes the profile, it returns the number of documents
+	// removed.
+	Delete(ctx context.Context, id domain.UserID) (int64, error)
+}
+
+// ProfilesConfig limits the profile data: its BSON size and the nesting
+// of objects and arrays.
+type ProfilesConfig struct {
+	MaxBytes int
+	MaxDepth int
+}
+
+func NewUsersProfilesRepo(c Collection, cfg ProfilesConfig) UsersProfilesRepo {
+	return usersProfilesRepo{
+		col: c,
+		cfg: cfg,
+	}
+}
+
+type usersProfilesRepo struct {
+	col Collection
+	cfg ProfilesConfig
+}
+
+type profileDoc struct {
+	ID        string    `bson:"_id"`
+	Data      bson.M    `bson:"data"`
+	Version   int64     `bson:"version"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func (r usersProfilesRepo) Get(ctx context.Context, id domain.UserID) (domain.Profile, error) {
+	var doc profileDoc
+	if err := r.col().FindOne(ctx, bson.M{"_id": string(id)}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.Profile{UserID: id, Data: map[string]any{}}, nil
+		}
+		return domain.Profile{}, fmt.Errorf("finding a doc: %w", err)
+	}
+	return domain.Profile{
+		UserID:    id,
+		Data:      fromBSON(doc.Data).(map[string]any),
+		Version:   doc.Version,
+		UpdatedAt: doc.UpdatedAt,
+	}, nil
+}
+
+func (r usersProfilesRepo) Save(ctx context.Context, read domain.Profile, data map[string]any) (domain.Profile, error) {
+	if err := r.check(data); err != nil {
+		return domain.Profile{}, err
+	}
+	saved := domain.Profile{
+		UserID:    read.UserID,
+		Data:      data,
+		Version:   read.Version + 1,
+		UpdatedAt: time.Now().UTC().Truncate(time.Millisecond), // BSON dates are milliseconds
+	}
+
+	if read.Version == 0 {
+		_, err := r.col().InsertOne(ctx, profileDoc{
+			ID:        string(saved.UserID),
+			Data:      data,
+			Version:   saved.Version,
+			UpdatedAt: saved.UpdatedAt,
+		})
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return domain.Profile{}, ErrVersionConflict
+			}
+			return domain.Profile{}, fmt.Errorf("inserting a doc: %w", err)
+		}
+		return saved, nil
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	diffFields(read.Data, data, "data.", set, unset)
+	if len(set) == 0 && len(unset) == 0 {
+		return read, nil
+	}
+	set["updated_at"] = saved.UpdatedAt
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	res, err := r.col().UpdateOne(ctx, bson.M{"_id": string(read.UserID), "version": read.Version}, update)
+	if err != nil {
+		return domain.Profile{}, fmt.Errorf("updating a doc: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return domain.Profile{}, ErrVersionConflict
+	}
+	return saved, nil
+}
+
+func (r usersProfilesRepo) Delete(ctx context.Context, id domain.UserID) (int64, error) {
+	res, err := r.col().DeleteOne(ctx, bson.M{"_id": string(id)})
+	if err != nil {
+		return 0, fmt.Errorf("deleting a doc: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+// check rejects the data Mongo can't store as fields or over the limits.
+func (r usersProfilesRepo) check(data map[string]any) error {
+	if err := checkFields(data, r.cfg.MaxDepth); err != nil {
+		return err
+	}
+	raw, err := bson.Marshal(bson.M{"data": data})
+	if err != nil {
+		return fmt.Errorf("encoding profile: %w", err)
+	}
+	if len(raw) > r.cfg.MaxBytes {
+		return domain.NewTooLargeError(fmt.Sprintf("profile is %d bytes, over the limit of %d", len(raw), r.cfg.MaxBytes))
+	}
+	return nil
+}
+
+func checkFields(v any, depth int) error {
+	switch vv := v.(type) {
+	case map[string]any:
+		if depth == 0 {
+			return domain.NewTooLargeError("profile is nested too deep")
+		}
+		for k, item := range vv {
+			if k == "" || strings.Contains(k, ".") || strings.HasPrefix(k, "$") {
+				return domain.NewInvalidError(fmt.Sprintf("profile field %q is empty, has a dot or starts with $", k))
+			}
+			if err := checkFields(item, depth-1); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if depth == 0 {
+			return domain.NewTooLargeError("profile is nested too deep")
+		}
+		for _, item := range vv {
+			if err := checkFields(item, depth-1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// diffFields adds the $set and $unset of the fields changed from one
+// object to the other, recursing into the objects in both. Arrays are
+// set whole.
+func diffFields(from, to map[string]any, prefix string, set, unset bson.M) {
+	for k := range from {
+		if _, ok := to[k]; !ok {
+			unset[prefix+k] = ""
+		}
+	}
+	for k, v := range to {
+		if fromV, ok := from[k]; ok {
+			fromObj, fromIsObj := fromV.(map[string]any)
+			toObj, toIsObj := v.(map[string]any)
+			if fromIsObj && toIsObj {
+				diffFields(fromObj, toObj, prefix+k+".", set, unset)
+				continue
+			}
+			if jsondoc.Equal(fromV, v) {
+				continue
+			}
+		}
+		set[prefix+k] = v
+	}
+}
+
+// fromBSON turns the decoded BSON into the values of encoding/json.
+func fromBSON(v any) any {
+	switch vv := v.(type) {
+	case primitive.M:
+		return fromBSON(map[string]any(vv))
+	case map[string]any:
+		res := make(map[string]any, len(vv))
+		for k, item := range vv {
+			res[k] = fromBSON(item)
+		}
+		return res
+	case primitive.D:
+		res := make(map[string]any, len(vv))
+		for _, e := range vv {
+			res[e.Key] = fromBSON(e.Value)
+		}
+		return res
+	case primitive.A:
+		return fromBSON([]any(vv))
+	case []any:
+		res := make([]any, len(vv))
+		for i, item := range vv {
+			res[i] = fromBSON(item)
+		}
+		return res
+	case int32:
+		return int64(vv)
+	}
+	return v
+}
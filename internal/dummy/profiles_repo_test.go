@@ -0,0 +1,119 @@
+package dummy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/migrator"
+	"ws-dummy-go/migrations"
+)
+
+func Test_usersProfilesRepo(t *testing.T) {
+	ctx := context.Background()
+	db := testMongoClient.Database("test_profiles")
+	defer db.Drop(ctx)
+
+	// The migrations add the validator of the saved documents.
+	mg, err := migrator.NewMongo(db, migrations.Mongo)
+	require.NoError(t, err)
+	migs, err := mg.Migrations(0, false)
+	require.NoError(t, err)
+	steps, err := migrator.PlanUp(migs, 0)
+	require.NoError(t, err)
+	require.NoError(t, mg.Run(ctx, steps))
+
+	r := NewUsersProfilesRepo(StaticCollection(db.Collection("user_profiles")), ProfilesConfig{MaxBytes: 1024, MaxDepth: 3})
+
+	empty, err := r.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.Profile{UserID: "1", Data: map[string]any{}}, empty)
+
+	v1, err := r.Save(ctx, empty, map[string]any{"a": "b", "nested": map[string]any{"x": int64(1), "y": []any{true, 1.5}}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v1.Version)
+
+	_, err = r.Save(ctx, empty, map[string]any{"a": "c"})
+	assert.ErrorIs(t, err, ErrVersionConflict, "second insert")
+
+	v2, err := r.Save(ctx, v1, map[string]any{"nested": map[string]any{"x": int64(2), "y": []any{true, 1.5}}, "new": nil})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v2.Version)
+
+	_, err = r.Save(ctx, v1, map[string]any{"a": "stale"})
+	assert.ErrorIs(t, err, ErrVersionConflict, "stale version")
+
+	unchanged, err := r.Save(ctx, v2, v2.Data)
+	require.NoError(t, err)
+	assert.Equal(t, v2, unchanged, "nothing to save")
+
+	got, err := r.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, v2.Data, got.Data)
+	assert.Equal(t, v2.Version, got.Version)
+	assert.True(t, v2.UpdatedAt.Equal(got.UpdatedAt))
+
+	tests := []struct {
+		name    string
+		data    map[string]any
+		wantErr any
+	}{
+		{name: "Negative: Dotted field", data: map[string]any{"a.b": 1}, wantErr: &domain.InvalidError{}},
+		{name: "Negative: Operator field", data: map[string]any{"a": map[string]any{"$set": 1}}, wantErr: &domain.InvalidError{}},
+		{name: "Negative: Too deep", data: map[string]any{"a": []any{map[string]any{"b": []any{}}}}, wantErr: &domain.TooLargeError{}},
+		{name: "Negative: Too large", data: map[string]any{"a": string(make([]byte, 1024))}, wantErr: &domain.TooLargeError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.Save(ctx, v2, tt.data)
+
+			assert.ErrorAs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_diffFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  map[string]any
+		wantSet   bson.M
+		wantUnset bson.M
+	}{
+		{
+			name:      "Positive: Nothing changed, numbers by value",
+			from:      map[string]any{"a": int64(1), "b": []any{"c"}},
+			to:        map[string]any{"a": 1.0, "b": []any{"c"}},
+			wantSet:   bson.M{},
+			wantUnset: bson.M{},
+		},
+		{
+			name:      "Positive: Nested fields",
+			from:      map[string]any{"a": map[string]any{"b": 1, "c": 2}, "d": 3},
+			to:        map[string]any{"a": map[string]any{"b": 1, "e": 4}},
+			wantSet:   bson.M{"data.a.e": 4},
+			wantUnset: bson.M{"data.a.c": "", "data.d": ""},
+		},
+		{
+			name:      "Positive: Object replaced by a value, arrays whole",
+			from:      map[string]any{"a": map[string]any{"b": 1}, "c": []any{1, 2}},
+			to:        map[string]any{"a": "b", "c": []any{1, 3}},
+			wantSet:   bson.M{"data.a": "b", "data.c": []any{1, 3}},
+			wantUnset: bson.M{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, unset := bson.M{}, bson.M{}
+
+			diffFields(tt.from, tt.to, "data.", set, unset)
+
+			assert.Equal(t, tt.wantSet, set)
+			assert.Equal(t, tt.wantUnset, unset)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package dummy
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisClient returns the Redis client of a repo. Like the Collection, the
+// client behind it may be replaced while the repo is in use.
+type RedisClient func() *redis.Client
+
+// StaticRedisClient always returns c.
+func StaticRedisClient(c *redis.Client) RedisClient {
+	return func() *redis.Client { return c }
+}
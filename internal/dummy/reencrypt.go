@@ -0,0 +1,285 @@
+package dummy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
+)
+
+// ReencryptReport counts the users encrypted again, by store.
+type ReencryptReport struct {
+	Rows         int // Postgres
+	Docs         int // Mongo
+	Hashes       int // Redis
+	CacheDeleted int
+}
+
+// ReencryptUsers encrypts the names not encrypted with the active data
+// key, the plaintext ones included, and sets their blind indexes. The
+// Postgres users keep their version, the names are the same. A name
+// changed meanwhile is left to the next run. The cached users are
+// deleted, they are cached again encrypted.
+func ReencryptUsers(
+	ctx context.Context, pool *pgxpool.Pool, col *mongo.Collection, c *redis.Client, keys Keys, ci pii.Cipher, batch int,
+) (ReencryptReport, error) {
+	var (
+		report ReencryptReport
+		err    error
+	)
+	if report.Rows, err = reencryptRows(ctx, pool, ci, batch); err != nil {
+		return report, fmt.Errorf("reencrypting postgres users: %w", err)
+	}
+	if report.Docs, err = reencryptDocs(ctx, col, ci, batch); err != nil {
+		return report, fmt.Errorf("reencrypting mongo users: %w", err)
+	}
+	if report.Hashes, err = reencryptHashes(ctx, c, keys, ci, batch); err != nil {
+		return report, fmt.Errorf("reencrypting redis users: %w", err)
+	}
+	if report.CacheDeleted, err = deleteKeys(ctx, c, keys.CachedUser("*"), batch); err != nil {
+		return report, fmt.Errorf("deleting cached users: %w", err)
+	}
+	return report, nil
+}
+
+// reencrypted returns the name encrypted again and its blind index, ok is
+// false if both are current.
+func reencrypted(ctx context.Context, ci pii.Cipher, value, bidx string) (string, string, bool, error) {
+	name, err := ci.Decrypt(ctx, nameField, value)
+	if err != nil {
+		return "", "", false, err
+	}
+	newBidx := ci.BlindIndex(nameField, name)
+	if !ci.Stale(value) && bidx == newBidx {
+		return "", "", false, nil
+	}
+	encrypted, err := ci.Encrypt(nameField, name)
+	if err != nil {
+		return "", "", false, err
+	}
+	return encrypted, newBidx, true, nil
+}
+
+// reencryptRows pages through the users by ID, deleted or not.
+func reencryptRows(ctx context.Context, pool *pgxpool.Pool, ci pii.Cipher, batch int) (int, error) {
+	type row struct {
+		id   int64
+		name string
+		bidx *string
+	}
+	var (
+		n     int
+		after int64
+	)
+	for {
+		sql, params, err := db.
+			Select("user_id", "name", "name_bidx").
+			From("users").
+			Where(goqu.C("user_id").Gt(after)).
+			Order(goqu.C("user_id").Asc()).
+			Limit(uint(batch)).
+			ToSQL()
+		if err != nil {
+			return n, fmt.Errorf("creating query: %w", err)
+		}
+		rows, err := pool.Query(ctx, sql, params...)
+		if err != nil {
+			return n, fmt.Errorf("executing query: %w", err)
+		}
+		var page []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.name, &r.bidx); err != nil {
+				rows.Close()
+				return n, fmt.Errorf("scanning user: %w", err)
+			}
+			page = append(page, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return n, fmt.Errorf("reading users: %w", err)
+		}
+
+		for _, r := range page {
+			var bidx string
+			if r.bidx != nil {
+				bidx = *r.bidx
+			}
+			name, newBidx, ok, err := reencrypted(ctx, ci, r.name, bidx)
+			if err != nil {
+				return n, fmt.Errorf("user %d: %w", r.id, err)
+			}
+			if !ok {
+				continue
+			}
+			sql, params, err := db.
+				Update("users").
+				Set(goqu.Record{"name": name, "name_bidx": newBidx}).
+				Where(goqu.C("user_id").Eq(r.id), goqu.C("name").Eq(r.name)).
+				ToSQL()
+			if err != nil {
+				return n, fmt.Errorf("creating query: %w", err)
+			}
+			tag, err := pool.Exec(ctx, sql, params...)
+			if err != nil {
+				return n, fmt.Errorf("updating user %d: %w", r.id, err)
+			}
+			n += int(tag.RowsAffected())
+		}
+		if len(page) < batch {
+			return n, nil
+		}
+		after = page[len(page)-1].id
+	}
+}
+
+func reencryptDocs(ctx context.Context, col *mongo.Collection, ci pii.Cipher, batch int) (int, error) {
+	cur, err := col.Find(ctx, bson.M{}, options.Find().
+		SetProjection(bson.M{"name": 1, "name_bidx": 1}).
+		SetBatchSize(int32(batch)))
+	if err != nil {
+		return 0, fmt.Errorf("finding docs: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var n int
+	for cur.Next(ctx) {
+		var doc struct {
+			ID   any    `bson:"_id"`
+			Name string `bson:"name"`
+			Bidx string `bson:"name_bidx"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return n, fmt.Errorf("decoding doc: %w", err)
+		}
+		name, bidx, ok, err := reencrypted(ctx, ci, doc.Name, doc.Bidx)
+		if err != nil {
+			return n, fmt.Errorf("doc %v: %w", doc.ID, err)
+		}
+		if !ok {
+			continue
+		}
+		res, err := col.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "name": doc.Name},
+			bson.M{"$set": bson.M{"name": name, "name_bidx": bidx}},
+		)
+		if err != nil {
+			return n, fmt.Errorf("updating doc %v: %w", doc.ID, err)
+		}
+		n += int(res.ModifiedCount)
+	}
+	if err := cur.Err(); err != nil {
+		return n, fmt.Errorf("reading docs: %w", err)
+	}
+	return n, nil
+}
+
+// reencryptHashes also moves the name index of a plaintext name to its
+// blind index.
+func reencryptHashes(ctx context.Context, c *redis.Client, keys Keys, ci pii.Cipher, batch int) (int, error) {
+	var n int
+	iter := c.Scan(ctx, 0, keys.User("*"), int64(batch)).Iterator()
+	for iter.Next(ctx) {
+		userKey := iter.Val()
+		id := domain.UserID(userKey[len(keys.User("")):])
+		done, err := reencryptHash(ctx, c, keys, ci, id)
+		if err != nil {
+			return n, fmt.Errorf("reencrypting %q: %w", userKey, err)
+		}
+		if done {
+			n++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return n, fmt.Errorf("scanning keys: %w", err)
+	}
+	return n, nil
+}
+
+func reencryptHash(ctx context.Context, c *redis.Client, keys Keys, ci pii.Cipher, id domain.UserID) (bool, error) {
+	userKey := keys.User(id)
+	var done bool
+	err := c.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.HGet(ctx, userKey, "name").Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) { // gone meanwhile
+				return nil
+			}
+			return fmt.Errorf("getting name: %w", err)
+		}
+		if !ci.Stale(value) {
+			return nil
+		}
+		plain, err := ci.Decrypt(ctx, nameField, value)
+		if err != nil {
+			return err
+		}
+		encrypted, err := ci.Encrypt(nameField, plain)
+		if err != nil {
+			return err
+		}
+
+		// The index of a plaintext name is keyed by the name.
+		oldNameKey := keys.UserName(plain)
+		newNameKey := keys.UserName(ci.BlindIndex(nameField, plain))
+		moveIndex := false
+		var ttl time.Duration
+		if oldNameKey != newNameKey {
+			indexed, err := tx.Get(ctx, oldNameKey).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return fmt.Errorf("getting name index: %w", err)
+			}
+			if moveIndex = indexed == string(id); moveIndex {
+				if ttl, err = tx.PTTL(ctx, oldNameKey).Result(); err != nil {
+					return fmt.Errorf("getting name index ttl: %w", err)
+				}
+				if ttl < 0 { // no expiry
+					ttl = 0
+				}
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, userKey, "name", encrypted)
+			if moveIndex {
+				p.SetNX(ctx, newNameKey, string(id), ttl)
+				p.Del(ctx, oldNameKey)
+			}
+			return nil
+		})
+		done = err == nil
+		return err
+	}, userKey)
+	if err != nil {
+		return false, err
+	}
+	return done, nil
+}
+
+// deleteKeys deletes the keys matching the pattern, it returns the number
+// of keys deleted.
+func deleteKeys(ctx context.Context, c *redis.Client, match string, batch int) (int, error) {
+	var n int
+	iter := c.Scan(ctx, 0, match, int64(batch)).Iterator()
+	for iter.Next(ctx) {
+		deleted, err := c.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return n, fmt.Errorf("deleting %q: %w", iter.Val(), err)
+		}
+		n += int(deleted)
+	}
+	if err := iter.Err(); err != nil {
+		return n, fmt.Errorf("scanning keys: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,125 @@
+package dummy
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
+)
+
+func TestReencryptUsers(t *testing.T) {
+	ctx := context.Background()
+	master := &pii.KeyFile{}
+	require.NoError(t, master.AddKey("m1"))
+	keyring, err := pii.OpenKeyring(ctx, master, pii.NewKeysSQLRepo(testPostgresPool))
+	require.NoError(t, err)
+	plain := pii.NewPlaintext()
+
+	col := testMongoClient.Database("test_dummy").Collection("reencrypt_users")
+	defer col.Drop(ctx)
+	keys := NewKeys("reenc", "test")
+	name := "reencrypt_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	cacheCfg := UsersCacheConfig{TTL: time.Minute}
+
+	// Stored before the encryption.
+	id, err := NewUsersSQLRepo(testPostgresPool, plain).Insert(ctx, name)
+	require.NoError(t, err)
+	require.NoError(t, NewUsersKVRepo(StaticRedisClient(testRedisClient), keys, time.Hour, plain).Set(ctx, id, name))
+	require.NoError(t, NewUsersDocsRepo(StaticCollection(col), NewRandIDGenerator(), plain).Insert(ctx, id, name))
+	require.NoError(t, NewUsersRedisCache(StaticRedisClient(testRedisClient), keys, cacheCfg, discard.NewCounter(), plain).
+		Set(ctx, domain.User{ID: id, Name: name, Version: 1}))
+
+	// The other tests read the users as plaintext.
+	defer decryptRows(t, keyring)
+
+	report, err := ReencryptUsers(ctx, testPostgresPool, col, testRedisClient, keys, keyring, 2)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, report.Rows, 1)
+	assert.Equal(t, 1, report.Docs)
+	assert.Equal(t, 1, report.Hashes)
+	assert.Equal(t, 1, report.CacheDeleted)
+
+	var stored string
+	require.NoError(t, testPostgresPool.QueryRow(ctx, "SELECT name FROM users WHERE user_id = $1", string(id)).Scan(&stored))
+	assert.True(t, strings.HasPrefix(stored, pii.Prefix+keyring.ActiveID()+":"), stored)
+
+	sqlRepo := NewUsersSQLRepo(testPostgresPool, keyring)
+	u, err := sqlRepo.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, name, u.Name)
+	matches, err := sqlRepo.Search(ctx, domain.SearchUsersQuery{Text: name, Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, id, matches[0].ID)
+		assert.Equal(t, domain.HighlightStart+name+domain.HighlightStop, matches[0].Highlight)
+	}
+	_, err = sqlRepo.List(ctx, domain.ListUsersQuery{Filter: domain.UsersFilter{NamePrefix: "re"}, Limit: 10})
+	var invalid *domain.InvalidError
+	assert.ErrorAs(t, err, &invalid, "no prefix filter on encrypted names")
+
+	kv, err := NewUsersKVRepo(StaticRedisClient(testRedisClient), keys, time.Hour, keyring).Export(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, kv, 2, "name index moved to the blind index")
+	for _, v := range kv {
+		if hash, ok := v.(map[string]string); ok {
+			assert.Equal(t, name, hash["name"])
+		}
+	}
+	n, err := testRedisClient.Exists(ctx, keys.UserName(name)).Result()
+	require.NoError(t, err)
+	assert.Zero(t, n, "plaintext name index deleted")
+
+	docs, err := NewUsersDocsRepo(StaticCollection(col), NewRandIDGenerator(), keyring).Export(ctx, id)
+	require.NoError(t, err)
+	if assert.Len(t, docs, 1) {
+		assert.Equal(t, name, docs[0]["name"])
+	}
+
+	// Nothing left to do.
+	again, err := ReencryptUsers(ctx, testPostgresPool, col, testRedisClient, keys, keyring, 2)
+	require.NoError(t, err)
+	assert.Equal(t, ReencryptReport{}, again)
+
+	// A rotation encrypts them again.
+	_, err = keyring.Rotate(ctx)
+	require.NoError(t, err)
+	rotated, err := ReencryptUsers(ctx, testPostgresPool, col, testRedisClient, keys, keyring, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rotated.Docs)
+	assert.Equal(t, 1, rotated.Hashes)
+	u, err = sqlRepo.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, name, u.Name)
+}
+
+// decryptRows stores the encrypted names of the users as plaintext again.
+func decryptRows(t *testing.T, keyring pii.Cipher) {
+	ctx := context.Background()
+	rows, err := testPostgresPool.Query(ctx, "SELECT user_id, name FROM users WHERE name LIKE 'enc:v1:%'")
+	require.NoError(t, err)
+	names := map[int64]string{}
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		require.NoError(t, rows.Scan(&id, &name))
+		names[id] = name
+	}
+	rows.Close()
+	require.NoError(t, rows.Err())
+	for id, value := range names {
+		name, err := keyring.Decrypt(ctx, nameField, value)
+		require.NoError(t, err)
+		_, err = testPostgresPool.Exec(ctx, "UPDATE users SET name = $1, name_bidx = $1 WHERE user_id = $2", name, id)
+		require.NoError(t, err)
+	}
+}
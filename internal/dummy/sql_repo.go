@@ -3,63 +3,688 @@ package dummy
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	// Needed to choose dialect
 	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
 )
 
 var db = goqu.Dialect("postgres")
 
+// nameField is the user name to the cipher, the same in every store.
+const nameField = "user.name"
+
 type UsersSQLRepo interface {
 	Insert(ctx context.Context, name string) (domain.UserID, error)
+	Get(ctx context.Context, id domain.UserID) (domain.User, error)
+	// GetAny gets the user even if soft deleted.
+	GetAny(ctx context.Context, id domain.UserID) (domain.User, error)
+	// Update and Delete change the user if its version matches, else they
+	// return a domain.PreconditionFailedError. Both bump the version.
+	// Delete is soft, the deleted users are found by none of the methods
+	// but Restore and ListExpired.
+	Update(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (domain.User, error)
+	Delete(ctx context.Context, id domain.UserID, match domain.VersionMatch) (domain.User, error)
+	// Restore undeletes a user deleted after the time. A user deleted
+	// before or being erased is not found, a user not deleted is a
+	// domain.ConflictError.
+	Restore(ctx context.Context, id domain.UserID, deletedAfter time.Time) (domain.User, error)
+	// ListExpired returns up to limit users deleted before the time, the
+	// oldest first.
+	ListExpired(ctx context.Context, deletedBefore time.Time, limit int) ([]domain.User, error)
+	// Purge hard deletes the user, if it is still deleted since
+	// p.DeletedAt, and records the purge. False if it wasn't.
+	Purge(ctx context.Context, p domain.UserPurge) (bool, error)
+	// StartErasure soft deletes the user and records its pending erasure.
+	// The user has only the ID if a pending erasure outlived its row, it
+	// is not found if it has none.
+	StartErasure(ctx context.Context, id domain.UserID) (domain.User, error)
+	// CompleteErasure hard deletes the user and completes its erasure, the
+	// counts add up over the attempts.
+	CompleteErasure(ctx context.Context, e domain.UserErasure) (domain.UserErasure, error)
+	// ListPendingErasures returns up to limit erasures not completed, the
+	// oldest first.
+	ListPendingErasures(ctx context.Context, limit int) ([]domain.UserErasure, error)
+	// List returns up to q.Limit users in the listing order, the ones
+	// right after q.After or right before q.Before.
+	List(ctx context.Context, q domain.ListUsersQuery) ([]domain.User, error)
+	// Search returns the users whose names match the text, best first.
+	// The encrypted names match only the whole text.
+	Search(ctx context.Context, q domain.SearchUsersQuery) ([]domain.UserMatch, error)
 }
 
-func NewUsersSQLRepo(p *pgxpool.Pool) UsersSQLRepo {
+// NewUsersSQLRepo stores the names encrypted, with their blind index.
+func NewUsersSQLRepo(p *pgxpool.Pool, ci pii.Cipher) UsersSQLRepo {
 	return usersSQLRepo{
-		pool: p,
+		pool:   p,
+		cipher: ci,
 	}
 }
 
 type usersSQLRepo struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	cipher pii.Cipher
 }
 
 func (r usersSQLRepo) Insert(ctx context.Context, name string) (domain.UserID, error) {
-	q1 := db.
+	encrypted, err := r.cipher.Encrypt(nameField, name)
+	if err != nil {
+		return "", fmt.Errorf("encrypting name: %w", err)
+	}
+	query := db.
 		Insert("users").
-		Cols("name", "created_at").
-		Vals(goqu.Vals{name, goqu.L("NOW()")})
+		Cols("name", "name_bidx", "created_at").
+		Vals(goqu.Vals{encrypted, r.cipher.BlindIndex(nameField, name), goqu.L("NOW()")}).
+		Returning("user_id")
 
-	sql1, params, err := q1.ToSQL()
+	sql, params, err := query.ToSQL()
 	if err != nil {
 		return "", fmt.Errorf("creating query: %w", err)
 	}
-	_, err = r.pool.Exec(ctx, sql1, params...)
-	if err != nil {
+	var userID int64
+	if err := r.pool.QueryRow(ctx, sql, params...).Scan(&userID); err != nil {
 		return "", fmt.Errorf("executing query: %w", err)
 	}
+	return domain.UserID(strconv.FormatInt(userID, 10)), nil
+}
+
+// userColumns are read by scanUser.
+var userColumns = []interface{}{"user_id", "name", "created_at", "version", "deleted_at"}
+
+// live leaves out the soft deleted users.
+var live = goqu.C("deleted_at").IsNull()
 
-	q2 := db.
-		Select("user_id").
+// plain is the predicate of the partial name indexes, the plaintext names.
+// The encrypted names match no prefix or trigram.
+var plain = goqu.C("name").NotLike(pii.Prefix + "%")
+
+// erasing is true for the users with an erasure, pending or not.
+var (
+	erasing   = goqu.L(`EXISTS (SELECT 1 FROM "user_erasures" WHERE "user_erasures"."user_id" = "users"."user_id")`)
+	notErased = goqu.L("NOT ?", erasing)
+)
+
+func (r usersSQLRepo) Get(ctx context.Context, id domain.UserID) (domain.User, error) {
+	query := db.
+		Select(userColumns...).
 		From("users").
-		Order(goqu.I("created_at").Desc()).
-		Limit(1)
+		Where(goqu.C("user_id").Eq(string(id)), live)
 
-	sql2, _, err := q2.ToSQL()
+	sql, params, err := query.ToSQL()
 	if err != nil {
-		return "", fmt.Errorf("creating query: %w", err)
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
 	}
-	var res string
-	if err := r.pool.QueryRow(ctx, sql2).Scan(&res); err != nil {
+	u, err := r.scanUser(ctx, r.pool.QueryRow(ctx, sql, params...))
+	if err != nil {
 		if err == pgx.ErrNoRows {
-			return "", domain.NewNotFoundError("user not found")
+			return domain.User{}, domain.NewNotFoundError("user not found")
 		}
-		return "", fmt.Errorf("executing query: %w", err)
+		return domain.User{}, fmt.Errorf("executing query: %w", err)
+	}
+	return u, nil
+}
+
+func (r usersSQLRepo) GetAny(ctx context.Context, id domain.UserID) (domain.User, error) {
+	query := db.
+		Select(userColumns...).
+		From("users").
+		Where(goqu.C("user_id").Eq(string(id)))
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
+	}
+	u, err := r.scanUser(ctx, r.pool.QueryRow(ctx, sql, params...))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.User{}, domain.NewNotFoundError("user not found")
+		}
+		return domain.User{}, fmt.Errorf("executing query: %w", err)
+	}
+	return u, nil
+}
+
+func (r usersSQLRepo) Update(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (domain.User, error) {
+	encrypted, err := r.cipher.Encrypt(nameField, name)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("encrypting name: %w", err)
+	}
+	return r.change(ctx, id, match, goqu.Record{"name": encrypted, "name_bidx": r.cipher.BlindIndex(nameField, name)})
+}
+
+func (r usersSQLRepo) Delete(ctx context.Context, id domain.UserID, match domain.VersionMatch) (domain.User, error) {
+	return r.change(ctx, id, match, goqu.Record{"deleted_at": goqu.L("NOW()")})
+}
+
+// change sets the columns of a live user of a matching version and bumps
+// the version.
+func (r usersSQLRepo) change(ctx context.Context, id domain.UserID, match domain.VersionMatch, set goqu.Record) (domain.User, error) {
+	if !match.Any && len(match.Versions) == 0 {
+		return domain.User{}, r.mismatch(ctx, id)
+	}
+	where := []goqu.Expression{goqu.C("user_id").Eq(string(id)), live}
+	if !match.Any {
+		where = append(where, goqu.C("version").In(match.Versions))
+	}
+	set["version"] = goqu.L(`"version" + 1`)
+	query := db.
+		Update("users").
+		Set(set).
+		Where(where...).
+		Returning(userColumns...)
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
+	}
+	u, err := r.scanUser(ctx, r.pool.QueryRow(ctx, sql, params...))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.User{}, r.mismatch(ctx, id)
+		}
+		return domain.User{}, fmt.Errorf("executing query: %w", err)
+	}
+	return u, nil
+}
+
+// mismatch tells why a conditional change touched no row: the user is
+// gone or its version doesn't match.
+func (r usersSQLRepo) mismatch(ctx context.Context, id domain.UserID) error {
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return domain.NewPreconditionFailedError("user version doesn't match")
+}
+
+func (r usersSQLRepo) Restore(ctx context.Context, id domain.UserID, deletedAfter time.Time) (domain.User, error) {
+	query := db.
+		Update("users").
+		Set(goqu.Record{"deleted_at": nil, "version": goqu.L(`"version" + 1`)}).
+		Where(goqu.C("user_id").Eq(string(id)), goqu.C("deleted_at").Gt(deletedAfter), notErased).
+		Returning(userColumns...)
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
+	}
+	u, err := r.scanUser(ctx, r.pool.QueryRow(ctx, sql, params...))
+	if err == nil {
+		return u, nil
+	}
+	if err != pgx.ErrNoRows {
+		return domain.User{}, fmt.Errorf("executing query: %w", err)
+	}
+
+	// Not restored, tell why.
+	sql, params, err = db.
+		Select("deleted_at", erasing).
+		From("users").
+		Where(goqu.C("user_id").Eq(string(id))).
+		ToSQL()
+	if err != nil {
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
+	}
+	var (
+		deletedAt *time.Time
+		erased    bool
+	)
+	if err := r.pool.QueryRow(ctx, sql, params...).Scan(&deletedAt, &erased); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.User{}, domain.NewNotFoundError("user not found")
+		}
+		return domain.User{}, fmt.Errorf("executing query: %w", err)
+	}
+	if erased {
+		return domain.User{}, domain.NewNotFoundError("user being erased")
+	}
+	if deletedAt == nil {
+		return domain.User{}, domain.NewConflictError("user isn't deleted")
+	}
+	return domain.User{}, domain.NewNotFoundError("user deleted before the retention window")
+}
+
+func (r usersSQLRepo) ListExpired(ctx context.Context, deletedBefore time.Time, limit int) ([]domain.User, error) {
+	query := db.
+		Select(userColumns...).
+		From("users").
+		Where(goqu.C("deleted_at").Lt(deletedBefore)).
+		Order(goqu.C("deleted_at").Asc()).
+		Limit(uint(limit))
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("creating query: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	defer rows.Close()
+
+	res := []domain.User{}
+	for rows.Next() {
+		u, err := r.scanUser(ctx, rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning user: %w", err)
+		}
+		res = append(res, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading users: %w", err)
+	}
+	return res, nil
+}
+
+func (r usersSQLRepo) Purge(ctx context.Context, p domain.UserPurge) (bool, error) {
+	del, delParams, err := db.
+		Delete("users").
+		Where(goqu.C("user_id").Eq(string(p.UserID)), goqu.C("deleted_at").Eq(p.DeletedAt)).
+		ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("creating query: %w", err)
+	}
+	audit, auditParams, err := db.
+		Insert("user_purges").
+		Rows(goqu.Record{
+			"user_id":      string(p.UserID),
+			"deleted_at":   p.DeletedAt,
+			"docs_deleted": p.DocsDeleted,
+			"keys_deleted": p.KeysDeleted,
+		}).
+		ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("creating query: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // a no-op after commit
+
+	tag, err := tx.Exec(ctx, del, delParams...)
+	if err != nil {
+		return false, fmt.Errorf("deleting user: %w", err)
+	}
+	if tag.RowsAffected() == 0 { // restored or purged meanwhile
+		return false, nil
+	}
+	if _, err := tx.Exec(ctx, audit, auditParams...); err != nil {
+		return false, fmt.Errorf("recording purge: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("committing transaction: %w", err)
+	}
+	return true, nil
+}
+
+func (r usersSQLRepo) StartErasure(ctx context.Context, id domain.UserID) (domain.User, error) {
+	del, delParams, err := db.
+		Update("users").
+		Set(goqu.Record{
+			"deleted_at": goqu.COALESCE(goqu.C("deleted_at"), goqu.L("NOW()")),
+			"version":    goqu.L(`"version" + 1`),
+		}).
+		Where(goqu.C("user_id").Eq(string(id))).
+		Returning(userColumns...).
+		ToSQL()
+	if err != nil {
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
+	}
+	record, recordParams, err := db.
+		Insert("user_erasures").
+		Rows(goqu.Record{"user_id": string(id)}).
+		OnConflict(goqu.DoNothing()).
+		ToSQL()
+	if err != nil {
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // a no-op after commit
+
+	u, err := r.scanUser(ctx, tx.QueryRow(ctx, del, delParams...))
+	if err == pgx.ErrNoRows {
+		return r.erasureOutlived(ctx, id)
+	}
+	if err != nil {
+		return domain.User{}, fmt.Errorf("deleting user: %w", err)
+	}
+	if _, err := tx.Exec(ctx, record, recordParams...); err != nil {
+		return domain.User{}, fmt.Errorf("recording erasure: %w", err)
 	}
-	return domain.UserID(res), nil
+	if err := tx.Commit(ctx); err != nil {
+		return domain.User{}, fmt.Errorf("committing transaction: %w", err)
+	}
+	return u, nil
+}
+
+// erasureOutlived returns the user of a pending erasure without a row,
+// purged meanwhile or erased by a run that failed to complete.
+func (r usersSQLRepo) erasureOutlived(ctx context.Context, id domain.UserID) (domain.User, error) {
+	sql, params, err := db.
+		Select("completed_at").
+		From("user_erasures").
+		Where(goqu.C("user_id").Eq(string(id))).
+		ToSQL()
+	if err != nil {
+		return domain.User{}, fmt.Errorf("creating query: %w", err)
+	}
+	var completedAt *time.Time
+	if err := r.pool.QueryRow(ctx, sql, params...).Scan(&completedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.User{}, domain.NewNotFoundError("user not found")
+		}
+		return domain.User{}, fmt.Errorf("executing query: %w", err)
+	}
+	if completedAt != nil {
+		return domain.User{}, domain.NewNotFoundError("user erased")
+	}
+	return domain.User{ID: id}, nil
+}
+
+func (r usersSQLRepo) CompleteErasure(ctx context.Context, e domain.UserErasure) (domain.UserErasure, error) {
+	del, delParams, err := db.
+		Delete("users").
+		Where(goqu.C("user_id").Eq(string(e.UserID))).
+		ToSQL()
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("creating query: %w", err)
+	}
+	complete, completeParams, err := db.
+		Update("user_erasures").
+		Set(goqu.Record{
+			"completed_at": goqu.COALESCE(goqu.C("completed_at"), goqu.L("NOW()")),
+			"docs_deleted": goqu.L(`"docs_deleted" + ?`, e.DocsDeleted),
+			"keys_deleted": goqu.L(`"keys_deleted" + ?`, e.KeysDeleted),
+		}).
+		Where(goqu.C("user_id").Eq(string(e.UserID))).
+		Returning("requested_at", "completed_at", "docs_deleted", "keys_deleted").
+		ToSQL()
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("creating query: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // a no-op after commit
+
+	if _, err := tx.Exec(ctx, del, delParams...); err != nil {
+		return domain.UserErasure{}, fmt.Errorf("deleting user: %w", err)
+	}
+	res := domain.UserErasure{UserID: e.UserID}
+	err = tx.QueryRow(ctx, complete, completeParams...).Scan(&res.RequestedAt, &res.CompletedAt, &res.DocsDeleted, &res.KeysDeleted)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.UserErasure{}, domain.NewNotFoundError("erasure not found")
+		}
+		return domain.UserErasure{}, fmt.Errorf("completing erasure: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.UserErasure{}, fmt.Errorf("committing transaction: %w", err)
+	}
+	return res, nil
+}
+
+func (r usersSQLRepo) ListPendingErasures(ctx context.Context, limit int) ([]domain.UserErasure, error) {
+	query := db.
+		Select("user_id", "requested_at").
+		From("user_erasures").
+		Where(goqu.C("completed_at").IsNull()).
+		Order(goqu.C("requested_at").Asc()).
+		Limit(uint(limit))
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("creating query: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	defer rows.Close()
+
+	res := []domain.UserErasure{}
+	for rows.Next() {
+		var (
+			e      domain.UserErasure
+			userID int64
+		)
+		if err := rows.Scan(&userID, &e.RequestedAt); err != nil {
+			return nil, fmt.Errorf("scanning erasure: %w", err)
+		}
+		e.UserID = domain.UserID(strconv.FormatInt(userID, 10))
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading erasures: %w", err)
+	}
+	return res, nil
+}
+
+func (r usersSQLRepo) scanUser(ctx context.Context, row pgx.Row) (domain.User, error) {
+	var (
+		u         domain.User
+		userID    int64
+		deletedAt *time.Time
+	)
+	if err := row.Scan(&userID, &u.Name, &u.CreatedAt, &u.Version, &deletedAt); err != nil {
+		return domain.User{}, err
+	}
+	name, err := r.cipher.Decrypt(ctx, nameField, u.Name)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("decrypting name: %w", err)
+	}
+	u.Name = name
+	u.ID = domain.UserID(strconv.FormatInt(userID, 10))
+	if deletedAt != nil {
+		u.DeletedAt = *deletedAt
+	}
+	return u, nil
+}
+
+func (r usersSQLRepo) List(ctx context.Context, q domain.ListUsersQuery) ([]domain.User, error) {
+	where := []goqu.Expression{live}
+	if q.Filter.NamePrefix != "" {
+		if r.cipher.Enabled() {
+			return nil, domain.NewInvalidError("the names are encrypted, they can't be filtered by prefix")
+		}
+		where = append(where, plain, goqu.C("name").Like(likePrefix(q.Filter.NamePrefix)))
+	}
+	if !q.Filter.CreatedAfter.IsZero() {
+		where = append(where, goqu.C("created_at").Gt(q.Filter.CreatedAfter))
+	}
+	if !q.Filter.CreatedBefore.IsZero() {
+		where = append(where, goqu.C("created_at").Lt(q.Filter.CreatedBefore))
+	}
+
+	// Keyset pagination, backwards in reverse order.
+	order := []exp.OrderedExpression{goqu.C("created_at").Asc(), goqu.C("user_id").Asc()}
+	switch {
+	case q.After != nil:
+		where = append(where, goqu.L("(created_at, user_id) > (?, ?)", q.After.CreatedAt, string(q.After.ID)))
+	case q.Before != nil:
+		where = append(where, goqu.L("(created_at, user_id) < (?, ?)", q.Before.CreatedAt, string(q.Before.ID)))
+		order = []exp.OrderedExpression{goqu.C("created_at").Desc(), goqu.C("user_id").Desc()}
+	}
+
+	query := db.
+		Select("user_id", "name", "created_at").
+		From("users").
+		Where(where...).
+		Order(order...).
+		Limit(uint(q.Limit))
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("creating query: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	defer rows.Close()
+
+	res := []domain.User{}
+	for rows.Next() {
+		var (
+			u  domain.User
+			id int64
+		)
+		if err := rows.Scan(&id, &u.Name, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning user: %w", err)
+		}
+		if u.Name, err = r.cipher.Decrypt(ctx, nameField, u.Name); err != nil {
+			return nil, fmt.Errorf("decrypting name: %w", err)
+		}
+		u.ID = domain.UserID(strconv.FormatInt(id, 10))
+		res = append(res, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading users: %w", err)
+	}
+
+	if q.Before != nil {
+		slices.Reverse(res)
+	}
+	return res, nil
+}
+
+// Search matches the names by full text and by trigram similarity of the
+// whole name or of a word in it, for partial and misspelled names. The
+// similarity thresholds are set for the transaction, so the trigram
+// operators can use the GIN index.
+func (r usersSQLRepo) Search(ctx context.Context, q domain.SearchUsersQuery) ([]domain.UserMatch, error) {
+	if r.cipher.Enabled() {
+		return r.searchBlindIndex(ctx, q)
+	}
+	// Same expression as the users_name_tsv_idx index.
+	tsv := goqu.L("to_tsvector('simple', name)")
+	tsq := goqu.L("websearch_to_tsquery('simple', ?)", q.Text)
+	headline := "StartSel=" + domain.HighlightStart + ", StopSel=" + domain.HighlightStop + ", HighlightAll=true"
+
+	query := db.
+		Select(
+			"user_id", "name", "created_at",
+			goqu.L("GREATEST(similarity(name, ?), word_similarity(?, name)) + ts_rank(?, ?)", q.Text, q.Text, tsv, tsq).As("rank"),
+			goqu.L("ts_headline('simple', name, ?, ?)", tsq, headline).As("highlight"),
+		).
+		From("users").
+		Where(live, plain, goqu.Or(
+			goqu.L("? @@ ?", tsv, tsq),
+			goqu.L("name % ?", q.Text),
+			goqu.L("? <% name", q.Text),
+		)).
+		Order(goqu.I("rank").Desc(), goqu.C("user_id").Asc()).
+		Limit(uint(q.Limit)).
+		Offset(uint(q.Offset))
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("creating query: %w", err)
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // a no-op after commit
+
+	threshold := strconv.FormatFloat(q.MinSimilarity, 'f', -1, 64)
+	if _, err := tx.Exec(ctx,
+		"SELECT set_config('pg_trgm.similarity_threshold', $1, true), set_config('pg_trgm.word_similarity_threshold', $1, true)",
+		threshold,
+	); err != nil {
+		return nil, fmt.Errorf("setting similarity threshold: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	res := []domain.UserMatch{}
+	for rows.Next() {
+		var (
+			m  domain.UserMatch
+			id int64
+		)
+		if err := rows.Scan(&id, &m.Name, &m.CreatedAt, &m.Rank, &m.Highlight); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning user: %w", err)
+		}
+		m.ID = domain.UserID(strconv.FormatInt(id, 10))
+		res = append(res, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading users: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return res, nil
+}
+
+// searchBlindIndex matches the encrypted names equal to the text, all of
+// the name is highlighted.
+func (r usersSQLRepo) searchBlindIndex(ctx context.Context, q domain.SearchUsersQuery) ([]domain.UserMatch, error) {
+	query := db.
+		Select("user_id", "name", "created_at").
+		From("users").
+		Where(live, goqu.C("name_bidx").Eq(r.cipher.BlindIndex(nameField, q.Text))).
+		Order(goqu.C("user_id").Asc()).
+		Limit(uint(q.Limit)).
+		Offset(uint(q.Offset))
+
+	sql, params, err := query.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("creating query: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	defer rows.Close()
+
+	res := []domain.UserMatch{}
+	for rows.Next() {
+		var (
+			m  domain.UserMatch
+			id int64
+		)
+		if err := rows.Scan(&id, &m.Name, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning user: %w", err)
+		}
+		if m.Name, err = r.cipher.Decrypt(ctx, nameField, m.Name); err != nil {
+			return nil, fmt.Errorf("decrypting name: %w", err)
+		}
+		m.ID = domain.UserID(strconv.FormatInt(id, 10))
+		m.Rank = 1
+		m.Highlight = domain.HighlightStart + m.Name + domain.HighlightStop
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading users: %w", err)
+	}
+	return res, nil
+}
+
+// likePrefix escapes the LIKE wildcards in the prefix.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
 }
@@ -2,12 +2,19 @@ package dummy
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
 )
 
 var (
@@ -33,7 +40,8 @@ func Test_usersSQLRepo_Insert(t *testing.T) {
 	}
 
 	r := usersSQLRepo{
-		pool: testPostgresPool,
+		pool:   testPostgresPool,
+		cipher: pii.NewPlaintext(),
 	}
 
 	for _, tt := range tests {
@@ -48,3 +56,442 @@ func Test_usersSQLRepo_Insert(t *testing.T) {
 		})
 	}
 }
+
+// Test_usersSQLRepo_Insert_concurrent checks that each insert returns the
+// ID of its own row.
+func Test_usersSQLRepo_Insert_concurrent(t *testing.T) {
+	ctx := context.Background()
+	r := usersSQLRepo{
+		pool:   testPostgresPool,
+		cipher: pii.NewPlaintext(),
+	}
+
+	const inserts = 20
+	ids := make([]domain.UserID, inserts)
+	var wg sync.WaitGroup
+	for i := 0; i < inserts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := r.Insert(ctx, fmt.Sprintf("concurrent_%d", i))
+			assert.NoError(t, err)
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	for i, id := range ids {
+		var name string
+		err := testPostgresPool.QueryRow(ctx, "SELECT name FROM users WHERE user_id = $1", string(id)).Scan(&name)
+		require.NoError(t, err, id)
+		assert.Equal(t, fmt.Sprintf("concurrent_%d", i), name)
+	}
+}
+
+func Test_usersSQLRepo_GetUpdateDelete(t *testing.T) {
+	ctx := context.Background()
+	r := usersSQLRepo{
+		pool:   testPostgresPool,
+		cipher: pii.NewPlaintext(),
+	}
+
+	var userID int64
+	err := testPostgresPool.QueryRow(ctx,
+		"INSERT INTO users (name, created_at) VALUES ('get_1', NOW()) RETURNING user_id",
+	).Scan(&userID)
+	require.NoError(t, err)
+	id := domain.UserID(strconv.FormatInt(userID, 10))
+
+	update := func(name string, match domain.VersionMatch) func() error {
+		return func() error {
+			_, err := r.Update(ctx, id, name, match)
+			return err
+		}
+	}
+	del := func(match domain.VersionMatch) func() error {
+		return func() error {
+			_, err := r.Delete(ctx, id, match)
+			return err
+		}
+	}
+	restore := func(deletedAfter time.Time) func() error {
+		return func() error {
+			_, err := r.Restore(ctx, id, deletedAfter)
+			return err
+		}
+	}
+	purge := func(wantPurged bool) func() error {
+		return func() error {
+			expired, err := r.ListExpired(ctx, time.Now().Add(time.Hour), 100)
+			if err != nil {
+				return err
+			}
+			var u domain.User
+			for _, e := range expired {
+				if e.ID == id {
+					u = e
+				}
+			}
+			purged, err := r.Purge(ctx, domain.UserPurge{UserID: id, DeletedAt: u.DeletedAt, DocsDeleted: 1, KeysDeleted: 2})
+			if err != nil {
+				return err
+			}
+			if purged != wantPurged {
+				return fmt.Errorf("purged %t, want %t", purged, wantPurged)
+			}
+			return nil
+		}
+	}
+	hourAgo := time.Now().Add(-time.Hour)
+
+	// Steps in order, each on the state left by the previous ones.
+	tests := []struct {
+		name         string
+		act          func() error
+		wantName     string // from Get after the step, empty = not found
+		wantVersion  int64
+		wantNotFound bool
+		wantMismatch bool
+		wantConflict bool
+	}{
+		{
+			name:        "Positive: Get",
+			act:         func() error { return nil },
+			wantName:    "get_1",
+			wantVersion: 1,
+		},
+		{
+			name:        "Positive: Update any version",
+			act:         update("get_2", domain.AnyVersion),
+			wantName:    "get_2",
+			wantVersion: 2,
+		},
+		{
+			name:        "Positive: Update matching version",
+			act:         update("get_3", domain.VersionMatch{Versions: []int64{1, 2}}),
+			wantName:    "get_3",
+			wantVersion: 3,
+		},
+		{
+			name:         "Negative: Update stale version",
+			act:          update("get_4", domain.VersionMatch{Versions: []int64{2}}),
+			wantName:     "get_3",
+			wantVersion:  3,
+			wantMismatch: true,
+		},
+		{
+			name:         "Negative: Update no version",
+			act:          update("get_4", domain.VersionMatch{}),
+			wantName:     "get_3",
+			wantVersion:  3,
+			wantMismatch: true,
+		},
+		{
+			name:         "Negative: Restore not deleted",
+			act:          restore(hourAgo),
+			wantName:     "get_3",
+			wantVersion:  3,
+			wantConflict: true,
+		},
+		{
+			name:         "Negative: Delete stale version",
+			act:          del(domain.VersionMatch{Versions: []int64{2}}),
+			wantName:     "get_3",
+			wantVersion:  3,
+			wantMismatch: true,
+		},
+		{
+			name: "Positive: Delete matching version",
+			act:  del(domain.VersionMatch{Versions: []int64{3}}),
+		},
+		{
+			name:         "Negative: Update deleted",
+			act:          update("get_5", domain.AnyVersion),
+			wantNotFound: true,
+		},
+		{
+			name:         "Negative: Delete deleted",
+			act:          del(domain.AnyVersion),
+			wantNotFound: true,
+		},
+		{
+			name:        "Positive: Restore",
+			act:         restore(hourAgo),
+			wantName:    "get_3",
+			wantVersion: 5,
+		},
+		{
+			name: "Positive: Delete again",
+			act:  del(domain.AnyVersion),
+		},
+		{
+			name:         "Negative: Restore after the retention",
+			act:          restore(time.Now().Add(time.Hour)),
+			wantNotFound: true,
+		},
+		{
+			name: "Positive: Purge",
+			act:  purge(true),
+		},
+		{
+			name: "Positive: Purge purged",
+			act:  purge(false),
+		},
+		{
+			name:         "Negative: Restore purged",
+			act:          restore(hourAgo),
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := tt.act()
+			var (
+				nf       *domain.NotFoundError
+				mismatch *domain.PreconditionFailedError
+				conflict *domain.ConflictError
+			)
+			assert.Equal(tt.wantNotFound, errors.As(err, &nf), err)
+			assert.Equal(tt.wantMismatch, errors.As(err, &mismatch), err)
+			assert.Equal(tt.wantConflict, errors.As(err, &conflict), err)
+			if !tt.wantNotFound && !tt.wantMismatch && !tt.wantConflict {
+				assert.NoError(err)
+			}
+
+			got, err := r.Get(ctx, id)
+			if tt.wantName == "" {
+				assert.True(errors.As(err, &nf), err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(id, got.ID)
+			assert.Equal(tt.wantName, got.Name)
+			assert.Equal(tt.wantVersion, got.Version)
+		})
+	}
+
+	var audited int
+	err = testPostgresPool.QueryRow(ctx,
+		"SELECT count(*) FROM user_purges WHERE user_id = $1 AND docs_deleted = 1 AND keys_deleted = 2", userID,
+	).Scan(&audited)
+	require.NoError(t, err)
+	assert.Equal(t, 1, audited, "purge audited once")
+}
+
+func Test_usersSQLRepo_Erasure(t *testing.T) {
+	ctx := context.Background()
+	r := usersSQLRepo{
+		pool:   testPostgresPool,
+		cipher: pii.NewPlaintext(),
+	}
+
+	var userID int64
+	err := testPostgresPool.QueryRow(ctx,
+		"INSERT INTO users (name, created_at) VALUES ('erase_1', NOW()) RETURNING user_id",
+	).Scan(&userID)
+	require.NoError(t, err)
+	id := domain.UserID(strconv.FormatInt(userID, 10))
+
+	pending := func() []domain.UserID {
+		erasures, err := r.ListPendingErasures(ctx, 100)
+		require.NoError(t, err)
+		var ids []domain.UserID
+		for _, e := range erasures {
+			ids = append(ids, e.UserID)
+		}
+		return ids
+	}
+
+	u, err := r.StartErasure(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "erase_1", u.Name)
+	assert.False(t, u.DeletedAt.IsZero(), "soft deleted")
+	assert.Contains(t, pending(), id)
+
+	_, err = r.Get(ctx, id)
+	var nf *domain.NotFoundError
+	assert.ErrorAs(t, err, &nf, "gone for the readers")
+	_, err = r.Restore(ctx, id, time.Now().Add(-time.Hour))
+	assert.ErrorAs(t, err, &nf, "not restorable")
+
+	// Started again after a failure, the deletion time is kept.
+	again, err := r.StartErasure(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, u.DeletedAt.Equal(again.DeletedAt))
+
+	first, err := r.CompleteErasure(ctx, domain.UserErasure{UserID: id, DocsDeleted: 1, KeysDeleted: 2})
+	require.NoError(t, err)
+	assert.False(t, first.CompletedAt.IsZero())
+	assert.NotContains(t, pending(), id)
+
+	_, err = r.GetAny(ctx, id)
+	assert.ErrorAs(t, err, &nf, "row deleted")
+	_, err = r.StartErasure(ctx, id)
+	assert.ErrorAs(t, err, &nf, "erased")
+
+	// Completed again by a concurrent attempt, the counts add up.
+	second, err := r.CompleteErasure(ctx, domain.UserErasure{UserID: id, DocsDeleted: 1})
+	require.NoError(t, err)
+	assert.True(t, first.CompletedAt.Equal(second.CompletedAt))
+	assert.Equal(t, int64(2), second.DocsDeleted)
+	assert.Equal(t, int64(2), second.KeysDeleted)
+}
+
+func Test_usersSQLRepo_List(t *testing.T) {
+	ctx := context.Background()
+	r := usersSQLRepo{
+		pool:   testPostgresPool,
+		cipher: pii.NewPlaintext(),
+	}
+
+	// Same created_at for some, the user_id breaks the tie.
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var all []domain.User
+	for i, at := range []time.Duration{0, time.Hour, time.Hour, time.Hour, 2 * time.Hour} {
+		var id int64
+		err := testPostgresPool.QueryRow(ctx,
+			"INSERT INTO users (name, created_at) VALUES ($1, $2) RETURNING user_id",
+			fmt.Sprintf("list_%d", i), base.Add(at),
+		).Scan(&id)
+		require.NoError(t, err)
+		all = append(all, domain.User{ID: domain.UserID(strconv.FormatInt(id, 10)), Name: fmt.Sprintf("list_%d", i), CreatedAt: base.Add(at)})
+	}
+	_, err := testPostgresPool.Exec(ctx, "INSERT INTO users (name, created_at) VALUES ('list%_other', NOW())")
+	require.NoError(t, err)
+
+	prefix := domain.UsersFilter{NamePrefix: "list_"}
+	key := func(i int) *domain.UserKey {
+		k := all[i].Key()
+		return &k
+	}
+
+	tests := []struct {
+		name    string
+		query   domain.ListUsersQuery
+		want    []domain.User
+		wantErr bool
+	}{
+		{
+			name:  "Positive: First page",
+			query: domain.ListUsersQuery{Filter: prefix, Limit: 2},
+			want:  all[:2],
+		},
+		{
+			name:  "Positive: After a key with the same created_at",
+			query: domain.ListUsersQuery{Filter: prefix, After: key(1), Limit: 2},
+			want:  all[2:4],
+		},
+		{
+			name:  "Positive: Before a key, in order",
+			query: domain.ListUsersQuery{Filter: prefix, Before: key(3), Limit: 2},
+			want:  all[1:3],
+		},
+		{
+			name:  "Positive: Created between",
+			query: domain.ListUsersQuery{Filter: domain.UsersFilter{NamePrefix: "list_", CreatedAfter: base, CreatedBefore: base.Add(2 * time.Hour)}, Limit: 10},
+			want:  all[1:4],
+		},
+		{
+			name:  "Positive: Wildcards in the prefix are literal",
+			query: domain.ListUsersQuery{Filter: prefix, Limit: 10},
+			want:  all, // not list%_other
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := r.List(ctx, tt.query)
+
+			assert.Equal(tt.wantErr, err != nil, err)
+			assert.Equal(len(tt.want), len(got))
+			for i := range tt.want {
+				if i < len(got) {
+					assert.Equal(tt.want[i].ID, got[i].ID)
+					assert.Equal(tt.want[i].Name, got[i].Name)
+					assert.True(tt.want[i].CreatedAt.Equal(got[i].CreatedAt))
+				}
+			}
+		})
+	}
+}
+
+func Test_usersSQLRepo_Search(t *testing.T) {
+	ctx := context.Background()
+	r := usersSQLRepo{
+		pool:   testPostgresPool,
+		cipher: pii.NewPlaintext(),
+	}
+
+	for _, name := range []string{"Katherine Search", "Catherine Search", "Kathryn Search", "Bob Search"} {
+		_, err := testPostgresPool.Exec(ctx, "INSERT INTO users (name, created_at) VALUES ($1, NOW())", name)
+		require.NoError(t, err)
+	}
+
+	names := func(ms []domain.UserMatch) []string {
+		res := []string{}
+		for _, m := range ms {
+			res = append(res, m.Name)
+		}
+		return res
+	}
+
+	// The scores depend on pg_trgm, only the certain outcomes are checked.
+	tests := []struct {
+		name          string
+		query         domain.SearchUsersQuery
+		wantFirst     string
+		wantLen       int
+		wantHighlight string
+	}{
+		{
+			name:          "Positive: Exact word ranks first and is highlighted",
+			query:         domain.SearchUsersQuery{Text: "katherine", MinSimilarity: 0.3, Limit: 10},
+			wantFirst:     "Katherine Search",
+			wantHighlight: domain.HighlightStart + "Katherine" + domain.HighlightStop + " Search",
+		},
+		{
+			name:      "Positive: Misspelled",
+			query:     domain.SearchUsersQuery{Text: "katherin", MinSimilarity: 0.3, Limit: 10},
+			wantFirst: "Katherine Search",
+		},
+		{
+			name:    "Positive: Paged",
+			query:   domain.SearchUsersQuery{Text: "katherine", MinSimilarity: 0.3, Offset: 1, Limit: 1},
+			wantLen: 1,
+		},
+		{
+			name:  "Positive: Nothing similar enough",
+			query: domain.SearchUsersQuery{Text: "zzzz", MinSimilarity: 0.3, Limit: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := r.Search(ctx, tt.query)
+
+			assert.NoError(err)
+			assert.NotContains(names(got), "Bob Search")
+			switch {
+			case tt.wantFirst != "":
+				if assert.NotEmpty(got) {
+					assert.Equal(tt.wantFirst, got[0].Name)
+				}
+			case tt.wantLen > 0:
+				assert.Len(got, tt.wantLen)
+				assert.NotContains(names(got), "Katherine Search")
+			default:
+				assert.Empty(got)
+			}
+			if tt.wantHighlight != "" && len(got) > 0 {
+				assert.Equal(tt.wantHighlight, got[0].Highlight)
+			}
+		})
+	}
+}
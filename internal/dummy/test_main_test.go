@@ -10,13 +10,15 @@ import (
 	"github.com/go-kit/log"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"ws-dummy-go/internal/migrator"
+	"ws-dummy-go/migrations"
 )
 
 const (
@@ -25,6 +27,12 @@ const (
 	timeout  = 30 // seconds
 )
 
+var (
+	// testPostgresURL is for the tests connecting on their own, e.g. to
+	// another database of the server.
+	testPostgresURL string
+)
+
 func TestMain(m *testing.M) {
 	logger := log.NewLogfmtLogger(os.Stderr)
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
@@ -147,6 +155,7 @@ func TestMain(m *testing.M) {
 		"postgres://%s:%s@localhost:%s/postgres?connect_timeout=%d&sslmode=disable",
 		username, password, pgImage.GetPort("5432/tcp"), 10,
 	)
+	testPostgresURL = pgURL
 	if err := pool.Retry(func() error {
 		testPostgresPool, err = pgxpool.New(context.Background(), pgURL)
 		if err != nil {
@@ -168,7 +177,12 @@ func TestMain(m *testing.M) {
 	}()
 
 	// Migrate
-	mg, err := migrate.New("file://../../migrations", pgURL)
+	src, err := migrations.Source()
+	if err != nil {
+		logger.Log("msg", "opening migrations", "err", err)
+		return
+	}
+	mg, err := migrate.NewWithSourceInstance("iofs", src, pgURL)
 	if err != nil {
 		logger.Log("msg", "initializing migrations", "err", err)
 		return
@@ -178,5 +192,17 @@ func TestMain(m *testing.M) {
 		return
 	}
 
+	mongoMg, err := migrator.NewMongo(testMongoClient.Database("test_dummy"), migrations.Mongo)
+	if err != nil {
+		logger.Log("msg", "initializing mongo migrations", "err", err)
+		return
+	}
+	mongoMigs, _ := mongoMg.Migrations(migrator.NoVersion, false)
+	mongoSteps, _ := migrator.PlanUp(mongoMigs, 0)
+	if err := mongoMg.Run(context.Background(), mongoSteps); err != nil {
+		logger.Log("msg", "running mongo migrations up", "err", err)
+		return
+	}
+
 	m.Run()
 }
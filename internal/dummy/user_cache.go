@@ -0,0 +1,146 @@
+package dummy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/redis/go-redis/v9"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
+)
+
+// UsersCache keeps the users by ID. Get of a user cached as not found
+// returns found and a domain.NotFoundError.
+type UsersCache interface {
+	Get(ctx context.Context, id domain.UserID) (domain.User, bool, error)
+	Set(ctx context.Context, u domain.User) error
+	SetNotFound(ctx context.Context, id domain.UserID) error
+	Delete(ctx context.Context, id domain.UserID) error
+}
+
+// UsersCacheConfig sets the expiry of the cached users. Each entry lives
+// TTL plus a random part of Jitter, so entries cached together don't
+// expire together. A zero NegativeTTL doesn't cache not found users.
+type UsersCacheConfig struct {
+	TTL         time.Duration
+	Jitter      time.Duration
+	NegativeTTL time.Duration
+}
+
+// Lookup results counted by the users cache.
+const (
+	cacheHit         = "hit"
+	cacheNegativeHit = "negative_hit"
+	cacheMiss        = "miss"
+	cacheError       = "error"
+)
+
+// notFoundValue marks the users cached as not found.
+const notFoundValue = ""
+
+// NewUsersRedisCache counts the lookups by "result": hit, negative_hit,
+// miss or error. The names are cached encrypted.
+func NewUsersRedisCache(c RedisClient, keys Keys, cfg UsersCacheConfig, lookups metrics.Counter, ci pii.Cipher) UsersCache {
+	return usersRedisCache{
+		client:  c,
+		keys:    keys,
+		cfg:     cfg,
+		lookups: lookups,
+		cipher:  ci,
+	}
+}
+
+type usersRedisCache struct {
+	client  RedisClient
+	keys    Keys
+	cfg     UsersCacheConfig
+	lookups metrics.Counter
+	cipher  pii.Cipher
+}
+
+type cachedUser struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int64     `json:"version"`
+}
+
+func (c usersRedisCache) Get(ctx context.Context, id domain.UserID) (domain.User, bool, error) {
+	raw, err := c.client().Get(ctx, c.keys.CachedUser(id)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			c.count(cacheMiss)
+			return domain.User{}, false, nil
+		}
+		c.count(cacheError)
+		return domain.User{}, false, fmt.Errorf("getting key: %w", err)
+	}
+	if raw == notFoundValue {
+		c.count(cacheNegativeHit)
+		return domain.User{}, true, domain.NewNotFoundError("user not found")
+	}
+	var u cachedUser
+	if err := json.Unmarshal([]byte(raw), &u); err != nil {
+		c.count(cacheError)
+		return domain.User{}, false, fmt.Errorf("decoding user: %w", err)
+	}
+	if u.Version == 0 { // cached before the versions, its ETag would be wrong
+		c.count(cacheMiss)
+		return domain.User{}, false, nil
+	}
+	name, err := c.cipher.Decrypt(ctx, nameField, u.Name)
+	if err != nil {
+		c.count(cacheError)
+		return domain.User{}, false, fmt.Errorf("decrypting name: %w", err)
+	}
+	c.count(cacheHit)
+	return domain.User{ID: domain.UserID(u.ID), Name: name, CreatedAt: u.CreatedAt, Version: u.Version}, true, nil
+}
+
+func (c usersRedisCache) Set(ctx context.Context, u domain.User) error {
+	name, err := c.cipher.Encrypt(nameField, u.Name)
+	if err != nil {
+		return fmt.Errorf("encrypting name: %w", err)
+	}
+	raw, err := json.Marshal(cachedUser{ID: string(u.ID), Name: name, CreatedAt: u.CreatedAt, Version: u.Version})
+	if err != nil {
+		return fmt.Errorf("encoding user: %w", err)
+	}
+	if err := c.client().Set(ctx, c.keys.CachedUser(u.ID), raw, withJitter(c.cfg.TTL, c.cfg.Jitter)).Err(); err != nil {
+		return fmt.Errorf("setting key: %w", err)
+	}
+	return nil
+}
+
+func (c usersRedisCache) SetNotFound(ctx context.Context, id domain.UserID) error {
+	if c.cfg.NegativeTTL <= 0 {
+		return nil
+	}
+	if err := c.client().Set(ctx, c.keys.CachedUser(id), notFoundValue, c.cfg.NegativeTTL).Err(); err != nil {
+		return fmt.Errorf("setting key: %w", err)
+	}
+	return nil
+}
+
+func (c usersRedisCache) Delete(ctx context.Context, id domain.UserID) error {
+	if err := c.client().Del(ctx, c.keys.CachedUser(id)).Err(); err != nil {
+		return fmt.Errorf("deleting key: %w", err)
+	}
+	return nil
+}
+
+func (c usersRedisCache) count(result string) {
+	c.lookups.With("result", result).Add(1)
+}
+
+func withJitter(ttl, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return ttl
+	}
+	return ttl + rand.N(jitter)
+}
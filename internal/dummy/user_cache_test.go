@@ -0,0 +1,126 @@
+package dummy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/pii"
+)
+
+// resultCounter counts by the "result" label.
+type resultCounter map[string]float64
+
+func (c resultCounter) With(lvs ...string) metrics.Counter {
+	return labeledCounter{c, lvs[1]}
+}
+
+func (c resultCounter) Add(float64) {}
+
+type labeledCounter struct {
+	counts resultCounter
+	result string
+}
+
+func (c labeledCounter) With(...string) metrics.Counter { return c }
+func (c labeledCounter) Add(delta float64)              { c.counts[c.result] += delta }
+
+func Test_usersRedisCache(t *testing.T) {
+	ctx := context.Background()
+	lookups := resultCounter{}
+	c := usersRedisCache{
+		client:  StaticRedisClient(testRedisClient),
+		keys:    NewKeys("test", "test"),
+		cfg:     UsersCacheConfig{TTL: time.Minute, Jitter: 10 * time.Second, NegativeTTL: 5 * time.Second},
+		lookups: lookups,
+		cipher:  pii.NewPlaintext(),
+	}
+	user := domain.User{ID: "cache1", Name: "testname123", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC), Version: 3}
+
+	// Steps in order, each on the state left by the previous ones.
+	tests := []struct {
+		name         string
+		act          func() error
+		wantFound    bool
+		wantNotFound bool
+		wantTTL      time.Duration // at least, if found
+		wantResult   string
+	}{
+		{
+			name:       "Positive: Miss",
+			act:        func() error { return nil },
+			wantResult: cacheMiss,
+		},
+		{
+			name:       "Positive: Hit with jittered TTL",
+			act:        func() error { return c.Set(ctx, user) },
+			wantFound:  true,
+			wantTTL:    50 * time.Second,
+			wantResult: cacheHit,
+		},
+		{
+			name: "Negative: Cached without a version",
+			act: func() error {
+				return testRedisClient.Set(ctx, c.keys.CachedUser(user.ID), `{"id":"cache1","name":"testname123"}`, time.Minute).Err()
+			},
+			wantResult: cacheMiss,
+		},
+		{
+			name:       "Positive: Delete",
+			act:        func() error { return c.Delete(ctx, user.ID) },
+			wantResult: cacheMiss,
+		},
+		{
+			name:         "Negative: Cached not found",
+			act:          func() error { return c.SetNotFound(ctx, user.ID) },
+			wantFound:    true,
+			wantNotFound: true,
+			wantTTL:      time.Second,
+			wantResult:   cacheNegativeHit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			require.NoError(t, tt.act())
+			before := lookups[tt.wantResult]
+
+			got, found, err := c.Get(ctx, user.ID)
+
+			assert.Equal(tt.wantFound, found)
+			var nf *domain.NotFoundError
+			assert.Equal(tt.wantNotFound, errors.As(err, &nf), err)
+			if tt.wantFound && !tt.wantNotFound {
+				assert.NoError(err)
+				assert.Equal(user.ID, got.ID)
+				assert.Equal(user.Name, got.Name)
+				assert.Equal(user.Version, got.Version)
+				assert.True(user.CreatedAt.Equal(got.CreatedAt))
+			}
+			if tt.wantFound {
+				ttl, err := testRedisClient.TTL(ctx, c.keys.CachedUser(user.ID)).Result()
+				require.NoError(t, err)
+				assert.GreaterOrEqual(ttl, tt.wantTTL)
+			}
+			assert.Equal(before+1, lookups[tt.wantResult])
+		})
+	}
+}
+
+func Test_withJitter(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(time.Minute, withJitter(time.Minute, 0))
+	for i := 0; i < 100; i++ {
+		got := withJitter(time.Minute, time.Second)
+		assert.GreaterOrEqual(got, time.Minute)
+		assert.Less(got, time.Minute+time.Second)
+	}
+}
@@ -2,38 +2,320 @@ package dummy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
 
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/jsondoc"
 )
 
 // UserService provides operations on dummys.
 type UserService interface {
 	CreateUser(ctx context.Context, name string) (domain.UserID, error)
+	GetUser(ctx context.Context, id domain.UserID) (domain.User, error)
+	// The changes apply if the version of the user or profile matches,
+	// else they return a domain.PreconditionFailedError.
+	UpdateUser(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (domain.User, error)
+	// DeleteUser is soft, RestoreUser undoes it within the retention.
+	DeleteUser(ctx context.Context, id domain.UserID, match domain.VersionMatch) error
+	RestoreUser(ctx context.Context, id domain.UserID) (domain.User, error)
+	// EraseUser removes the user from all the stores for good, deleted or
+	// not, and returns its tombstone. ExportUser returns all its data.
+	EraseUser(ctx context.Context, id domain.UserID) (domain.UserErasure, error)
+	ExportUser(ctx context.Context, id domain.UserID) (domain.UserExport, error)
+	GetProfile(ctx context.Context, id domain.UserID) (domain.Profile, error)
+	PutProfile(ctx context.Context, id domain.UserID, data map[string]any, match domain.VersionMatch) (domain.Profile, error)
+	PatchProfile(ctx context.Context, id domain.UserID, patch domain.ProfilePatch, match domain.VersionMatch) (domain.Profile, error)
+	ListUsers(ctx context.Context, q domain.ListUsersQuery) (domain.UsersPage, error)
+	SearchUsers(ctx context.Context, q domain.SearchUsersQuery) (domain.UsersSearchPage, error)
 }
 
-func NewUserService(kv UsersKVRepo, sql UsersSQLRepo, docs UsersDocsRepo) UserService {
-	return userService{kv, sql, docs}
+// NewUserService restores the users deleted within the retention, the
+// older ones are left to the UsersPurger.
+func NewUserService(
+	kv UsersKVRepo, sql UsersSQLRepo, docs UsersDocsRepo, cache UsersCache, profiles UsersProfilesRepo, retention time.Duration,
+) UserService {
+	return userService{
+		kv, sql, docs, cache, profiles, &singleflight.Group{}, retention,
+		NewUsersEraser(sql, kv, docs, profiles, cache),
+	}
 }
 
 type userService struct {
-	kvRepo   UsersKVRepo
-	sqlRepo  UsersSQLRepo
-	docsRepo UsersDocsRepo
+	kvRepo       UsersKVRepo
+	sqlRepo      UsersSQLRepo
+	docsRepo     UsersDocsRepo
+	cache        UsersCache
+	profilesRepo UsersProfilesRepo
+	loads        *singleflight.Group // cache misses by user ID
+	retention    time.Duration
+	eraser       UsersEraser
 }
 
+// profileSaveAttempts bounds the retries of a profile change that lost a
+// race with another one.
+const profileSaveAttempts = 3
+
 func (s userService) CreateUser(ctx context.Context, name string) (domain.UserID, error) {
 	id1, err := s.sqlRepo.Insert(ctx, name)
 	if err != nil {
 		return "", fmt.Errorf("inserting user in sql repo: %w", err)
 	}
-	id2, err := s.kvRepo.Set(ctx, name)
-	if err != nil {
+	// The ID may be cached as not found.
+	if err := s.cache.Delete(ctx, id1); err != nil {
+		return "", fmt.Errorf("invalidating cached user: %w", err)
+	}
+	if err := s.kvRepo.Set(ctx, id1, name); err != nil {
 		return "", fmt.Errorf("setting user in kv repo: %w", err)
 	}
-	id3, err := s.docsRepo.Insert(ctx, name)
-	if err != nil {
+	if err := s.docsRepo.Insert(ctx, id1, name); err != nil {
 		return "", fmt.Errorf("inserting user in docs repo: %w", err)
 	}
-	return domain.UserID(fmt.Sprintf("%s-%s-%s", id1, id2, id3)), nil
+	// The Postgres ID keys the user in every store.
+	return id1, nil
+}
+
+// GetUser reads through the cache. Concurrent misses of a user share one
+// query, which isn't canceled when the caller that started it gives up.
+// The cache is best effort here: its errors fall back to the sql repo.
+func (s userService) GetUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	u, found, err := s.cache.Get(ctx, id)
+	if found {
+		return u, err
+	}
+
+	loadCtx := context.WithoutCancel(ctx)
+	load := s.loads.DoChan(string(id), func() (interface{}, error) {
+		u, err := s.sqlRepo.Get(loadCtx, id)
+		if err != nil {
+			var e *domain.NotFoundError
+			if errors.As(err, &e) {
+				_ = s.cache.SetNotFound(loadCtx, id)
+				return domain.User{}, err
+			}
+			return domain.User{}, fmt.Errorf("getting user in sql repo: %w", err)
+		}
+		_ = s.cache.Set(loadCtx, u)
+		return u, nil
+	})
+	select {
+	case res := <-load:
+		return res.Val.(domain.User), res.Err
+	case <-ctx.Done():
+		return domain.User{}, ctx.Err()
+	}
+}
+
+// UpdateUser and DeleteUser evict the user from the cache after the
+// change. A miss loading the old row meanwhile may cache it again, for at
+// most the cache TTL, so a mismatched version evicts it too: the client
+// reading the user again gets the current version. The kv and docs repos
+// are renamed after the sql repo, if they fail an update renames them.
+func (s userService) UpdateUser(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (domain.User, error) {
+	u, err := s.sqlRepo.Update(ctx, id, name, match)
+	if err != nil {
+		s.evictMismatched(ctx, id, err)
+		return domain.User{}, fmt.Errorf("updating user in sql repo: %w", err)
+	}
+	if err := s.cache.Delete(ctx, id); err != nil {
+		return domain.User{}, fmt.Errorf("invalidating cached user: %w", err)
+	}
+	if err := s.kvRepo.Update(ctx, id, name); err != nil {
+		return domain.User{}, fmt.Errorf("updating user in kv repo: %w", err)
+	}
+	if err := s.docsRepo.Update(ctx, id, name, u.Version); err != nil {
+		return domain.User{}, fmt.Errorf("updating user in docs repo: %w", err)
+	}
+	return u, nil
+}
+
+// DeleteUser soft deletes the user in the sql repo first: it is gone for
+// the readers, and if the other stores fail the purge removes it from
+// them anyway. The kv repo copy is removed, the restore sets it again.
+func (s userService) DeleteUser(ctx context.Context, id domain.UserID, match domain.VersionMatch) error {
+	u, err := s.sqlRepo.Delete(ctx, id, match)
+	if err != nil {
+		s.evictMismatched(ctx, id, err)
+		return fmt.Errorf("deleting user in sql repo: %w", err)
+	}
+	if err := s.cache.Delete(ctx, id); err != nil {
+		return fmt.Errorf("invalidating cached user: %w", err)
+	}
+	if _, err := s.kvRepo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("deleting user in kv repo: %w", err)
+	}
+	if err := s.docsRepo.SoftDelete(ctx, id, u.DeletedAt); err != nil {
+		return fmt.Errorf("deleting user in docs repo: %w", err)
+	}
+	return nil
+}
+
+func (s userService) RestoreUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	u, err := s.sqlRepo.Restore(ctx, id, time.Now().Add(-s.retention))
+	if err != nil {
+		return domain.User{}, fmt.Errorf("restoring user in sql repo: %w", err)
+	}
+	// Likely cached as not found.
+	if err := s.cache.Delete(ctx, id); err != nil {
+		return domain.User{}, fmt.Errorf("invalidating cached user: %w", err)
+	}
+	if err := s.kvRepo.Set(ctx, id, u.Name); err != nil {
+		return domain.User{}, fmt.Errorf("setting user in kv repo: %w", err)
+	}
+	if err := s.docsRepo.Restore(ctx, id); err != nil {
+		return domain.User{}, fmt.Errorf("restoring user in docs repo: %w", err)
+	}
+	return u, nil
+}
+
+func (s userService) EraseUser(ctx context.Context, id domain.UserID) (domain.UserErasure, error) {
+	return s.eraser.Erase(ctx, id)
+}
+
+func (s userService) ExportUser(ctx context.Context, id domain.UserID) (domain.UserExport, error) {
+	u, err := s.sqlRepo.GetAny(ctx, id)
+	if err != nil {
+		return domain.UserExport{}, fmt.Errorf("getting user in sql repo: %w", err)
+	}
+	kv, err := s.kvRepo.Export(ctx, id)
+	if err != nil {
+		return domain.UserExport{}, fmt.Errorf("exporting user in kv repo: %w", err)
+	}
+	docs, err := s.docsRepo.Export(ctx, id)
+	if err != nil {
+		return domain.UserExport{}, fmt.Errorf("exporting user in docs repo: %w", err)
+	}
+	p, err := s.profilesRepo.Get(ctx, id)
+	if err != nil {
+		return domain.UserExport{}, fmt.Errorf("getting profile in profiles repo: %w", err)
+	}
+	return domain.UserExport{User: u, Redis: kv, Docs: docs, Profile: p}, nil
+}
+
+func (s userService) evictMismatched(ctx context.Context, id domain.UserID, err error) {
+	var mismatch *domain.PreconditionFailedError
+	if errors.As(err, &mismatch) {
+		_ = s.cache.Delete(ctx, id) // best effort, the change failed anyway
+	}
+}
+
+func (s userService) GetProfile(ctx context.Context, id domain.UserID) (domain.Profile, error) {
+	if _, err := s.GetUser(ctx, id); err != nil {
+		return domain.Profile{}, err
+	}
+	p, err := s.profilesRepo.Get(ctx, id)
+	if err != nil {
+		return domain.Profile{}, fmt.Errorf("getting profile in profiles repo: %w", err)
+	}
+	return p, nil
+}
+
+func (s userService) PutProfile(ctx context.Context, id domain.UserID, data map[string]any, match domain.VersionMatch) (domain.Profile, error) {
+	return s.changeProfile(ctx, id, match, func(map[string]any) (map[string]any, error) {
+		return data, nil
+	})
+}
+
+func (s userService) PatchProfile(ctx context.Context, id domain.UserID, patch domain.ProfilePatch, match domain.VersionMatch) (domain.Profile, error) {
+	return s.changeProfile(ctx, id, match, func(data map[string]any) (map[string]any, error) {
+		if patch.Merge != nil {
+			return jsondoc.MergePatch(data, patch.Merge).(map[string]any), nil
+		}
+		patched, err := jsondoc.Apply(data, patch.Ops)
+		if err != nil {
+			if errors.Is(err, jsondoc.ErrConflict) {
+				return nil, domain.NewConflictError(err.Error())
+			}
+			return nil, domain.NewInvalidError(err.Error())
+		}
+		obj, ok := patched.(map[string]any)
+		if !ok {
+			return nil, domain.NewInvalidError("profile must be an object")
+		}
+		return obj, nil
+	})
+}
+
+// changeProfile reads the profile, changes its data and saves it. If it
+// was saved by someone else meanwhile, the change starts over on the new
+// version, which must still match.
+func (s userService) changeProfile(
+	ctx context.Context, id domain.UserID, match domain.VersionMatch, change func(map[string]any) (map[string]any, error),
+) (domain.Profile, error) {
+	if _, err := s.GetUser(ctx, id); err != nil {
+		return domain.Profile{}, err
+	}
+	for i := 0; i < profileSaveAttempts; i++ {
+		read, err := s.profilesRepo.Get(ctx, id)
+		if err != nil {
+			return domain.Profile{}, fmt.Errorf("getting profile in profiles repo: %w", err)
+		}
+		if !match.Matches(read.Version) {
+			return domain.Profile{}, domain.NewPreconditionFailedError("profile version doesn't match")
+		}
+		data, err := change(read.Data)
+		if err != nil {
+			return domain.Profile{}, err
+		}
+		saved, err := s.profilesRepo.Save(ctx, read, data)
+		if errors.Is(err, ErrVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return domain.Profile{}, fmt.Errorf("saving profile in profiles repo: %w", err)
+		}
+		return saved, nil
+	}
+	return domain.Profile{}, domain.NewConflictError("profile is being changed concurrently, try again")
+}
+
+// ListUsers reads one user more than asked to know if there is a page
+// past this one in the direction of the query.
+func (s userService) ListUsers(ctx context.Context, q domain.ListUsersQuery) (domain.UsersPage, error) {
+	limit := q.Limit
+	q.Limit++
+	users, err := s.sqlRepo.List(ctx, q)
+	if err != nil {
+		return domain.UsersPage{}, fmt.Errorf("listing users in sql repo: %w", err)
+	}
+
+	more := len(users) > limit
+	if more {
+		if q.Before != nil {
+			users = users[1:] // the extra one is the first
+		} else {
+			users = users[:limit]
+		}
+	}
+	page := domain.UsersPage{Users: users}
+	if len(users) == 0 {
+		return page, nil
+	}
+
+	first, last := users[0].Key(), users[len(users)-1].Key()
+	if more || q.Before != nil {
+		page.Next = &last
+	}
+	if (more && q.Before != nil) || q.After != nil {
+		page.Prev = &first
+	}
+	return page, nil
+}
+
+func (s userService) SearchUsers(ctx context.Context, q domain.SearchUsersQuery) (domain.UsersSearchPage, error) {
+	limit := q.Limit
+	q.Limit++
+	matches, err := s.sqlRepo.Search(ctx, q)
+	if err != nil {
+		return domain.UsersSearchPage{}, fmt.Errorf("searching users in sql repo: %w", err)
+	}
+	page := domain.UsersSearchPage{Matches: matches}
+	if len(matches) > limit {
+		page.Matches = matches[:limit]
+		page.NextOffset = q.Offset + limit
+	}
+	return page, nil
 }
@@ -3,21 +3,29 @@ package dummy
 import (
 	"context"
 	"errors"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-kit/kit/metrics/discard"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/jsondoc"
 	"ws-dummy-go/internal/mocks"
+	"ws-dummy-go/internal/pii"
 )
 
 func Test_userService_CreateUser(t *testing.T) {
 	kvRepoMock := &mocks.UsersKVRepo{}
 	sqlRepoMock := &mocks.UsersSQLRepo{}
 	docsRepoMock := &mocks.UsersDocsRepo{}
+	cacheMock := &mocks.UsersCache{}
 
-	s := NewUserService(kvRepoMock, sqlRepoMock, docsRepoMock)
+	s := NewUserService(kvRepoMock, sqlRepoMock, docsRepoMock, cacheMock, &mocks.UsersProfilesRepo{}, time.Hour)
 
 	testname := "testname123"
 	mockError := errors.New("mock error")
@@ -37,15 +45,17 @@ func Test_userService_CreateUser(t *testing.T) {
 			arrange: func() {
 				sqlRepoMock.EXPECT().Insert(mock.Anything, testname).Return(domain.UserID("1"), nil).
 					Once()
-				kvRepoMock.EXPECT().Set(mock.Anything, testname).Return(domain.UserID("2"), nil).
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).
 					Once()
-				docsRepoMock.EXPECT().Insert(mock.Anything, testname).Return(domain.UserID("3"), nil).
+				kvRepoMock.EXPECT().Set(mock.Anything, domain.UserID("1"), testname).Return(nil).
+					Once()
+				docsRepoMock.EXPECT().Insert(mock.Anything, domain.UserID("1"), testname).Return(nil).
 					Once()
 			},
 			args: args{
 				name: testname,
 			},
-			want:    domain.UserID("1-2-3"),
+			want:    domain.UserID("1"),
 			wantErr: false,
 		},
 		{
@@ -65,7 +75,9 @@ func Test_userService_CreateUser(t *testing.T) {
 			arrange: func() {
 				sqlRepoMock.EXPECT().Insert(mock.Anything, testname).Return(domain.UserID("1"), nil).
 					Once()
-				kvRepoMock.EXPECT().Set(mock.Anything, testname).Return("", mockError).
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).
+					Once()
+				kvRepoMock.EXPECT().Set(mock.Anything, domain.UserID("1"), testname).Return(mockError).
 					Once()
 			},
 			args: args{
@@ -79,9 +91,11 @@ func Test_userService_CreateUser(t *testing.T) {
 			arrange: func() {
 				sqlRepoMock.EXPECT().Insert(mock.Anything, testname).Return(domain.UserID("1"), nil).
 					Once()
-				kvRepoMock.EXPECT().Set(mock.Anything, testname).Return(domain.UserID("2"), nil).
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).
+					Once()
+				kvRepoMock.EXPECT().Set(mock.Anything, domain.UserID("1"), testname).Return(nil).
 					Once()
-				docsRepoMock.EXPECT().Insert(mock.Anything, testname).Return("", mockError).
+				docsRepoMock.EXPECT().Insert(mock.Anything, domain.UserID("1"), testname).Return(mockError).
 					Once()
 			},
 			args: args{
@@ -106,6 +120,784 @@ func Test_userService_CreateUser(t *testing.T) {
 			kvRepoMock.AssertExpectations(t)
 			sqlRepoMock.AssertExpectations(t)
 			docsRepoMock.AssertExpectations(t)
+			cacheMock.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_userService_GetUser(t *testing.T) {
+	user := domain.User{ID: "1", Name: "testname123", CreatedAt: time.Now()}
+	notFound := domain.NewNotFoundError("user not found")
+	mockError := errors.New("mock error")
+
+	tests := []struct {
+		name    string
+		arrange func(sqlRepoMock *mocks.UsersSQLRepo, cacheMock *mocks.UsersCache)
+		want    domain.User
+		wantErr error
+	}{
+		{
+			name: "Positive: Cache hit",
+			arrange: func(_ *mocks.UsersSQLRepo, cacheMock *mocks.UsersCache) {
+				cacheMock.EXPECT().Get(mock.Anything, user.ID).Return(user, true, nil).Once()
+			},
+			want: user,
+		},
+		{
+			name: "Positive: Cache miss reads through",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, cacheMock *mocks.UsersCache) {
+				cacheMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, false, nil).Once()
+				sqlRepoMock.EXPECT().Get(mock.Anything, user.ID).Return(user, nil).Once()
+				cacheMock.EXPECT().Set(mock.Anything, user).Return(nil).Once()
+			},
+			want: user,
+		},
+		{
+			name: "Positive: Cache fails, read from sql repo",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, cacheMock *mocks.UsersCache) {
+				cacheMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, false, mockError).Once()
+				sqlRepoMock.EXPECT().Get(mock.Anything, user.ID).Return(user, nil).Once()
+				cacheMock.EXPECT().Set(mock.Anything, user).Return(mockError).Once()
+			},
+			want: user,
+		},
+		{
+			name: "Negative: Cached as not found",
+			arrange: func(_ *mocks.UsersSQLRepo, cacheMock *mocks.UsersCache) {
+				cacheMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, true, notFound).Once()
+			},
+			wantErr: notFound,
+		},
+		{
+			name: "Negative: Not found is cached",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, cacheMock *mocks.UsersCache) {
+				cacheMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, false, nil).Once()
+				sqlRepoMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, notFound).Once()
+				cacheMock.EXPECT().SetNotFound(mock.Anything, user.ID).Return(nil).Once()
+			},
+			wantErr: notFound,
+		},
+		{
+			name: "Negative: Getting in sql repo fails",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, cacheMock *mocks.UsersCache) {
+				cacheMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, false, nil).Once()
+				sqlRepoMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, mockError).Once()
+			},
+			wantErr: mockError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			sqlRepoMock := &mocks.UsersSQLRepo{}
+			cacheMock := &mocks.UsersCache{}
+			s := NewUserService(&mocks.UsersKVRepo{}, sqlRepoMock, &mocks.UsersDocsRepo{}, cacheMock, &mocks.UsersProfilesRepo{}, time.Hour)
+			tt.arrange(sqlRepoMock, cacheMock)
+
+			got, err := s.GetUser(context.Background(), user.ID)
+
+			assert.Equal(tt.want, got)
+			if tt.wantErr == nil {
+				assert.NoError(err)
+			} else {
+				assert.ErrorIs(err, tt.wantErr)
+			}
+
+			sqlRepoMock.AssertExpectations(t)
+			cacheMock.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_userService_GetUser_collapsesMisses(t *testing.T) {
+	sqlRepoMock := &mocks.UsersSQLRepo{}
+	cacheMock := &mocks.UsersCache{}
+	s := NewUserService(&mocks.UsersKVRepo{}, sqlRepoMock, &mocks.UsersDocsRepo{}, cacheMock, &mocks.UsersProfilesRepo{}, time.Hour)
+
+	const callers = 10
+	user := domain.User{ID: "1", Name: "testname123"}
+	var missed sync.WaitGroup
+	missed.Add(callers)
+
+	cacheMock.EXPECT().Get(mock.Anything, user.ID).Return(domain.User{}, false, nil).
+		Run(func(context.Context, domain.UserID) { missed.Done() }).Times(callers)
+	// The query lasts until every caller missed the cache and waits for it.
+	sqlRepoMock.EXPECT().Get(mock.Anything, user.ID).Return(user, nil).
+		Run(func(context.Context, domain.UserID) {
+			missed.Wait()
+			time.Sleep(50 * time.Millisecond)
+		}).Once()
+	cacheMock.EXPECT().Set(mock.Anything, user).Return(nil).Once()
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := s.GetUser(context.Background(), user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, user, got)
+		}()
+	}
+	wg.Wait()
+
+	sqlRepoMock.AssertExpectations(t)
+	cacheMock.AssertExpectations(t)
+}
+
+func Test_userService_UpdateUser(t *testing.T) {
+	mockError := errors.New("mock error")
+	match := domain.VersionMatch{Versions: []int64{1}}
+	updated := domain.User{ID: "1", Name: "newname", Version: 2}
+
+	tests := []struct {
+		name    string
+		arrange func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache)
+		wantErr bool
+	}{
+		{
+			name: "Positive: Update everywhere and evict",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", match).Return(updated, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				kvRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname").Return(nil).Once()
+				docsRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", int64(2)).Return(nil).Once()
+			},
+		},
+		{
+			name: "Negative: Version mismatch evicts",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", match).
+					Return(domain.User{}, domain.NewPreconditionFailedError("user version doesn't match")).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative: Updating in sql repo fails",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo, _ *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", match).Return(domain.User{}, mockError).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative: Evicting fails",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", match).Return(updated, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(mockError).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative: Updating in kv repo fails",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", match).Return(updated, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				kvRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname").Return(mockError).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative: Updating in docs repo fails",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", match).Return(updated, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				kvRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname").Return(nil).Once()
+				docsRepoMock.EXPECT().Update(mock.Anything, domain.UserID("1"), "newname", int64(2)).Return(mockError).Once()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sqlRepoMock := &mocks.UsersSQLRepo{}
+			kvRepoMock := &mocks.UsersKVRepo{}
+			docsRepoMock := &mocks.UsersDocsRepo{}
+			cacheMock := &mocks.UsersCache{}
+			s := NewUserService(kvRepoMock, sqlRepoMock, docsRepoMock, cacheMock, &mocks.UsersProfilesRepo{}, time.Hour)
+			tt.arrange(sqlRepoMock, kvRepoMock, docsRepoMock, cacheMock)
+
+			got, err := s.UpdateUser(context.Background(), "1", "newname", match)
+
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			if !tt.wantErr {
+				assert.Equal(t, updated, got)
+			}
+			sqlRepoMock.AssertExpectations(t)
+			kvRepoMock.AssertExpectations(t)
+			docsRepoMock.AssertExpectations(t)
+			cacheMock.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_userService_DeleteUser(t *testing.T) {
+	notFound := domain.NewNotFoundError("user not found")
+	mismatch := domain.NewPreconditionFailedError("user version doesn't match")
+	deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleted := domain.User{ID: "1", Name: "a", Version: 2, DeletedAt: deletedAt}
+
+	tests := []struct {
+		name    string
+		arrange func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache)
+		wantErr error
+	}{
+		{
+			name: "Positive: Soft delete everywhere and evict",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1"), domain.AnyVersion).Return(deleted, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				kvRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(2, nil).Once()
+				docsRepoMock.EXPECT().SoftDelete(mock.Anything, domain.UserID("1"), deletedAt).Return(nil).Once()
+			},
+		},
+		{
+			name: "Negative: Not found",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo, _ *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1"), domain.AnyVersion).Return(domain.User{}, notFound).Once()
+			},
+			wantErr: notFound,
+		},
+		{
+			name: "Negative: Version mismatch evicts",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1"), domain.AnyVersion).Return(domain.User{}, mismatch).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+			},
+			wantErr: mismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sqlRepoMock := &mocks.UsersSQLRepo{}
+			kvRepoMock := &mocks.UsersKVRepo{}
+			docsRepoMock := &mocks.UsersDocsRepo{}
+			cacheMock := &mocks.UsersCache{}
+			s := NewUserService(kvRepoMock, sqlRepoMock, docsRepoMock, cacheMock, &mocks.UsersProfilesRepo{}, time.Hour)
+			tt.arrange(sqlRepoMock, kvRepoMock, docsRepoMock, cacheMock)
+
+			err := s.DeleteUser(context.Background(), "1", domain.AnyVersion)
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			sqlRepoMock.AssertExpectations(t)
+			kvRepoMock.AssertExpectations(t)
+			docsRepoMock.AssertExpectations(t)
+			cacheMock.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_userService_RestoreUser(t *testing.T) {
+	expired := domain.NewNotFoundError("user deleted before the retention window")
+	restored := domain.User{ID: "1", Name: "a", Version: 3}
+
+	tests := []struct {
+		name    string
+		arrange func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache)
+		want    domain.User
+		wantErr error
+	}{
+		{
+			name: "Positive: Restore everywhere",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo, cacheMock *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Restore(mock.Anything, domain.UserID("1"), mock.MatchedBy(func(after time.Time) bool {
+					return time.Since(after) >= time.Hour && time.Since(after) < time.Hour+time.Minute
+				})).Return(restored, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				kvRepoMock.EXPECT().Set(mock.Anything, domain.UserID("1"), "a").Return(nil).Once()
+				docsRepoMock.EXPECT().Restore(mock.Anything, domain.UserID("1")).Return(nil).Once()
+			},
+			want: restored,
+		},
+		{
+			name: "Negative: Deleted before the retention",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo, _ *mocks.UsersCache) {
+				sqlRepoMock.EXPECT().Restore(mock.Anything, domain.UserID("1"), mock.Anything).Return(domain.User{}, expired).Once()
+			},
+			wantErr: expired,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sqlRepoMock := &mocks.UsersSQLRepo{}
+			kvRepoMock := &mocks.UsersKVRepo{}
+			docsRepoMock := &mocks.UsersDocsRepo{}
+			cacheMock := &mocks.UsersCache{}
+			s := NewUserService(kvRepoMock, sqlRepoMock, docsRepoMock, cacheMock, &mocks.UsersProfilesRepo{}, time.Hour)
+			tt.arrange(sqlRepoMock, kvRepoMock, docsRepoMock, cacheMock)
+
+			got, err := s.RestoreUser(context.Background(), "1")
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+			sqlRepoMock.AssertExpectations(t)
+			kvRepoMock.AssertExpectations(t)
+			docsRepoMock.AssertExpectations(t)
+			cacheMock.AssertExpectations(t)
+		})
+	}
+}
+
+// Test_userService_versions checks that the Mongo user keeps the version of
+// the Postgres user through the changes.
+func Test_userService_versions(t *testing.T) {
+	ctx := context.Background()
+	plain := pii.NewPlaintext()
+	db := testMongoClient.Database("test_dummy")
+	keys := NewKeys("versions", "test")
+	sqlRepo := NewUsersSQLRepo(testPostgresPool, plain)
+	docsRepo := NewUsersDocsRepo(StaticCollection(db.Collection("users")), NewRandIDGenerator(), plain)
+	s := NewUserService(
+		NewUsersKVRepo(StaticRedisClient(testRedisClient), keys, time.Hour, plain),
+		sqlRepo,
+		docsRepo,
+		NewUsersRedisCache(StaticRedisClient(testRedisClient), keys, UsersCacheConfig{TTL: time.Minute}, discard.NewCounter(), plain),
+		NewUsersProfilesRepo(StaticCollection(db.Collection("user_profiles")), ProfilesConfig{MaxBytes: 1024, MaxDepth: 3}),
+		time.Hour,
+	)
+
+	id, err := s.CreateUser(ctx, "versions_1")
+	require.NoError(t, err)
+
+	// Steps in order, each on the state left by the previous ones.
+	tests := []struct {
+		name string
+		act  func() error
+	}{
+		{
+			name: "Positive: Create",
+			act:  func() error { return nil },
+		},
+		{
+			name: "Positive: Update",
+			act: func() error {
+				_, err := s.UpdateUser(ctx, id, "versions_2", domain.AnyVersion)
+				return err
+			},
+		},
+		{
+			name: "Positive: Delete",
+			act:  func() error { return s.DeleteUser(ctx, id, domain.AnyVersion) },
+		},
+		{
+			name: "Positive: Restore",
+			act: func() error {
+				_, err := s.RestoreUser(ctx, id)
+				return err
+			},
+		},
+		{
+			name: "Positive: Update restored",
+			act: func() error {
+				_, err := s.UpdateUser(ctx, id, "versions_3", domain.AnyVersion)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.act())
+
+			u, err := sqlRepo.GetAny(ctx, id)
+			require.NoError(t, err)
+			docs, err := docsRepo.Export(ctx, id)
+			require.NoError(t, err)
+			require.Len(t, docs, 1)
+			assert.Equal(t, u.Version, docs[0]["version"])
+			assert.Equal(t, u.Name, docs[0]["name"])
+		})
+	}
+}
+
+func Test_userService_ExportUser(t *testing.T) {
+	deleted := domain.User{ID: "1", Name: "a", Version: 2, DeletedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	kv := map[string]any{"k:name:a": "7"}
+	docs := []map[string]any{{"_id": "x", "name": "a", "deleted": true}}
+	profile := domain.Profile{UserID: "1", Data: map[string]any{"bio": "hi"}, Version: 1}
+
+	sqlRepoMock := &mocks.UsersSQLRepo{}
+	kvRepoMock := &mocks.UsersKVRepo{}
+	docsRepoMock := &mocks.UsersDocsRepo{}
+	profilesRepoMock := &mocks.UsersProfilesRepo{}
+	s := NewUserService(kvRepoMock, sqlRepoMock, docsRepoMock, &mocks.UsersCache{}, profilesRepoMock, time.Hour)
+
+	// Soft deleted users are exported too.
+	sqlRepoMock.EXPECT().GetAny(mock.Anything, domain.UserID("1")).Return(deleted, nil).Once()
+	kvRepoMock.EXPECT().Export(mock.Anything, domain.UserID("1")).Return(kv, nil).Once()
+	docsRepoMock.EXPECT().Export(mock.Anything, domain.UserID("1")).Return(docs, nil).Once()
+	profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(profile, nil).Once()
+
+	got, err := s.ExportUser(context.Background(), "1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.UserExport{User: deleted, Redis: kv, Docs: docs, Profile: profile}, got)
+	sqlRepoMock.AssertExpectations(t)
+	kvRepoMock.AssertExpectations(t)
+	docsRepoMock.AssertExpectations(t)
+	profilesRepoMock.AssertExpectations(t)
+}
+
+func Test_userService_PatchProfile(t *testing.T) {
+	mockError := errors.New("mock error")
+	user := domain.User{ID: "1", Name: "name"}
+	read := domain.Profile{UserID: "1", Data: map[string]any{"a": "b", "c": int64(1)}, Version: 2}
+	saved := domain.Profile{UserID: "1", Data: map[string]any{"c": int64(1), "d": "e"}, Version: 3}
+	mergePatch := domain.ProfilePatch{Merge: map[string]any{"a": nil, "d": "e"}}
+
+	tests := []struct {
+		name    string
+		patch   domain.ProfilePatch
+		arrange func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo)
+		want    domain.Profile
+		wantErr error
+	}{
+		{
+			name:  "Positive: Merge patch",
+			patch: mergePatch,
+			arrange: func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo) {
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+				profilesRepoMock.EXPECT().Save(mock.Anything, read, saved.Data).Return(saved, nil).Once()
+			},
+			want: saved,
+		},
+		{
+			name: "Positive: JSON Patch",
+			patch: domain.ProfilePatch{Ops: []jsondoc.Operation{
+				{Op: "test", Path: "/c", Value: 1.0},
+				{Op: "move", From: "/a", Path: "/d"},
+				{Op: "replace", Path: "/d", Value: "e"},
+			}},
+			arrange: func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo) {
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+				profilesRepoMock.EXPECT().Save(mock.Anything, read, saved.Data).Return(saved, nil).Once()
+			},
+			want: saved,
+		},
+		{
+			name:  "Positive: Saved by someone else, patched again",
+			patch: mergePatch,
+			arrange: func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo) {
+				newer := domain.Profile{UserID: "1", Data: map[string]any{"a": "b"}, Version: 3}
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+				profilesRepoMock.EXPECT().Save(mock.Anything, read, saved.Data).Return(domain.Profile{}, ErrVersionConflict).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(newer, nil).Once()
+				profilesRepoMock.EXPECT().Save(mock.Anything, newer, map[string]any{"d": "e"}).Return(saved, nil).Once()
+			},
+			want: saved,
+		},
+		{
+			name:  "Negative: Conflicts on every attempt",
+			patch: mergePatch,
+			arrange: func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo) {
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Times(profileSaveAttempts)
+				profilesRepoMock.EXPECT().Save(mock.Anything, read, saved.Data).Return(domain.Profile{}, ErrVersionConflict).
+					Times(profileSaveAttempts)
+			},
+			wantErr: &domain.ConflictError{},
+		},
+		{
+			name:  "Negative: Failed test op",
+			patch: domain.ProfilePatch{Ops: []jsondoc.Operation{{Op: "test", Path: "/a", Value: "x"}}},
+			arrange: func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo) {
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+			},
+			wantErr: &domain.ConflictError{},
+		},
+		{
+			name:  "Negative: Patched into a non object",
+			patch: domain.ProfilePatch{Ops: []jsondoc.Operation{{Op: "replace", Path: "", Value: []any{}}}},
+			arrange: func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo) {
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+			},
+			wantErr: &domain.InvalidError{},
+		},
+		{
+			name:  "Negative: User not found",
+			patch: mergePatch,
+			arrange: func(cacheMock *mocks.UsersCache, _ *mocks.UsersProfilesRepo) {
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).
+					Return(domain.User{}, true, domain.NewNotFoundError("user not found")).Once()
+			},
+			wantErr: &domain.NotFoundError{},
+		},
+		{
+			name:  "Negative: Saving fails",
+			patch: mergePatch,
+			arrange: func(cacheMock *mocks.UsersCache, profilesRepoMock *mocks.UsersProfilesRepo) {
+				cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+				profilesRepoMock.EXPECT().Save(mock.Anything, read, saved.Data).Return(domain.Profile{}, mockError).Once()
+			},
+			wantErr: mockError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cacheMock := &mocks.UsersCache{}
+			profilesRepoMock := &mocks.UsersProfilesRepo{}
+			s := NewUserService(&mocks.UsersKVRepo{}, &mocks.UsersSQLRepo{}, &mocks.UsersDocsRepo{}, cacheMock, profilesRepoMock, time.Hour)
+			tt.arrange(cacheMock, profilesRepoMock)
+
+			got, err := s.PatchProfile(context.Background(), "1", tt.patch, domain.AnyVersion)
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			case *domain.ConflictError:
+				assert.ErrorAs(t, err, &want)
+			case *domain.InvalidError:
+				assert.ErrorAs(t, err, &want)
+			case *domain.NotFoundError:
+				assert.ErrorAs(t, err, &want)
+			default:
+				assert.ErrorIs(t, err, want)
+			}
+			assert.Equal(t, map[string]any{"a": "b", "c": int64(1)}, read.Data, "read data unchanged")
+			cacheMock.AssertExpectations(t)
+			profilesRepoMock.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_userService_PutProfile(t *testing.T) {
+	user := domain.User{ID: "1", Name: "name", Version: 1}
+	read := domain.Profile{UserID: "1", Data: map[string]any{"a": "b"}, Version: 2}
+	data := map[string]any{"c": "d"}
+	saved := domain.Profile{UserID: "1", Data: data, Version: 3}
+
+	tests := []struct {
+		name    string
+		match   domain.VersionMatch
+		arrange func(profilesRepoMock *mocks.UsersProfilesRepo)
+		wantErr bool
+	}{
+		{
+			name:  "Positive: Version matches",
+			match: domain.VersionMatch{Versions: []int64{2}},
+			arrange: func(profilesRepoMock *mocks.UsersProfilesRepo) {
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+				profilesRepoMock.EXPECT().Save(mock.Anything, read, data).Return(saved, nil).Once()
+			},
+		},
+		{
+			name:  "Negative: Version doesn't match",
+			match: domain.VersionMatch{Versions: []int64{1}},
+			arrange: func(profilesRepoMock *mocks.UsersProfilesRepo) {
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Negative: Saved by someone else, no retry",
+			match: domain.VersionMatch{Versions: []int64{2}},
+			arrange: func(profilesRepoMock *mocks.UsersProfilesRepo) {
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(read, nil).Once()
+				profilesRepoMock.EXPECT().Save(mock.Anything, read, data).Return(domain.Profile{}, ErrVersionConflict).Once()
+				profilesRepoMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(saved, nil).Once()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cacheMock := &mocks.UsersCache{}
+			profilesRepoMock := &mocks.UsersProfilesRepo{}
+			s := NewUserService(&mocks.UsersKVRepo{}, &mocks.UsersSQLRepo{}, &mocks.UsersDocsRepo{}, cacheMock, profilesRepoMock, time.Hour)
+			cacheMock.EXPECT().Get(mock.Anything, domain.UserID("1")).Return(user, true, nil).Once()
+			tt.arrange(profilesRepoMock)
+
+			got, err := s.PutProfile(context.Background(), "1", data, tt.match)
+
+			if tt.wantErr {
+				var mismatch *domain.PreconditionFailedError
+				assert.ErrorAs(t, err, &mismatch)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, saved, got)
+			}
+			cacheMock.AssertExpectations(t)
+			profilesRepoMock.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_userService_ListUsers(t *testing.T) {
+	sqlRepoMock := &mocks.UsersSQLRepo{}
+
+	s := NewUserService(&mocks.UsersKVRepo{}, sqlRepoMock, &mocks.UsersDocsRepo{}, &mocks.UsersCache{}, &mocks.UsersProfilesRepo{}, time.Hour)
+
+	now := time.Now()
+	users := make([]domain.User, 4)
+	for i := range users {
+		users[i] = domain.User{ID: domain.UserID(strconv.Itoa(i + 1)), Name: "user", CreatedAt: now.Add(time.Duration(i) * time.Second)}
+	}
+	key := func(i int) *domain.UserKey {
+		k := users[i].Key()
+		return &k
+	}
+	mockError := errors.New("mock error")
+
+	tests := []struct {
+		name    string
+		query   domain.ListUsersQuery
+		repo    []domain.User
+		repoErr error
+		want    domain.UsersPage
+		wantErr bool
+	}{
+		{
+			name:  "Positive: First page with more",
+			query: domain.ListUsersQuery{Limit: 2},
+			repo:  users[:3],
+			want:  domain.UsersPage{Users: users[:2], Next: key(1)},
+		},
+		{
+			name:  "Positive: Only page",
+			query: domain.ListUsersQuery{Limit: 5},
+			repo:  users,
+			want:  domain.UsersPage{Users: users},
+		},
+		{
+			name:  "Positive: Middle page after a key",
+			query: domain.ListUsersQuery{Limit: 1, After: key(0)},
+			repo:  users[1:3],
+			want:  domain.UsersPage{Users: users[1:2], Next: key(1), Prev: key(1)},
+		},
+		{
+			name:  "Positive: Last page after a key",
+			query: domain.ListUsersQuery{Limit: 2, After: key(1)},
+			repo:  users[2:],
+			want:  domain.UsersPage{Users: users[2:], Prev: key(2)},
+		},
+		{
+			name:  "Positive: Page before a key with more",
+			query: domain.ListUsersQuery{Limit: 2, Before: key(3)},
+			repo:  users[:3],
+			want:  domain.UsersPage{Users: users[1:3], Next: key(2), Prev: key(1)},
+		},
+		{
+			name:  "Positive: First page before a key",
+			query: domain.ListUsersQuery{Limit: 2, Before: key(2)},
+			repo:  users[:2],
+			want:  domain.UsersPage{Users: users[:2], Next: key(1)},
+		},
+		{
+			name:  "Positive: Empty",
+			query: domain.ListUsersQuery{Limit: 2, Filter: domain.UsersFilter{NamePrefix: "nobody"}},
+			repo:  []domain.User{},
+			want:  domain.UsersPage{Users: []domain.User{}},
+		},
+		{
+			name:    "Negative: Listing in sql repo fails",
+			query:   domain.ListUsersQuery{Limit: 2},
+			repoErr: mockError,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			repoQuery := tt.query
+			repoQuery.Limit++
+			sqlRepoMock.EXPECT().List(mock.Anything, repoQuery).Return(tt.repo, tt.repoErr).Once()
+
+			got, err := s.ListUsers(context.Background(), tt.query)
+
+			assert.Equal(tt.want, got)
+			assert.Equal(tt.wantErr, err != nil, err)
+
+			sqlRepoMock.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_userService_SearchUsers(t *testing.T) {
+	sqlRepoMock := &mocks.UsersSQLRepo{}
+
+	s := NewUserService(&mocks.UsersKVRepo{}, sqlRepoMock, &mocks.UsersDocsRepo{}, &mocks.UsersCache{}, &mocks.UsersProfilesRepo{}, time.Hour)
+
+	matches := []domain.UserMatch{
+		{User: domain.User{ID: "1", Name: "john"}, Rank: 1.5},
+		{User: domain.User{ID: "2", Name: "jon"}, Rank: 0.6},
+		{User: domain.User{ID: "3", Name: "joan"}, Rank: 0.4},
+	}
+	mockError := errors.New("mock error")
+
+	tests := []struct {
+		name    string
+		query   domain.SearchUsersQuery
+		repo    []domain.UserMatch
+		repoErr error
+		want    domain.UsersSearchPage
+		wantErr bool
+	}{
+		{
+			name:  "Positive: Page with more",
+			query: domain.SearchUsersQuery{Text: "john", MinSimilarity: 0.3, Offset: 4, Limit: 2},
+			repo:  matches,
+			want:  domain.UsersSearchPage{Matches: matches[:2], NextOffset: 6},
+		},
+		{
+			name:  "Positive: Last page",
+			query: domain.SearchUsersQuery{Text: "john", MinSimilarity: 0.3, Limit: 3},
+			repo:  matches,
+			want:  domain.UsersSearchPage{Matches: matches},
+		},
+		{
+			name:    "Negative: Searching in sql repo fails",
+			query:   domain.SearchUsersQuery{Text: "john", MinSimilarity: 0.3, Limit: 3},
+			repoErr: mockError,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			repoQuery := tt.query
+			repoQuery.Limit++
+			sqlRepoMock.EXPECT().Search(mock.Anything, repoQuery).Return(tt.repo, tt.repoErr).Once()
+
+			got, err := s.SearchUsers(context.Background(), tt.query)
+
+			assert.Equal(tt.want, got)
+			assert.Equal(tt.wantErr, err != nil, err)
+
+			sqlRepoMock.AssertExpectations(t)
 		})
 	}
 }
@@ -0,0 +1,92 @@
+package dummy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ws-dummy-go/internal/dummy/domain"
+)
+
+// UsersEraser erases the users from all the stores on request, deleted
+// or not, and keeps a tombstone of each.
+type UsersEraser struct {
+	sqlRepo      UsersSQLRepo
+	kvRepo       UsersKVRepo
+	docsRepo     UsersDocsRepo
+	profilesRepo UsersProfilesRepo
+	cache        UsersCache
+}
+
+func NewUsersEraser(sql UsersSQLRepo, kv UsersKVRepo, docs UsersDocsRepo, profiles UsersProfilesRepo, cache UsersCache) UsersEraser {
+	return UsersEraser{
+		sqlRepo:      sql,
+		kvRepo:       kv,
+		docsRepo:     docs,
+		profilesRepo: profiles,
+		cache:        cache,
+	}
+}
+
+// Erase records the erasure, removes the user from the other stores and
+// from the sql repo last. A failed erasure stays pending, Erase or Resume
+// completes it.
+func (e UsersEraser) Erase(ctx context.Context, id domain.UserID) (domain.UserErasure, error) {
+	u, err := e.sqlRepo.StartErasure(ctx, id)
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("starting erasure in sql repo: %w", err)
+	}
+	record := domain.UserErasure{UserID: id}
+
+	if err := e.cache.Delete(ctx, id); err != nil {
+		return domain.UserErasure{}, fmt.Errorf("invalidating cached user: %w", err)
+	}
+	record.DocsDeleted, err = e.profilesRepo.Delete(ctx, id)
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("deleting profile in profiles repo: %w", err)
+	}
+	record.KeysDeleted, err = e.kvRepo.Delete(ctx, id)
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("deleting user in kv repo: %w", err)
+	}
+	// The row may be gone already, with its deleted_at.
+	deletedAt := u.DeletedAt
+	if deletedAt.IsZero() {
+		deletedAt = time.Now()
+	}
+	if err := e.docsRepo.SoftDelete(ctx, id, deletedAt); err != nil {
+		return domain.UserErasure{}, fmt.Errorf("deleting user in docs repo: %w", err)
+	}
+	docs, err := e.docsRepo.Purge(ctx, id)
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("purging user in docs repo: %w", err)
+	}
+	record.DocsDeleted += docs
+
+	record, err = e.sqlRepo.CompleteErasure(ctx, record)
+	if err != nil {
+		return domain.UserErasure{}, fmt.Errorf("completing erasure in sql repo: %w", err)
+	}
+	return record, nil
+}
+
+// Resume completes the pending erasures, up to batch per query. It
+// returns the number completed.
+func (e UsersEraser) Resume(ctx context.Context, batch int) (int, error) {
+	var done int
+	for {
+		pending, err := e.sqlRepo.ListPendingErasures(ctx, batch)
+		if err != nil {
+			return done, fmt.Errorf("listing pending erasures in sql repo: %w", err)
+		}
+		for _, p := range pending {
+			if _, err := e.Erase(ctx, p.UserID); err != nil {
+				return done, fmt.Errorf("erasing user %s: %w", p.UserID, err)
+			}
+			done++
+		}
+		if len(pending) < batch {
+			return done, nil
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package dummy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/mocks"
+)
+
+func TestUsersEraser_Erase(t *testing.T) {
+	deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	u := domain.User{ID: "1", Name: "a", Version: 3, DeletedAt: deletedAt}
+	tombstone := domain.UserErasure{UserID: "1", RequestedAt: deletedAt, CompletedAt: deletedAt.Add(time.Second), DocsDeleted: 2, KeysDeleted: 2}
+	notFound := domain.NewNotFoundError("user erased")
+	errKV := errors.New("redis down")
+
+	tests := []struct {
+		name    string
+		arrange func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo,
+			profilesRepoMock *mocks.UsersProfilesRepo, cacheMock *mocks.UsersCache)
+		want    domain.UserErasure
+		wantErr error
+	}{
+		{
+			name: "Positive: Every store, the sql repo last",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo,
+				profilesRepoMock *mocks.UsersProfilesRepo, cacheMock *mocks.UsersCache,
+			) {
+				sqlRepoMock.EXPECT().StartErasure(mock.Anything, domain.UserID("1")).Return(u, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				profilesRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(1, nil).Once()
+				kvRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(2, nil).Once()
+				docsRepoMock.EXPECT().SoftDelete(mock.Anything, domain.UserID("1"), deletedAt).Return(nil).Once()
+				docsRepoMock.EXPECT().Purge(mock.Anything, domain.UserID("1")).Return(1, nil).Once()
+				sqlRepoMock.EXPECT().CompleteErasure(mock.Anything, domain.UserErasure{UserID: "1", DocsDeleted: 2, KeysDeleted: 2}).
+					Return(tombstone, nil).Once()
+			},
+			want: tombstone,
+		},
+		{
+			name: "Positive: Row gone, the other stores are erased by ID",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo,
+				profilesRepoMock *mocks.UsersProfilesRepo, cacheMock *mocks.UsersCache,
+			) {
+				sqlRepoMock.EXPECT().StartErasure(mock.Anything, domain.UserID("1")).Return(domain.User{ID: "1"}, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				profilesRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(0, nil).Once()
+				kvRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(1, nil).Once()
+				docsRepoMock.EXPECT().SoftDelete(mock.Anything, domain.UserID("1"), mock.AnythingOfType("time.Time")).Return(nil).Once()
+				docsRepoMock.EXPECT().Purge(mock.Anything, domain.UserID("1")).Return(1, nil).Once()
+				sqlRepoMock.EXPECT().CompleteErasure(mock.Anything, domain.UserErasure{UserID: "1", DocsDeleted: 1, KeysDeleted: 1}).
+					Return(tombstone, nil).Once()
+			},
+			want: tombstone,
+		},
+		{
+			name: "Negative: Erased",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo,
+				_ *mocks.UsersProfilesRepo, _ *mocks.UsersCache,
+			) {
+				sqlRepoMock.EXPECT().StartErasure(mock.Anything, domain.UserID("1")).Return(domain.User{}, notFound).Once()
+			},
+			wantErr: notFound,
+		},
+		{
+			name: "Negative: Store failed, the erasure stays pending",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo,
+				profilesRepoMock *mocks.UsersProfilesRepo, cacheMock *mocks.UsersCache,
+			) {
+				sqlRepoMock.EXPECT().StartErasure(mock.Anything, domain.UserID("1")).Return(u, nil).Once()
+				cacheMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(nil).Once()
+				profilesRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(1, nil).Once()
+				kvRepoMock.EXPECT().Delete(mock.Anything, domain.UserID("1")).Return(0, errKV).Once()
+			},
+			wantErr: errKV,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sqlRepoMock := &mocks.UsersSQLRepo{}
+			kvRepoMock := &mocks.UsersKVRepo{}
+			docsRepoMock := &mocks.UsersDocsRepo{}
+			profilesRepoMock := &mocks.UsersProfilesRepo{}
+			cacheMock := &mocks.UsersCache{}
+			e := NewUsersEraser(sqlRepoMock, kvRepoMock, docsRepoMock, profilesRepoMock, cacheMock)
+			tt.arrange(sqlRepoMock, kvRepoMock, docsRepoMock, profilesRepoMock, cacheMock)
+
+			got, err := e.Erase(context.Background(), "1")
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+			sqlRepoMock.AssertExpectations(t)
+			kvRepoMock.AssertExpectations(t)
+			docsRepoMock.AssertExpectations(t)
+			profilesRepoMock.AssertExpectations(t)
+			cacheMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersEraser_Resume(t *testing.T) {
+	sqlRepoMock := &mocks.UsersSQLRepo{}
+	profilesRepoMock := &mocks.UsersProfilesRepo{}
+	cacheMock := &mocks.UsersCache{}
+	e := NewUsersEraser(sqlRepoMock, &mocks.UsersKVRepo{}, &mocks.UsersDocsRepo{}, profilesRepoMock, cacheMock)
+
+	// Completed erasures aren't pending anymore, the next query has the next ones.
+	sqlRepoMock.EXPECT().ListPendingErasures(mock.Anything, 2).Return([]domain.UserErasure{{UserID: "1"}, {UserID: "2"}}, nil).Once()
+	sqlRepoMock.EXPECT().ListPendingErasures(mock.Anything, 2).Return([]domain.UserErasure{{UserID: "3"}}, nil).Once()
+	for _, id := range []domain.UserID{"1", "2", "3"} {
+		sqlRepoMock.EXPECT().StartErasure(mock.Anything, id).Return(domain.User{ID: id}, nil).Once()
+		cacheMock.EXPECT().Delete(mock.Anything, id).Return(nil).Once()
+		profilesRepoMock.EXPECT().Delete(mock.Anything, id).Return(0, nil).Once()
+		sqlRepoMock.EXPECT().CompleteErasure(mock.Anything, domain.UserErasure{UserID: id}).Return(domain.UserErasure{UserID: id}, nil).Once()
+	}
+
+	got, err := e.Resume(context.Background(), 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got)
+	sqlRepoMock.AssertExpectations(t)
+	profilesRepoMock.AssertExpectations(t)
+	cacheMock.AssertExpectations(t)
+}
@@ -0,0 +1,99 @@
+package dummy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ws-dummy-go/internal/dummy/domain"
+)
+
+// PurgeReport counts the expired users seen by a purge.
+type PurgeReport struct {
+	Purged  int
+	Skipped int // restored or purged by another run meanwhile
+}
+
+// UsersPurger hard deletes the users soft deleted for longer than the
+// retention, from all the stores.
+type UsersPurger struct {
+	sqlRepo      UsersSQLRepo
+	kvRepo       UsersKVRepo
+	docsRepo     UsersDocsRepo
+	profilesRepo UsersProfilesRepo
+	cache        UsersCache
+	retention    time.Duration
+	batch        int
+}
+
+// NewUsersPurger purges up to batch users per query.
+func NewUsersPurger(
+	sql UsersSQLRepo, kv UsersKVRepo, docs UsersDocsRepo, profiles UsersProfilesRepo, cache UsersCache,
+	retention time.Duration, batch int,
+) UsersPurger {
+	return UsersPurger{
+		sqlRepo:      sql,
+		kvRepo:       kv,
+		docsRepo:     docs,
+		profilesRepo: profiles,
+		cache:        cache,
+		retention:    retention,
+		batch:        batch,
+	}
+}
+
+// Purge removes the users expired at now, a batch at a time. Each user is
+// removed from the other stores first and from the sql repo last, with
+// its audit record, so a failed purge is done again by the next one.
+func (p UsersPurger) Purge(ctx context.Context, now time.Time) (PurgeReport, error) {
+	var report PurgeReport
+	before := now.Add(-p.retention)
+	for {
+		users, err := p.sqlRepo.ListExpired(ctx, before, p.batch)
+		if err != nil {
+			return report, fmt.Errorf("listing expired users in sql repo: %w", err)
+		}
+		for _, u := range users {
+			purged, err := p.purge(ctx, u)
+			if err != nil {
+				return report, fmt.Errorf("purging user %s: %w", u.ID, err)
+			}
+			if purged {
+				report.Purged++
+			} else {
+				report.Skipped++
+			}
+		}
+		if len(users) < p.batch {
+			return report, nil
+		}
+	}
+}
+
+func (p UsersPurger) purge(ctx context.Context, u domain.User) (bool, error) {
+	record := domain.UserPurge{UserID: u.ID, DeletedAt: u.DeletedAt}
+
+	docs, err := p.docsRepo.Purge(ctx, u.ID)
+	if err != nil {
+		return false, fmt.Errorf("purging user in docs repo: %w", err)
+	}
+	profiles, err := p.profilesRepo.Delete(ctx, u.ID)
+	if err != nil {
+		return false, fmt.Errorf("deleting profile in profiles repo: %w", err)
+	}
+	record.DocsDeleted = docs + profiles
+
+	record.KeysDeleted, err = p.kvRepo.Delete(ctx, u.ID)
+	if err != nil {
+		return false, fmt.Errorf("deleting user in kv repo: %w", err)
+	}
+	if err := p.cache.Delete(ctx, u.ID); err != nil {
+		return false, fmt.Errorf("invalidating cached user: %w", err)
+	}
+
+	purged, err := p.sqlRepo.Purge(ctx, record)
+	if err != nil {
+		return false, fmt.Errorf("purging user in sql repo: %w", err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,163 @@
+package dummy
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/dummy/domain"
+	"ws-dummy-go/internal/mocks"
+	"ws-dummy-go/internal/pii"
+)
+
+func TestUsersPurger_Purge(t *testing.T) {
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	a := domain.User{ID: "1", Name: "a", DeletedAt: before.Add(-time.Minute)}
+	b := domain.User{ID: "2", Name: "b", DeletedAt: before.Add(-time.Second)}
+	c := domain.User{ID: "3", Name: "c", DeletedAt: before.Add(-time.Second)}
+	errDocs := errors.New("mongo down")
+
+	purgeStores := func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo,
+		profilesRepoMock *mocks.UsersProfilesRepo, cacheMock *mocks.UsersCache, u domain.User, purged bool,
+	) {
+		docsRepoMock.EXPECT().Purge(mock.Anything, u.ID).Return(1, nil).Once()
+		profilesRepoMock.EXPECT().Delete(mock.Anything, u.ID).Return(1, nil).Once()
+		kvRepoMock.EXPECT().Delete(mock.Anything, u.ID).Return(2, nil).Once()
+		cacheMock.EXPECT().Delete(mock.Anything, u.ID).Return(nil).Once()
+		sqlRepoMock.EXPECT().Purge(mock.Anything, domain.UserPurge{
+			UserID: u.ID, DeletedAt: u.DeletedAt, DocsDeleted: 2, KeysDeleted: 2,
+		}).Return(purged, nil).Once()
+	}
+
+	tests := []struct {
+		name    string
+		arrange func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo,
+			profilesRepoMock *mocks.UsersProfilesRepo, cacheMock *mocks.UsersCache)
+		want    PurgeReport
+		wantErr error
+	}{
+		{
+			name: "Positive: Batches until a short one",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, kvRepoMock *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo,
+				profilesRepoMock *mocks.UsersProfilesRepo, cacheMock *mocks.UsersCache,
+			) {
+				sqlRepoMock.EXPECT().ListExpired(mock.Anything, before, 2).Return([]domain.User{a, b}, nil).Once()
+				sqlRepoMock.EXPECT().ListExpired(mock.Anything, before, 2).Return([]domain.User{c}, nil).Once()
+				purgeStores(sqlRepoMock, kvRepoMock, docsRepoMock, profilesRepoMock, cacheMock, a, true)
+				purgeStores(sqlRepoMock, kvRepoMock, docsRepoMock, profilesRepoMock, cacheMock, b, false)
+				purgeStores(sqlRepoMock, kvRepoMock, docsRepoMock, profilesRepoMock, cacheMock, c, true)
+			},
+			want: PurgeReport{Purged: 2, Skipped: 1},
+		},
+		{
+			name: "Positive: Nothing expired",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, _ *mocks.UsersDocsRepo,
+				_ *mocks.UsersProfilesRepo, _ *mocks.UsersCache,
+			) {
+				sqlRepoMock.EXPECT().ListExpired(mock.Anything, before, 2).Return(nil, nil).Once()
+			},
+		},
+		{
+			name: "Negative: Store failed, the user is kept for the next purge",
+			arrange: func(sqlRepoMock *mocks.UsersSQLRepo, _ *mocks.UsersKVRepo, docsRepoMock *mocks.UsersDocsRepo,
+				_ *mocks.UsersProfilesRepo, _ *mocks.UsersCache,
+			) {
+				sqlRepoMock.EXPECT().ListExpired(mock.Anything, before, 2).Return([]domain.User{a}, nil).Once()
+				docsRepoMock.EXPECT().Purge(mock.Anything, a.ID).Return(0, errDocs).Once()
+			},
+			wantErr: errDocs,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sqlRepoMock := &mocks.UsersSQLRepo{}
+			kvRepoMock := &mocks.UsersKVRepo{}
+			docsRepoMock := &mocks.UsersDocsRepo{}
+			profilesRepoMock := &mocks.UsersProfilesRepo{}
+			cacheMock := &mocks.UsersCache{}
+			p := NewUsersPurger(sqlRepoMock, kvRepoMock, docsRepoMock, profilesRepoMock, cacheMock, time.Hour, 2)
+			tt.arrange(sqlRepoMock, kvRepoMock, docsRepoMock, profilesRepoMock, cacheMock)
+
+			got, err := p.Purge(context.Background(), now)
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+			sqlRepoMock.AssertExpectations(t)
+			kvRepoMock.AssertExpectations(t)
+			docsRepoMock.AssertExpectations(t)
+			profilesRepoMock.AssertExpectations(t)
+			cacheMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersPurger_sameName(t *testing.T) {
+	ctx := context.Background()
+	plain := pii.NewPlaintext()
+	db := testMongoClient.Database("test_dummy")
+	keys := NewKeys("purge", "test")
+	sqlRepo := NewUsersSQLRepo(testPostgresPool, plain)
+	kvRepo := NewUsersKVRepo(StaticRedisClient(testRedisClient), keys, time.Hour, plain)
+	docsRepo := NewUsersDocsRepo(StaticCollection(db.Collection("users")), NewRandIDGenerator(), plain)
+	profilesRepo := NewUsersProfilesRepo(StaticCollection(db.Collection("user_profiles")), ProfilesConfig{MaxBytes: 1024, MaxDepth: 3})
+	cache := NewUsersRedisCache(StaticRedisClient(testRedisClient), keys, UsersCacheConfig{TTL: time.Minute}, discard.NewCounter(), plain)
+	s := NewUserService(kvRepo, sqlRepo, docsRepo, cache, profilesRepo, time.Hour)
+	p := NewUsersPurger(sqlRepo, kvRepo, docsRepo, profilesRepo, cache, time.Hour, 10)
+
+	name := "purge_same_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	deleted, err := s.CreateUser(ctx, name)
+	require.NoError(t, err)
+	kept, err := s.CreateUser(ctx, name)
+	require.NoError(t, err)
+
+	// The other user is left alone by the delete and the restore.
+	require.NoError(t, s.DeleteUser(ctx, deleted, domain.VersionMatch{Any: true}))
+	assertStored(t, kvRepo, docsRepo, kept)
+	_, err = s.RestoreUser(ctx, deleted)
+	require.NoError(t, err)
+	assertStored(t, kvRepo, docsRepo, deleted)
+	assertStored(t, kvRepo, docsRepo, kept)
+
+	// And by the purge.
+	require.NoError(t, s.DeleteUser(ctx, deleted, domain.VersionMatch{Any: true}))
+	u, err := sqlRepo.GetAny(ctx, deleted)
+	require.NoError(t, err)
+	purged, err := p.purge(ctx, u)
+	require.NoError(t, err)
+	assert.True(t, purged)
+
+	kv, err := kvRepo.Export(ctx, deleted)
+	require.NoError(t, err)
+	assert.Empty(t, kv)
+	docs, err := docsRepo.Export(ctx, deleted)
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+	assertStored(t, kvRepo, docsRepo, kept)
+}
+
+// assertStored asserts the user is in Redis and once in Mongo, not deleted.
+func assertStored(t *testing.T, kvRepo UsersKVRepo, docsRepo UsersDocsRepo, id domain.UserID) {
+	t.Helper()
+	kv, err := kvRepo.Export(context.Background(), id)
+	require.NoError(t, err)
+	assert.NotEmpty(t, kv, id)
+	docs, err := docsRepo.Export(context.Background(), id)
+	require.NoError(t, err)
+	if assert.Len(t, docs, 1, id) {
+		assert.Nil(t, docs[0]["deleted"], id)
+	}
+}
@@ -0,0 +1,410 @@
+// Package jsondoc changes decoded JSON documents with JSON Merge Patch
+// (RFC 7396) and JSON Patch (RFC 6902). The documents are the values of
+// encoding/json: map[string]any, []any, string, bool, nil and numbers,
+// which are int64 if integral or else float64, see Decode.
+package jsondoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalid is a malformed patch.
+	ErrInvalid = errors.New("invalid patch")
+	// ErrConflict is a patch that doesn't apply to the document: a missing
+	// path or a failed test.
+	ErrConflict = errors.New("patch doesn't apply")
+)
+
+// Operation is a JSON Patch operation. Value is set for add, replace and
+// test, From for move and copy.
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value any
+}
+
+// Decode reads one JSON value.
+func Decode(r io.Reader) (any, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("data after the JSON value")
+	}
+	return normalize(v), nil
+}
+
+// DecodePatch reads a JSON Patch document, an array of operations.
+func DecodePatch(r io.Reader) ([]Operation, error) {
+	v, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: not an array", ErrInvalid)
+	}
+	ops := make([]Operation, 0, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: operation %d is not an object", ErrInvalid, i)
+		}
+		var op Operation
+		for name, dst := range map[string]*string{"op": &op.Op, "path": &op.Path, "from": &op.From} {
+			if s, ok := obj[name].(string); ok {
+				*dst = s
+			} else if _, set := obj[name]; set {
+				return nil, fmt.Errorf("%w: operation %d %s is not a string", ErrInvalid, i, name)
+			}
+		}
+		if _, ok := obj["path"]; !ok {
+			return nil, fmt.Errorf("%w: operation %d has no path", ErrInvalid, i)
+		}
+		value, hasValue := obj["value"]
+		switch op.Op {
+		case "add", "replace", "test":
+			if !hasValue {
+				return nil, fmt.Errorf("%w: operation %d %s has no value", ErrInvalid, i, op.Op)
+			}
+			op.Value = value
+		case "move", "copy":
+			if _, ok := obj["from"]; !ok {
+				return nil, fmt.Errorf("%w: operation %d %s has no from", ErrInvalid, i, op.Op)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d has unknown op %q", ErrInvalid, i, op.Op)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// normalize replaces the json.Numbers.
+func normalize(v any) any {
+	switch vv := v.(type) {
+	case json.Number:
+		if n, err := vv.Int64(); err == nil {
+			return n
+		}
+		f, _ := vv.Float64()
+		return f
+	case map[string]any:
+		for k, item := range vv {
+			vv[k] = normalize(item)
+		}
+	case []any:
+		for i, item := range vv {
+			vv[i] = normalize(item)
+		}
+	}
+	return v
+}
+
+// MergePatch returns the target with the merge patch applied, the target
+// isn't changed.
+func MergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return Clone(patch)
+	}
+	t, ok := target.(map[string]any)
+	if ok {
+		t = Clone(t).(map[string]any)
+	} else {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = MergePatch(t[k], v)
+	}
+	return t
+}
+
+// Apply returns the document with the operations applied in order, all
+// or none. The document isn't changed.
+func Apply(doc any, ops []Operation) (any, error) {
+	doc = Clone(doc)
+	for i, op := range ops {
+		var err error
+		if doc, err = apply(doc, op); err != nil {
+			return nil, fmt.Errorf("operation %d %s %q: %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		return add(doc, path, Clone(op.Value))
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return Clone(op.Value), nil
+		}
+		doc, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, Clone(op.Value))
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return add(doc, path, Clone(v))
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("%w: moving a value into itself", ErrInvalid)
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "test":
+		v, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(v, op.Value) {
+			return nil, fmt.Errorf("%w: test failed", ErrConflict)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalid, op.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("%w: pointer %q doesn't start with /", ErrInvalid, p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for i, token := range path {
+		switch d := doc.(type) {
+		case map[string]any:
+			v, ok := d[token]
+			if !ok {
+				return nil, notFound(path[:i+1])
+			}
+			doc = v
+		case []any:
+			idx, err := index(token, len(d)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[idx]
+		default:
+			return nil, notFound(path[:i+1])
+		}
+	}
+	return doc, nil
+}
+
+// set replaces the value at the path, its parent must exist.
+func set(doc any, path []string, v any) (any, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = v
+	case []any:
+		idx, err := index(last, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[idx] = v
+	default:
+		return nil, notFound(path)
+	}
+	return doc, nil
+}
+
+func add(doc any, path []string, v any) (any, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = v
+		return doc, nil
+	case []any:
+		idx := len(p)
+		if last != "-" {
+			if idx, err = index(last, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		grown := make([]any, 0, len(p)+1)
+		grown = append(append(append(grown, p[:idx]...), v), p[idx:]...)
+		return set(doc, path[:len(path)-1], grown)
+	}
+	return nil, notFound(path)
+}
+
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: removing the whole document", ErrInvalid)
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		if _, ok := p[last]; !ok {
+			return nil, notFound(path)
+		}
+		delete(p, last)
+		return doc, nil
+	case []any:
+		idx, err := index(last, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		shrunk := append(append(make([]any, 0, len(p)-1), p[:idx]...), p[idx+1:]...)
+		return set(doc, path[:len(path)-1], shrunk)
+	}
+	return nil, notFound(path)
+}
+
+// index parses an array index up to max.
+func index(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: bad array index %q", ErrInvalid, token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("%w: bad array index %q", ErrInvalid, token)
+	}
+	if idx > max {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrConflict, idx)
+	}
+	return idx, nil
+}
+
+func notFound(path []string) error {
+	var b bytes.Buffer
+	for _, t := range path {
+		b.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return fmt.Errorf("%w: %s not found", ErrConflict, b.String())
+}
+
+// Clone deep copies the objects and arrays.
+func Clone(v any) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(vv))
+		for k, item := range vv {
+			res[k] = Clone(item)
+		}
+		return res
+	case []any:
+		res := make([]any, len(vv))
+		for i, item := range vv {
+			res[i] = Clone(item)
+		}
+		return res
+	}
+	return v
+}
+
+// Equal compares the values as JSON, the numbers by value.
+func Equal(a, b any) bool {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, item := range av {
+			other, ok := bv[k]
+			if !ok || !Equal(item, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !Equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if af, ok := number(a); ok {
+		bf, ok := number(b)
+		return ok && af == bf
+	}
+	return a == b
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,231 @@
+package jsondoc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	v, err := Decode(strings.NewReader(s))
+	require.NoError(t, err)
+	return v
+}
+
+// From RFC 7396 Appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{name: "Positive: Replace", target: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "Positive: Add", target: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "Positive: Remove", target: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{name: "Positive: Remove one of two", target: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "Positive: Array replaced", target: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "Positive: Value to array", target: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{name: "Positive: Nested", target: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{name: "Positive: Array of objects replaced", target: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{name: "Positive: Not an object", target: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{name: "Positive: Object to array", target: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{name: "Positive: Null removes nothing", target: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+		{name: "Positive: Array to object", target: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{name: "Positive: Deep null stripped", target: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			target := decode(t, tt.target)
+			before := Clone(target)
+
+			got := MergePatch(target, decode(t, tt.patch))
+
+			assert.Equal(t, decode(t, tt.want), got)
+			assert.Equal(t, before, target, "target unchanged")
+		})
+	}
+}
+
+// Mostly from RFC 6902 Appendix A.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "Positive: Add an object member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:  "Positive: Add an array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "Positive: Append to an array",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:  "Positive: Remove an array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "Positive: Replace",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "Positive: Move a value",
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "Positive: Move an array element",
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:  "Positive: Copy",
+			doc:   `{"a":{"b":[1]}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`,
+			want:  `{"a":{"b":[1]},"c":{"b":[1,2]}}`,
+		},
+		{
+			name:  "Positive: Test numbers by value, escaped pointer",
+			doc:   `{"baz":"qux","foo":["a",2,"c"],"m~n":1.0,"a/b":1}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0},{"op":"test","path":"/m~0n","value":1},{"op":"remove","path":"/a~1b"}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"],"m~n":1.0}`,
+		},
+		{
+			name:  "Positive: Add a nested member object",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:  `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "Positive: Replace the whole document",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"replace","path":"","value":{"baz":1}}]`,
+			want:  `{"baz":1}`,
+		},
+		{
+			name:    "Negative: Test failed, nothing applied",
+			doc:     `{"baz":"qux"}`,
+			patch:   `[{"op":"add","path":"/new","value":1},{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr: ErrConflict,
+		},
+		{
+			name:    "Negative: Add to a nonexistent target",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: ErrConflict,
+		},
+		{
+			name:    "Negative: Array index out of range",
+			doc:     `{"foo":["bar"]}`,
+			patch:   `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			wantErr: ErrConflict,
+		},
+		{
+			name:    "Negative: Remove a missing member",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"remove","path":"/baz"}]`,
+			wantErr: ErrConflict,
+		},
+		{
+			name:    "Negative: Leading zero index",
+			doc:     `{"foo":["bar","baz"]}`,
+			patch:   `[{"op":"remove","path":"/foo/01"}]`,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "Negative: Move into itself",
+			doc:     `{"a":{"b":{}}}`,
+			patch:   `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "Negative: Pointer without slash",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"remove","path":"foo"}]`,
+			wantErr: ErrInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			doc := decode(t, tt.doc)
+			before := Clone(doc)
+			ops, err := DecodePatch(strings.NewReader(tt.patch))
+			require.NoError(t, err)
+
+			got, err := Apply(doc, ops)
+
+			assert.Equal(t, before, doc, "document unchanged")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, decode(t, tt.want), got)
+		})
+	}
+}
+
+func TestDecodePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    []Operation
+		wantErr bool
+	}{
+		{
+			name:  "Positive: Null value, numbers",
+			patch: `[{"op":"add","path":"/a","value":null},{"op":"replace","path":"/b","value":[1,1.5]},{"op":"copy","from":"/a","path":"/c"}]`,
+			want: []Operation{
+				{Op: "add", Path: "/a"},
+				{Op: "replace", Path: "/b", Value: []any{int64(1), 1.5}},
+				{Op: "copy", Path: "/c", From: "/a"},
+			},
+		},
+		{name: "Negative: Not an array", patch: `{"op":"remove","path":"/a"}`, wantErr: true},
+		{name: "Negative: Unknown op", patch: `[{"op":"merge","path":"/a"}]`, wantErr: true},
+		{name: "Negative: No value", patch: `[{"op":"add","path":"/a"}]`, wantErr: true},
+		{name: "Negative: No from", patch: `[{"op":"move","path":"/a"}]`, wantErr: true},
+		{name: "Negative: No path", patch: `[{"op":"remove"}]`, wantErr: true},
+		{name: "Negative: Path not a string", patch: `[{"op":"remove","path":1}]`, wantErr: true},
+		{name: "Negative: Trailing data", patch: `[] []`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePatch(strings.NewReader(tt.patch))
+
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler gets the current level on GET and sets it on PUT {"level": "debug"}.
+func LevelHandler(lvl *Level) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body levelBody
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&body); err != nil {
+				http.Error(w, "expected {\"level\": \"debug|info|warn|error\"}", http.StatusBadRequest)
+				return
+			}
+			if err := lvl.Set(body.Level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelBody{Level: lvl.String()})
+	})
+}
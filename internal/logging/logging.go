@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+type (
+	contextKey string
+
+	// forceValue marks the records that pass the filter whatever the level.
+	forceValue struct{}
+)
+
+const (
+	FormatLogfmt = "logfmt"
+	FormatJSON   = "json"
+
+	forceKey             = "debugOverride"
+	forcedKey contextKey = "forced"
+)
+
+var levels = []string{"debug", "info", "warn", "error"}
+
+func (forceValue) String() string {
+	return "true"
+}
+
+// New creates a logger writing logfmt or JSON records.
+func New(w io.Writer, format string) (log.Logger, error) {
+	switch format {
+	case FormatLogfmt:
+		return log.NewLogfmtLogger(log.NewSyncWriter(w)), nil
+	case FormatJSON:
+		return log.NewJSONLogger(log.NewSyncWriter(w)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// NewContext marks the request for debug logging whatever the current level.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcedKey, true)
+}
+
+// FromContext returns the logger for the request: the same logger, or one
+// whose records all pass the filter if the request is marked for debugging.
+func FromContext(ctx context.Context, logger log.Logger) log.Logger {
+	if Forced(ctx) {
+		return log.With(logger, forceKey, forceValue{})
+	}
+	return logger
+}
+
+// Forced tells whether the request is marked for debug logging.
+func Forced(ctx context.Context) bool {
+	forced, _ := ctx.Value(forcedKey).(bool)
+	return forced
+}
+
+// Level is a log level that can be changed at runtime. The zero Level is debug.
+type Level struct {
+	idx atomic.Int32
+}
+
+func NewLevel(name string) (*Level, error) {
+	l := &Level{}
+	if err := l.Set(name); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Level) Set(name string) error {
+	for i, n := range levels {
+		if n == name {
+			l.idx.Store(int32(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q", name)
+}
+
+func (l *Level) String() string {
+	return levels[l.idx.Load()]
+}
+
+// NewFilter drops the records below the current level.
+// Records without a level and the forced ones always pass.
+func NewFilter(next log.Logger, lvl *Level) log.Logger {
+	return &filter{
+		next: next,
+		lvl:  lvl,
+		filters: []log.Logger{
+			level.NewFilter(next, level.AllowDebug()),
+			level.NewFilter(next, level.AllowInfo()),
+			level.NewFilter(next, level.AllowWarn()),
+			level.NewFilter(next, level.AllowError()),
+		},
+	}
+}
+
+type filter struct {
+	next    log.Logger
+	lvl     *Level
+	filters []log.Logger // one per level, in the order of levels
+}
+
+func (f *filter) Log(keyvals ...interface{}) error {
+	for i := 1; i < len(keyvals); i += 2 {
+		if _, ok := keyvals[i].(forceValue); ok {
+			return f.next.Log(keyvals...)
+		}
+	}
+	return f.filters[f.lvl.idx.Load()].Log(keyvals...)
+}
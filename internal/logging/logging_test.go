@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	lvl, err := NewLevel("info")
+	require.NoError(t, err)
+	logger := NewFilter(log.NewLogfmtLogger(&buf), lvl)
+
+	level.Debug(logger).Log("msg", "hidden")
+	level.Info(logger).Log("msg", "shown")
+	logger.Log("msg", "no level")
+	assert.NotContains(buf.String(), "hidden")
+	assert.Contains(buf.String(), "shown")
+	assert.Contains(buf.String(), "no level")
+
+	buf.Reset()
+	require.NoError(t, lvl.Set("debug"))
+	level.Debug(logger).Log("msg", "now shown")
+	assert.Contains(buf.String(), "now shown")
+
+	assert.Error(lvl.Set("verbose"))
+	assert.Equal("debug", lvl.String())
+}
+
+func TestFromContext(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	lvl, err := NewLevel("error")
+	require.NoError(t, err)
+	logger := NewFilter(log.NewLogfmtLogger(&buf), lvl)
+
+	level.Debug(FromContext(context.Background(), logger)).Log("msg", "hidden")
+	assert.Empty(buf.String())
+
+	level.Debug(FromContext(NewContext(context.Background()), logger)).Log("msg", "forced")
+	assert.Contains(buf.String(), "msg=forced")
+	assert.Contains(buf.String(), "debugOverride=true")
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON)
+	require.NoError(t, err)
+	level.Info(logger).Log("msg", "hello")
+
+	var got map[string]string
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(map[string]string{"level": "info", "msg": "hello"}, got)
+
+	_, err = New(&buf, "xml")
+	assert.Error(err)
+}
+
+func TestLevelHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantLevel  string
+	}{
+		{
+			name:       "Positive: Get",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantLevel:  "info",
+		},
+		{
+			name:       "Positive: Set",
+			method:     http.MethodPut,
+			body:       `{"level": "debug"}`,
+			wantStatus: http.StatusOK,
+			wantLevel:  "debug",
+		},
+		{
+			name:       "Negative: Unknown level",
+			method:     http.MethodPut,
+			body:       `{"level": "verbose"}`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  "info",
+		},
+		{
+			name:       "Negative: Bad body",
+			method:     http.MethodPut,
+			body:       `debug`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  "info",
+		},
+		{
+			name:       "Negative: Wrong method",
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantLevel:  "info",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			lvl, err := NewLevel("info")
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			LevelHandler(lvl).ServeHTTP(rec, httptest.NewRequest(tt.method, "/debug/log-level", strings.NewReader(tt.body)))
+
+			assert.Equal(tt.wantStatus, rec.Code)
+			assert.Equal(tt.wantLevel, lvl.String())
+		})
+	}
+}
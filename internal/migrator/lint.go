@@ -0,0 +1,415 @@
+package migrator
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lint rules, the names are used in the allow annotations.
+const (
+	RuleDropTable        = "drop-table"
+	RuleDropColumn       = "drop-column"
+	RuleNotNull          = "not-null-without-default"
+	RuleSetNotNull       = "set-not-null"
+	RuleIndexConcurrency = "index-not-concurrent"
+	RuleColumnType       = "column-type-change"
+	RuleUnbatchedWrite   = "unbatched-write"
+	RuleMissingDown      = "missing-down"
+	RuleVersionSequence  = "version-sequence"
+	RuleFileName         = "file-name"
+)
+
+// allowMark in a comment allows the rules given after it, comma separated,
+// for the statement the comment is in or precedes, e.g.
+//
+//	-- lint:allow drop-table reverts the create
+//
+// The file rules (missing-down, version-sequence) are allowed by an
+// annotation anywhere in the up file.
+const allowMark = "lint:allow"
+
+var (
+	fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+	createTableRe = regexp.MustCompile(`^CREATE (?:(?:GLOBAL |LOCAL )?(?:TEMP|TEMPORARY|UNLOGGED) )?TABLE (?:IF NOT EXISTS )?(\S+)`)
+	dropTableRe   = regexp.MustCompile(`^DROP TABLE `)
+	createIndexRe = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX (CONCURRENTLY )?.*? ON (?:ONLY )?([^\s(]+)`)
+	alterTableRe  = regexp.MustCompile(`^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?(\S+) (.*)$`)
+	writeRe       = regexp.MustCompile(`^(UPDATE|DELETE FROM) (?:ONLY )?(\S+)`)
+	whereRe       = regexp.MustCompile(`\bWHERE\b`)
+	limitRe       = regexp.MustCompile(`\bLIMIT\b`)
+
+	dropColumnRe = regexp.MustCompile(`^DROP (?:COLUMN )?(?:IF EXISTS )?(\S+)`)
+	addColumnRe  = regexp.MustCompile(`^ADD (?:COLUMN )?(?:IF NOT EXISTS )?(\S+)`)
+	alterColRe   = regexp.MustCompile(`^ALTER (?:COLUMN )?(\S+) (?:SET DATA )?(TYPE|SET NOT NULL)\b`)
+)
+
+type (
+	// Finding is a risky operation found in a migration file.
+	Finding struct {
+		File    string
+		Line    int
+		Rule    string
+		Message string
+	}
+
+	statement struct {
+		text  string // upper case, comments removed, spaces collapsed
+		line  int
+		allow map[string]bool
+	}
+
+	migrationFile struct {
+		name    string
+		version uint64
+		up      bool
+	}
+)
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", f.File, f.Rule, f.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", f.File, f.Line, f.Rule, f.Message)
+}
+
+// Lint checks the migration files in the root of the file system.
+func Lint(fsys fs.FS) ([]Finding, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	var (
+		findings []Finding
+		files    []migrationFile
+		allowed  = map[string]map[string]bool{} // up file: rules
+	)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		m := fileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			findings = append(findings, Finding{File: e.Name(), Rule: RuleFileName,
+				Message: "not named <version>_<name>.up.sql or .down.sql"})
+			continue
+		}
+		v, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			findings = append(findings, Finding{File: e.Name(), Rule: RuleFileName, Message: "bad version"})
+			continue
+		}
+		files = append(files, migrationFile{name: e.Name(), version: v, up: m[3] == Up})
+
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", e.Name(), err)
+		}
+		stmts := splitStatements(string(b))
+		findings = append(findings, lintStatements(e.Name(), stmts)...)
+		if m[3] == Up {
+			allowed[e.Name()] = map[string]bool{}
+			for _, s := range stmts {
+				for r := range s.allow {
+					allowed[e.Name()][r] = true
+				}
+			}
+		}
+	}
+	findings = append(findings, lintFiles(files, allowed)...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+func lintFiles(files []migrationFile, allowed map[string]map[string]bool) []Finding {
+	type pair struct {
+		up, down string
+	}
+	var findings []Finding
+	pairs := map[uint64]*pair{}
+	for _, f := range files {
+		p := pairs[f.version]
+		if p == nil {
+			p = &pair{}
+			pairs[f.version] = p
+		}
+		name := &p.down
+		if f.up {
+			name = &p.up
+		}
+		if *name != "" {
+			findings = append(findings, Finding{File: f.name, Rule: RuleVersionSequence,
+				Message: fmt.Sprintf("version %d is also used by %s", f.version, *name)})
+			continue
+		}
+		*name = f.name
+	}
+
+	versions := make([]uint64, 0, len(pairs))
+	for v := range pairs {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for i, v := range versions {
+		p := pairs[v]
+		if p.up == "" {
+			findings = append(findings, Finding{File: p.down, Rule: RuleVersionSequence, Message: "down file without an up file"})
+			continue
+		}
+		if p.down == "" && !allowed[p.up][RuleMissingDown] {
+			findings = append(findings, Finding{File: p.up, Rule: RuleMissingDown, Message: "no down file, the migration can't be reverted"})
+		}
+		want := uint64(1)
+		if i > 0 {
+			want = versions[i-1] + 1
+		}
+		if v != want && !allowed[p.up][RuleVersionSequence] {
+			findings = append(findings, Finding{File: p.up, Rule: RuleVersionSequence,
+				Message: fmt.Sprintf("version %d, expected %d", v, want)})
+		}
+	}
+	return findings
+}
+
+func lintStatements(file string, stmts []statement) []Finding {
+	var findings []Finding
+	created := map[string]bool{} // new tables are empty, locking them is fine
+	add := func(s statement, rule, msg string) {
+		if !s.allow[rule] {
+			findings = append(findings, Finding{File: file, Line: s.line, Rule: rule, Message: msg})
+		}
+	}
+
+	for _, s := range stmts {
+		if m := createTableRe.FindStringSubmatch(s.text); m != nil {
+			created[tableName(m[1])] = true
+			continue
+		}
+		if dropTableRe.MatchString(s.text) {
+			add(s, RuleDropTable, "drops a table and its data")
+			continue
+		}
+		if m := createIndexRe.FindStringSubmatch(s.text); m != nil {
+			if m[1] == "" && !created[tableName(m[2])] {
+				add(s, RuleIndexConcurrency, "CREATE INDEX without CONCURRENTLY blocks writes to the table")
+			}
+			continue
+		}
+		if m := writeRe.FindStringSubmatch(s.text); m != nil {
+			// Batched: the rows come from a subquery with a LIMIT, the
+			// rest is left to the next run or a backfill job.
+			if !created[tableName(m[2])] && !limitRe.MatchString(s.text) {
+				verb := strings.ToLower(strings.Fields(m[1])[0]) + "s"
+				if whereRe.MatchString(s.text) {
+					add(s, RuleUnbatchedWrite, verb+" "+tableName(m[2])+" without a batch LIMIT, may lock every row until the migration commits")
+				} else {
+					add(s, RuleUnbatchedWrite, verb+" every row of "+tableName(m[2])+" in the migration transaction")
+				}
+			}
+			continue
+		}
+		m := alterTableRe.FindStringSubmatch(s.text)
+		if m == nil {
+			continue
+		}
+		table := tableName(m[1])
+		for _, action := range splitTopLevel(m[2]) {
+			if am := dropColumnRe.FindStringSubmatch(action); am != nil {
+				switch am[1] {
+				case "CONSTRAINT", "DEFAULT", "NOT", "IDENTITY", "EXPRESSION":
+				default:
+					add(s, RuleDropColumn, "drops column "+strings.ToLower(am[1])+" and its data")
+				}
+				continue
+			}
+			if created[table] {
+				continue
+			}
+			if am := addColumnRe.FindStringSubmatch(action); am != nil && am[1] != "CONSTRAINT" {
+				if strings.Contains(action, "NOT NULL") && !strings.Contains(action, "DEFAULT") {
+					add(s, RuleNotNull, "adds NOT NULL column "+strings.ToLower(am[1])+" without a default, fails on existing rows")
+				}
+				continue
+			}
+			if am := alterColRe.FindStringSubmatch(action); am != nil {
+				if am[2] == "TYPE" {
+					add(s, RuleColumnType, "changes the type of "+strings.ToLower(am[1])+", may rewrite the table under an exclusive lock")
+				} else {
+					add(s, RuleSetNotNull, "SET NOT NULL on "+strings.ToLower(am[1])+" scans the table under an exclusive lock")
+				}
+			}
+		}
+	}
+	return findings
+}
+
+func tableName(s string) string {
+	s = strings.ToLower(strings.ReplaceAll(s, `"`, ""))
+	return strings.TrimPrefix(s, "public.")
+}
+
+// splitTopLevel splits on the commas outside parentheses.
+func splitTopLevel(s string) []string {
+	var (
+		res   []string
+		depth int
+		start int
+	)
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				res = append(res, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(res, strings.TrimSpace(s[start:]))
+}
+
+// splitStatements splits SQL on semicolons, skipping strings, quoted
+// identifiers, dollar quoted bodies and comments. Comments go to the
+// statement that follows them, for the allow annotations.
+func splitStatements(sql string) []statement {
+	var (
+		res   []statement
+		text  strings.Builder
+		cur   = statement{allow: map[string]bool{}}
+		line  = 1
+		runes = []rune(sql)
+	)
+	flush := func() {
+		cur.text = strings.Join(strings.Fields(strings.ToUpper(text.String())), " ")
+		if cur.text != "" {
+			res = append(res, cur)
+		}
+		text.Reset()
+		cur = statement{allow: map[string]bool{}}
+	}
+	comment := func(c string) {
+		i := strings.Index(c, allowMark)
+		if i < 0 {
+			return
+		}
+		fields := strings.Fields(c[i+len(allowMark):])
+		if len(fields) == 0 {
+			return
+		}
+		for _, r := range strings.Split(strings.TrimSuffix(fields[0], ":"), ",") {
+			if r != "" {
+				cur.allow[r] = true
+			}
+		}
+	}
+	write := func(r rune) {
+		if cur.line == 0 && !isSpace(r) {
+			cur.line = line
+		}
+		text.WriteRune(r)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			j := i
+			for j < len(runes) && runes[j] != '\n' {
+				j++
+			}
+			comment(string(runes[i:j]))
+			i = j - 1
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			j := i + 2
+			for j+1 < len(runes) && !(runes[j] == '*' && runes[j+1] == '/') {
+				if runes[j] == '\n' {
+					line++
+				}
+				j++
+			}
+			comment(string(runes[i:min(j+2, len(runes))]))
+			i = j + 1
+			write(' ')
+		case r == '\'' || r == '"':
+			// Literals are kept, they don't matter to the rules.
+			write(r)
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\n' {
+					line++
+				}
+				text.WriteRune(runes[j])
+				j++
+			}
+			if j < len(runes) {
+				text.WriteRune(runes[j])
+			}
+			i = j
+		case r == '$':
+			tag := []rune(dollarTag(runes[i:]))
+			if len(tag) == 0 {
+				write(r)
+				continue
+			}
+			j := i + len(tag)
+			for j < len(runes) && !hasPrefix(runes[j:], tag) {
+				j++
+			}
+			j = min(j+len(tag), len(runes))
+			write(r)
+			for _, b := range runes[i+1 : j] {
+				if b == '\n' {
+					line++
+				}
+				text.WriteRune(b)
+			}
+			i = j - 1
+		case r == ';':
+			flush()
+		default:
+			if r == '\n' {
+				line++
+			}
+			write(r)
+		}
+	}
+	flush()
+	return res
+}
+
+// dollarTag returns the $tag$ the runes start with, if any.
+func dollarTag(runes []rune) string {
+	for i := 1; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '$':
+			return string(runes[:i+1])
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func hasPrefix(runes, prefix []rune) bool {
+	return len(runes) >= len(prefix) && string(runes[:len(prefix)]) == string(prefix)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,148 @@
+package migrator
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	const down = "SELECT 1;"
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "Positive: New table with indexes",
+			files: map[string]string{
+				"000001_users.up.sql": `CREATE TABLE IF NOT EXISTS public.users (
+    user_id bigint PRIMARY KEY,
+    "name" varchar NOT NULL
+);
+CREATE INDEX users_name_idx ON users (name);
+ALTER TABLE users ADD COLUMN email text NOT NULL;`,
+				"000001_users.down.sql": down,
+			},
+		},
+		{
+			name: "Positive: Safe changes to an existing table",
+			files: map[string]string{
+				"000001_users.up.sql": `CREATE INDEX CONCURRENTLY users_name_idx ON users (name);
+ALTER TABLE users ADD COLUMN email text NOT NULL DEFAULT '', ALTER COLUMN name DROP NOT NULL, DROP CONSTRAINT users_name_key;`,
+				"000001_users.down.sql": down,
+			},
+		},
+		{
+			name: "Positive: Batched and new table writes",
+			files: map[string]string{
+				"000001_users.up.sql": `CREATE TABLE tags (tag_id bigint, "name" text);
+UPDATE tags SET "name" = lower("name");
+UPDATE users SET email = '' WHERE user_id IN (SELECT user_id FROM users WHERE email IS NULL LIMIT 1000);`,
+				"000001_users.down.sql": down,
+			},
+		},
+		{
+			name: "Negative: Unbatched writes",
+			files: map[string]string{
+				"000001_users.up.sql": `UPDATE public.users SET name_bidx = "name" WHERE name_bidx IS NULL;
+DELETE FROM users;
+-- lint:allow unbatched-write a few rows
+UPDATE api_keys SET scopes = '{}' WHERE owner = 'x';`,
+				"000001_users.down.sql": down,
+			},
+			want: []string{
+				"000001_users.up.sql:1: unbatched-write: updates users without a batch LIMIT, may lock every row until the migration commits",
+				"000001_users.up.sql:2: unbatched-write: deletes every row of users in the migration transaction",
+			},
+		},
+		{
+			name: "Positive: Allowed by annotations",
+			files: map[string]string{
+				"000001_users.up.sql": `-- lint:allow missing-down the data is gone anyway
+-- lint:allow drop-table,drop-column: cleanup
+DROP TABLE users;
+/* lint:allow index-not-concurrent small table */ CREATE UNIQUE INDEX ON api_keys (prefix);`,
+			},
+		},
+		{
+			name: "Positive: Semicolons in strings, comments and function bodies",
+			files: map[string]string{
+				"000001_fn.up.sql": `-- DROP TABLE users;
+COMMENT ON TABLE users IS 'DROP TABLE users;';
+CREATE FUNCTION f() RETURNS void AS $body$ DROP TABLE users; $body$ LANGUAGE sql;`,
+				"000001_fn.down.sql": down,
+			},
+		},
+		{
+			name: "Negative: Destructive and locking statements",
+			files: map[string]string{
+				"000001_users.up.sql": `DROP TABLE IF EXISTS public.old_users;
+
+ALTER TABLE users
+    DROP COLUMN nickname,
+    ADD COLUMN email text NOT NULL,
+    ALTER COLUMN name TYPE text,
+    ALTER COLUMN created_at SET NOT NULL;
+CREATE UNIQUE INDEX users_email_idx ON public.users (email);`,
+				"000001_users.down.sql": down,
+			},
+			want: []string{
+				"000001_users.up.sql:1: drop-table: drops a table and its data",
+				"000001_users.up.sql:3: drop-column: drops column nickname and its data",
+				"000001_users.up.sql:3: not-null-without-default: adds NOT NULL column email without a default, fails on existing rows",
+				"000001_users.up.sql:3: column-type-change: changes the type of name, may rewrite the table under an exclusive lock",
+				"000001_users.up.sql:3: set-not-null: SET NOT NULL on created_at scans the table under an exclusive lock",
+				"000001_users.up.sql:8: index-not-concurrent: CREATE INDEX without CONCURRENTLY blocks writes to the table",
+			},
+		},
+		{
+			name: "Negative: Annotation for another rule",
+			files: map[string]string{
+				"000001_users.up.sql":   "-- lint:allow drop-column\nDROP TABLE users;",
+				"000001_users.down.sql": down,
+			},
+			want: []string{"000001_users.up.sql:2: drop-table: drops a table and its data"},
+		},
+		{
+			name: "Negative: Missing down and non-sequential versions",
+			files: map[string]string{
+				"000001_a.up.sql":   down,
+				"000003_b.up.sql":   down,
+				"000003_b.down.sql": down,
+				"000003_c.up.sql":   down,
+				"000004_d.down.sql": down,
+				"readme.sql":        down,
+			},
+			want: []string{
+				"000001_a.up.sql: missing-down: no down file, the migration can't be reverted",
+				"000003_b.up.sql: version-sequence: version 3, expected 2",
+				"000003_c.up.sql: version-sequence: version 3 is also used by 000003_b.up.sql",
+				"000004_d.down.sql: version-sequence: down file without an up file",
+				"readme.sql: file-name: not named <version>_<name>.up.sql or .down.sql",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+
+			findings, err := Lint(fsys)
+			require.NoError(t, err)
+
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package migrator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Logger adapts a go-kit logger to migrate.Logger.
+type Logger struct {
+	Logger log.Logger
+}
+
+func (l Logger) Printf(format string, v ...interface{}) {
+	level.Info(l.Logger).Log("msg", strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (Logger) Verbose() bool {
+	return false
+}
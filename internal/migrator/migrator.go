@@ -0,0 +1,182 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+const (
+	Up   = "up"
+	Down = "down"
+
+	// NoVersion is the state of a database no migration has been applied to.
+	NoVersion = -1
+)
+
+var (
+	ErrNotEnough = errors.New("not enough migrations")
+	ErrNoVersion = errors.New("no such migration version")
+
+	nameRe = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type (
+	// Migration is a migration file pair with its state in the database.
+	Migration struct {
+		Version uint
+		Name    string // identifier, e.g. create_users_table
+		HasDown bool
+		Applied bool
+		Dirty   bool
+	}
+
+	// Step is a migration to run in a direction.
+	Step struct {
+		Version   uint
+		Name      string
+		Direction string
+	}
+
+	// Target is a database the migrations are applied to.
+	Target interface {
+		// Version is the current version, NoVersion if none is applied.
+		Version(ctx context.Context) (version int, dirty bool, err error)
+		// Migrations lists the migrations marked as of the version.
+		Migrations(current int, dirty bool) ([]Migration, error)
+		// Run runs the planned steps in order.
+		Run(ctx context.Context, steps []Step) error
+		// Force sets the version and clears the dirty flag without running anything.
+		Force(ctx context.Context, version int) error
+		Close(ctx context.Context) error
+	}
+)
+
+// List reads the migrations from the source and marks the ones applied
+// up to the current version, NoVersion if none.
+func List(src source.Driver, current int, dirty bool) ([]Migration, error) {
+	var res []Migration
+	v, err := src.First()
+	for err == nil {
+		m := Migration{Version: v, Applied: int(v) <= current}
+		m.Dirty = dirty && int(v) == current
+
+		r, id, upErr := src.ReadUp(v)
+		if upErr != nil {
+			return nil, fmt.Errorf("reading up migration %d: %w", v, upErr)
+		}
+		_ = r.Close()
+		m.Name = id
+
+		if r, _, downErr := src.ReadDown(v); downErr == nil {
+			_ = r.Close()
+			m.HasDown = true
+		}
+		res = append(res, m)
+		v, err = src.Next(v)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+	return res, nil
+}
+
+// PlanUp returns the next n pending migrations, all of them if n is 0.
+func PlanUp(migs []Migration, n int) ([]Step, error) {
+	var steps []Step
+	for _, m := range migs {
+		if !m.Applied {
+			steps = append(steps, Step{m.Version, m.Name, Up})
+		}
+	}
+	return limit(steps, n)
+}
+
+// PlanDown returns the last n applied migrations, newest first, all of them if n is 0.
+func PlanDown(migs []Migration, n int) ([]Step, error) {
+	var steps []Step
+	for i := len(migs) - 1; i >= 0; i-- {
+		if migs[i].Applied {
+			steps = append(steps, Step{migs[i].Version, migs[i].Name, Down})
+		}
+	}
+	return limit(steps, n)
+}
+
+// PlanGoto returns the migrations to run to get to the version, up or down.
+func PlanGoto(migs []Migration, current int, version uint) ([]Step, error) {
+	found := false
+	for _, m := range migs {
+		found = found || m.Version == version
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %d", ErrNoVersion, version)
+	}
+
+	var steps []Step
+	if int(version) >= current {
+		for _, m := range migs {
+			if int(m.Version) > current && m.Version <= version {
+				steps = append(steps, Step{m.Version, m.Name, Up})
+			}
+		}
+		return steps, nil
+	}
+	for i := len(migs) - 1; i >= 0; i-- {
+		if m := migs[i]; int(m.Version) <= current && m.Version > version {
+			steps = append(steps, Step{m.Version, m.Name, Down})
+		}
+	}
+	return steps, nil
+}
+
+func limit(steps []Step, n int) ([]Step, error) {
+	if n == 0 {
+		return steps, nil
+	}
+	if n > len(steps) {
+		return nil, fmt.Errorf("%w: asked for %d, there are %d", ErrNotEnough, n, len(steps))
+	}
+	return steps[:n], nil
+}
+
+// Create writes empty up and down files for the next version in the directory.
+func Create(dir, name string) (up, down string, err error) {
+	name = strings.Trim(nameRe.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("empty migration name")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return "", "", err
+	}
+	var last uint
+	for _, f := range files {
+		var v uint
+		if _, err := fmt.Sscanf(filepath.Base(f), "%d_", &v); err == nil && v > last {
+			last = v
+		}
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", last+1, name))
+	up, down = base+".up.sql", base+".down.sql"
+	for _, f := range []string{up, down} {
+		// O_EXCL: never overwrite a migration.
+		file, err := os.OpenFile(f, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return "", "", fmt.Errorf("creating migration file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return "", "", err
+		}
+	}
+	return up, down, nil
+}
@@ -0,0 +1,135 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMigrations(t *testing.T, current int) []Migration {
+	fsys := fstest.MapFS{
+		"000001_create_users.up.sql":    {Data: []byte("CREATE TABLE users ()")},
+		"000001_create_users.down.sql":  {Data: []byte("DROP TABLE users")},
+		"000002_add_name.up.sql":        {Data: []byte("ALTER TABLE users ADD name text")},
+		"000002_add_name.down.sql":      {Data: []byte("ALTER TABLE users DROP name")},
+		"000003_create_api_keys.up.sql": {Data: []byte("CREATE TABLE api_keys ()")},
+		"000005_add_index.up.sql":       {Data: []byte("CREATE INDEX ON users (name)")},
+		"000005_add_index.down.sql":     {Data: []byte("DROP INDEX users_name_idx")},
+	}
+	src, err := iofs.New(fsys, ".")
+	require.NoError(t, err)
+
+	migs, err := List(src, current, false)
+	require.NoError(t, err)
+	return migs
+}
+
+func TestList(t *testing.T) {
+	assert := assert.New(t)
+
+	migs := testMigrations(t, 2)
+
+	assert.Equal([]Migration{
+		{Version: 1, Name: "create_users", HasDown: true, Applied: true},
+		{Version: 2, Name: "add_name", HasDown: true, Applied: true},
+		{Version: 3, Name: "create_api_keys"},
+		{Version: 5, Name: "add_index", HasDown: true},
+	}, migs)
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		plan    func([]Migration, int) ([]Step, error)
+		want    []Step
+		wantErr error
+	}{
+		{
+			name:    "Positive: Up all on a fresh database",
+			current: NoVersion,
+			plan:    func(m []Migration, _ int) ([]Step, error) { return PlanUp(m, 0) },
+			want:    []Step{{1, "create_users", Up}, {2, "add_name", Up}, {3, "create_api_keys", Up}, {5, "add_index", Up}},
+		},
+		{
+			name:    "Positive: Up 1",
+			current: 2,
+			plan:    func(m []Migration, _ int) ([]Step, error) { return PlanUp(m, 1) },
+			want:    []Step{{3, "create_api_keys", Up}},
+		},
+		{
+			name:    "Positive: Up when up to date",
+			current: 5,
+			plan:    func(m []Migration, _ int) ([]Step, error) { return PlanUp(m, 0) },
+		},
+		{
+			name:    "Positive: Down 2",
+			current: 5,
+			plan:    func(m []Migration, _ int) ([]Step, error) { return PlanDown(m, 2) },
+			want:    []Step{{5, "add_index", Down}, {3, "create_api_keys", Down}},
+		},
+		{
+			name:    "Positive: Goto a later version",
+			current: 1,
+			plan:    func(m []Migration, cur int) ([]Step, error) { return PlanGoto(m, cur, 3) },
+			want:    []Step{{2, "add_name", Up}, {3, "create_api_keys", Up}},
+		},
+		{
+			name:    "Positive: Goto an earlier version",
+			current: 5,
+			plan:    func(m []Migration, cur int) ([]Step, error) { return PlanGoto(m, cur, 2) },
+			want:    []Step{{5, "add_index", Down}, {3, "create_api_keys", Down}},
+		},
+		{
+			name:    "Negative: Up more than pending",
+			current: 3,
+			plan:    func(m []Migration, _ int) ([]Step, error) { return PlanUp(m, 2) },
+			wantErr: ErrNotEnough,
+		},
+		{
+			name:    "Negative: Down on a fresh database",
+			current: NoVersion,
+			plan:    func(m []Migration, _ int) ([]Step, error) { return PlanDown(m, 1) },
+			wantErr: ErrNotEnough,
+		},
+		{
+			name:    "Negative: Goto a missing version",
+			current: 1,
+			plan:    func(m []Migration, cur int) ([]Step, error) { return PlanGoto(m, cur, 4) },
+			wantErr: ErrNoVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := tt.plan(testMigrations(t, tt.current), tt.current)
+
+			assert.ErrorIs(err, tt.wantErr)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000007_old.up.sql"), nil, 0o644))
+
+	up, down, err := Create(dir, "Add users' email!")
+	require.NoError(t, err)
+	assert.Equal(filepath.Join(dir, "000008_add_users_email.up.sql"), up)
+	assert.Equal(filepath.Join(dir, "000008_add_users_email.down.sql"), down)
+	assert.FileExists(up)
+	assert.FileExists(down)
+
+	_, _, err = Create(dir, "!!!")
+	assert.Error(err)
+}
@@ -0,0 +1,148 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStateCollection keeps the version of the applied Mongo migrations.
+const MongoStateCollection = "schema_migrations"
+
+const mongoStateID = "version"
+
+type (
+	// MongoMigration creates indexes, validators and the like. Down may be
+	// nil when the migration can't be reverted.
+	MongoMigration struct {
+		Version uint
+		Name    string
+		Up      func(ctx context.Context, db *mongo.Database) error
+		Down    func(ctx context.Context, db *mongo.Database) error
+	}
+
+	// Mongo runs the Mongo migrations. Like golang-migrate, it marks the
+	// version dirty while a migration runs so a failed one is noticed.
+	Mongo struct {
+		db    *mongo.Database
+		state *mongo.Collection
+		migs  []MongoMigration
+	}
+
+	mongoState struct {
+		ID      string `bson:"_id"`
+		Version int    `bson:"version"`
+		Dirty   bool   `bson:"dirty"`
+	}
+)
+
+// NewMongo takes over the database client, Close disconnects it.
+func NewMongo(db *mongo.Database, migs []MongoMigration) (*Mongo, error) {
+	migs = append([]MongoMigration(nil), migs...)
+	sort.Slice(migs, func(i, j int) bool { return migs[i].Version < migs[j].Version })
+	for i, m := range migs {
+		if m.Version == 0 || m.Up == nil {
+			return nil, fmt.Errorf("invalid mongo migration %d %s", m.Version, m.Name)
+		}
+		if i > 0 && migs[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate mongo migration version %d", m.Version)
+		}
+	}
+	return &Mongo{
+		db:    db,
+		state: db.Collection(MongoStateCollection),
+		migs:  migs,
+	}, nil
+}
+
+func (m *Mongo) Version(ctx context.Context) (int, bool, error) {
+	var s mongoState
+	err := m.state.FindOne(ctx, bson.D{{Key: "_id", Value: mongoStateID}}).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return NoVersion, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("reading mongo migrations version: %w", err)
+	}
+	return s.Version, s.Dirty, nil
+}
+
+func (m *Mongo) Migrations(current int, dirty bool) ([]Migration, error) {
+	res := make([]Migration, 0, len(m.migs))
+	for _, mig := range m.migs {
+		res = append(res, Migration{
+			Version: mig.Version,
+			Name:    mig.Name,
+			HasDown: mig.Down != nil,
+			Applied: int(mig.Version) <= current,
+			Dirty:   dirty && int(mig.Version) == current,
+		})
+	}
+	return res, nil
+}
+
+func (m *Mongo) Run(ctx context.Context, steps []Step) error {
+	for _, s := range steps {
+		i := m.index(s.Version)
+		if i < 0 {
+			return fmt.Errorf("%w: %d", ErrNoVersion, s.Version)
+		}
+		mig := m.migs[i]
+
+		run, version := mig.Up, int(mig.Version)
+		if s.Direction == Down {
+			if mig.Down == nil {
+				return fmt.Errorf("mongo migration %d %s has no down", mig.Version, mig.Name)
+			}
+			run, version = mig.Down, NoVersion
+			if i > 0 {
+				version = int(m.migs[i-1].Version)
+			}
+		}
+
+		if err := m.setVersion(ctx, version, true); err != nil {
+			return err
+		}
+		if err := run(ctx, m.db); err != nil {
+			return fmt.Errorf("running mongo migration %d %s %s: %w", mig.Version, mig.Name, s.Direction, err)
+		}
+		if err := m.setVersion(ctx, version, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Mongo) Force(ctx context.Context, version int) error {
+	return m.setVersion(ctx, version, false)
+}
+
+func (m *Mongo) Close(ctx context.Context) error {
+	return m.db.Client().Disconnect(ctx)
+}
+
+func (m *Mongo) index(version uint) int {
+	for i, mig := range m.migs {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Mongo) setVersion(ctx context.Context, version int, dirty bool) error {
+	_, err := m.state.ReplaceOne(ctx,
+		bson.D{{Key: "_id", Value: mongoStateID}},
+		mongoState{ID: mongoStateID, Version: version, Dirty: dirty},
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("setting mongo migrations version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// Postgres runs the SQL migrations with golang-migrate.
+type Postgres struct {
+	src source.Driver
+	mig *migrate.Migrate
+}
+
+// NewPostgres takes over the source, Close closes it.
+func NewPostgres(src source.Driver, dbURL string, logger log.Logger) (*Postgres, error) {
+	mig, err := migrate.NewWithSourceInstance("migrations", src, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("initializing migrations: %w", err)
+	}
+	mig.Log = Logger{Logger: logger}
+	return &Postgres{src: src, mig: mig}, nil
+}
+
+func (p *Postgres) Version(context.Context) (int, bool, error) {
+	v, dirty, err := p.mig.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return NoVersion, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return int(v), dirty, nil
+}
+
+func (p *Postgres) Migrations(current int, dirty bool) ([]Migration, error) {
+	return List(p.src, current, dirty)
+}
+
+// Run expects the steps of one plan, they all go in the same direction.
+func (p *Postgres) Run(_ context.Context, steps []Step) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	n := len(steps)
+	if steps[0].Direction == Down {
+		n = -n
+	}
+	if err := p.mig.Steps(n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) Force(_ context.Context, version int) error {
+	return p.mig.Force(version)
+}
+
+func (p *Postgres) Close(context.Context) error {
+	srcErr, dbErr := p.mig.Close()
+	return errors.Join(srcErr, dbErr)
+}
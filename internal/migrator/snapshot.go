@@ -0,0 +1,165 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is a pgx pool or connection.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Schema is a snapshot of the database objects, the object, e.g.
+// "column users.name", to its definition.
+type Schema map[string]string
+
+// snapshotQueries each return an object kind, name and definition. The
+// migrate version table isn't part of the schema.
+var snapshotQueries = []string{
+	`SELECT 'table', c.relname, c.relkind::text
+	FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'S') AND c.relname <> 'schema_migrations'`,
+
+	`SELECT 'column', c.relname || '.' || a.attname,
+		format_type(a.atttypid, a.atttypmod)
+		|| CASE WHEN a.attnotnull THEN ' NOT NULL' ELSE '' END
+		|| CASE WHEN a.attidentity <> '' THEN ' IDENTITY ' || a.attidentity ELSE '' END
+		|| COALESCE(' DEFAULT ' || pg_get_expr(d.adbin, d.adrelid), '')
+	FROM pg_attribute a
+	JOIN pg_class c ON c.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+	WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+		AND c.relname <> 'schema_migrations'`,
+
+	`SELECT 'constraint', c.relname || '.' || con.conname, pg_get_constraintdef(con.oid)
+	FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname <> 'schema_migrations'`,
+
+	`SELECT 'index', tablename || '.' || indexname, indexdef
+	FROM pg_indexes
+	WHERE schemaname = $1 AND tablename <> 'schema_migrations'`,
+
+	`SELECT 'view', c.relname, pg_get_viewdef(c.oid)
+	FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relkind IN ('v', 'm')`,
+
+	`SELECT 'function', p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')', md5(pg_get_functiondef(p.oid))
+	FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+	WHERE n.nspname = $1 AND p.prokind IN ('f', 'p')`,
+
+	`SELECT 'trigger', c.relname || '.' || t.tgname, pg_get_triggerdef(t.oid)
+	FROM pg_trigger t
+	JOIN pg_class c ON c.oid = t.tgrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND NOT t.tgisinternal`,
+
+	`SELECT 'extension', e.extname, e.extversion
+	FROM pg_extension e JOIN pg_namespace n ON n.oid = e.extnamespace
+	WHERE n.nspname = $1`,
+}
+
+// Snapshot reads the objects of the database schema from pg_catalog.
+func Snapshot(ctx context.Context, q Querier, schema string) (Schema, error) {
+	res := Schema{}
+	for _, query := range snapshotQueries {
+		rows, err := q.Query(ctx, query, schema)
+		if err != nil {
+			return nil, fmt.Errorf("querying schema: %w", err)
+		}
+		for rows.Next() {
+			var kind, name, def string
+			if err := rows.Scan(&kind, &name, &def); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scanning schema: %w", err)
+			}
+			res[kind+" "+name] = def
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("reading schema: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// Diff lists the differences of the schema from the wanted one:
+// "- object: def" is missing, "+ object: def" is extra and
+// "~ object: want -> got" is defined differently.
+func Diff(want, got Schema) []string {
+	var res []string
+	for obj, def := range want {
+		gotDef, ok := got[obj]
+		switch {
+		case !ok:
+			res = append(res, fmt.Sprintf("- %s: %s", obj, def))
+		case gotDef != def:
+			res = append(res, fmt.Sprintf("~ %s: %s -> %s", obj, def, gotDef))
+		}
+	}
+	for obj, def := range got {
+		if _, ok := want[obj]; !ok {
+			res = append(res, fmt.Sprintf("+ %s: %s", obj, def))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i][2:] < res[j][2:] })
+	return res
+}
+
+// RoundTrip applies the migrations one at a time from a fresh database
+// and checks that each down brings the schema back to what it was before
+// the up, and that the up after it gives the same schema again.
+func RoundTrip(ctx context.Context, tgt Target, snapshot func(ctx context.Context) (Schema, error)) error {
+	migs, err := tgt.Migrations(NoVersion, false)
+	if err != nil {
+		return err
+	}
+	before, err := snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range migs {
+		up := []Step{{m.Version, m.Name, Up}}
+		if err := tgt.Run(ctx, up); err != nil {
+			return fmt.Errorf("%d %s up: %w", m.Version, m.Name, err)
+		}
+		after, err := snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if !m.HasDown {
+			before = after
+			continue
+		}
+
+		if err := tgt.Run(ctx, []Step{{m.Version, m.Name, Down}}); err != nil {
+			return fmt.Errorf("%d %s down: %w", m.Version, m.Name, err)
+		}
+		reverted, err := snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if diff := Diff(before, reverted); len(diff) > 0 {
+			return fmt.Errorf("%d %s down doesn't revert up: %v", m.Version, m.Name, diff)
+		}
+
+		if err := tgt.Run(ctx, up); err != nil {
+			return fmt.Errorf("%d %s up again: %w", m.Version, m.Name, err)
+		}
+		again, err := snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if diff := Diff(after, again); len(diff) > 0 {
+			return fmt.Errorf("%d %s up again gives another schema: %v", m.Version, m.Name, diff)
+		}
+		before = after
+	}
+	return nil
+}
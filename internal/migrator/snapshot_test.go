@@ -0,0 +1,131 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTarget applies migrations that add and remove schema objects.
+type fakeTarget struct {
+	schema Schema
+	ups    map[uint]Schema
+	downs  map[uint][]string // objects the down removes
+}
+
+func (f *fakeTarget) Version(context.Context) (int, bool, error) { return NoVersion, false, nil }
+
+func (f *fakeTarget) Migrations(int, bool) ([]Migration, error) {
+	var res []Migration
+	for v := uint(1); v <= uint(len(f.ups)); v++ {
+		_, hasDown := f.downs[v]
+		res = append(res, Migration{Version: v, Name: "m", HasDown: hasDown})
+	}
+	return res, nil
+}
+
+func (f *fakeTarget) Run(_ context.Context, steps []Step) error {
+	for _, s := range steps {
+		if s.Direction == Up {
+			for obj, def := range f.ups[s.Version] {
+				f.schema[obj] = def
+			}
+			continue
+		}
+		for _, obj := range f.downs[s.Version] {
+			delete(f.schema, obj)
+		}
+	}
+	return nil
+}
+
+func (f *fakeTarget) Force(context.Context, int) error { return nil }
+func (f *fakeTarget) Close(context.Context) error      { return nil }
+
+func (f *fakeTarget) snapshot(context.Context) (Schema, error) {
+	res := Schema{}
+	for obj, def := range f.schema {
+		res[obj] = def
+	}
+	return res, nil
+}
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	got := Diff(
+		Schema{"table users": "r", "column users.name": "text", "index users.users_pkey": "pk"},
+		Schema{"table users": "r", "column users.name": "varchar", "column users.email": "text"},
+	)
+
+	assert.Equal([]string{
+		"+ column users.email: text",
+		"~ column users.name: text -> varchar",
+		"- index users.users_pkey: pk",
+	}, got)
+	assert.Empty(Diff(Schema{"table users": "r"}, Schema{"table users": "r"}))
+}
+
+func TestRoundTrip(t *testing.T) {
+	ups := map[uint]Schema{
+		1: {"table users": "r", "column users.name": "text"},
+		2: {"column users.email": "text", "index users.users_email_idx": "btree"},
+		3: {"table audit": "r"},
+	}
+
+	tests := []struct {
+		name    string
+		downs   map[uint][]string
+		wantErr string
+	}{
+		{
+			name: "Positive: Downs revert the ups",
+			downs: map[uint][]string{
+				1: {"table users", "column users.name"},
+				2: {"column users.email", "index users.users_email_idx"},
+				3: {"table audit"},
+			},
+		},
+		{
+			name: "Positive: Migration without down",
+			downs: map[uint][]string{
+				1: {"table users", "column users.name"},
+				2: {"column users.email", "index users.users_email_idx"},
+			},
+		},
+		{
+			name: "Negative: Down leaves an index",
+			downs: map[uint][]string{
+				1: {"table users", "column users.name"},
+				2: {"column users.email"},
+				3: {"table audit"},
+			},
+			wantErr: "2 m down doesn't revert up: [+ index users.users_email_idx: btree]",
+		},
+		{
+			name: "Negative: Down drops too much",
+			downs: map[uint][]string{
+				1: {"table users", "column users.name"},
+				2: {"column users.email", "index users.users_email_idx", "column users.name"},
+				3: {"table audit"},
+			},
+			wantErr: "2 m down doesn't revert up: [- column users.name: text]",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tgt := &fakeTarget{schema: Schema{}, ups: ups, downs: tt.downs}
+
+			err := RoundTrip(context.Background(), tgt, tgt.snapshot)
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
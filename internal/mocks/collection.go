@@ -0,0 +1,82 @@
+// Code generated by mockery v2.43.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	mongo "go.mongodb.org/mongo-driver/mongo"
+)
+
+// Collection is an autogenerated mock type for the Collection type
+type Collection struct {
+	mock.Mock
+}
+
+type Collection_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *Collection) EXPECT() *Collection_Expecter {
+	return &Collection_Expecter{mock: &_m.Mock}
+}
+
+// Execute provides a mock function with given fields:
+func (_m *Collection) Execute() *mongo.Collection {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Execute")
+	}
+
+	var r0 *mongo.Collection
+	if rf, ok := ret.Get(0).(func() *mongo.Collection); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mongo.Collection)
+		}
+	}
+
+	return r0
+}
+
+// Collection_Execute_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Execute'
+type Collection_Execute_Call struct {
+	*mock.Call
+}
+
+// Execute is a helper method to define mock.On call
+func (_e *Collection_Expecter) Execute() *Collection_Execute_Call {
+	return &Collection_Execute_Call{Call: _e.mock.On("Execute")}
+}
+
+func (_c *Collection_Execute_Call) Run(run func()) *Collection_Execute_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Collection_Execute_Call) Return(_a0 *mongo.Collection) *Collection_Execute_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Collection_Execute_Call) RunAndReturn(run func() *mongo.Collection) *Collection_Execute_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewCollection creates a new instance of Collection. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewCollection(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Collection {
+	mock := &Collection{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -79,6 +79,630 @@ func (_c *UserService_CreateUser_Call) RunAndReturn(run func(context.Context, st
 	return _c
 }
 
+// DeleteUser provides a mock function with given fields: ctx, id, match
+func (_m *UserService) DeleteUser(ctx context.Context, id domain.UserID, match domain.VersionMatch) error {
+	ret := _m.Called(ctx, id, match)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, domain.VersionMatch) error); ok {
+		r0 = rf(ctx, id, match)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UserService_DeleteUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteUser'
+type UserService_DeleteUser_Call struct {
+	*mock.Call
+}
+
+// DeleteUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - match domain.VersionMatch
+func (_e *UserService_Expecter) DeleteUser(ctx interface{}, id interface{}, match interface{}) *UserService_DeleteUser_Call {
+	return &UserService_DeleteUser_Call{Call: _e.mock.On("DeleteUser", ctx, id, match)}
+}
+
+func (_c *UserService_DeleteUser_Call) Run(run func(ctx context.Context, id domain.UserID, match domain.VersionMatch)) *UserService_DeleteUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(domain.VersionMatch))
+	})
+	return _c
+}
+
+func (_c *UserService_DeleteUser_Call) Return(_a0 error) *UserService_DeleteUser_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UserService_DeleteUser_Call) RunAndReturn(run func(context.Context, domain.UserID, domain.VersionMatch) error) *UserService_DeleteUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// EraseUser provides a mock function with given fields: ctx, id
+func (_m *UserService) EraseUser(ctx context.Context, id domain.UserID) (domain.UserErasure, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EraseUser")
+	}
+
+	var r0 domain.UserErasure
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.UserErasure, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.UserErasure); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.UserErasure)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_EraseUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EraseUser'
+type UserService_EraseUser_Call struct {
+	*mock.Call
+}
+
+// EraseUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UserService_Expecter) EraseUser(ctx interface{}, id interface{}) *UserService_EraseUser_Call {
+	return &UserService_EraseUser_Call{Call: _e.mock.On("EraseUser", ctx, id)}
+}
+
+func (_c *UserService_EraseUser_Call) Run(run func(ctx context.Context, id domain.UserID)) *UserService_EraseUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UserService_EraseUser_Call) Return(_a0 domain.UserErasure, _a1 error) *UserService_EraseUser_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_EraseUser_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.UserErasure, error)) *UserService_EraseUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExportUser provides a mock function with given fields: ctx, id
+func (_m *UserService) ExportUser(ctx context.Context, id domain.UserID) (domain.UserExport, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportUser")
+	}
+
+	var r0 domain.UserExport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.UserExport, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.UserExport); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.UserExport)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_ExportUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExportUser'
+type UserService_ExportUser_Call struct {
+	*mock.Call
+}
+
+// ExportUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UserService_Expecter) ExportUser(ctx interface{}, id interface{}) *UserService_ExportUser_Call {
+	return &UserService_ExportUser_Call{Call: _e.mock.On("ExportUser", ctx, id)}
+}
+
+func (_c *UserService_ExportUser_Call) Run(run func(ctx context.Context, id domain.UserID)) *UserService_ExportUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UserService_ExportUser_Call) Return(_a0 domain.UserExport, _a1 error) *UserService_ExportUser_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_ExportUser_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.UserExport, error)) *UserService_ExportUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetProfile provides a mock function with given fields: ctx, id
+func (_m *UserService) GetProfile(ctx context.Context, id domain.UserID) (domain.Profile, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetProfile")
+	}
+
+	var r0 domain.Profile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.Profile, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.Profile); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Profile)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_GetProfile_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetProfile'
+type UserService_GetProfile_Call struct {
+	*mock.Call
+}
+
+// GetProfile is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UserService_Expecter) GetProfile(ctx interface{}, id interface{}) *UserService_GetProfile_Call {
+	return &UserService_GetProfile_Call{Call: _e.mock.On("GetProfile", ctx, id)}
+}
+
+func (_c *UserService_GetProfile_Call) Run(run func(ctx context.Context, id domain.UserID)) *UserService_GetProfile_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UserService_GetProfile_Call) Return(_a0 domain.Profile, _a1 error) *UserService_GetProfile_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_GetProfile_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.Profile, error)) *UserService_GetProfile_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetUser provides a mock function with given fields: ctx, id
+func (_m *UserService) GetUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUser")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_GetUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetUser'
+type UserService_GetUser_Call struct {
+	*mock.Call
+}
+
+// GetUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UserService_Expecter) GetUser(ctx interface{}, id interface{}) *UserService_GetUser_Call {
+	return &UserService_GetUser_Call{Call: _e.mock.On("GetUser", ctx, id)}
+}
+
+func (_c *UserService_GetUser_Call) Run(run func(ctx context.Context, id domain.UserID)) *UserService_GetUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UserService_GetUser_Call) Return(_a0 domain.User, _a1 error) *UserService_GetUser_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_GetUser_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.User, error)) *UserService_GetUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListUsers provides a mock function with given fields: ctx, q
+func (_m *UserService) ListUsers(ctx context.Context, q domain.ListUsersQuery) (domain.UsersPage, error) {
+	ret := _m.Called(ctx, q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUsers")
+	}
+
+	var r0 domain.UsersPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.ListUsersQuery) (domain.UsersPage, error)); ok {
+		return rf(ctx, q)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.ListUsersQuery) domain.UsersPage); ok {
+		r0 = rf(ctx, q)
+	} else {
+		r0 = ret.Get(0).(domain.UsersPage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.ListUsersQuery) error); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_ListUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListUsers'
+type UserService_ListUsers_Call struct {
+	*mock.Call
+}
+
+// ListUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - q domain.ListUsersQuery
+func (_e *UserService_Expecter) ListUsers(ctx interface{}, q interface{}) *UserService_ListUsers_Call {
+	return &UserService_ListUsers_Call{Call: _e.mock.On("ListUsers", ctx, q)}
+}
+
+func (_c *UserService_ListUsers_Call) Run(run func(ctx context.Context, q domain.ListUsersQuery)) *UserService_ListUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.ListUsersQuery))
+	})
+	return _c
+}
+
+func (_c *UserService_ListUsers_Call) Return(_a0 domain.UsersPage, _a1 error) *UserService_ListUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_ListUsers_Call) RunAndReturn(run func(context.Context, domain.ListUsersQuery) (domain.UsersPage, error)) *UserService_ListUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PatchProfile provides a mock function with given fields: ctx, id, patch, match
+func (_m *UserService) PatchProfile(ctx context.Context, id domain.UserID, patch domain.ProfilePatch, match domain.VersionMatch) (domain.Profile, error) {
+	ret := _m.Called(ctx, id, patch, match)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PatchProfile")
+	}
+
+	var r0 domain.Profile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, domain.ProfilePatch, domain.VersionMatch) (domain.Profile, error)); ok {
+		return rf(ctx, id, patch, match)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, domain.ProfilePatch, domain.VersionMatch) domain.Profile); ok {
+		r0 = rf(ctx, id, patch, match)
+	} else {
+		r0 = ret.Get(0).(domain.Profile)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID, domain.ProfilePatch, domain.VersionMatch) error); ok {
+		r1 = rf(ctx, id, patch, match)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_PatchProfile_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PatchProfile'
+type UserService_PatchProfile_Call struct {
+	*mock.Call
+}
+
+// PatchProfile is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - patch domain.ProfilePatch
+//   - match domain.VersionMatch
+func (_e *UserService_Expecter) PatchProfile(ctx interface{}, id interface{}, patch interface{}, match interface{}) *UserService_PatchProfile_Call {
+	return &UserService_PatchProfile_Call{Call: _e.mock.On("PatchProfile", ctx, id, patch, match)}
+}
+
+func (_c *UserService_PatchProfile_Call) Run(run func(ctx context.Context, id domain.UserID, patch domain.ProfilePatch, match domain.VersionMatch)) *UserService_PatchProfile_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(domain.ProfilePatch), args[3].(domain.VersionMatch))
+	})
+	return _c
+}
+
+func (_c *UserService_PatchProfile_Call) Return(_a0 domain.Profile, _a1 error) *UserService_PatchProfile_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_PatchProfile_Call) RunAndReturn(run func(context.Context, domain.UserID, domain.ProfilePatch, domain.VersionMatch) (domain.Profile, error)) *UserService_PatchProfile_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PutProfile provides a mock function with given fields: ctx, id, data, match
+func (_m *UserService) PutProfile(ctx context.Context, id domain.UserID, data map[string]interface{}, match domain.VersionMatch) (domain.Profile, error) {
+	ret := _m.Called(ctx, id, data, match)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PutProfile")
+	}
+
+	var r0 domain.Profile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, map[string]interface{}, domain.VersionMatch) (domain.Profile, error)); ok {
+		return rf(ctx, id, data, match)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, map[string]interface{}, domain.VersionMatch) domain.Profile); ok {
+		r0 = rf(ctx, id, data, match)
+	} else {
+		r0 = ret.Get(0).(domain.Profile)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID, map[string]interface{}, domain.VersionMatch) error); ok {
+		r1 = rf(ctx, id, data, match)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_PutProfile_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PutProfile'
+type UserService_PutProfile_Call struct {
+	*mock.Call
+}
+
+// PutProfile is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - data map[string]interface{}
+//   - match domain.VersionMatch
+func (_e *UserService_Expecter) PutProfile(ctx interface{}, id interface{}, data interface{}, match interface{}) *UserService_PutProfile_Call {
+	return &UserService_PutProfile_Call{Call: _e.mock.On("PutProfile", ctx, id, data, match)}
+}
+
+func (_c *UserService_PutProfile_Call) Run(run func(ctx context.Context, id domain.UserID, data map[string]interface{}, match domain.VersionMatch)) *UserService_PutProfile_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(map[string]interface{}), args[3].(domain.VersionMatch))
+	})
+	return _c
+}
+
+func (_c *UserService_PutProfile_Call) Return(_a0 domain.Profile, _a1 error) *UserService_PutProfile_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_PutProfile_Call) RunAndReturn(run func(context.Context, domain.UserID, map[string]interface{}, domain.VersionMatch) (domain.Profile, error)) *UserService_PutProfile_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RestoreUser provides a mock function with given fields: ctx, id
+func (_m *UserService) RestoreUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreUser")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_RestoreUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreUser'
+type UserService_RestoreUser_Call struct {
+	*mock.Call
+}
+
+// RestoreUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UserService_Expecter) RestoreUser(ctx interface{}, id interface{}) *UserService_RestoreUser_Call {
+	return &UserService_RestoreUser_Call{Call: _e.mock.On("RestoreUser", ctx, id)}
+}
+
+func (_c *UserService_RestoreUser_Call) Run(run func(ctx context.Context, id domain.UserID)) *UserService_RestoreUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UserService_RestoreUser_Call) Return(_a0 domain.User, _a1 error) *UserService_RestoreUser_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_RestoreUser_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.User, error)) *UserService_RestoreUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SearchUsers provides a mock function with given fields: ctx, q
+func (_m *UserService) SearchUsers(ctx context.Context, q domain.SearchUsersQuery) (domain.UsersSearchPage, error) {
+	ret := _m.Called(ctx, q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SearchUsers")
+	}
+
+	var r0 domain.UsersSearchPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.SearchUsersQuery) (domain.UsersSearchPage, error)); ok {
+		return rf(ctx, q)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.SearchUsersQuery) domain.UsersSearchPage); ok {
+		r0 = rf(ctx, q)
+	} else {
+		r0 = ret.Get(0).(domain.UsersSearchPage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.SearchUsersQuery) error); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_SearchUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SearchUsers'
+type UserService_SearchUsers_Call struct {
+	*mock.Call
+}
+
+// SearchUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - q domain.SearchUsersQuery
+func (_e *UserService_Expecter) SearchUsers(ctx interface{}, q interface{}) *UserService_SearchUsers_Call {
+	return &UserService_SearchUsers_Call{Call: _e.mock.On("SearchUsers", ctx, q)}
+}
+
+func (_c *UserService_SearchUsers_Call) Run(run func(ctx context.Context, q domain.SearchUsersQuery)) *UserService_SearchUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.SearchUsersQuery))
+	})
+	return _c
+}
+
+func (_c *UserService_SearchUsers_Call) Return(_a0 domain.UsersSearchPage, _a1 error) *UserService_SearchUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_SearchUsers_Call) RunAndReturn(run func(context.Context, domain.SearchUsersQuery) (domain.UsersSearchPage, error)) *UserService_SearchUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateUser provides a mock function with given fields: ctx, id, name, match
+func (_m *UserService) UpdateUser(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (domain.User, error) {
+	ret := _m.Called(ctx, id, name, match)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUser")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string, domain.VersionMatch) (domain.User, error)); ok {
+		return rf(ctx, id, name, match)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string, domain.VersionMatch) domain.User); ok {
+		r0 = rf(ctx, id, name, match)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID, string, domain.VersionMatch) error); ok {
+		r1 = rf(ctx, id, name, match)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserService_UpdateUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateUser'
+type UserService_UpdateUser_Call struct {
+	*mock.Call
+}
+
+// UpdateUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - name string
+//   - match domain.VersionMatch
+func (_e *UserService_Expecter) UpdateUser(ctx interface{}, id interface{}, name interface{}, match interface{}) *UserService_UpdateUser_Call {
+	return &UserService_UpdateUser_Call{Call: _e.mock.On("UpdateUser", ctx, id, name, match)}
+}
+
+func (_c *UserService_UpdateUser_Call) Run(run func(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch)) *UserService_UpdateUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(string), args[3].(domain.VersionMatch))
+	})
+	return _c
+}
+
+func (_c *UserService_UpdateUser_Call) Return(_a0 domain.User, _a1 error) *UserService_UpdateUser_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UserService_UpdateUser_Call) RunAndReturn(run func(context.Context, domain.UserID, string, domain.VersionMatch) (domain.User, error)) *UserService_UpdateUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewUserService creates a new instance of UserService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserService(t interface {
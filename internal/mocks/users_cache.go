@@ -0,0 +1,242 @@
+// Code generated by mockery v2.43.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "ws-dummy-go/internal/dummy/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// UsersCache is an autogenerated mock type for the UsersCache type
+type UsersCache struct {
+	mock.Mock
+}
+
+type UsersCache_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *UsersCache) EXPECT() *UsersCache_Expecter {
+	return &UsersCache_Expecter{mock: &_m.Mock}
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *UsersCache) Delete(ctx context.Context, id domain.UserID) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsersCache_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type UsersCache_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersCache_Expecter) Delete(ctx interface{}, id interface{}) *UsersCache_Delete_Call {
+	return &UsersCache_Delete_Call{Call: _e.mock.On("Delete", ctx, id)}
+}
+
+func (_c *UsersCache_Delete_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersCache_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersCache_Delete_Call) Return(_a0 error) *UsersCache_Delete_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersCache_Delete_Call) RunAndReturn(run func(context.Context, domain.UserID) error) *UsersCache_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Get provides a mock function with given fields: ctx, id
+func (_m *UsersCache) Get(ctx context.Context, id domain.UserID) (domain.User, bool, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 domain.User
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.User, bool, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) bool); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, domain.UserID) error); ok {
+		r2 = rf(ctx, id)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UsersCache_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type UsersCache_Get_Call struct {
+	*mock.Call
+}
+
+// Get is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersCache_Expecter) Get(ctx interface{}, id interface{}) *UsersCache_Get_Call {
+	return &UsersCache_Get_Call{Call: _e.mock.On("Get", ctx, id)}
+}
+
+func (_c *UsersCache_Get_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersCache_Get_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersCache_Get_Call) Return(_a0 domain.User, _a1 bool, _a2 error) *UsersCache_Get_Call {
+	_c.Call.Return(_a0, _a1, _a2)
+	return _c
+}
+
+func (_c *UsersCache_Get_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.User, bool, error)) *UsersCache_Get_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Set provides a mock function with given fields: ctx, u
+func (_m *UsersCache) Set(ctx context.Context, u domain.User) error {
+	ret := _m.Called(ctx, u)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Set")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.User) error); ok {
+		r0 = rf(ctx, u)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsersCache_Set_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Set'
+type UsersCache_Set_Call struct {
+	*mock.Call
+}
+
+// Set is a helper method to define mock.On call
+//   - ctx context.Context
+//   - u domain.User
+func (_e *UsersCache_Expecter) Set(ctx interface{}, u interface{}) *UsersCache_Set_Call {
+	return &UsersCache_Set_Call{Call: _e.mock.On("Set", ctx, u)}
+}
+
+func (_c *UsersCache_Set_Call) Run(run func(ctx context.Context, u domain.User)) *UsersCache_Set_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.User))
+	})
+	return _c
+}
+
+func (_c *UsersCache_Set_Call) Return(_a0 error) *UsersCache_Set_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersCache_Set_Call) RunAndReturn(run func(context.Context, domain.User) error) *UsersCache_Set_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetNotFound provides a mock function with given fields: ctx, id
+func (_m *UsersCache) SetNotFound(ctx context.Context, id domain.UserID) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetNotFound")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsersCache_SetNotFound_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetNotFound'
+type UsersCache_SetNotFound_Call struct {
+	*mock.Call
+}
+
+// SetNotFound is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersCache_Expecter) SetNotFound(ctx interface{}, id interface{}) *UsersCache_SetNotFound_Call {
+	return &UsersCache_SetNotFound_Call{Call: _e.mock.On("SetNotFound", ctx, id)}
+}
+
+func (_c *UsersCache_SetNotFound_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersCache_SetNotFound_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersCache_SetNotFound_Call) Return(_a0 error) *UsersCache_SetNotFound_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersCache_SetNotFound_Call) RunAndReturn(run func(context.Context, domain.UserID) error) *UsersCache_SetNotFound_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewUsersCache creates a new instance of UsersCache. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUsersCache(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UsersCache {
+	mock := &UsersCache{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
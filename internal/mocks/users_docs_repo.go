@@ -7,6 +7,8 @@ import (
 	domain "ws-dummy-go/internal/dummy/domain"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // UsersDocsRepo is an autogenerated mock type for the UsersDocsRepo type
@@ -22,27 +24,29 @@ func (_m *UsersDocsRepo) EXPECT() *UsersDocsRepo_Expecter {
 	return &UsersDocsRepo_Expecter{mock: &_m.Mock}
 }
 
-// Insert provides a mock function with given fields: ctx, name
-func (_m *UsersDocsRepo) Insert(ctx context.Context, name string) (domain.UserID, error) {
-	ret := _m.Called(ctx, name)
+// Export provides a mock function with given fields: ctx, id
+func (_m *UsersDocsRepo) Export(ctx context.Context, id domain.UserID) ([]map[string]interface{}, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Insert")
+		panic("no return value specified for Export")
 	}
 
-	var r0 domain.UserID
+	var r0 []map[string]interface{}
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.UserID, error)); ok {
-		return rf(ctx, name)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) ([]map[string]interface{}, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) domain.UserID); ok {
-		r0 = rf(ctx, name)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) []map[string]interface{}); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r0 = ret.Get(0).(domain.UserID)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]map[string]interface{})
+		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, name)
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -50,6 +54,53 @@ func (_m *UsersDocsRepo) Insert(ctx context.Context, name string) (domain.UserID
 	return r0, r1
 }
 
+// UsersDocsRepo_Export_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Export'
+type UsersDocsRepo_Export_Call struct {
+	*mock.Call
+}
+
+// Export is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersDocsRepo_Expecter) Export(ctx interface{}, id interface{}) *UsersDocsRepo_Export_Call {
+	return &UsersDocsRepo_Export_Call{Call: _e.mock.On("Export", ctx, id)}
+}
+
+func (_c *UsersDocsRepo_Export_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersDocsRepo_Export_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersDocsRepo_Export_Call) Return(_a0 []map[string]interface{}, _a1 error) *UsersDocsRepo_Export_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersDocsRepo_Export_Call) RunAndReturn(run func(context.Context, domain.UserID) ([]map[string]interface{}, error)) *UsersDocsRepo_Export_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Insert provides a mock function with given fields: ctx, id, name
+func (_m *UsersDocsRepo) Insert(ctx context.Context, id domain.UserID, name string) error {
+	ret := _m.Called(ctx, id, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Insert")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string) error); ok {
+		r0 = rf(ctx, id, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UsersDocsRepo_Insert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Insert'
 type UsersDocsRepo_Insert_Call struct {
 	*mock.Call
@@ -57,24 +108,226 @@ type UsersDocsRepo_Insert_Call struct {
 
 // Insert is a helper method to define mock.On call
 //   - ctx context.Context
+//   - id domain.UserID
 //   - name string
-func (_e *UsersDocsRepo_Expecter) Insert(ctx interface{}, name interface{}) *UsersDocsRepo_Insert_Call {
-	return &UsersDocsRepo_Insert_Call{Call: _e.mock.On("Insert", ctx, name)}
+func (_e *UsersDocsRepo_Expecter) Insert(ctx interface{}, id interface{}, name interface{}) *UsersDocsRepo_Insert_Call {
+	return &UsersDocsRepo_Insert_Call{Call: _e.mock.On("Insert", ctx, id, name)}
+}
+
+func (_c *UsersDocsRepo_Insert_Call) Run(run func(ctx context.Context, id domain.UserID, name string)) *UsersDocsRepo_Insert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *UsersDocsRepo_Insert_Call) Return(_a0 error) *UsersDocsRepo_Insert_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersDocsRepo_Insert_Call) RunAndReturn(run func(context.Context, domain.UserID, string) error) *UsersDocsRepo_Insert_Call {
+	_c.Call.Return(run)
+	return _c
 }
 
-func (_c *UsersDocsRepo_Insert_Call) Run(run func(ctx context.Context, name string)) *UsersDocsRepo_Insert_Call {
+// Purge provides a mock function with given fields: ctx, id
+func (_m *UsersDocsRepo) Purge(ctx context.Context, id domain.UserID) (int64, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Purge")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (int64, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) int64); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersDocsRepo_Purge_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Purge'
+type UsersDocsRepo_Purge_Call struct {
+	*mock.Call
+}
+
+// Purge is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersDocsRepo_Expecter) Purge(ctx interface{}, id interface{}) *UsersDocsRepo_Purge_Call {
+	return &UsersDocsRepo_Purge_Call{Call: _e.mock.On("Purge", ctx, id)}
+}
+
+func (_c *UsersDocsRepo_Purge_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersDocsRepo_Purge_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string))
+		run(args[0].(context.Context), args[1].(domain.UserID))
 	})
 	return _c
 }
 
-func (_c *UsersDocsRepo_Insert_Call) Return(_a0 domain.UserID, _a1 error) *UsersDocsRepo_Insert_Call {
+func (_c *UsersDocsRepo_Purge_Call) Return(_a0 int64, _a1 error) *UsersDocsRepo_Purge_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *UsersDocsRepo_Insert_Call) RunAndReturn(run func(context.Context, string) (domain.UserID, error)) *UsersDocsRepo_Insert_Call {
+func (_c *UsersDocsRepo_Purge_Call) RunAndReturn(run func(context.Context, domain.UserID) (int64, error)) *UsersDocsRepo_Purge_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Restore provides a mock function with given fields: ctx, id
+func (_m *UsersDocsRepo) Restore(ctx context.Context, id domain.UserID) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsersDocsRepo_Restore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Restore'
+type UsersDocsRepo_Restore_Call struct {
+	*mock.Call
+}
+
+// Restore is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersDocsRepo_Expecter) Restore(ctx interface{}, id interface{}) *UsersDocsRepo_Restore_Call {
+	return &UsersDocsRepo_Restore_Call{Call: _e.mock.On("Restore", ctx, id)}
+}
+
+func (_c *UsersDocsRepo_Restore_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersDocsRepo_Restore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersDocsRepo_Restore_Call) Return(_a0 error) *UsersDocsRepo_Restore_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersDocsRepo_Restore_Call) RunAndReturn(run func(context.Context, domain.UserID) error) *UsersDocsRepo_Restore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SoftDelete provides a mock function with given fields: ctx, id, at
+func (_m *UsersDocsRepo) SoftDelete(ctx context.Context, id domain.UserID, at time.Time) error {
+	ret := _m.Called(ctx, id, at)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SoftDelete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, time.Time) error); ok {
+		r0 = rf(ctx, id, at)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsersDocsRepo_SoftDelete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SoftDelete'
+type UsersDocsRepo_SoftDelete_Call struct {
+	*mock.Call
+}
+
+// SoftDelete is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - at time.Time
+func (_e *UsersDocsRepo_Expecter) SoftDelete(ctx interface{}, id interface{}, at interface{}) *UsersDocsRepo_SoftDelete_Call {
+	return &UsersDocsRepo_SoftDelete_Call{Call: _e.mock.On("SoftDelete", ctx, id, at)}
+}
+
+func (_c *UsersDocsRepo_SoftDelete_Call) Run(run func(ctx context.Context, id domain.UserID, at time.Time)) *UsersDocsRepo_SoftDelete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *UsersDocsRepo_SoftDelete_Call) Return(_a0 error) *UsersDocsRepo_SoftDelete_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersDocsRepo_SoftDelete_Call) RunAndReturn(run func(context.Context, domain.UserID, time.Time) error) *UsersDocsRepo_SoftDelete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, id, name, version
+func (_m *UsersDocsRepo) Update(ctx context.Context, id domain.UserID, name string, version int64) error {
+	ret := _m.Called(ctx, id, name, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string, int64) error); ok {
+		r0 = rf(ctx, id, name, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsersDocsRepo_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type UsersDocsRepo_Update_Call struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - name string
+//   - version int64
+func (_e *UsersDocsRepo_Expecter) Update(ctx interface{}, id interface{}, name interface{}, version interface{}) *UsersDocsRepo_Update_Call {
+	return &UsersDocsRepo_Update_Call{Call: _e.mock.On("Update", ctx, id, name, version)}
+}
+
+func (_c *UsersDocsRepo_Update_Call) Run(run func(ctx context.Context, id domain.UserID, name string, version int64)) *UsersDocsRepo_Update_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(string), args[3].(int64))
+	})
+	return _c
+}
+
+func (_c *UsersDocsRepo_Update_Call) Return(_a0 error) *UsersDocsRepo_Update_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersDocsRepo_Update_Call) RunAndReturn(run func(context.Context, domain.UserID, string, int64) error) *UsersDocsRepo_Update_Call {
 	_c.Call.Return(run)
 	return _c
 }
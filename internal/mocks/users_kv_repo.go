@@ -22,27 +22,27 @@ func (_m *UsersKVRepo) EXPECT() *UsersKVRepo_Expecter {
 	return &UsersKVRepo_Expecter{mock: &_m.Mock}
 }
 
-// Set provides a mock function with given fields: ctx, name
-func (_m *UsersKVRepo) Set(ctx context.Context, name string) (domain.UserID, error) {
-	ret := _m.Called(ctx, name)
+// Delete provides a mock function with given fields: ctx, id
+func (_m *UsersKVRepo) Delete(ctx context.Context, id domain.UserID) (int64, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Set")
+		panic("no return value specified for Delete")
 	}
 
-	var r0 domain.UserID
+	var r0 int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.UserID, error)); ok {
-		return rf(ctx, name)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (int64, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) domain.UserID); ok {
-		r0 = rf(ctx, name)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) int64); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r0 = ret.Get(0).(domain.UserID)
+		r0 = ret.Get(0).(int64)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, name)
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -50,6 +50,112 @@ func (_m *UsersKVRepo) Set(ctx context.Context, name string) (domain.UserID, err
 	return r0, r1
 }
 
+// UsersKVRepo_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type UsersKVRepo_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersKVRepo_Expecter) Delete(ctx interface{}, id interface{}) *UsersKVRepo_Delete_Call {
+	return &UsersKVRepo_Delete_Call{Call: _e.mock.On("Delete", ctx, id)}
+}
+
+func (_c *UsersKVRepo_Delete_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersKVRepo_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersKVRepo_Delete_Call) Return(_a0 int64, _a1 error) *UsersKVRepo_Delete_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersKVRepo_Delete_Call) RunAndReturn(run func(context.Context, domain.UserID) (int64, error)) *UsersKVRepo_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Export provides a mock function with given fields: ctx, id
+func (_m *UsersKVRepo) Export(ctx context.Context, id domain.UserID) (map[string]interface{}, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Export")
+	}
+
+	var r0 map[string]interface{}
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (map[string]interface{}, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) map[string]interface{}); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]interface{})
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersKVRepo_Export_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Export'
+type UsersKVRepo_Export_Call struct {
+	*mock.Call
+}
+
+// Export is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersKVRepo_Expecter) Export(ctx interface{}, id interface{}) *UsersKVRepo_Export_Call {
+	return &UsersKVRepo_Export_Call{Call: _e.mock.On("Export", ctx, id)}
+}
+
+func (_c *UsersKVRepo_Export_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersKVRepo_Export_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersKVRepo_Export_Call) Return(_a0 map[string]interface{}, _a1 error) *UsersKVRepo_Export_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersKVRepo_Export_Call) RunAndReturn(run func(context.Context, domain.UserID) (map[string]interface{}, error)) *UsersKVRepo_Export_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Set provides a mock function with given fields: ctx, id, name
+func (_m *UsersKVRepo) Set(ctx context.Context, id domain.UserID, name string) error {
+	ret := _m.Called(ctx, id, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Set")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string) error); ok {
+		r0 = rf(ctx, id, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UsersKVRepo_Set_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Set'
 type UsersKVRepo_Set_Call struct {
 	*mock.Call
@@ -57,24 +163,73 @@ type UsersKVRepo_Set_Call struct {
 
 // Set is a helper method to define mock.On call
 //   - ctx context.Context
+//   - id domain.UserID
 //   - name string
-func (_e *UsersKVRepo_Expecter) Set(ctx interface{}, name interface{}) *UsersKVRepo_Set_Call {
-	return &UsersKVRepo_Set_Call{Call: _e.mock.On("Set", ctx, name)}
+func (_e *UsersKVRepo_Expecter) Set(ctx interface{}, id interface{}, name interface{}) *UsersKVRepo_Set_Call {
+	return &UsersKVRepo_Set_Call{Call: _e.mock.On("Set", ctx, id, name)}
 }
 
-func (_c *UsersKVRepo_Set_Call) Run(run func(ctx context.Context, name string)) *UsersKVRepo_Set_Call {
+func (_c *UsersKVRepo_Set_Call) Run(run func(ctx context.Context, id domain.UserID, name string)) *UsersKVRepo_Set_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string))
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(string))
 	})
 	return _c
 }
 
-func (_c *UsersKVRepo_Set_Call) Return(_a0 domain.UserID, _a1 error) *UsersKVRepo_Set_Call {
-	_c.Call.Return(_a0, _a1)
+func (_c *UsersKVRepo_Set_Call) Return(_a0 error) *UsersKVRepo_Set_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *UsersKVRepo_Set_Call) RunAndReturn(run func(context.Context, domain.UserID, string) error) *UsersKVRepo_Set_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, id, name
+func (_m *UsersKVRepo) Update(ctx context.Context, id domain.UserID, name string) error {
+	ret := _m.Called(ctx, id, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string) error); ok {
+		r0 = rf(ctx, id, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsersKVRepo_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type UsersKVRepo_Update_Call struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - name string
+func (_e *UsersKVRepo_Expecter) Update(ctx interface{}, id interface{}, name interface{}) *UsersKVRepo_Update_Call {
+	return &UsersKVRepo_Update_Call{Call: _e.mock.On("Update", ctx, id, name)}
+}
+
+func (_c *UsersKVRepo_Update_Call) Run(run func(ctx context.Context, id domain.UserID, name string)) *UsersKVRepo_Update_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *UsersKVRepo_Update_Call) Return(_a0 error) *UsersKVRepo_Update_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *UsersKVRepo_Set_Call) RunAndReturn(run func(context.Context, string) (domain.UserID, error)) *UsersKVRepo_Set_Call {
+func (_c *UsersKVRepo_Update_Call) RunAndReturn(run func(context.Context, domain.UserID, string) error) *UsersKVRepo_Update_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,209 @@
+// Code generated by mockery v2.43.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "ws-dummy-go/internal/dummy/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// UsersProfilesRepo is an autogenerated mock type for the UsersProfilesRepo type
+type UsersProfilesRepo struct {
+	mock.Mock
+}
+
+type UsersProfilesRepo_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *UsersProfilesRepo) EXPECT() *UsersProfilesRepo_Expecter {
+	return &UsersProfilesRepo_Expecter{mock: &_m.Mock}
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *UsersProfilesRepo) Delete(ctx context.Context, id domain.UserID) (int64, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (int64, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) int64); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersProfilesRepo_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type UsersProfilesRepo_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersProfilesRepo_Expecter) Delete(ctx interface{}, id interface{}) *UsersProfilesRepo_Delete_Call {
+	return &UsersProfilesRepo_Delete_Call{Call: _e.mock.On("Delete", ctx, id)}
+}
+
+func (_c *UsersProfilesRepo_Delete_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersProfilesRepo_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersProfilesRepo_Delete_Call) Return(_a0 int64, _a1 error) *UsersProfilesRepo_Delete_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersProfilesRepo_Delete_Call) RunAndReturn(run func(context.Context, domain.UserID) (int64, error)) *UsersProfilesRepo_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Get provides a mock function with given fields: ctx, id
+func (_m *UsersProfilesRepo) Get(ctx context.Context, id domain.UserID) (domain.Profile, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 domain.Profile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.Profile, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.Profile); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Profile)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersProfilesRepo_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type UsersProfilesRepo_Get_Call struct {
+	*mock.Call
+}
+
+// Get is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersProfilesRepo_Expecter) Get(ctx interface{}, id interface{}) *UsersProfilesRepo_Get_Call {
+	return &UsersProfilesRepo_Get_Call{Call: _e.mock.On("Get", ctx, id)}
+}
+
+func (_c *UsersProfilesRepo_Get_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersProfilesRepo_Get_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersProfilesRepo_Get_Call) Return(_a0 domain.Profile, _a1 error) *UsersProfilesRepo_Get_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersProfilesRepo_Get_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.Profile, error)) *UsersProfilesRepo_Get_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Save provides a mock function with given fields: ctx, read, data
+func (_m *UsersProfilesRepo) Save(ctx context.Context, read domain.Profile, data map[string]interface{}) (domain.Profile, error) {
+	ret := _m.Called(ctx, read, data)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Save")
+	}
+
+	var r0 domain.Profile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Profile, map[string]interface{}) (domain.Profile, error)); ok {
+		return rf(ctx, read, data)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Profile, map[string]interface{}) domain.Profile); ok {
+		r0 = rf(ctx, read, data)
+	} else {
+		r0 = ret.Get(0).(domain.Profile)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.Profile, map[string]interface{}) error); ok {
+		r1 = rf(ctx, read, data)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersProfilesRepo_Save_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Save'
+type UsersProfilesRepo_Save_Call struct {
+	*mock.Call
+}
+
+// Save is a helper method to define mock.On call
+//   - ctx context.Context
+//   - read domain.Profile
+//   - data map[string]interface{}
+func (_e *UsersProfilesRepo_Expecter) Save(ctx interface{}, read interface{}, data interface{}) *UsersProfilesRepo_Save_Call {
+	return &UsersProfilesRepo_Save_Call{Call: _e.mock.On("Save", ctx, read, data)}
+}
+
+func (_c *UsersProfilesRepo_Save_Call) Run(run func(ctx context.Context, read domain.Profile, data map[string]interface{})) *UsersProfilesRepo_Save_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.Profile), args[2].(map[string]interface{}))
+	})
+	return _c
+}
+
+func (_c *UsersProfilesRepo_Save_Call) Return(_a0 domain.Profile, _a1 error) *UsersProfilesRepo_Save_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersProfilesRepo_Save_Call) RunAndReturn(run func(context.Context, domain.Profile, map[string]interface{}) (domain.Profile, error)) *UsersProfilesRepo_Save_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewUsersProfilesRepo creates a new instance of UsersProfilesRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUsersProfilesRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UsersProfilesRepo {
+	mock := &UsersProfilesRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
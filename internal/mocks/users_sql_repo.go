@@ -7,6 +7,8 @@ import (
 	domain "ws-dummy-go/internal/dummy/domain"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // UsersSQLRepo is an autogenerated mock type for the UsersSQLRepo type
@@ -22,6 +24,235 @@ func (_m *UsersSQLRepo) EXPECT() *UsersSQLRepo_Expecter {
 	return &UsersSQLRepo_Expecter{mock: &_m.Mock}
 }
 
+// CompleteErasure provides a mock function with given fields: ctx, e
+func (_m *UsersSQLRepo) CompleteErasure(ctx context.Context, e domain.UserErasure) (domain.UserErasure, error) {
+	ret := _m.Called(ctx, e)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteErasure")
+	}
+
+	var r0 domain.UserErasure
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserErasure) (domain.UserErasure, error)); ok {
+		return rf(ctx, e)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserErasure) domain.UserErasure); ok {
+		r0 = rf(ctx, e)
+	} else {
+		r0 = ret.Get(0).(domain.UserErasure)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserErasure) error); ok {
+		r1 = rf(ctx, e)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_CompleteErasure_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompleteErasure'
+type UsersSQLRepo_CompleteErasure_Call struct {
+	*mock.Call
+}
+
+// CompleteErasure is a helper method to define mock.On call
+//   - ctx context.Context
+//   - e domain.UserErasure
+func (_e *UsersSQLRepo_Expecter) CompleteErasure(ctx interface{}, e interface{}) *UsersSQLRepo_CompleteErasure_Call {
+	return &UsersSQLRepo_CompleteErasure_Call{Call: _e.mock.On("CompleteErasure", ctx, e)}
+}
+
+func (_c *UsersSQLRepo_CompleteErasure_Call) Run(run func(ctx context.Context, e domain.UserErasure)) *UsersSQLRepo_CompleteErasure_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserErasure))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_CompleteErasure_Call) Return(_a0 domain.UserErasure, _a1 error) *UsersSQLRepo_CompleteErasure_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_CompleteErasure_Call) RunAndReturn(run func(context.Context, domain.UserErasure) (domain.UserErasure, error)) *UsersSQLRepo_CompleteErasure_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Delete provides a mock function with given fields: ctx, id, match
+func (_m *UsersSQLRepo) Delete(ctx context.Context, id domain.UserID, match domain.VersionMatch) (domain.User, error) {
+	ret := _m.Called(ctx, id, match)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, domain.VersionMatch) (domain.User, error)); ok {
+		return rf(ctx, id, match)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, domain.VersionMatch) domain.User); ok {
+		r0 = rf(ctx, id, match)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID, domain.VersionMatch) error); ok {
+		r1 = rf(ctx, id, match)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type UsersSQLRepo_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - match domain.VersionMatch
+func (_e *UsersSQLRepo_Expecter) Delete(ctx interface{}, id interface{}, match interface{}) *UsersSQLRepo_Delete_Call {
+	return &UsersSQLRepo_Delete_Call{Call: _e.mock.On("Delete", ctx, id, match)}
+}
+
+func (_c *UsersSQLRepo_Delete_Call) Run(run func(ctx context.Context, id domain.UserID, match domain.VersionMatch)) *UsersSQLRepo_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(domain.VersionMatch))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_Delete_Call) Return(_a0 domain.User, _a1 error) *UsersSQLRepo_Delete_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_Delete_Call) RunAndReturn(run func(context.Context, domain.UserID, domain.VersionMatch) (domain.User, error)) *UsersSQLRepo_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Get provides a mock function with given fields: ctx, id
+func (_m *UsersSQLRepo) Get(ctx context.Context, id domain.UserID) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type UsersSQLRepo_Get_Call struct {
+	*mock.Call
+}
+
+// Get is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersSQLRepo_Expecter) Get(ctx interface{}, id interface{}) *UsersSQLRepo_Get_Call {
+	return &UsersSQLRepo_Get_Call{Call: _e.mock.On("Get", ctx, id)}
+}
+
+func (_c *UsersSQLRepo_Get_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersSQLRepo_Get_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_Get_Call) Return(_a0 domain.User, _a1 error) *UsersSQLRepo_Get_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_Get_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.User, error)) *UsersSQLRepo_Get_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetAny provides a mock function with given fields: ctx, id
+func (_m *UsersSQLRepo) GetAny(ctx context.Context, id domain.UserID) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAny")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_GetAny_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetAny'
+type UsersSQLRepo_GetAny_Call struct {
+	*mock.Call
+}
+
+// GetAny is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersSQLRepo_Expecter) GetAny(ctx interface{}, id interface{}) *UsersSQLRepo_GetAny_Call {
+	return &UsersSQLRepo_GetAny_Call{Call: _e.mock.On("GetAny", ctx, id)}
+}
+
+func (_c *UsersSQLRepo_GetAny_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersSQLRepo_GetAny_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_GetAny_Call) Return(_a0 domain.User, _a1 error) *UsersSQLRepo_GetAny_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_GetAny_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.User, error)) *UsersSQLRepo_GetAny_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Insert provides a mock function with given fields: ctx, name
 func (_m *UsersSQLRepo) Insert(ctx context.Context, name string) (domain.UserID, error) {
 	ret := _m.Called(ctx, name)
@@ -79,6 +310,474 @@ func (_c *UsersSQLRepo_Insert_Call) RunAndReturn(run func(context.Context, strin
 	return _c
 }
 
+// List provides a mock function with given fields: ctx, q
+func (_m *UsersSQLRepo) List(ctx context.Context, q domain.ListUsersQuery) ([]domain.User, error) {
+	ret := _m.Called(ctx, q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.ListUsersQuery) ([]domain.User, error)); ok {
+		return rf(ctx, q)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.ListUsersQuery) []domain.User); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.ListUsersQuery) error); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type UsersSQLRepo_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - ctx context.Context
+//   - q domain.ListUsersQuery
+func (_e *UsersSQLRepo_Expecter) List(ctx interface{}, q interface{}) *UsersSQLRepo_List_Call {
+	return &UsersSQLRepo_List_Call{Call: _e.mock.On("List", ctx, q)}
+}
+
+func (_c *UsersSQLRepo_List_Call) Run(run func(ctx context.Context, q domain.ListUsersQuery)) *UsersSQLRepo_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.ListUsersQuery))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_List_Call) Return(_a0 []domain.User, _a1 error) *UsersSQLRepo_List_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_List_Call) RunAndReturn(run func(context.Context, domain.ListUsersQuery) ([]domain.User, error)) *UsersSQLRepo_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListExpired provides a mock function with given fields: ctx, deletedBefore, limit
+func (_m *UsersSQLRepo) ListExpired(ctx context.Context, deletedBefore time.Time, limit int) ([]domain.User, error) {
+	ret := _m.Called(ctx, deletedBefore, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListExpired")
+	}
+
+	var r0 []domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]domain.User, error)); ok {
+		return rf(ctx, deletedBefore, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []domain.User); ok {
+		r0 = rf(ctx, deletedBefore, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, deletedBefore, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_ListExpired_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListExpired'
+type UsersSQLRepo_ListExpired_Call struct {
+	*mock.Call
+}
+
+// ListExpired is a helper method to define mock.On call
+//   - ctx context.Context
+//   - deletedBefore time.Time
+//   - limit int
+func (_e *UsersSQLRepo_Expecter) ListExpired(ctx interface{}, deletedBefore interface{}, limit interface{}) *UsersSQLRepo_ListExpired_Call {
+	return &UsersSQLRepo_ListExpired_Call{Call: _e.mock.On("ListExpired", ctx, deletedBefore, limit)}
+}
+
+func (_c *UsersSQLRepo_ListExpired_Call) Run(run func(ctx context.Context, deletedBefore time.Time, limit int)) *UsersSQLRepo_ListExpired_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_ListExpired_Call) Return(_a0 []domain.User, _a1 error) *UsersSQLRepo_ListExpired_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_ListExpired_Call) RunAndReturn(run func(context.Context, time.Time, int) ([]domain.User, error)) *UsersSQLRepo_ListExpired_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListPendingErasures provides a mock function with given fields: ctx, limit
+func (_m *UsersSQLRepo) ListPendingErasures(ctx context.Context, limit int) ([]domain.UserErasure, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListPendingErasures")
+	}
+
+	var r0 []domain.UserErasure
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]domain.UserErasure, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []domain.UserErasure); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.UserErasure)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_ListPendingErasures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListPendingErasures'
+type UsersSQLRepo_ListPendingErasures_Call struct {
+	*mock.Call
+}
+
+// ListPendingErasures is a helper method to define mock.On call
+//   - ctx context.Context
+//   - limit int
+func (_e *UsersSQLRepo_Expecter) ListPendingErasures(ctx interface{}, limit interface{}) *UsersSQLRepo_ListPendingErasures_Call {
+	return &UsersSQLRepo_ListPendingErasures_Call{Call: _e.mock.On("ListPendingErasures", ctx, limit)}
+}
+
+func (_c *UsersSQLRepo_ListPendingErasures_Call) Run(run func(ctx context.Context, limit int)) *UsersSQLRepo_ListPendingErasures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_ListPendingErasures_Call) Return(_a0 []domain.UserErasure, _a1 error) *UsersSQLRepo_ListPendingErasures_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_ListPendingErasures_Call) RunAndReturn(run func(context.Context, int) ([]domain.UserErasure, error)) *UsersSQLRepo_ListPendingErasures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Purge provides a mock function with given fields: ctx, p
+func (_m *UsersSQLRepo) Purge(ctx context.Context, p domain.UserPurge) (bool, error) {
+	ret := _m.Called(ctx, p)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Purge")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserPurge) (bool, error)); ok {
+		return rf(ctx, p)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserPurge) bool); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserPurge) error); ok {
+		r1 = rf(ctx, p)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_Purge_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Purge'
+type UsersSQLRepo_Purge_Call struct {
+	*mock.Call
+}
+
+// Purge is a helper method to define mock.On call
+//   - ctx context.Context
+//   - p domain.UserPurge
+func (_e *UsersSQLRepo_Expecter) Purge(ctx interface{}, p interface{}) *UsersSQLRepo_Purge_Call {
+	return &UsersSQLRepo_Purge_Call{Call: _e.mock.On("Purge", ctx, p)}
+}
+
+func (_c *UsersSQLRepo_Purge_Call) Run(run func(ctx context.Context, p domain.UserPurge)) *UsersSQLRepo_Purge_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserPurge))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_Purge_Call) Return(_a0 bool, _a1 error) *UsersSQLRepo_Purge_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_Purge_Call) RunAndReturn(run func(context.Context, domain.UserPurge) (bool, error)) *UsersSQLRepo_Purge_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Restore provides a mock function with given fields: ctx, id, deletedAfter
+func (_m *UsersSQLRepo) Restore(ctx context.Context, id domain.UserID, deletedAfter time.Time) (domain.User, error) {
+	ret := _m.Called(ctx, id, deletedAfter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, time.Time) (domain.User, error)); ok {
+		return rf(ctx, id, deletedAfter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, time.Time) domain.User); ok {
+		r0 = rf(ctx, id, deletedAfter)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID, time.Time) error); ok {
+		r1 = rf(ctx, id, deletedAfter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_Restore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Restore'
+type UsersSQLRepo_Restore_Call struct {
+	*mock.Call
+}
+
+// Restore is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - deletedAfter time.Time
+func (_e *UsersSQLRepo_Expecter) Restore(ctx interface{}, id interface{}, deletedAfter interface{}) *UsersSQLRepo_Restore_Call {
+	return &UsersSQLRepo_Restore_Call{Call: _e.mock.On("Restore", ctx, id, deletedAfter)}
+}
+
+func (_c *UsersSQLRepo_Restore_Call) Run(run func(ctx context.Context, id domain.UserID, deletedAfter time.Time)) *UsersSQLRepo_Restore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_Restore_Call) Return(_a0 domain.User, _a1 error) *UsersSQLRepo_Restore_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_Restore_Call) RunAndReturn(run func(context.Context, domain.UserID, time.Time) (domain.User, error)) *UsersSQLRepo_Restore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Search provides a mock function with given fields: ctx, q
+func (_m *UsersSQLRepo) Search(ctx context.Context, q domain.SearchUsersQuery) ([]domain.UserMatch, error) {
+	ret := _m.Called(ctx, q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Search")
+	}
+
+	var r0 []domain.UserMatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.SearchUsersQuery) ([]domain.UserMatch, error)); ok {
+		return rf(ctx, q)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.SearchUsersQuery) []domain.UserMatch); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.UserMatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.SearchUsersQuery) error); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_Search_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Search'
+type UsersSQLRepo_Search_Call struct {
+	*mock.Call
+}
+
+// Search is a helper method to define mock.On call
+//   - ctx context.Context
+//   - q domain.SearchUsersQuery
+func (_e *UsersSQLRepo_Expecter) Search(ctx interface{}, q interface{}) *UsersSQLRepo_Search_Call {
+	return &UsersSQLRepo_Search_Call{Call: _e.mock.On("Search", ctx, q)}
+}
+
+func (_c *UsersSQLRepo_Search_Call) Run(run func(ctx context.Context, q domain.SearchUsersQuery)) *UsersSQLRepo_Search_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.SearchUsersQuery))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_Search_Call) Return(_a0 []domain.UserMatch, _a1 error) *UsersSQLRepo_Search_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_Search_Call) RunAndReturn(run func(context.Context, domain.SearchUsersQuery) ([]domain.UserMatch, error)) *UsersSQLRepo_Search_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// StartErasure provides a mock function with given fields: ctx, id
+func (_m *UsersSQLRepo) StartErasure(ctx context.Context, id domain.UserID) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StartErasure")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) (domain.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_StartErasure_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StartErasure'
+type UsersSQLRepo_StartErasure_Call struct {
+	*mock.Call
+}
+
+// StartErasure is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+func (_e *UsersSQLRepo_Expecter) StartErasure(ctx interface{}, id interface{}) *UsersSQLRepo_StartErasure_Call {
+	return &UsersSQLRepo_StartErasure_Call{Call: _e.mock.On("StartErasure", ctx, id)}
+}
+
+func (_c *UsersSQLRepo_StartErasure_Call) Run(run func(ctx context.Context, id domain.UserID)) *UsersSQLRepo_StartErasure_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_StartErasure_Call) Return(_a0 domain.User, _a1 error) *UsersSQLRepo_StartErasure_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_StartErasure_Call) RunAndReturn(run func(context.Context, domain.UserID) (domain.User, error)) *UsersSQLRepo_StartErasure_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, id, name, match
+func (_m *UsersSQLRepo) Update(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch) (domain.User, error) {
+	ret := _m.Called(ctx, id, name, match)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string, domain.VersionMatch) (domain.User, error)); ok {
+		return rf(ctx, id, name, match)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserID, string, domain.VersionMatch) domain.User); ok {
+		r0 = rf(ctx, id, name, match)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserID, string, domain.VersionMatch) error); ok {
+		r1 = rf(ctx, id, name, match)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersSQLRepo_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type UsersSQLRepo_Update_Call struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id domain.UserID
+//   - name string
+//   - match domain.VersionMatch
+func (_e *UsersSQLRepo_Expecter) Update(ctx interface{}, id interface{}, name interface{}, match interface{}) *UsersSQLRepo_Update_Call {
+	return &UsersSQLRepo_Update_Call{Call: _e.mock.On("Update", ctx, id, name, match)}
+}
+
+func (_c *UsersSQLRepo_Update_Call) Run(run func(ctx context.Context, id domain.UserID, name string, match domain.VersionMatch)) *UsersSQLRepo_Update_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.UserID), args[2].(string), args[3].(domain.VersionMatch))
+	})
+	return _c
+}
+
+func (_c *UsersSQLRepo_Update_Call) Return(_a0 domain.User, _a1 error) *UsersSQLRepo_Update_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *UsersSQLRepo_Update_Call) RunAndReturn(run func(context.Context, domain.UserID, string, domain.VersionMatch) (domain.User, error)) *UsersSQLRepo_Update_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewUsersSQLRepo creates a new instance of UsersSQLRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUsersSQLRepo(t interface {
@@ -0,0 +1,113 @@
+package pii
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"ws-dummy-go/internal/secrets"
+)
+
+// MasterKeys wrap the data keys. The master key of a wrapped key is
+// stored with it, so the active one can change.
+type MasterKeys interface {
+	ActiveID() string
+	Wrap(dataKey []byte) (masterKeyID string, wrapped []byte, err error)
+	Unwrap(masterKeyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeyFile holds the master keys in a local JSON file:
+// {"active": "m2", "keys": {"m1": "<base64>", "m2": "<base64>"}}.
+type KeyFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+
+	keys map[string][]byte
+}
+
+func LoadKeyFile(path string) (*KeyFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	var f KeyFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decoding key file: %w", err)
+	}
+	if err := f.decode(); err != nil {
+		return nil, fmt.Errorf("decoding key file: %w", err)
+	}
+	return &f, nil
+}
+
+// AddKey adds a new random master key and makes it the active one.
+func (f *KeyFile) AddKey(id string) error {
+	if _, ok := f.Keys[id]; ok {
+		return fmt.Errorf("master key %s exists", id)
+	}
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if f.Keys == nil {
+		f.Keys = map[string]string{}
+	}
+	f.Keys[id], f.Active = key, id
+	return f.decode()
+}
+
+// Save writes the file, readable by the owner only.
+func (f *KeyFile) Save(path string) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding key file: %w", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing key file: %w", err)
+	}
+	return nil
+}
+
+func (f *KeyFile) ActiveID() string {
+	return f.Active
+}
+
+func (f *KeyFile) Wrap(dataKey []byte) (string, []byte, error) {
+	wrapped, err := secrets.Seal(f.keys[f.Active], dataKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("wrapping key: %w", err)
+	}
+	return f.Active, wrapped, nil
+}
+
+func (f *KeyFile) Unwrap(masterKeyID string, wrapped []byte) ([]byte, error) {
+	key, ok := f.keys[masterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %s not in the key file", masterKeyID)
+	}
+	dataKey, err := secrets.Open(key, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func (f *KeyFile) decode() error {
+	if _, ok := f.Keys[f.Active]; !ok {
+		return errors.New("no active master key")
+	}
+	f.keys = make(map[string][]byte, len(f.Keys))
+	for id, s := range f.Keys {
+		key, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("master key %s: %w", id, err)
+		}
+		if len(key) != secrets.KeySize {
+			return fmt.Errorf("master key %s must be %d bytes, got %d", id, secrets.KeySize, len(key))
+		}
+		f.keys[id] = key
+	}
+	return nil
+}
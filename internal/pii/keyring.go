@@ -0,0 +1,291 @@
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ws-dummy-go/internal/secrets"
+)
+
+// Purposes of the data keys.
+const (
+	PurposeData  = "data"
+	PurposeIndex = "index"
+)
+
+// indexKeyID is the only blind index key. Changing it would change every
+// index, it isn't rotated.
+const indexKeyID = "index"
+
+// DataKey is a data key wrapped by a master key.
+type DataKey struct {
+	ID          string
+	Purpose     string
+	MasterKeyID string
+	Wrapped     []byte
+	CreatedAt   time.Time
+}
+
+type KeyStore interface {
+	// List returns the keys, the oldest first.
+	List(ctx context.Context) ([]DataKey, error)
+	// Insert does nothing if a key has the ID.
+	Insert(ctx context.Context, k DataKey) error
+	Rewrap(ctx context.Context, id, masterKeyID string, wrapped []byte) error
+}
+
+// Keyring encrypts with the newest data key and decrypts with any. The
+// keys added by another process are loaded when a value needs one.
+type Keyring struct {
+	master MasterKeys
+	store  KeyStore
+
+	mu     sync.RWMutex
+	aeads  map[string]cipher.AEAD
+	active string
+	index  []byte
+}
+
+func NewKeyring(master MasterKeys, store KeyStore) *Keyring {
+	return &Keyring{master: master, store: store}
+}
+
+// OpenKeyring creates the keys if missing and loads them.
+func OpenKeyring(ctx context.Context, master MasterKeys, store KeyStore) (*Keyring, error) {
+	k := NewKeyring(master, store)
+	if err := k.Init(ctx); err != nil {
+		return nil, err
+	}
+	if err := k.Load(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Init creates the blind index key and a first data key, unless stored.
+func (k *Keyring) Init(ctx context.Context) error {
+	keys, err := k.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing keys: %w", err)
+	}
+	var hasData bool
+	for _, dk := range keys {
+		hasData = hasData || dk.Purpose == PurposeData
+	}
+	if err := k.insert(ctx, indexKeyID, PurposeIndex); err != nil {
+		return err
+	}
+	if hasData {
+		return nil
+	}
+	// Two processes may both add one, the newest is used.
+	_, err = k.Rotate(ctx)
+	return err
+}
+
+// Rotate adds a data key, the one encrypting from now on. The values
+// encrypted with the previous ones stay readable.
+func (k *Keyring) Rotate(ctx context.Context) (string, error) {
+	id, err := newKeyID()
+	if err != nil {
+		return "", err
+	}
+	if err := k.insert(ctx, id, PurposeData); err != nil {
+		return "", err
+	}
+	return id, k.Load(ctx)
+}
+
+// Rewrap wraps the data keys again with the active master key, it returns
+// the number of keys rewrapped.
+func (k *Keyring) Rewrap(ctx context.Context) (int, error) {
+	keys, err := k.store.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("listing keys: %w", err)
+	}
+	var n int
+	for _, dk := range keys {
+		if dk.MasterKeyID == k.master.ActiveID() {
+			continue
+		}
+		plain, err := k.master.Unwrap(dk.MasterKeyID, dk.Wrapped)
+		if err != nil {
+			return n, fmt.Errorf("key %s: %w", dk.ID, err)
+		}
+		masterKeyID, wrapped, err := k.master.Wrap(plain)
+		if err != nil {
+			return n, fmt.Errorf("key %s: %w", dk.ID, err)
+		}
+		if err := k.store.Rewrap(ctx, dk.ID, masterKeyID, wrapped); err != nil {
+			return n, fmt.Errorf("rewrapping key %s: %w", dk.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Load reads and unwraps the stored keys.
+func (k *Keyring) Load(ctx context.Context) error {
+	keys, err := k.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing keys: %w", err)
+	}
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	var (
+		active string
+		index  []byte
+	)
+	for _, dk := range keys {
+		plain, err := k.master.Unwrap(dk.MasterKeyID, dk.Wrapped)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", dk.ID, err)
+		}
+		switch dk.Purpose {
+		case PurposeIndex:
+			index = plain
+		case PurposeData:
+			aead, err := newAEAD(plain)
+			if err != nil {
+				return fmt.Errorf("key %s: %w", dk.ID, err)
+			}
+			aeads[dk.ID], active = aead, dk.ID
+		}
+	}
+	if index == nil || active == "" {
+		return errors.New("keys not initialized")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.aeads, k.active, k.index = aeads, active, index
+	return nil
+}
+
+// ActiveID is the data key encrypting the values.
+func (k *Keyring) ActiveID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+func (k *Keyring) Encrypt(field, plaintext string) (string, error) {
+	if isEncrypted(plaintext) {
+		return "", ErrReserved
+	}
+	k.mu.RLock()
+	id, aead := k.active, k.aeads[k.active]
+	k.mu.RUnlock()
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+	return Prefix + id + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) Decrypt(ctx context.Context, field, value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	id, enc, ok := strings.Cut(value[len(Prefix):], ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	aead, err := k.aead(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s with key %s: wrong key or corrupted value", field, id)
+	}
+	return string(plain), nil
+}
+
+func (k *Keyring) BlindIndex(field, plaintext string) string {
+	k.mu.RLock()
+	mac := hmac.New(sha256.New, k.index)
+	k.mu.RUnlock()
+	// The separator keeps the field and the value apart.
+	mac.Write([]byte(field + "\x00" + plaintext))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (k *Keyring) Stale(value string) bool {
+	return !strings.HasPrefix(value, Prefix+k.ActiveID()+":")
+}
+
+func (k *Keyring) Enabled() bool {
+	return true
+}
+
+// aead returns the cipher of the data key, loading the keys if unknown.
+func (k *Keyring) aead(ctx context.Context, id string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	aead, ok := k.aeads[id]
+	k.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+	if err := k.Load(ctx); err != nil {
+		return nil, err
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if aead, ok := k.aeads[id]; ok {
+		return aead, nil
+	}
+	return nil, fmt.Errorf("data key %s not found", id)
+}
+
+func (k *Keyring) insert(ctx context.Context, id, purpose string) error {
+	plain := make([]byte, secrets.KeySize)
+	if _, err := rand.Read(plain); err != nil {
+		return fmt.Errorf("generating key: %w", err)
+	}
+	masterKeyID, wrapped, err := k.master.Wrap(plain)
+	if err != nil {
+		return err
+	}
+	dk := DataKey{ID: id, Purpose: purpose, MasterKeyID: masterKeyID, Wrapped: wrapped}
+	if err := k.store.Insert(ctx, dk); err != nil {
+		return fmt.Errorf("inserting key: %w", err)
+	}
+	return nil
+}
+
+func newKeyID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating key ID: %w", err)
+	}
+	return "d" + hex.EncodeToString(b), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
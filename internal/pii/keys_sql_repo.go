@@ -0,0 +1,92 @@
+package pii
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	// Needed to choose dialect
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var db = goqu.Dialect("postgres")
+
+func NewKeysSQLRepo(p *pgxpool.Pool) KeyStore {
+	return keysSQLRepo{
+		pool: p,
+	}
+}
+
+type keysSQLRepo struct {
+	pool *pgxpool.Pool
+}
+
+func (r keysSQLRepo) List(ctx context.Context) ([]DataKey, error) {
+	q := db.
+		Select("key_id", "purpose", "master_key_id", "wrapped_key", "created_at").
+		From("encryption_keys").
+		Order(goqu.C("created_at").Asc(), goqu.C("key_id").Asc())
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("creating query: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []DataKey
+	for rows.Next() {
+		var k DataKey
+		if err := rows.Scan(&k.ID, &k.Purpose, &k.MasterKeyID, &k.Wrapped, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %w", err)
+	}
+	return keys, nil
+}
+
+func (r keysSQLRepo) Insert(ctx context.Context, k DataKey) error {
+	q := db.
+		Insert("encryption_keys").
+		Rows(goqu.Record{
+			"key_id":        k.ID,
+			"purpose":       k.Purpose,
+			"master_key_id": k.MasterKeyID,
+			"wrapped_key":   k.Wrapped,
+		}).
+		OnConflict(goqu.DoNothing()).
+		Prepared(true)
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return fmt.Errorf("creating query: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, params...); err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+	return nil
+}
+
+func (r keysSQLRepo) Rewrap(ctx context.Context, id, masterKeyID string, wrapped []byte) error {
+	q := db.
+		Update("encryption_keys").
+		Set(goqu.Record{"master_key_id": masterKeyID, "wrapped_key": wrapped}).
+		Where(goqu.C("key_id").Eq(id)).
+		Prepared(true)
+
+	sql, params, err := q.ToSQL()
+	if err != nil {
+		return fmt.Errorf("creating query: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, params...); err != nil {
+		return fmt.Errorf("executing query: %w", err)
+	}
+	return nil
+}
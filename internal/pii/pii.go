@@ -0,0 +1,67 @@
+// Package pii encrypts the personal data stored by the app, field by
+// field, with data keys wrapped by a master key (envelope encryption).
+// Deterministic blind indexes stand in for the encrypted values in
+// equality lookups.
+package pii
+
+import (
+	"context"
+	"errors"
+)
+
+// Prefix starts the encrypted values: enc:v1:<key ID>:<nonce and ciphertext>.
+// The values without it are plaintext, stored before the encryption.
+const Prefix = "enc:v1:"
+
+var ErrReserved = errors.New("plaintext starts with the encrypted value prefix")
+
+// Cipher encrypts the values of a field, the field being authenticated
+// with the value so it can't be moved to another one.
+type Cipher interface {
+	Encrypt(field, plaintext string) (string, error)
+	// Decrypt returns the plaintext values as is.
+	Decrypt(ctx context.Context, field, value string) (string, error)
+	// BlindIndex is the same for the same plaintext of the field.
+	BlindIndex(field, plaintext string) string
+	// Stale tells whether the value isn't encrypted with the active key.
+	Stale(value string) bool
+	// Enabled is false if the values are stored as plaintext.
+	Enabled() bool
+}
+
+// NewPlaintext stores the values as they are, the encryption is off.
+func NewPlaintext() Cipher {
+	return plaintext{}
+}
+
+type plaintext struct{}
+
+func (plaintext) Encrypt(_, plaintext string) (string, error) {
+	if isEncrypted(plaintext) {
+		return "", ErrReserved
+	}
+	return plaintext, nil
+}
+
+func (plaintext) Decrypt(_ context.Context, _, value string) (string, error) {
+	if isEncrypted(value) {
+		return "", errors.New("encrypted value without a keyring")
+	}
+	return value, nil
+}
+
+func (plaintext) BlindIndex(_, plaintext string) string {
+	return plaintext
+}
+
+func (plaintext) Stale(string) bool {
+	return false
+}
+
+func (plaintext) Enabled() bool {
+	return false
+}
+
+func isEncrypted(value string) bool {
+	return len(value) >= len(Prefix) && value[:len(Prefix)] == Prefix
+}
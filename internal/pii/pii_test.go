@@ -0,0 +1,266 @@
+package pii
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memKeyStore is a KeyStore in memory.
+type memKeyStore struct {
+	mu   sync.Mutex
+	keys []DataKey
+}
+
+func (s *memKeyStore) List(context.Context) ([]DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DataKey{}, s.keys...), nil
+}
+
+func (s *memKeyStore) Insert(_ context.Context, k DataKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stored := range s.keys {
+		if stored.ID == k.ID {
+			return nil
+		}
+	}
+	s.keys = append(s.keys, k)
+	return nil
+}
+
+func (s *memKeyStore) Rewrap(_ context.Context, id, masterKeyID string, wrapped []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.keys {
+		if s.keys[i].ID == id {
+			s.keys[i].MasterKeyID, s.keys[i].Wrapped = masterKeyID, wrapped
+		}
+	}
+	return nil
+}
+
+func newTestKeyFile(t *testing.T, ids ...string) *KeyFile {
+	f := &KeyFile{}
+	for _, id := range ids {
+		require.NoError(t, f.AddKey(id))
+	}
+	return f
+}
+
+func TestKeyring_Decrypt(t *testing.T) {
+	ctx := context.Background()
+	k, err := OpenKeyring(ctx, newTestKeyFile(t, "m1"), &memKeyStore{})
+	require.NoError(t, err)
+
+	encrypted, err := k.Encrypt("user.name", "juwis")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encrypted, Prefix+k.ActiveID()+":"), encrypted)
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+
+	tests := []struct {
+		name    string
+		field   string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Positive: Encrypted",
+			field: "user.name",
+			value: encrypted,
+			want:  "juwis",
+		},
+		{
+			name:  "Positive: Plaintext as is",
+			field: "user.name",
+			value: "juwis",
+			want:  "juwis",
+		},
+		{
+			name:    "Negative: Other field",
+			field:   "user.email",
+			value:   encrypted,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Tampered",
+			field:   "user.name",
+			value:   tampered,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Unknown key",
+			field:   "user.name",
+			value:   Prefix + "dnone:" + encrypted[strings.LastIndex(encrypted, ":")+1:],
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Malformed",
+			field:   "user.name",
+			value:   Prefix + "nokey",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Decrypt(ctx, tt.field, tt.value)
+
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeyring_Encrypt(t *testing.T) {
+	k, err := OpenKeyring(context.Background(), newTestKeyFile(t, "m1"), &memKeyStore{})
+	require.NoError(t, err)
+
+	a, err := k.Encrypt("user.name", "juwis")
+	require.NoError(t, err)
+	b, err := k.Encrypt("user.name", "juwis")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b, "random nonces")
+	assert.NotContains(t, a, "juwis")
+
+	_, err = k.Encrypt("user.name", Prefix+"x")
+	assert.ErrorIs(t, err, ErrReserved)
+	_, err = NewPlaintext().Encrypt("user.name", Prefix+"x")
+	assert.ErrorIs(t, err, ErrReserved)
+}
+
+func TestKeyring_BlindIndex(t *testing.T) {
+	k, err := OpenKeyring(context.Background(), newTestKeyFile(t, "m1"), &memKeyStore{})
+	require.NoError(t, err)
+	other, err := OpenKeyring(context.Background(), newTestKeyFile(t, "m1"), &memKeyStore{})
+	require.NoError(t, err)
+
+	bidx := k.BlindIndex("user.name", "juwis")
+	assert.Equal(t, bidx, k.BlindIndex("user.name", "juwis"))
+	assert.NotEqual(t, bidx, k.BlindIndex("user.name", "juwis2"))
+	assert.NotEqual(t, bidx, k.BlindIndex("user.email", "juwis"))
+	assert.NotEqual(t, bidx, other.BlindIndex("user.name", "juwis"), "other index key")
+	assert.NotContains(t, bidx, "juwis")
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	ctx := context.Background()
+	master := newTestKeyFile(t, "m1")
+	store := &memKeyStore{}
+	k, err := OpenKeyring(ctx, master, store)
+	require.NoError(t, err)
+	// Another process, it loads the key rotated by k when it needs it.
+	other, err := OpenKeyring(ctx, master, store)
+	require.NoError(t, err)
+
+	before, err := k.Encrypt("user.name", "juwis")
+	require.NoError(t, err)
+	bidx := k.BlindIndex("user.name", "juwis")
+	oldID := k.ActiveID()
+
+	newID, err := k.Rotate(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldID, newID)
+	assert.Equal(t, newID, k.ActiveID())
+	after, err := k.Encrypt("user.name", "juwis")
+	require.NoError(t, err)
+
+	assert.True(t, k.Stale(before))
+	assert.False(t, k.Stale(after))
+	assert.True(t, k.Stale("juwis"))
+	assert.Equal(t, bidx, k.BlindIndex("user.name", "juwis"), "the index key isn't rotated")
+	for _, value := range []string{before, after} {
+		got, err := other.Decrypt(ctx, "user.name", value)
+		assert.NoError(t, err)
+		assert.Equal(t, "juwis", got)
+	}
+	assert.Equal(t, newID, other.ActiveID())
+
+	// Init again keeps the keys.
+	require.NoError(t, k.Init(ctx))
+	keys, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+}
+
+func TestKeyring_Rewrap(t *testing.T) {
+	ctx := context.Background()
+	master := newTestKeyFile(t, "m1")
+	store := &memKeyStore{}
+	k, err := OpenKeyring(ctx, master, store)
+	require.NoError(t, err)
+	encrypted, err := k.Encrypt("user.name", "juwis")
+	require.NoError(t, err)
+
+	require.NoError(t, master.AddKey("m2"))
+	n, err := k.Rewrap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = k.Rewrap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// The old master key can go.
+	onlyM2 := &KeyFile{Active: "m2", Keys: map[string]string{"m2": master.Keys["m2"]}}
+	require.NoError(t, onlyM2.decode())
+	rewrapped, err := OpenKeyring(ctx, onlyM2, store)
+	require.NoError(t, err)
+	got, err := rewrapped.Decrypt(ctx, "user.name", encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "juwis", got)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := newTestKeyFile(t, "m1")
+	require.NoError(t, valid.Save(filepath.Join(dir, "valid.json")))
+	for name, content := range map[string]string{
+		"no_active.json": `{"active": "m2", "keys": {"m1": "` + valid.Keys["m1"] + `"}}`,
+		"short.json":     `{"active": "m1", "keys": {"m1": "c2hvcnQ="}}`,
+		"base64.json":    `{"active": "m1", "keys": {"m1": "!!"}}`,
+		"json.json":      `{"active": `,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{name: "Positive: Valid", file: "valid.json"},
+		{name: "Negative: Missing", file: "missing.json", wantErr: true},
+		{name: "Negative: Active key missing", file: "no_active.json", wantErr: true},
+		{name: "Negative: Short key", file: "short.json", wantErr: true},
+		{name: "Negative: Not base64", file: "base64.json", wantErr: true},
+		{name: "Negative: Not JSON", file: "json.json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadKeyFile(filepath.Join(dir, tt.file))
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "m1", got.ActiveID())
+			_, wrapped, err := got.Wrap([]byte("data key"))
+			require.NoError(t, err)
+			plain, err := valid.Unwrap("m1", wrapped)
+			require.NoError(t, err)
+			assert.Equal(t, "data key", string(plain))
+		})
+	}
+}
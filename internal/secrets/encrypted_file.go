@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const KeySize = 32 // AES-256
+
+// EncryptedFileProvider reads secrets from a JSON object {"NAME": "value"}
+// encrypted with AES-256-GCM. The file is decrypted again when it changes,
+// so rotation is a matter of replacing the file.
+type EncryptedFileProvider struct {
+	path string
+	key  []byte
+
+	mu      sync.Mutex
+	modTime time.Time
+	values  map[string]string
+}
+
+func NewEncryptedFileProvider(path string, key []byte) *EncryptedFileProvider {
+	return &EncryptedFileProvider{path: path, key: key}
+}
+
+func (p *EncryptedFileProvider) Get(_ context.Context, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return "", err
+	}
+	v, ok := p.values[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return v, nil
+}
+
+// Names lists the secrets in the file.
+func (p *EncryptedFileProvider) Names() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(p.values))
+	for k := range p.values {
+		names = append(names, k)
+	}
+	return names, nil
+}
+
+func (p *EncryptedFileProvider) load() error {
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("reading secrets file: %w", err)
+	}
+	if p.values != nil && fi.ModTime().Equal(p.modTime) {
+		return nil
+	}
+	sealed, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("reading secrets file: %w", err)
+	}
+	plain, err := Open(p.key, sealed)
+	if err != nil {
+		return fmt.Errorf("decrypting secrets file: %w", err)
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return fmt.Errorf("decoding secrets file: %w", err)
+	}
+	p.values, p.modTime = values, fi.ModTime()
+	return nil
+}
+
+// Seal encrypts the plaintext, the random nonce is prepended to the result.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts what Seal has encrypted.
+func Open(key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("wrong key or corrupted ciphertext")
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateKey returns a new random key, base64 encoded as stored in key files.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generating key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ReadKeyFile reads a base64 encoded key.
+func ReadKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding key file: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
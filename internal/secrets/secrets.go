@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var ErrNotFound = errors.New("secret not found")
+
+// Provider is a source of secrets, e.g. mounted files, an encrypted file or a vault.
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// fileProvider reads each secret from its own file, the name being the path.
+// This is how Docker and Kubernetes mount secrets.
+type fileProvider struct{}
+
+func NewFileProvider() Provider {
+	return fileProvider{}
+}
+
+func (fileProvider) Get(_ context.Context, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+	// Editors and `echo` add a trailing newline, it's never part of the secret.
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Secret is a value resolved from a provider. Refresh re-reads it, so rotated
+// credentials are picked up by the clients that call Value.
+type Secret struct {
+	Key      string // config key, e.g. POSTGRES_PASSWORD
+	provider Provider
+	name     string
+
+	mu    sync.RWMutex
+	value string
+}
+
+func NewSecret(key string, provider Provider, name string) *Secret {
+	return &Secret{Key: key, provider: provider, name: name}
+}
+
+func (s *Secret) Value() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value
+}
+
+// Refresh fetches the secret and tells whether it has changed.
+// On error the current value is kept.
+func (s *Secret) Refresh(ctx context.Context) (bool, error) {
+	v, err := s.provider.Get(ctx, s.name)
+	if err != nil {
+		return false, fmt.Errorf("resolving %s: %w", s.Key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.value != v
+	s.value = v
+	return changed, nil
+}
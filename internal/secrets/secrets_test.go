@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) []byte {
+	s, err := GenerateKey()
+	require.NoError(t, err)
+	key, err := base64.StdEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return key
+}
+
+func writeSealed(t *testing.T, path string, key []byte, plain string, modTime time.Time) {
+	sealed, err := Seal(key, []byte(plain))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, sealed, 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestOpen(t *testing.T) {
+	key := newTestKey(t)
+	sealed, err := Seal(key, []byte("s3cr3t"))
+	require.NoError(t, err)
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		key     []byte
+		sealed  []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Positive: Round trip",
+			key:    key,
+			sealed: sealed,
+			want:   "s3cr3t",
+		},
+		{
+			name:    "Negative: Wrong key",
+			key:     newTestKey(t),
+			sealed:  sealed,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Tampered ciphertext",
+			key:     key,
+			sealed:  tampered,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Short key",
+			key:     key[:16],
+			sealed:  sealed,
+			wantErr: true,
+		},
+		{
+			name:    "Negative: Truncated ciphertext",
+			key:     key,
+			sealed:  sealed[:4],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := Open(tt.key, tt.sealed)
+
+			assert.Equal(tt.wantErr, err != nil, err)
+			assert.Equal(tt.want, string(got))
+		})
+	}
+}
+
+func TestWatcher_Rotation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	key := newTestKey(t)
+
+	path := filepath.Join(dir, "secrets.enc")
+	now := time.Now()
+	writeSealed(t, path, key, `{"DB_PASSWORD": "old"}`, now)
+
+	s := NewSecret("DB_PASSWORD", NewEncryptedFileProvider(path, key), "DB_PASSWORD")
+	_, err := s.Refresh(ctx)
+	require.NoError(t, err)
+
+	w := NewWatcher(time.Minute, log.NewNopLogger(), []*Secret{s})
+	rotations := 0
+	w.OnChange("DB_PASSWORD", func() { rotations++ })
+
+	w.Refresh(ctx)
+	assert.Equal("old", w.Get("DB_PASSWORD").Value())
+	assert.Equal(0, rotations)
+
+	writeSealed(t, path, key, `{"DB_PASSWORD": "new"}`, now.Add(time.Minute))
+	w.Refresh(ctx)
+	assert.Equal("new", s.Value())
+	assert.Equal(1, rotations)
+
+	// A broken file keeps the current value.
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	require.NoError(t, os.Chtimes(path, now.Add(2*time.Minute), now.Add(2*time.Minute)))
+	w.Refresh(ctx)
+	assert.Equal("new", s.Value())
+	assert.Equal(1, rotations)
+
+	assert.Nil(w.Get("OTHER"))
+}
+
+func TestFileProvider_Get(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("pass\n"), 0o600))
+
+	got, err := NewFileProvider().Get(context.Background(), path)
+	assert.NoError(err)
+	assert.Equal("pass", got)
+
+	_, err = NewFileProvider().Get(context.Background(), path+".missing")
+	assert.ErrorIs(err, ErrNotFound)
+}
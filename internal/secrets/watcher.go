@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Watcher refreshes secrets periodically and runs the handlers of the ones
+// that have changed, e.g. to make a connection pool reconnect.
+type Watcher struct {
+	interval time.Duration
+	logger   log.Logger
+	secrets  []*Secret
+	handlers map[string][]func()
+}
+
+func NewWatcher(interval time.Duration, logger log.Logger, secrets []*Secret) *Watcher {
+	return &Watcher{
+		interval: interval,
+		logger:   logger,
+		secrets:  secrets,
+		handlers: map[string][]func(){},
+	}
+}
+
+// Get returns the secret resolved for the config key, nil if the value was plain.
+func (w *Watcher) Get(key string) *Secret {
+	for _, s := range w.secrets {
+		if s.Key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+// OnChange registers a handler for the secret of the config key.
+func (w *Watcher) OnChange(key string, fn func()) {
+	w.handlers[key] = append(w.handlers[key], fn)
+}
+
+// Refresh checks every secret once.
+func (w *Watcher) Refresh(ctx context.Context) {
+	for _, s := range w.secrets {
+		changed, err := s.Refresh(ctx)
+		if err != nil {
+			level.Warn(w.logger).Log("msg", "refreshing secret, keeping the current value", "key", s.Key, "err", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		level.Info(w.logger).Log("msg", "secret rotated", "key", s.Key, "handlers", len(w.handlers[s.Key]))
+		for _, fn := range w.handlers[s.Key] {
+			fn()
+		}
+	}
+}
+
+// Run refreshes the secrets until the context is done.
+func (w *Watcher) Run(ctx context.Context) {
+	if len(w.secrets) == 0 {
+		return
+	}
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.Refresh(ctx)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package settings
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Settings are the config values that can change without a restart.
+type Settings struct {
+	LogLevel        string
+	Mode            string // debug dumps requests
+	RequestTimeout  time.Duration
+	ShutdownTimeout time.Duration
+	RateLimit       float64 // requests per second, 0 = unlimited
+	RateBurst       int
+	Features        map[string]bool
+
+	SearchMinSimilarity float64 // 0 to 1, fuzzy matches below it are left out
+}
+
+// Feature tells whether the feature flag is on.
+func (s *Settings) Feature(name string) bool {
+	return s.Features[name]
+}
+
+// Store holds the current settings. They are replaced as a whole,
+// so readers never see a mix of the old and the new values.
+type Store struct {
+	current atomic.Pointer[Settings]
+
+	mu          sync.Mutex
+	subscribers []func(*Settings)
+}
+
+func NewStore(s Settings) *Store {
+	st := &Store{}
+	st.current.Store(&s)
+	return st
+}
+
+// Load returns the current settings, they must not be modified.
+func (st *Store) Load() *Settings {
+	return st.current.Load()
+}
+
+// Update replaces the settings and notifies the subscribers.
+func (st *Store) Update(s Settings) {
+	st.current.Store(&s)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, fn := range st.subscribers {
+		fn(&s)
+	}
+}
+
+// Subscribe calls fn with the current settings and after every update.
+func (st *Store) Subscribe(fn func(*Settings)) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.subscribers = append(st.subscribers, fn)
+	fn(st.Load())
+}
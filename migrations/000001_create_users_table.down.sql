@@ -1 +1,2 @@
+-- lint:allow drop-table reverts 000001
 DROP TABLE IF EXISTS public.users;
\ No newline at end of file
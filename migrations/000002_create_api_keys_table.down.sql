@@ -0,0 +1,2 @@
+-- lint:allow drop-table reverts 000002
+DROP TABLE IF EXISTS public.api_keys;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS public.api_keys (
+    key_id bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    prefix varchar(16) NOT NULL UNIQUE,
+    key_hash bytea NOT NULL,
+    "owner" varchar NOT NULL,
+    scopes text[] NOT NULL DEFAULT '{}',
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    revoked_at timestamp with time zone
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS public.users_created_at_user_id_idx;
\ No newline at end of file
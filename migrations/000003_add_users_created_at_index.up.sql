@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_created_at_user_id_idx ON public.users (created_at, user_id);
\ No newline at end of file
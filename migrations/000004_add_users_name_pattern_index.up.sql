@@ -0,0 +1,4 @@
+-- For the name prefix filter, LIKE 'prefix%'. Of the plaintext names only,
+-- the encrypted ones match no prefix or trigram; the queries repeat the
+-- predicate.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_name_pattern_idx ON public.users ("name" varchar_pattern_ops) WHERE "name" NOT LIKE 'enc:v1:%';
\ No newline at end of file
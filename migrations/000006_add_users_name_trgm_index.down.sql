@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS public.users_name_trgm_idx;
\ No newline at end of file
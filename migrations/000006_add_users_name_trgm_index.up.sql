@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_name_trgm_idx ON public.users USING gin ("name" gin_trgm_ops) WHERE "name" NOT LIKE 'enc:v1:%';
\ No newline at end of file
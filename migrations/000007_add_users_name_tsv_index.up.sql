@@ -0,0 +1,3 @@
+-- An expression index, no column to add and no table rewrite. The search
+-- queries must use the same expression and predicate.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_name_tsv_idx ON public.users USING gin (to_tsvector('simple', "name")) WHERE "name" NOT LIKE 'enc:v1:%';
\ No newline at end of file
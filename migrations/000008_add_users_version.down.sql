@@ -0,0 +1,2 @@
+-- lint:allow drop-column reverts 000008
+ALTER TABLE public.users DROP COLUMN IF EXISTS version;
\ No newline at end of file
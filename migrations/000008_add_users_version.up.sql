@@ -0,0 +1,2 @@
+-- A constant default, no table rewrite. Bumped by every update, see the ETags.
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
\ No newline at end of file
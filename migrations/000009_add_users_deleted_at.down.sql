@@ -0,0 +1,2 @@
+-- lint:allow drop-column reverts 000009
+ALTER TABLE public.users DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
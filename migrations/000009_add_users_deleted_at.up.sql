@@ -0,0 +1,2 @@
+-- Nullable without a default, no table rewrite. Set on the soft deleted users.
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
\ No newline at end of file
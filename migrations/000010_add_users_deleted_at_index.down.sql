@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS public.users_deleted_at_idx;
\ No newline at end of file
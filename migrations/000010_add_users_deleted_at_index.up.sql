@@ -0,0 +1,2 @@
+-- Partial, only the soft deleted users: the purge looks for the expired ones.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_deleted_at_idx ON public.users (deleted_at) WHERE deleted_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- lint:allow drop-table reverts 000011
+DROP TABLE IF EXISTS public.user_purges;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- The audit of the purges, no user data but the ID.
+CREATE TABLE IF NOT EXISTS public.user_purges (
+    purge_id bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id bigint NOT NULL,
+    deleted_at timestamp with time zone NOT NULL,
+    purged_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    docs_deleted bigint NOT NULL,
+    keys_deleted bigint NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- lint:allow drop-table reverts 000012
+DROP TABLE IF EXISTS public.user_erasures;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- The tombstones of the erased users, no user data but the ID. An erasure
+-- is pending until completed_at is set.
+CREATE TABLE IF NOT EXISTS public.user_erasures (
+    user_id bigint PRIMARY KEY,
+    requested_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    completed_at timestamp with time zone,
+    docs_deleted bigint NOT NULL DEFAULT 0,
+    keys_deleted bigint NOT NULL DEFAULT 0
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- lint:allow drop-table reverts 000013
+DROP TABLE IF EXISTS public.encryption_keys;
\ No newline at end of file
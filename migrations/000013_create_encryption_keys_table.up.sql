@@ -0,0 +1,8 @@
+-- The data keys of the PII encryption, wrapped by the master key master_key_id.
+CREATE TABLE IF NOT EXISTS public.encryption_keys (
+    key_id varchar PRIMARY KEY,
+    purpose varchar NOT NULL,
+    master_key_id varchar NOT NULL,
+    wrapped_key bytea NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
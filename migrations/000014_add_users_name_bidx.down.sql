@@ -0,0 +1,2 @@
+-- lint:allow drop-column reverts 000014
+ALTER TABLE public.users DROP COLUMN IF EXISTS name_bidx;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- The blind index of the name for the equality lookups. NULL for the
+-- users stored before, pii reencrypt sets it in batches: run it before
+-- turning the encryption on, else they aren't found by name.
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS name_bidx varchar;
\ No newline at end of file
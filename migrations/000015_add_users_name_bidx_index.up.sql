@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_name_bidx_idx ON public.users (name_bidx);
\ No newline at end of file
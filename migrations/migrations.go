@@ -0,0 +1,17 @@
+// Package migrations embeds the Postgres migrations into the binaries.
+package migrations
+
+import (
+	"embed"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Source returns a migrate source reading the embedded migrations.
+func Source() (source.Driver, error) {
+	return iofs.New(FS, ".")
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ws-dummy-go/internal/migrator"
+)
+
+func TestSource(t *testing.T) {
+	src, err := Source()
+	require.NoError(t, err)
+	defer src.Close()
+
+	migs, err := migrator.List(src, migrator.NoVersion, false)
+	require.NoError(t, err)
+	require.NotEmpty(t, migs)
+	for _, m := range migs {
+		assert.True(t, m.HasDown, "migration %d %s has no down file", m.Version, m.Name)
+	}
+}
@@ -0,0 +1,222 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"ws-dummy-go/internal/migrator"
+)
+
+const (
+	usersCollection    = "users"
+	profilesCollection = "user_profiles"
+
+	// Not unique, Postgres allows users with the same name.
+	usersNameIndex      = "name"
+	usersNameBidxIndex  = "name_bidx"
+	usersCreatedAtIndex = "created_at"
+	// Unique, sparse for the users stored before the user_id.
+	usersUserIDIndex = "user_id"
+)
+
+// Mongo are the Mongo migrations, in code since they are commands.
+var Mongo = []migrator.MongoMigration{
+	{
+		Version: 1,
+		Name:    "create_users_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(usersCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "name", Value: 1}},
+					Options: options.Index().SetName(usersNameIndex),
+				},
+				{
+					Keys:    bson.D{{Key: "created_at", Value: 1}},
+					Options: options.Index().SetName(usersCreatedAtIndex),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{usersNameIndex, usersCreatedAtIndex} {
+				if _, err := db.Collection(usersCollection).Indexes().DropOne(ctx, name); err != nil {
+					return fmt.Errorf("dropping index %s: %w", name, err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add_users_validator",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return setValidator(ctx, db, usersCollection, usersValidator(2))
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return setValidator(ctx, db, usersCollection, bson.M{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_user_profiles",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return setValidator(ctx, db, profilesCollection, bson.M{
+				"$jsonSchema": bson.M{
+					"bsonType": "object",
+					"required": bson.A{"_id", "data", "version", "updated_at"},
+					"properties": bson.M{
+						"_id":        bson.M{"bsonType": "string"},
+						"data":       bson.M{"bsonType": "object"},
+						"version":    bson.M{"bsonType": "long", "minimum": 1},
+						"updated_at": bson.M{"bsonType": "date"},
+					},
+				},
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return db.Collection(profilesCollection).Drop(ctx)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_users_version",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(usersCollection).UpdateMany(ctx,
+				bson.M{"version": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"version": int64(1)}},
+			)
+			if err != nil {
+				return fmt.Errorf("setting versions: %w", err)
+			}
+			return setValidator(ctx, db, usersCollection, usersValidator(4))
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := setValidator(ctx, db, usersCollection, usersValidator(2)); err != nil {
+				return err
+			}
+			_, err := db.Collection(usersCollection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"version": ""}})
+			return err
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_users_deleted",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return setValidator(ctx, db, usersCollection, usersValidator(5))
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			// The soft deleted users would be back, they go with the flag.
+			if _, err := db.Collection(usersCollection).DeleteMany(ctx, bson.M{"deleted": true}); err != nil {
+				return fmt.Errorf("deleting soft deleted users: %w", err)
+			}
+			_, err := db.Collection(usersCollection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"deleted": "", "deleted_at": ""}})
+			if err != nil {
+				return fmt.Errorf("unsetting deleted: %w", err)
+			}
+			return setValidator(ctx, db, usersCollection, usersValidator(4))
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_users_name_bidx",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// The users stored before have none, pii reencrypt sets it.
+			_, err := db.Collection(usersCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "name_bidx", Value: 1}},
+				Options: options.Index().SetName(usersNameBidxIndex),
+			})
+			if err != nil {
+				return fmt.Errorf("creating index %s: %w", usersNameBidxIndex, err)
+			}
+			// The name index is kept for the plaintext names, the encryption may be off.
+			return setValidator(ctx, db, usersCollection, usersValidator(6))
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := setValidator(ctx, db, usersCollection, usersValidator(5)); err != nil {
+				return err
+			}
+			if _, err := db.Collection(usersCollection).Indexes().DropOne(ctx, usersNameBidxIndex); err != nil {
+				return fmt.Errorf("dropping index %s: %w", usersNameBidxIndex, err)
+			}
+			_, err := db.Collection(usersCollection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"name_bidx": ""}})
+			return err
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_users_user_id",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(usersCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "user_id", Value: 1}},
+				Options: options.Index().SetName(usersUserIDIndex).SetUnique(true).SetSparse(true),
+			})
+			if err != nil {
+				return fmt.Errorf("creating index %s: %w", usersUserIDIndex, err)
+			}
+			return setValidator(ctx, db, usersCollection, usersValidator(7))
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := setValidator(ctx, db, usersCollection, usersValidator(6)); err != nil {
+				return err
+			}
+			if _, err := db.Collection(usersCollection).Indexes().DropOne(ctx, usersUserIDIndex); err != nil {
+				return fmt.Errorf("dropping index %s: %w", usersUserIDIndex, err)
+			}
+			_, err := db.Collection(usersCollection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"user_id": ""}})
+			return err
+		},
+	},
+}
+
+// usersValidator is the schema of the users as of the migration: the
+// version since 4, the soft delete since 5, the name blind index since 6,
+// the Postgres user ID since 7. The users stored before have neither.
+func usersValidator(migration int) bson.M {
+	required := bson.A{"_id", "name", "created_at"}
+	properties := bson.M{
+		"name":       bson.M{"bsonType": "string", "minLength": 1},
+		"created_at": bson.M{"bsonType": "date"},
+	}
+	if migration >= 4 {
+		required = append(required, "version")
+		properties["version"] = bson.M{"bsonType": "long", "minimum": 1}
+	}
+	if migration >= 5 {
+		properties["deleted"] = bson.M{"bsonType": "bool"}
+		properties["deleted_at"] = bson.M{"bsonType": "date"}
+	}
+	if migration >= 6 {
+		properties["name_bidx"] = bson.M{"bsonType": "string", "minLength": 1}
+	}
+	if migration >= 7 {
+		properties["user_id"] = bson.M{"bsonType": "string", "minLength": 1}
+	}
+	return bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType":   "object",
+			"required":   required,
+			"properties": properties,
+		},
+	}
+}
+
+// setValidator creates the collection with the validator or sets it on the existing one.
+func setValidator(ctx context.Context, db *mongo.Database, collection string, validator bson.M) error {
+	names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: collection}})
+	if err != nil {
+		return fmt.Errorf("listing collections: %w", err)
+	}
+	if len(names) == 0 {
+		return db.CreateCollection(ctx, collection, options.CreateCollection().SetValidator(validator))
+	}
+	return db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "strict"},
+		{Key: "validationAction", Value: "error"},
+	}).Err()
+}